@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"star-sage/internal/ai"
+	"star-sage/internal/config"
+)
+
+var (
+	aiProvider    string
+	aiModel       string
+	aiBaseURL     string
+	aiAPIKey      string
+	aiTemperature float64
+	aiMaxTokens   int
+)
+
+// addProviderFlags registers the flags that select and override an AI provider profile on cmd.
+func addProviderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&aiProvider, "provider", "", "AI provider profile from the ai section of the config file (defaults to the profile set for this task)")
+	cmd.Flags().StringVar(&aiModel, "model", "", "Override the model of the provider profile")
+	cmd.Flags().StringVar(&aiBaseURL, "base-url", "", "Override the base URL of the provider profile")
+	cmd.Flags().StringVar(&aiAPIKey, "api-key", "", "Override the API key of the provider profile")
+	cmd.Flags().Float64Var(&aiTemperature, "temperature", 0, "Override the sampling temperature of the provider profile")
+	cmd.Flags().IntVar(&aiMaxTokens, "max-tokens", 0, "Override the maximum number of tokens to generate")
+}
+
+// resolveProviderProfile returns the provider profile for task, with the
+// overrides given on the command line of cmd applied.
+func resolveProviderProfile(cmd *cobra.Command, task string) (config.ProviderConfig, error) {
+	registry, err := ai.LoadRegistry()
+	if err != nil {
+		return config.ProviderConfig{}, err
+	}
+
+	var profile config.ProviderConfig
+	if aiProvider != "" {
+		profile, err = registry.Profile(aiProvider)
+	} else {
+		profile, err = registry.ProfileForTask(task)
+	}
+	if err != nil {
+		return profile, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("model") {
+		profile.Model = aiModel
+	}
+	if flags.Changed("base-url") {
+		profile.BaseURL = aiBaseURL
+	}
+	if flags.Changed("api-key") {
+		profile.APIKey = aiAPIKey
+	}
+	if flags.Changed("temperature") {
+		profile.Temperature = &aiTemperature
+	}
+	if flags.Changed("max-tokens") {
+		profile.MaxTokens = aiMaxTokens
+	}
+	return profile, nil
+}
+
+// newAIProvider creates the provider for task selected by the flags of cmd.
+func newAIProvider(cmd *cobra.Command, task string) (ai.Provider, error) {
+	profile, err := resolveProviderProfile(cmd, task)
+	if err != nil {
+		return nil, err
+	}
+	return ai.New(profile)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"star-sage/internal/ai"
+	"star-sage/internal/ask"
+	"star-sage/internal/db"
+)
+
+var noStream bool
+
+// askCmd represents the ask command
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Ask a question about your starred repositories.",
+	Long: `Finds the starred repositories most relevant to the question (using full-text
+search, plus embeddings when available) and lets the chat provider answer based
+on their descriptions, summaries and READMEs. The answer cites the repositories
+it used. Use --limit to change how many repositories are given to the model.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		question := strings.Join(args, " ")
+
+		provider, err := newAIProvider(cmd, ai.TaskChat)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		registry, err := ai.LoadRegistry()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		embedding, err := registry.ProfileForTask(ai.TaskEmbed)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		opts := ask.Options{
+			Limit:     limit,
+			Provider:  provider,
+			Embedding: embedding,
+			OnSources: func(sources []ask.Source) {
+				fmt.Printf("Answering based on %d repositories...\n\n", len(sources))
+			},
+		}
+		if !noStream {
+			opts.OnToken = func(token string) { fmt.Print(token) }
+		}
+
+		answer, err := ask.Ask(ctx, store, question, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if noStream {
+			fmt.Print(answer.Answer)
+		}
+		fmt.Println()
+		if answer.Warning != "" {
+			fmt.Printf("\nNote: %s\n", answer.Warning)
+		}
+
+		fmt.Println("\nSources:")
+		cited := make(map[string]bool)
+		for _, name := range answer.Citations {
+			cited[name] = true
+		}
+		for _, s := range answer.Sources {
+			marker := " "
+			if cited[s.FullName] {
+				marker = "*"
+			}
+			fmt.Printf(" %s %s  %s\n", marker, s.FullName, s.URL)
+		}
+		if len(answer.Citations) > 0 {
+			fmt.Println("(* cited in the answer)")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(askCmd)
+	askCmd.Flags().BoolVar(&noStream, "no-stream", false, "Print the answer only once it is complete")
+	addProviderFlags(askCmd)
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"star-sage/internal/db"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var migrateStatus bool
+
 // dbCmd represents the base command for database operations.
 var dbCmd = &cobra.Command{
 	Use:   "db",
@@ -18,13 +21,14 @@ var dbCmd = &cobra.Command{
 var resetCmd = &cobra.Command{
 	Use:   "reset",
 	Short: "Delete and reset the local database file.",
+	Long: `Deletes the database file so the next command starts from scratch. A snapshot
+is taken first, so a reset can be undone with 'starsage db restore'.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, err := os.UserHomeDir()
+		dbPath, err := db.Path()
 		if err != nil {
-			fmt.Printf("Error getting user home directory: %v\n", err)
+			fmt.Printf("Error locating database: %v\n", err)
 			return
 		}
-		dbPath := filepath.Join(home, ".config", "starsage", "stars.db")
 
 		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 			fmt.Println("Database file does not exist. Nothing to do.")
@@ -36,7 +40,20 @@ var resetCmd = &cobra.Command{
 		fmt.Scanln(&response)
 
 		if response == "y" || response == "Y" {
-			err := os.Remove(dbPath)
+			database, _, err := db.Open()
+			if err != nil {
+				fmt.Printf("Error opening database: %v\n", err)
+				return
+			}
+			snapshot, err := db.TakeSnapshot(database, db.SnapshotReset)
+			database.Close()
+			if err != nil {
+				fmt.Printf("Error taking snapshot, database not deleted: %v\n", err)
+				return
+			}
+			fmt.Printf("Snapshot saved to %s.\n", snapshot)
+
+			err = os.Remove(dbPath)
 			if err != nil {
 				fmt.Printf("Error deleting database file: %v\n", err)
 				return
@@ -48,7 +65,188 @@ var resetCmd = &cobra.Command{
 	},
 }
 
+// migrateCmd applies pending schema migrations or shows where the schema stands.
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations.",
+	Long: `Brings the database schema up to date. Every command does this automatically
+when it opens the database; this command lets you do it explicitly, or check
+with --status which migrations are applied and which are pending.
+
+Before migrating an existing database, a snapshot is taken (see 'starsage db
+snapshots').`,
+	Run: func(cmd *cobra.Command, args []string) {
+		database, dbPath, err := db.Open()
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			return
+		}
+		defer database.Close()
+
+		if migrateStatus {
+			if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+				fmt.Println("Database file does not exist yet.")
+				return
+			}
+			status, err := db.GetSchemaStatus(database)
+			if err != nil {
+				fmt.Printf("Error getting schema status: %v\n", err)
+				return
+			}
+			printSchemaStatus(dbPath, status)
+			return
+		}
+
+		result, err := db.Migrate(database, dbPath)
+		if err != nil {
+			fmt.Printf("Error migrating database: %v\n", err)
+			return
+		}
+		if result.From == result.To {
+			fmt.Printf("Database is up to date (schema version %d).\n", result.To)
+			return
+		}
+		fmt.Printf("Migrated database from schema version %d to %d.\n", result.From, result.To)
+		if result.Backup != "" {
+			fmt.Printf("Snapshot of the previous version: %s\n", result.Backup)
+		}
+	},
+}
+
+// backupCmd copies the database to a file.
+var backupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Save a consistent copy of the database.",
+	Long: `Saves a consistent copy of the database, by default to
+stars-backup-<time>.db in the current directory. It is safe to run while the
+web server is running.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dest := "stars-backup-" + time.Now().Format("20060102-150405") + ".db"
+		if len(args) > 0 {
+			dest = args[0]
+		}
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		if err := db.Backup(database, dest); err != nil {
+			fmt.Printf("Error backing up database: %v\n", err)
+			return
+		}
+		fmt.Printf("Database backed up to %s.\n", dest)
+	},
+}
+
+// restoreCmd replaces the database with a backup or snapshot.
+var restoreCmd = &cobra.Command{
+	Use:   "restore [file]",
+	Short: "Replace the database with a backup or snapshot.",
+	Long: `Replaces the contents of the database with a file made by 'starsage db backup'
+or one of the snapshots listed by 'starsage db snapshots'. The file is checked
+for corruption first, and the current contents are saved as a snapshot, so a
+restore can be undone as well.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := db.CheckBackup(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("%s passed the integrity check (schema version %d).\n", args[0], status.Version)
+
+		database, dbPath, err := db.Open()
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			return
+		}
+		defer database.Close()
+
+		fmt.Printf("Are you sure you want to replace the database at %s? [y/N]: ", dbPath)
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Restore cancelled.")
+			return
+		}
+
+		snapshot, err := db.Restore(database, args[0])
+		if snapshot != "" {
+			fmt.Printf("Snapshot of the previous contents saved to %s.\n", snapshot)
+		}
+		if err != nil {
+			fmt.Printf("Error restoring database: %v\n", err)
+			return
+		}
+		result, err := db.Migrate(database, dbPath)
+		if err != nil {
+			fmt.Printf("Error migrating restored database: %v\n", err)
+			return
+		}
+		if result.From != result.To {
+			fmt.Printf("Migrated restored database from schema version %d to %d.\n", result.From, result.To)
+		}
+		fmt.Println("Database restored.")
+	},
+}
+
+// snapshotsCmd lists the automatic snapshots.
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List the snapshots taken before destructive operations.",
+	Long: `Lists the snapshots taken automatically before migrations, resets, pruning
+syncs and restores, newest first. They are kept in the snapshots directory
+next to the database; snapshots.keep (default 10) and snapshots.max_age (e.g.
+720h) in the config file set how many are kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dbPath, err := db.Path()
+		if err != nil {
+			fmt.Printf("Error locating database: %v\n", err)
+			return
+		}
+		snapshots, err := db.ListSnapshots(dbPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("No snapshots found.")
+			return
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s  %-8s %8.1f MB  %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"), s.Reason, float64(s.Size)/(1<<20), s.Path)
+		}
+	},
+}
+
+// printSchemaStatus prints the schema version and every migration with the
+// time it was applied.
+func printSchemaStatus(dbPath string, status *db.SchemaStatus) {
+	fmt.Printf("Database:       %s\n", dbPath)
+	fmt.Printf("Schema version: %d (latest %d)\n", status.Version, status.Latest)
+	switch {
+	case status.Version > status.Latest:
+		fmt.Println("The database was migrated by a newer version of starsage.")
+	case status.Legacy:
+		fmt.Println("The database predates versioned migrations and will be baselined on the next migration.")
+	}
+	fmt.Println()
+	for _, m := range status.Migrations {
+		state := "pending"
+		if m.AppliedAt != "" {
+			state = "applied " + m.AppliedAt
+		}
+		fmt.Printf("  %04d  %-20s %s\n", m.Version, m.Name, state)
+	}
+	if n := status.Pending(); n > 0 {
+		fmt.Printf("\n%d pending migration(s). Run 'starsage db migrate' to apply them.\n", n)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(dbCmd)
-	dbCmd.AddCommand(resetCmd)
+	dbCmd.AddCommand(resetCmd, migrateCmd, backupCmd, restoreCmd, snapshotsCmd)
+	migrateCmd.Flags().BoolVar(&migrateStatus, "status", false, "Show applied and pending migrations without changing anything")
 }
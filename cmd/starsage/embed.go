@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"star-sage/internal/ai"
+	"star-sage/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+// embedCmd represents the embed command
+var embedCmd = &cobra.Command{
+	Use:   "embed",
+	Short: "Compute embeddings of starred repositories for semantic search.",
+	Long: `Builds a text from each repository's name, description, topics, summary and
+README, sends it to the embedding provider and stores the resulting vector in the
+local database. Only repositories without an embedding from the current model
+are processed, so it can be re-run after every sync or summarize.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		profile, err := resolveProviderProfile(cmd, ai.TaskEmbed)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		embedder, err := ai.NewEmbedder(profile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		repos, err := store.GetReposForEmbedding(profile.Model, limit)
+		if err != nil {
+			fmt.Printf("Error getting repositories to embed: %v\n", err)
+			return
+		}
+		if len(repos) == 0 {
+			fmt.Printf("All repositories already have embeddings from %s.\n", profile.Model)
+			return
+		}
+
+		fmt.Printf("Embedding %d repositories with %s...\n", len(repos), profile.Model)
+		embedded := 0
+		for i, repo := range repos {
+			vec, err := embedder.Embed(context.Background(), ai.EmbeddingText(repo))
+			if err != nil {
+				fmt.Printf("[%d/%d] Error embedding %s: %v\n", i+1, len(repos), repo.FullName, err)
+				continue
+			}
+			if err := store.UpdateRepoEmbedding(repo.ID, profile.Model, vec); err != nil {
+				fmt.Printf("[%d/%d] Error saving embedding for %s: %v\n", i+1, len(repos), repo.FullName, err)
+				continue
+			}
+			embedded++
+			fmt.Printf("[%d/%d] Embedded %s\n", i+1, len(repos), repo.FullName)
+		}
+		fmt.Printf("Successfully embedded %d of %d repositories.\n", embedded, len(repos))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(embedCmd)
+	addProviderFlags(embedCmd)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"star-sage/internal/db"
+	"star-sage/internal/export"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat   string
+	exportOutput   string
+	exportList     string
+	exportTag      string
+	exportLanguage string
+	exportGroupBy  string
+)
+
+// exportCmd writes the starred repositories in a format other tools can read.
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export your stars to JSON, CSV, Markdown, HTML bookmarks or OPML.",
+	Long: `Exports the starred repositories with their AI summaries, tags and lists.
+
+  json            all fields, including tags and lists
+  csv             one row per repository, for spreadsheets
+  markdown        an awesome-list style document with a section per list
+  html-bookmarks  a bookmark file browsers can import, with a folder per list
+  opml            an outline with a folder per list; GitHub repositories are
+                  feeds of their releases, for feed readers
+
+Markdown, HTML bookmarks and OPML group by list, or by topic with
+--group-by topic; repositories in no group are collected under Other.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, ok := export.ContentType(exportFormat); !ok {
+			fmt.Printf("Error: unknown format %q, must be one of %s\n", exportFormat, strings.Join(export.Formats, ", "))
+			return
+		}
+		withStore(func(store db.Store) {
+			opts := export.Options{
+				Format:  exportFormat,
+				Filter:  db.RepoFilter{Language: exportLanguage},
+				GroupBy: exportGroupBy,
+			}
+			if exportList != "" {
+				list, err := findList(store, exportList)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					return
+				}
+				opts.Filter.ListID = list.ID
+			}
+			if exportTag != "" {
+				tag, err := findTag(store, exportTag)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					return
+				}
+				opts.Filter.TagID = tag.ID
+			}
+
+			if exportOutput == "" {
+				if err := export.Export(os.Stdout, store, opts); err != nil {
+					fmt.Printf("Error exporting: %v\n", err)
+				}
+				return
+			}
+			err := writeFileAtomic(exportOutput, func(w io.Writer) error {
+				return export.Export(w, store, opts)
+			})
+			if err != nil {
+				fmt.Printf("Error exporting: %v\n", err)
+				return
+			}
+			fmt.Printf("Exported to %s.\n", exportOutput)
+		})
+	},
+}
+
+// writeFileAtomic writes the file at path with write. The content goes to a
+// temporary file in the same directory first, which replaces path only once
+// it is complete, so a failed export leaves an existing file intact.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", export.FormatJSON, "Output format: "+strings.Join(export.Formats, ", "))
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File to write to (default standard output)")
+	exportCmd.Flags().StringVar(&exportList, "list", "", "Only export the repositories of this list (name or ID)")
+	exportCmd.Flags().StringVar(&exportTag, "tag", "", "Only export the repositories carrying this tag")
+	exportCmd.Flags().StringVar(&exportLanguage, "language", "", "Only export repositories in this language")
+	exportCmd.Flags().StringVar(&exportGroupBy, "group-by", export.GroupByList, "Group Markdown, HTML and OPML output by list or topic")
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"star-sage/internal/ai"
+	"star-sage/internal/db"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var listNoClassify bool
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Manage AI lists of your starred repositories.",
+	Long: `An AI list holds the repositories matching a natural language prompt, as
+classified by the AI. Repositories added or removed by hand are pinned to or
+excluded from the list, and later classifications keep them that way.
+Lists are given by name or ID; without a subcommand, all lists are shown.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listLsCmd.Run(cmd, args)
+	},
+}
+
+// listLsCmd lists all lists.
+var listLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List all AI lists.",
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			lists, err := store.GetLists()
+			if err != nil {
+				fmt.Printf("Error getting lists: %v\n", err)
+				return
+			}
+			if len(lists) == 0 {
+				fmt.Println("No lists yet. Create one with 'starsage list create'.")
+				return
+			}
+			for _, l := range lists {
+				fmt.Printf("%4d  %-28s %4d  %s\n", l.ID, l.Name, l.RepoCount, l.Prompt)
+			}
+		})
+	},
+}
+
+// listShowCmd lists the repositories of a list.
+var listShowCmd = &cobra.Command{
+	Use:   "show [list]",
+	Short: "List the repositories of a list; pinned ones are marked with *.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			list, err := findList(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			repos, err := store.GetReposByListID(list.ID)
+			if err != nil {
+				fmt.Printf("Error getting repositories: %v\n", err)
+				return
+			}
+			fmt.Printf("%s: %s\n\n", list.Name, list.Prompt)
+			for _, r := range repos {
+				marker := " "
+				if r.Membership == db.ListMembershipPinned {
+					marker = "*"
+				}
+				fmt.Printf("%s %-40s %s\n", marker, r.FullName, r.Description)
+			}
+		})
+	},
+}
+
+// listCreateCmd creates a list and classifies the repositories for it.
+var listCreateCmd = &cobra.Command{
+	Use:   "create [name] [prompt]",
+	Short: "Create a list and let the AI fill it.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			id, err := store.CreateList(args[0], args[1])
+			if err != nil {
+				fmt.Printf("Error creating list: %v\n", err)
+				return
+			}
+			fmt.Printf("Created list %s (ID %d).\n", args[0], id)
+			if !listNoClassify {
+				classifyList(cmd, store, id, args[1])
+			}
+		})
+	},
+}
+
+// listRenameCmd renames a list.
+var listRenameCmd = &cobra.Command{
+	Use:   "rename [list] [new-name]",
+	Short: "Rename a list.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			list, err := findList(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.UpdateList(list.ID, &args[1], nil); err != nil {
+				fmt.Printf("Error renaming list: %v\n", err)
+				return
+			}
+			fmt.Printf("Renamed list %s to %s.\n", list.Name, args[1])
+		})
+	},
+}
+
+// listPromptCmd changes the prompt of a list and classifies it again.
+var listPromptCmd = &cobra.Command{
+	Use:   "prompt [list] [prompt]",
+	Short: "Change the prompt of a list and classify it again.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			list, err := findList(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.UpdateList(list.ID, nil, &args[1]); err != nil {
+				fmt.Printf("Error updating list: %v\n", err)
+				return
+			}
+			fmt.Printf("Updated prompt of list %s.\n", list.Name)
+			if !listNoClassify {
+				classifyList(cmd, store, list.ID, args[1])
+			}
+		})
+	},
+}
+
+// listClassifyCmd classifies the repositories for a list again.
+var listClassifyCmd = &cobra.Command{
+	Use:   "classify [list]",
+	Short: "Classify the repositories for a list again, keeping pinned and excluded ones.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			list, err := findList(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			classifyList(cmd, store, list.ID, list.Prompt)
+		})
+	},
+}
+
+// listDeleteCmd deletes a list.
+var listDeleteCmd = &cobra.Command{
+	Use:   "delete [list]",
+	Short: "Delete a list.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			list, err := findList(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.DeleteList(list.ID); err != nil {
+				fmt.Printf("Error deleting list: %v\n", err)
+				return
+			}
+			fmt.Printf("Deleted list %s.\n", list.Name)
+		})
+	},
+}
+
+// listAddCmd pins repositories to a list.
+var listAddCmd = &cobra.Command{
+	Use:   "add [list] [repo]...",
+	Short: "Add repositories to a list by hand.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeListRepos(args, db.Store.PinRepoToList, "Added %s to %s.\n")
+	},
+}
+
+// listRmCmd excludes repositories from a list.
+var listRmCmd = &cobra.Command{
+	Use:   "rm [list] [repo]...",
+	Short: "Remove repositories from a list by hand.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeListRepos(args, db.Store.ExcludeRepoFromList, "Removed %s from %s.\n")
+	},
+}
+
+// changeListRepos applies change to the list args[0] and each repository in args[1:].
+func changeListRepos(args []string, change func(db.Store, int64, int64) error, done string) {
+	withStore(func(store db.Store) {
+		list, err := findList(store, args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, name := range args[1:] {
+			repo, err := findRepository(store, name)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := change(store, list.ID, repo.ID); err != nil {
+				fmt.Printf("Error updating list: %v\n", err)
+				return
+			}
+			fmt.Printf(done, repo.FullName, list.Name)
+		}
+	})
+}
+
+// classifyList runs the AI classification for a list, leaving out the
+// repositories pinned to or excluded from it.
+func classifyList(cmd *cobra.Command, store db.Store, listID int64, prompt string) {
+	provider, err := newAIProvider(cmd, ai.TaskClassify)
+	if err != nil {
+		fmt.Printf("Error creating AI provider: %v\n", err)
+		return
+	}
+	list := &db.List{ID: listID, Prompt: prompt}
+	ids, err := ai.ClassifyList(context.Background(), store, provider, list, ai.ClassifyOptions{
+		OnStart: func(repos int) {
+			fmt.Printf("Classifying %d repositories...\n", repos)
+		},
+		OnChunk: func(chunk, chunks int) {
+			fmt.Printf("Processing chunk %d/%d...\n", chunk, chunks)
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error classifying repositories: %v\n", err)
+		return
+	}
+	fmt.Printf("Found %d matching repositories.\n", len(ids))
+}
+
+// findList looks up a list by name, or by ID if no list has that name.
+func findList(store db.Store, nameOrID string) (*db.List, error) {
+	list, err := store.GetListByName(nameOrID)
+	if err == nil && list == nil {
+		if id, perr := strconv.ParseInt(nameOrID, 10, 64); perr == nil {
+			list, err = store.GetListByID(id)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, fmt.Errorf("list %s not found", nameOrID)
+	}
+	return list, nil
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.AddCommand(listLsCmd, listShowCmd, listCreateCmd, listRenameCmd, listPromptCmd, listClassifyCmd, listDeleteCmd, listAddCmd, listRmCmd)
+	for _, cmd := range []*cobra.Command{listCreateCmd, listPromptCmd} {
+		cmd.Flags().BoolVar(&listNoClassify, "no-classify", false, "Do not run the AI classification")
+	}
+	for _, cmd := range []*cobra.Command{listCreateCmd, listPromptCmd, listClassifyCmd} {
+		addProviderFlags(cmd)
+	}
+}
@@ -6,23 +6,37 @@ import (
 
 	"github.com/spf13/cobra"
 	"star-sage/internal/config"
-	"star-sage/internal/gh"
+	"star-sage/internal/source"
 )
 
+var loginSource string
+
 // loginCmd represents the login command
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with GitHub.",
-	Long:  `Authenticate with GitHub using OAuth 2.0 Device Flow to get an access token.`,
+	Long: `Authenticate with GitHub using OAuth 2.0 Device Flow to get an access token.
+For Gitea/Forgejo and GitLab sources, you are asked for a personal access token instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Starting GitHub authentication...")
-		token, err := gh.PerformDeviceFlow(context.Background(), proxyURL)
+		srcCfg, err := config.GetSource(loginSource)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		src, err := source.New(srcCfg, source.Options{Proxy: networkProxy()})
+		if err != nil {
+			fmt.Printf("Error creating source %s: %v\n", srcCfg.Name, err)
+			return
+		}
+
+		fmt.Printf("Starting authentication with %s...\n", src.Key())
+		token, err := src.Authenticate(context.Background())
 		if err != nil {
 			fmt.Printf("Error during authentication: %v\n", err)
 			return
 		}
 
-		if err := config.SaveToken(token); err != nil {
+		if err := config.SaveSourceToken(srcCfg.Name, token); err != nil {
 			fmt.Printf("Error saving token: %v\n", err)
 			return
 		}
@@ -33,4 +47,5 @@ var loginCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().StringVar(&loginSource, "source", config.DefaultSourceName, "Name of the configured source to log in to")
 }
@@ -9,8 +9,10 @@ import (
 )
 
 var (
-	proxyURL string
-	limit    int
+	proxyURL    string
+	limit       int
+	profileName string
+	dbFile      string
 )
 
 var rootCmd = &cobra.Command{
@@ -19,6 +21,10 @@ var rootCmd = &cobra.Command{
 	Long: `A Fast and Flexible CLI for managing, searching, and summarizing your GitHub Stars.
 Complete documentation is available at https://github.com/publieople/StarSage`, // Placeholder URL
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.SetProfile(profileName); err != nil {
+			return err
+		}
+		config.SetDBPath(dbFile)
 		return config.InitConfig()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -34,8 +40,19 @@ func Execute() {
 	}
 }
 
+// networkProxy returns the proxy given with --proxy, or else the one stored
+// in the config file.
+func networkProxy() string {
+	if proxyURL != "" {
+		return proxyURL
+	}
+	return config.GetProxy()
+}
+
 func init() {
-	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "HTTP proxy to use for network requests (e.g. http://127.0.0.1:7890)")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "HTTP proxy to use for network requests (e.g. http://127.0.0.1:7890); defaults to proxy in the config file")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Use a named profile, with its own config file and database")
+	rootCmd.PersistentFlags().StringVar(&dbFile, "db", "", "Path of the database file, overriding the profile's database")
 	rootCmd.PersistentFlags().IntVar(&limit, "limit", 0, "Limit the number of items to process (0 for no limit)")
 }
 
@@ -1,58 +1,123 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"star-sage/internal/ai"
 	"star-sage/internal/db"
+	"star-sage/internal/search"
 )
 
+var (
+	semanticSearch bool
+	keywordSearch  bool
+	keywordWeight  float64
+	semanticWeight float64
+)
+
+// snippetHighlighter turns the <b></b> markers of search snippets into plain-text emphasis.
+var snippetHighlighter = strings.NewReplacer("<b>", "**", "</b>", "**")
+
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Search your starred repositories.",
-	Long: `Performs a full-text search on the name, description, and README
-of your starred repositories stored in the local database.`,
+	Long: `Searches the name, description, and README of your starred repositories
+stored in the local database.
+When embeddings exist (see 'starsage embed'), full-text and semantic matches are
+merged with reciprocal rank fusion, so both exact names and fuzzy descriptions of
+what you are looking for work. Use --keyword or --semantic to use only one of them.`,
 	Args: cobra.MinimumNArgs(1), // Require at least one argument for the query
 	Run: func(cmd *cobra.Command, args []string) {
 		query := strings.Join(args, " ")
 		fmt.Printf("Searching for: \"%s\"\n\n", query)
 
+		opts := search.Options{
+			Mode:           search.ModeHybrid,
+			Limit:          limit,
+			KeywordWeight:  &keywordWeight,
+			SemanticWeight: &semanticWeight,
+		}
+		switch {
+		case semanticSearch && keywordSearch:
+			fmt.Println("Error: --semantic and --keyword cannot be combined.")
+			return
+		case semanticSearch:
+			opts.Mode = search.ModeSemantic
+		case keywordSearch:
+			opts.Mode = search.ModeKeyword
+		}
+		if opts.Mode != search.ModeKeyword && semanticWeight > 0 {
+			profile, err := resolveProviderProfile(cmd, ai.TaskEmbed)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			opts.Embedding = profile
+		}
+
 		database, err := db.InitDB()
 		if err != nil {
 			fmt.Printf("Error initializing database: %v\n", err)
 			return
 		}
 		defer database.Close()
+		store := db.NewSQLiteStore(database)
 
-		results, err := db.SearchRepositories(database, query, limit)
+		resp, err := search.Search(context.Background(), store, query, opts)
 		if err != nil {
 			fmt.Printf("Error performing search: %v\n", err)
 			return
 		}
+		if resp.Warning != "" {
+			fmt.Printf("Note: %s\n\n", resp.Warning)
+		}
 
-		if len(results) == 0 {
+		if len(resp.Results) == 0 {
 			fmt.Println("No results found.")
 			return
 		}
 
-		fmt.Printf("Found %d results:\n", len(results))
-		for _, repo := range results {
+		fmt.Printf("Found %d results (%s search):\n", len(resp.Results), resp.Mode)
+		for _, result := range resp.Results {
 			fmt.Printf("----------------------------------------\n")
-			fmt.Printf("Repo: %s\n", repo.FullName)
-			fmt.Printf("URL: %s\n", repo.URL)
-			if repo.Description != "" {
-				fmt.Printf("Description: %s\n", repo.Description)
+			fmt.Printf("Repo: %s\n", result.FullName)
+			fmt.Printf("URL: %s\n", result.URL)
+			if result.Description != "" {
+				fmt.Printf("Description: %s\n", result.Description)
 			}
-			if repo.Summary != "" {
-				fmt.Printf("AI Summary: %s\n", repo.Summary)
+			if result.Summary != "" {
+				fmt.Printf("AI Summary: %s\n", result.Summary)
 			}
+			if result.Snippet != "" {
+				fmt.Printf("Match: %s\n", snippetHighlighter.Replace(result.Snippet))
+			}
+			fmt.Printf("Matched by: %s\n", describeMatch(result))
 		}
 		fmt.Printf("----------------------------------------\n")
 	},
 }
 
+// describeMatch explains which signals found a search result.
+func describeMatch(r search.Result) string {
+	var parts []string
+	if r.KeywordRank > 0 {
+		parts = append(parts, fmt.Sprintf("keyword (#%d)", r.KeywordRank))
+	}
+	if r.SemanticRank > 0 {
+		parts = append(parts, fmt.Sprintf("semantic (#%d, similarity %.2f)", r.SemanticRank, r.Similarity))
+	}
+	return strings.Join(parts, ", ")
+}
+
 func init() {
 	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolVar(&semanticSearch, "semantic", false, "Rank only by embedding similarity")
+	searchCmd.Flags().BoolVar(&keywordSearch, "keyword", false, "Use only full-text keyword matches")
+	searchCmd.Flags().Float64Var(&keywordWeight, "keyword-weight", 1, "Weight of keyword ranks in hybrid search; 0 leaves keyword search out")
+	searchCmd.Flags().Float64Var(&semanticWeight, "semantic-weight", 1, "Weight of semantic ranks in hybrid search; 0 leaves semantic search out")
+	addProviderFlags(searchCmd)
 }
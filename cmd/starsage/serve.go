@@ -1,22 +1,99 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"star-sage/internal/ai"
+	"star-sage/internal/config"
+	"star-sage/internal/jobs"
 	"star-sage/internal/server"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-var port int
+var (
+	port         int
+	bind         string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	workers      int
+	syncInterval time.Duration
+	syncSource   string
+	frontendDir  string
+	requireAuth  bool
+)
 
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start a web server to browse and manage your stars.",
-	Long:  `Starts a local web server that provides a UI for viewing, searching, and managing your starred repositories.`,
+	Long: `Starts a local web server that provides a UI for viewing, searching, and managing your starred repositories.
+The server only accepts local connections unless --bind says otherwise. With
+--auth, API requests need a token created with 'starsage serve token create'.
+With --sync-interval (or server.sync_interval in the config file) the server
+also syncs your stars in the background.
+Ctrl-C stops the server gracefully: requests in flight are finished and running
+jobs are checkpointed to resume on the next start.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := ai.LoadRegistry()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		// Flags given on the command line take precedence over the config file.
+		serverCfg, err := config.GetServerConfig()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		flags := cmd.Flags()
+		if !flags.Changed("bind") && serverCfg.Bind != "" {
+			bind = serverCfg.Bind
+		}
+		if !flags.Changed("read-timeout") && serverCfg.ReadTimeout != 0 {
+			readTimeout = serverCfg.ReadTimeout
+		}
+		if !flags.Changed("write-timeout") && serverCfg.WriteTimeout != 0 {
+			writeTimeout = serverCfg.WriteTimeout
+		}
+		if !flags.Changed("idle-timeout") && serverCfg.IdleTimeout != 0 {
+			idleTimeout = serverCfg.IdleTimeout
+		}
+		if !flags.Changed("auth") {
+			requireAuth = serverCfg.Auth
+		}
+		if !flags.Changed("sync-interval") {
+			syncInterval = serverCfg.SyncInterval
+		}
+		if !flags.Changed("sync-source") && serverCfg.SyncSource != "" {
+			syncSource = serverCfg.SyncSource
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
 		fmt.Printf("Starting server on port %d...\n", port)
-		if err := server.StartServer(port); err != nil {
+		err = server.StartServer(ctx, server.Options{
+			Bind:         bind,
+			Port:         port,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			IdleTimeout:  idleTimeout,
+			AI:           registry,
+			Workers:      workers,
+			Proxy:        networkProxy(),
+			SyncInterval: syncInterval,
+			SyncSource:   syncSource,
+			FrontendDir:  frontendDir,
+			Auth:         requireAuth,
+		})
+		if err != nil {
 			fmt.Printf("Error starting server: %v\n", err)
 		}
 	},
@@ -25,4 +102,13 @@ var serveCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to run the server on")
+	serveCmd.Flags().StringVar(&bind, "bind", server.DefaultBind, "Address to listen on (0.0.0.0 to accept connections from other machines)")
+	serveCmd.Flags().BoolVar(&requireAuth, "auth", false, "Require an API token or a login for the API")
+	serveCmd.Flags().DurationVar(&readTimeout, "read-timeout", server.DefaultReadTimeout, "Maximum time to read a request")
+	serveCmd.Flags().DurationVar(&writeTimeout, "write-timeout", server.DefaultWriteTimeout, "Maximum time to write a response (streaming responses are exempt)")
+	serveCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", server.DefaultIdleTimeout, "Maximum time to keep an idle connection open")
+	serveCmd.Flags().IntVar(&workers, "workers", jobs.DefaultWorkers, "Number of background jobs to run in parallel")
+	serveCmd.Flags().DurationVar(&syncInterval, "sync-interval", 0, "Sync stars in the background this often, e.g. 6h (0 to disable)")
+	serveCmd.Flags().StringVar(&syncSource, "sync-source", config.DefaultSourceName, "Name of the configured source to sync in the background")
+	serveCmd.Flags().StringVar(&frontendDir, "frontend-dir", "", "Serve the web UI from this directory with live reload instead of the built-in copy (for UI development)")
 }
@@ -3,17 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"star-sage/internal/ai"
 	"star-sage/internal/db"
+	"star-sage/internal/summarizer"
 
 	"github.com/spf13/cobra"
 )
 
-var (
-	aiProvider string
-	aiModel    string
-)
+var streamSummaries bool
 
 // summarizeCmd represents the summarize command
 var summarizeCmd = &cobra.Command{
@@ -31,67 +28,63 @@ and saves the summary back to the database.`,
 			return
 		}
 		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		provider, err := newAIProvider(cmd, ai.TaskSummarize)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
 
 		summarizeLimit := limit
 		if summarizeLimit == 0 {
-			summarizeLimit = 5 // Default to 5 if no limit is set
+			summarizeLimit = summarizer.DefaultLimit
 		}
 		fmt.Printf("Attempting to summarize up to %d repositories...\n", summarizeLimit)
 
-		repos, err := db.GetReposForSummarization(database, summarizeLimit)
+		opts := summarizer.Options{
+			Provider: provider,
+			Limit:    summarizeLimit,
+			OnStart: func(repo db.Repository, done, total int) {
+				fmt.Printf("Summarizing %s...\n", repo.FullName)
+			},
+			OnProgress: printSummaryProgress,
+		}
+		if streamSummaries {
+			opts.OnToken = func(token string) { fmt.Print(token) }
+		}
+		result, err := summarizer.Run(context.Background(), store, opts)
 		if err != nil {
-			fmt.Printf("Error getting repositories to summarize: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			return
 		}
-
-		if len(repos) == 0 {
+		if result.Total == 0 {
 			fmt.Println("No new repositories to summarize.")
 			return
 		}
+		fmt.Printf("Summarized %d of %d repositories (%d skipped, %d failed).\n",
+			result.Summarized, result.Total, result.Skipped, result.Failed)
+	},
+}
 
-		fmt.Printf("Found %d repositories to summarize.\n", len(repos))
-
-		// The AI provider might need its own http client (without auth or proxy)
-		aiClient := &http.Client{}
-		var provider ai.Provider
-		switch aiProvider {
-		case "ollama":
-			provider = ai.NewOllamaProvider(aiModel, "", aiClient) // Use default Ollama URL
-		default:
-			fmt.Printf("Unsupported AI provider: %s\n", aiProvider)
-			return
-		}
-
-		for _, repo := range repos {
-			fmt.Printf("Summarizing %s...\n", repo.FullName)
-			// We need the full README content, but our GetReposForSummarization only gets a subset of fields.
-			// This is a flaw in the current logic. For the MVP, we'll assume ReadmeContent is populated.
-			// A proper implementation would fetch the full repo details here if needed.
-			if repo.ReadmeContent == "" {
-				fmt.Printf("Skipping %s, no README content found in DB.\n", repo.FullName)
-				continue
-			}
-
-			// Build the prompt manually now that the provider is generic
-			prompt := fmt.Sprintf("Please provide a concise summary of the following project's README, focusing on its purpose and key features. Output only the summary text:\n\n---\n\n%s", repo.ReadmeContent)
-
-			summary, err := provider.Generate(context.Background(), prompt)
-			if err != nil {
-				fmt.Printf("Error summarizing %s: %v\n", repo.FullName, err)
-				continue // Move to the next repo
-			}
-
-			if err := db.UpdateRepoSummary(database, repo.ID, summary); err != nil {
-				fmt.Printf("Error saving summary for %s: %v\n", repo.FullName, err)
-				continue
-			}
-			fmt.Printf("Successfully summarized and saved for %s.\n\n", repo.FullName)
+// printSummaryProgress prints the outcome for one repository.
+func printSummaryProgress(p summarizer.Progress) {
+	switch {
+	case p.Skipped:
+		fmt.Printf("Skipping %s, no README content found in DB.\n", p.Repo.FullName)
+	case p.Err != nil:
+		fmt.Printf("Error summarizing %s: %v\n", p.Repo.FullName, p.Err)
+	default:
+		if streamSummaries {
+			// End the streamed summary.
+			fmt.Println()
 		}
-	},
+		fmt.Printf("Successfully summarized and saved for %s.\n\n", p.Repo.FullName)
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(summarizeCmd)
-	summarizeCmd.Flags().StringVar(&aiProvider, "provider", "ollama", "The AI provider to use (e.g., ollama, openai)")
-	summarizeCmd.Flags().StringVar(&aiModel, "model", "llama3:8b", "The specific model to use for summarization")
+	addProviderFlags(summarizeCmd)
+	summarizeCmd.Flags().BoolVar(&streamSummaries, "stream", false, "Print summaries as they are generated")
 }
@@ -3,96 +3,161 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"star-sage/internal/config"
 	"star-sage/internal/db"
-	"star-sage/internal/gh"
+	"star-sage/internal/source"
+	"star-sage/internal/syncer"
+)
+
+var (
+	useREST     bool
+	prune       bool
+	resume      bool
+	concurrency int
+	sourceName  string
 )
 
 // syncCmd represents the sync command
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync GitHub Stars to local database.",
-	Long:  `Fetches all starred repositories from GitHub and saves them to a local SQLite database.
-It supports incremental syncs to fetch only the new stars.`,
+	Long: `Fetches all starred repositories from GitHub and saves them to a local SQLite database.
+Other sources (GitHub Enterprise, Gitea/Forgejo, GitLab) configured under
+'sources' in the config file can be selected with --source.
+It supports incremental syncs to fetch only the new stars.
+Progress is checkpointed after every page; an interrupted sync can be continued
+with --resume, and 'starsage sync history' lists previous runs.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		token := config.GetToken()
-		if token == "" {
-			fmt.Println("Authentication token not found. Please run 'starsage login' first.")
+		src, err := syncer.OpenSource(sourceName, source.Options{Proxy: networkProxy(), UseREST: useREST})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
 			return
 		}
 
-		fmt.Println("Syncing GitHub Stars...")
-		repos, err := gh.GetStarredRepos(context.Background(), token, proxyURL, limit)
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		// Ctrl-C stops the sync after checkpointing, so it can be continued with --resume.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("Syncing stars from %s...\n", src.Key())
+		result, err := syncer.Run(ctx, store, syncer.Options{
+			Source:      src,
+			Limit:       limit,
+			Concurrency: concurrency,
+			Prune:       prune,
+			Resume:      resume,
+			OnProgress:  printSyncProgress,
+		})
 		if err != nil {
 			fmt.Printf("Error syncing stars: %v\n", err)
+			if result != nil {
+				fmt.Println("Progress was saved. Run 'starsage sync --resume' to continue.")
+			}
 			return
 		}
+		if result.Resumed {
+			fmt.Printf("Resumed sync run #%d started at %s.\n", result.Run.ID, result.Run.StartedAt)
+		}
 
-		fmt.Printf("Found %d starred repositories. Fetching READMEs and saving to database...\n", len(repos))
+		if result.Reconciled {
+			if result.Unstarred > 0 {
+				fmt.Printf("Marked %d repositories as unstarred.\n", result.Unstarred)
+			}
+			if prune {
+				fmt.Printf("Pruned %d unstarred repositories.\n", result.Pruned)
+				if result.Snapshot != "" {
+					fmt.Printf("Snapshot taken before pruning: %s\n", result.Snapshot)
+				}
+			}
+		} else if prune {
+			fmt.Println("Skipping --prune: unstarred detection needs a full sync without --limit.")
+		}
+
+		run := result.Run
+		fmt.Printf("Successfully synced %d repositories to the local database: %d added, %d updated, %d unchanged, %d failed, %d removed.\n",
+			result.Processed, run.Added, run.Updated, run.Unchanged, run.Failed, run.Removed)
+	},
+}
 
+// syncHistoryCmd lists previous sync runs.
+var syncHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the history of sync runs.",
+	Run: func(cmd *cobra.Command, args []string) {
 		database, err := db.InitDB()
 		if err != nil {
 			fmt.Printf("Error initializing database: %v\n", err)
 			return
 		}
 		defer database.Close()
+		store := db.NewSQLiteStore(database)
 
-		// We need a client to fetch READMEs
-		client, err := gh.NewClient(proxyURL, token)
-		if err != nil {
-			fmt.Printf("Error creating GitHub client: %v\n", err)
-			return
+		historyLimit := limit
+		if historyLimit == 0 {
+			historyLimit = 10
 		}
-
-		// Pre-fetch existing etags to avoid querying the DB in a loop
-		existingRepos, err := db.GetAllReposWithETags(database)
+		runs, err := store.GetSyncRuns(historyLimit)
 		if err != nil {
-			fmt.Printf("Warning: could not pre-fetch existing repo data: %v\n", err)
+			fmt.Printf("Error getting sync runs: %v\n", err)
+			return
 		}
-		etagMap := make(map[int64]string)
-		readmeMap := make(map[int64]string)
-		for _, r := range existingRepos {
-			etagMap[r.ID] = r.ETag
-			readmeMap[r.ID] = r.ReadmeContent
+		if len(runs) == 0 {
+			fmt.Println("No sync runs recorded yet.")
+			return
 		}
 
-		for i, repo := range repos {
-			fmt.Printf("[%d/%d] Syncing %s...\n", i+1, len(repos), repo.FullName)
-
-			currentEtag := etagMap[repo.ID]
-			readmeContent, newEtag, err := gh.GetReadme(context.Background(), client, repo.FullName, currentEtag)
-			if err != nil {
-				fmt.Printf("Could not get README for %s: %v. Skipping.\n", repo.FullName, err)
-			}
-
-			dbRepo := db.Repository{
-				ID:              repo.ID,
-				FullName:        repo.FullName,
-				Description:     repo.Description,
-				URL:             repo.HTMLURL,
-				Language:        repo.Language,
-				StargazersCount: repo.StargazersCount,
-				ETag:            newEtag,
-			}
-
-			// If README was not modified, use the old content from the map.
-			if newEtag == currentEtag && currentEtag != "" {
-				dbRepo.ReadmeContent = readmeMap[repo.ID]
-			} else {
-				dbRepo.ReadmeContent = readmeContent
-			}
-
-			if err := db.UpsertRepository(database, dbRepo); err != nil {
-				fmt.Printf("Error saving repository %s to database: %v\n", repo.FullName, err)
+		for _, run := range runs {
+			fmt.Printf("#%d  %s  %s  %-11s  %-7s  done=%d added=%d updated=%d unchanged=%d failed=%d removed=%d\n",
+				run.ID, run.StartedAt, run.Source, run.Status, run.API, run.ReposDone, run.Added, run.Updated, run.Unchanged, run.Failed, run.Removed)
+			if run.Error != "" {
+				fmt.Printf("      error: %s\n", run.Error)
 			}
 		}
-
-		fmt.Println("Successfully synced and saved repositories to the local database.")
 	},
 }
 
+// printSyncProgress prints sync progress updates to stdout.
+func printSyncProgress(p syncer.Progress) {
+	switch p.Phase {
+	case syncer.PhaseRepository:
+		total := "?"
+		if p.Total > 0 {
+			total = fmt.Sprint(p.Total)
+		}
+		if p.Err != nil {
+			fmt.Printf("[%d/%s] %s: %v\n", p.Processed, total, p.Repo, p.Err)
+		} else {
+			fmt.Printf("[%d/%s] Synced %s\n", p.Processed, total, p.Repo)
+		}
+	case syncer.PhaseWaiting:
+		fmt.Printf("GitHub rate limit reached, waiting %s...\n", p.Wait.Round(time.Second))
+	case syncer.PhaseFallback:
+		fmt.Printf("%v. Falling back to the %s API...\n", p.Err, strings.ToUpper(p.API))
+	case syncer.PhaseReconciling:
+		fmt.Println("Checking for unstarred repositories...")
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncHistoryCmd)
+	syncCmd.Flags().StringVar(&sourceName, "source", config.DefaultSourceName, "Name of the configured source to sync")
+	syncCmd.Flags().IntVar(&concurrency, "concurrency", syncer.DefaultConcurrency, "Number of README requests to run in parallel")
+	syncCmd.Flags().BoolVar(&prune, "prune", false, "Delete repositories that are no longer starred instead of only marking them")
+	syncCmd.Flags().BoolVar(&resume, "resume", false, "Continue the last interrupted sync from its checkpoint")
+	syncCmd.Flags().BoolVar(&useREST, "rest", false, "Use the REST API instead of GraphQL (one README request per repository)")
 }
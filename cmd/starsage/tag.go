@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"star-sage/internal/db"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var tagColor string
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage your own tags on starred repositories.",
+	Long: `Tags are labels you put on repositories yourself. They are included in
+full-text search, so 'starsage search <tag>' finds the repositories carrying it.
+Repositories are given by their full name, e.g. spf13/cobra.`,
+}
+
+// tagAddCmd tags a repository.
+var tagAddCmd = &cobra.Command{
+	Use:   "add [repo] [tag]...",
+	Short: "Add tags to a repository, creating the tags if needed.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			repo, err := findRepository(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			for _, name := range args[1:] {
+				tagID, err := store.GetOrCreateTag(name)
+				if err != nil {
+					fmt.Printf("Error creating tag %s: %v\n", name, err)
+					return
+				}
+				if err := store.TagRepository(repo.ID, tagID); err != nil {
+					fmt.Printf("Error tagging %s: %v\n", repo.FullName, err)
+					return
+				}
+			}
+			fmt.Printf("Tagged %s with %s.\n", repo.FullName, strings.Join(args[1:], ", "))
+		})
+	},
+}
+
+// tagRmCmd removes tags from a repository.
+var tagRmCmd = &cobra.Command{
+	Use:   "rm [repo] [tag]...",
+	Short: "Remove tags from a repository.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			repo, err := findRepository(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			for _, name := range args[1:] {
+				tag, err := findTag(store, name)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					return
+				}
+				if err := store.UntagRepository(repo.ID, tag.ID); err != nil {
+					fmt.Printf("Error untagging %s: %v\n", repo.FullName, err)
+					return
+				}
+			}
+			fmt.Printf("Removed %s from %s.\n", strings.Join(args[1:], ", "), repo.FullName)
+		})
+	},
+}
+
+// tagLsCmd lists all tags, the tags of a repository, or the repositories of a tag.
+var tagLsCmd = &cobra.Command{
+	Use:   "ls [repo]",
+	Short: "List all tags, or the tags of a repository.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			var tags []db.Tag
+			var err error
+			if len(args) == 1 {
+				repo, ferr := findRepository(store, args[0])
+				if ferr != nil {
+					fmt.Printf("Error: %v\n", ferr)
+					return
+				}
+				tags, err = store.GetRepoTags(repo.ID)
+			} else {
+				tags, err = store.GetTags()
+			}
+			if err != nil {
+				fmt.Printf("Error getting tags: %v\n", err)
+				return
+			}
+			if len(tags) == 0 {
+				fmt.Println("No tags found.")
+				return
+			}
+			for _, t := range tags {
+				line := t.Name
+				if len(args) == 0 {
+					line = fmt.Sprintf("%-24s %4d", t.Name, t.RepoCount)
+				}
+				if t.Color != "" {
+					line += "  " + t.Color
+				}
+				fmt.Println(line)
+			}
+		})
+	},
+}
+
+// tagShowCmd lists the repositories carrying a tag.
+var tagShowCmd = &cobra.Command{
+	Use:   "show [tag]",
+	Short: "List the repositories carrying a tag.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tag, err := findTag(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			repos, err := store.GetReposByTagID(tag.ID)
+			if err != nil {
+				fmt.Printf("Error getting repositories: %v\n", err)
+				return
+			}
+			for _, r := range repos {
+				fmt.Printf("%-40s %s\n", r.FullName, r.Description)
+			}
+		})
+	},
+}
+
+// tagCreateCmd creates a tag without tagging anything.
+var tagCreateCmd = &cobra.Command{
+	Use:   "create [tag]",
+	Short: "Create a tag.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			if _, err := store.CreateTag(args[0], tagColor); err != nil {
+				fmt.Printf("Error creating tag: %v\n", err)
+				return
+			}
+			fmt.Printf("Created tag %s.\n", args[0])
+		})
+	},
+}
+
+// tagRenameCmd renames a tag.
+var tagRenameCmd = &cobra.Command{
+	Use:   "rename [tag] [new-name]",
+	Short: "Rename a tag.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tag, err := findTag(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.UpdateTag(tag.ID, &args[1], nil); err != nil {
+				fmt.Printf("Error renaming tag: %v\n", err)
+				return
+			}
+			fmt.Printf("Renamed tag %s to %s.\n", tag.Name, args[1])
+		})
+	},
+}
+
+// tagColorCmd sets the color of a tag.
+var tagColorCmd = &cobra.Command{
+	Use:   "color [tag] [color]",
+	Short: "Set the color of a tag (omit the color to remove it).",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tag, err := findTag(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			color := ""
+			if len(args) == 2 {
+				color = args[1]
+			}
+			if err := store.UpdateTag(tag.ID, nil, &color); err != nil {
+				fmt.Printf("Error setting tag color: %v\n", err)
+				return
+			}
+			fmt.Printf("Updated color of tag %s.\n", tag.Name)
+		})
+	},
+}
+
+// tagMergeCmd merges one tag into another.
+var tagMergeCmd = &cobra.Command{
+	Use:   "merge [tag] [into-tag]",
+	Short: "Move all repositories of a tag to another tag and delete it.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			from, err := findTag(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			into, err := findTag(store, args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.MergeTags(from.ID, into.ID); err != nil {
+				fmt.Printf("Error merging tags: %v\n", err)
+				return
+			}
+			fmt.Printf("Merged tag %s into %s.\n", from.Name, into.Name)
+		})
+	},
+}
+
+// tagDeleteCmd deletes a tag.
+var tagDeleteCmd = &cobra.Command{
+	Use:   "delete [tag]",
+	Short: "Delete a tag and remove it from all repositories.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tag, err := findTag(store, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := store.DeleteTag(tag.ID); err != nil {
+				fmt.Printf("Error deleting tag: %v\n", err)
+				return
+			}
+			fmt.Printf("Deleted tag %s.\n", tag.Name)
+		})
+	},
+}
+
+// withStore opens the database, runs fn on its store and closes it again.
+func withStore(fn func(store db.Store)) {
+	database, err := db.InitDB()
+	if err != nil {
+		fmt.Printf("Error initializing database: %v\n", err)
+		return
+	}
+	defer database.Close()
+	fn(db.NewSQLiteStore(database))
+}
+
+// findRepository looks up a repository by its full name. Names shared by
+// repositories of several sources can be qualified as source/owner/name.
+func findRepository(store db.Store, name string) (*db.Repository, error) {
+	repos, err := store.FindRepositoriesByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		// Try "<source>/<owner>/<name>".
+		if i := strings.Index(name, "/"); i > 0 {
+			qualified, err := store.FindRepositoriesByName(name[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range qualified {
+				if strings.EqualFold(r.Source, name[:i]) {
+					return &r, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("repository %s not found", name)
+	}
+	if len(repos) > 1 {
+		var sources []string
+		for _, r := range repos {
+			sources = append(sources, r.Source+"/"+r.FullName)
+		}
+		return nil, fmt.Errorf("%s is ambiguous, use one of: %s", name, strings.Join(sources, ", "))
+	}
+	return &repos[0], nil
+}
+
+// findTag looks up a tag by name.
+func findTag(store db.Store, name string) (*db.Tag, error) {
+	tag, err := store.GetTagByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, fmt.Errorf("tag %s not found", name)
+	}
+	return tag, nil
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(tagAddCmd, tagRmCmd, tagLsCmd, tagShowCmd, tagCreateCmd, tagRenameCmd, tagColorCmd, tagMergeCmd, tagDeleteCmd)
+	tagCreateCmd.Flags().StringVar(&tagColor, "color", "", "Color of the tag, e.g. #ff8800")
+}
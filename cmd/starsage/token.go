@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"star-sage/internal/db"
+	"star-sage/internal/server"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var tokenScope string
+
+// tokenCmd groups the commands managing the API tokens of 'serve --auth'.
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage the API tokens accepted by the web server.",
+	Long: `Manages the tokens required by 'starsage serve --auth'. API clients send a
+token as "Authorization: Bearer <token>"; in the web UI you log in with one.
+Tokens with the read scope, the default, can browse but not change anything;
+create a write token with --scope write.`,
+}
+
+// tokenCreateCmd creates a token and prints it once.
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an API token.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			token, err := server.CreateToken(store, args[0], tokenScope)
+			if err != nil {
+				fmt.Printf("Error creating token: %v\n", err)
+				return
+			}
+			fmt.Printf("Created %s token %s:\n\n    %s\n\nStore it now, it cannot be shown again.\n", tokenScope, args[0], token)
+		})
+	},
+}
+
+// tokenListCmd lists the tokens without revealing them.
+var tokenListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the API tokens.",
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tokens, err := store.GetAPITokens()
+			if err != nil {
+				fmt.Printf("Error getting tokens: %v\n", err)
+				return
+			}
+			if len(tokens) == 0 {
+				fmt.Println("No tokens found.")
+				return
+			}
+			for _, t := range tokens {
+				lastUsed := t.LastUsedAt
+				if lastUsed == "" {
+					lastUsed = "never"
+				}
+				fmt.Printf("%d\t%s\t%s\tcreated %s\tlast used %s\n", t.ID, t.Name, t.Scope, t.CreatedAt, lastUsed)
+			}
+		})
+	},
+}
+
+// tokenRevokeCmd deletes a token and the logins made with it.
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke [name or id]",
+	Short: "Revoke an API token and end the logins made with it.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withStore(func(store db.Store) {
+			tokens, err := store.GetAPITokens()
+			if err != nil {
+				fmt.Printf("Error getting tokens: %v\n", err)
+				return
+			}
+			var found *db.APIToken
+			for i, t := range tokens {
+				if t.Name == args[0] || strconv.FormatInt(t.ID, 10) == args[0] {
+					found = &tokens[i]
+					break
+				}
+			}
+			if found == nil {
+				fmt.Printf("Error: token %s not found\n", args[0])
+				return
+			}
+			if err := store.DeleteAPIToken(found.ID); err != nil {
+				fmt.Printf("Error revoking token: %v\n", err)
+				return
+			}
+			fmt.Printf("Revoked token %s.\n", found.Name)
+		})
+	},
+}
+
+func init() {
+	serveCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	tokenCreateCmd.Flags().StringVar(&tokenScope, "scope", db.ScopeRead, "Scope of the token: read (browse only) or write")
+}
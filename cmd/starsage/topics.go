@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"star-sage/internal/ai"
+	"star-sage/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxTopics          int
+	minTopicConfidence float64
+)
+
+// topicsCmd represents the topics command
+var topicsCmd = &cobra.Command{
+	Use:   "topics",
+	Short: "Browse and discover topics of your starred repositories.",
+	Long: `Topics are a controlled vocabulary that organizes the whole collection.
+Run 'starsage topics discover' to let the AI propose them and assign every
+repository; without a subcommand, the discovered topics are listed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		topicsLsCmd.Run(cmd, args)
+	},
+}
+
+// topicsLsCmd lists the discovered topics.
+var topicsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the discovered topics.",
+	Run: func(cmd *cobra.Command, args []string) {
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		topics, err := store.GetTopics()
+		if err != nil {
+			fmt.Printf("Error getting topics: %v\n", err)
+			return
+		}
+		if len(topics) == 0 {
+			fmt.Println("No topics yet. Run 'starsage topics discover' first.")
+			return
+		}
+		for _, t := range topics {
+			fmt.Printf("%-28s %4d  %s\n", t.Name, t.RepoCount, t.Description)
+		}
+	},
+}
+
+// topicsShowCmd lists the repositories of a topic.
+var topicsShowCmd = &cobra.Command{
+	Use:   "show [topic]",
+	Short: "List the repositories of a topic.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		topic, err := store.GetTopicByName(args[0])
+		if err != nil {
+			fmt.Printf("Error getting topic: %v\n", err)
+			return
+		}
+		if topic == nil {
+			fmt.Printf("Topic %s not found.\n", args[0])
+			return
+		}
+
+		repos, err := store.GetReposByTopicID(topic.ID, minTopicConfidence)
+		if err != nil {
+			fmt.Printf("Error getting repositories: %v\n", err)
+			return
+		}
+		fmt.Printf("%s: %s\n\n", topic.Name, topic.Description)
+		for _, r := range repos {
+			fmt.Printf("%.2f  %-40s %s\n", r.Confidence, r.FullName, r.Description)
+		}
+	},
+}
+
+// topicsDiscoverCmd derives the topic vocabulary with the AI.
+var topicsDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Let the AI propose topics and assign repositories to them.",
+	Long: `Sends the names, descriptions, summaries and GitHub topics of all starred
+repositories to the classify provider. It proposes a vocabulary of topics,
+seeded with the GitHub topics shared by several repositories, and then assigns
+each repository to topics with a confidence. Repositories carrying a GitHub
+topic that is part of the vocabulary are always assigned to it.
+Running it again replaces the previous topics.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, err := newAIProvider(cmd, ai.TaskClassify)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		database, err := db.InitDB()
+		if err != nil {
+			fmt.Printf("Error initializing database: %v\n", err)
+			return
+		}
+		defer database.Close()
+		store := db.NewSQLiteStore(database)
+
+		repos, err := store.GetAllRepositories()
+		if err != nil {
+			fmt.Printf("Error getting repositories: %v\n", err)
+			return
+		}
+		if limit > 0 && len(repos) > limit {
+			repos = repos[:limit]
+		}
+		if len(repos) == 0 {
+			fmt.Println("No repositories found. Run 'starsage sync' first.")
+			return
+		}
+
+		fmt.Printf("Discovering topics for %d repositories...\n", len(repos))
+		discovery, err := ai.DiscoverTopics(context.Background(), provider, repos, ai.TopicOptions{
+			MaxTopics:     maxTopics,
+			MinConfidence: minTopicConfidence,
+			OnProgress:    printTopicProgress,
+		})
+		if err != nil {
+			fmt.Printf("Error discovering topics: %v\n", err)
+			return
+		}
+
+		if err := store.ReplaceTopics(discovery.Topics, discovery.Assignments); err != nil {
+			fmt.Printf("Error saving topics: %v\n", err)
+			return
+		}
+		fmt.Printf("Discovered %d topics with %d assignments. Run 'starsage topics' to list them.\n",
+			len(discovery.Topics), len(discovery.Assignments))
+	},
+}
+
+// printTopicProgress prints the stage topic discovery has reached.
+func printTopicProgress(stage string, chunk, chunks int) {
+	switch stage {
+	case ai.TopicStageSeeds:
+		fmt.Printf("Using %d GitHub topics as seed labels.\n", chunk)
+	case ai.TopicStagePropose:
+		fmt.Printf("Proposing topics for chunk %d/%d...\n", chunk, chunks)
+	case ai.TopicStageConsolidate:
+		fmt.Printf("Consolidating %d candidate topics...\n", chunk)
+	case ai.TopicStageAssign:
+		fmt.Printf("Assigning topics for chunk %d/%d...\n", chunk, chunks)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(topicsCmd)
+	topicsCmd.AddCommand(topicsLsCmd, topicsShowCmd, topicsDiscoverCmd)
+	topicsDiscoverCmd.Flags().IntVar(&maxTopics, "max-topics", ai.DefaultMaxTopics, "Maximum number of topics to discover")
+	topicsDiscoverCmd.Flags().Float64Var(&minTopicConfidence, "min-confidence", ai.DefaultMinTopicConfidence, "Drop AI assignments below this confidence")
+	topicsShowCmd.Flags().Float64Var(&minTopicConfidence, "min-confidence", 0, "Only list repositories assigned with at least this confidence")
+	addProviderFlags(topicsDiscoverCmd)
+}
@@ -0,0 +1,10 @@
+// Package frontend holds the assets of the web UI, compiled into the binary
+// so that 'starsage serve' works from any directory.
+package frontend
+
+import "embed"
+
+// Files contains the web UI, with index.html at the root.
+//
+//go:embed index.html script.js style.css
+var Files embed.FS
@@ -3,13 +3,18 @@ document.addEventListener('DOMContentLoaded', () => {
     let state = {
         currentView: 'repositories', // 'repositories' or 'lists'
         allRepos: [],
+        totalRepos: 0,
         allLists: [],
+        activeJobs: {}, // running and queued jobs by ID, from the event stream
+        session: null, // from /api/session: auth_enabled, authenticated, scope, csrf_token
     };
+    const pageSize = 50;
 
     // DOM Elements
     const nav = {
         repos: document.getElementById('nav-repos'),
         lists: document.getElementById('nav-lists'),
+        logout: document.getElementById('nav-logout'),
     };
     const views = {
         repositories: document.getElementById('repositories-view'),
@@ -18,6 +23,10 @@ document.addEventListener('DOMContentLoaded', () => {
     const repoListContainer = document.getElementById('repo-list');
     const listContainer = document.getElementById('ai-lists-container');
     const searchBox = document.getElementById('search-box');
+    const sortSelect = document.getElementById('sort-select');
+    const repoCount = document.getElementById('repo-count');
+    const loadMoreBtn = document.getElementById('load-more-btn');
+    const jobStatus = document.getElementById('job-status');
 
     // Modal Elements
     const modal = document.getElementById('create-list-modal');
@@ -27,10 +36,17 @@ document.addEventListener('DOMContentLoaded', () => {
     const listNameInput = document.getElementById('list-name');
     const listPromptInput = document.getElementById('list-prompt');
 
+    const loginModal = document.getElementById('login-modal');
+    const loginForm = document.getElementById('login-form');
+    const loginTokenInput = document.getElementById('login-token');
+    const loginError = document.getElementById('login-error');
+
     // --- RENDER FUNCTIONS ---
 
     function renderRepos(repos) {
         repoListContainer.innerHTML = '';
+        repoCount.textContent = `Showing ${repos.length} of ${state.totalRepos} repositories`;
+        loadMoreBtn.classList.toggle('hidden', repos.length >= state.totalRepos);
         if (repos.length === 0) {
             repoListContainer.innerHTML = '<p>No repositories found.</p>';
             return;
@@ -74,13 +90,77 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
+    function renderJobStatus() {
+        const jobs = Object.values(state.activeJobs).filter(job => job.Status === 'running');
+        jobStatus.classList.toggle('hidden', jobs.length === 0);
+        jobStatus.textContent = jobs
+            .map(job => job.Message ? `${job.Type}: ${job.Message}` : `${job.Type}...`)
+            .join(' · ');
+    }
+
     // --- API FUNCTIONS ---
 
-    async function fetchRepos() {
+    // api wraps fetch for API calls: it sends the CSRF token with requests
+    // that change something and asks to log in again when the session is gone.
+    async function api(url, options = {}) {
+        const method = (options.method || 'GET').toUpperCase();
+        const headers = { ...options.headers };
+        if (method !== 'GET' && method !== 'HEAD' && state.session && state.session.csrf_token) {
+            headers['X-CSRF-Token'] = state.session.csrf_token;
+        }
+        const response = await fetch(url, { ...options, headers });
+        if (response.status === 401) {
+            showLogin();
+        }
+        return response;
+    }
+
+    async function fetchSession() {
+        const response = await fetch('/api/session');
+        if (!response.ok) throw new Error(`HTTP error! status: ${response.status}`);
+        state.session = await response.json();
+    }
+
+    async function login(token) {
+        const response = await fetch('/api/login', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ token }),
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            throw new Error(result.error || `HTTP error! status: ${response.status}`);
+        }
+        state.session = result;
+    }
+
+    async function logout() {
+        await api('/api/logout', { method: 'POST' });
+        window.location.reload();
+    }
+
+    // fetchRepos loads the first page of repositories matching the search box,
+    // or the next page when append is true. Filtering happens on the server.
+    // With keepLoaded, as many repositories as are shown already are reloaded.
+    async function fetchRepos(append = false, keepLoaded = false) {
+        const params = new URLSearchParams({
+            limit: keepLoaded ? Math.max(pageSize, state.allRepos.length) : pageSize,
+            offset: append ? state.allRepos.length : 0,
+        });
+        const query = searchBox.value.trim();
+        if (query) {
+            params.set('q', query);
+        }
+        // Without a sort choice, text searches are ordered by relevance.
+        if (!query || sortSelect.dataset.touched) {
+            params.set('sort', sortSelect.value);
+        }
         try {
-            const response = await fetch('/api/repositories');
+            const response = await api(`/api/repositories?${params}`);
             if (!response.ok) throw new Error(`HTTP error! status: ${response.status}`);
-            state.allRepos = await response.json();
+            const page = await response.json();
+            state.allRepos = append ? state.allRepos.concat(page.Repositories) : page.Repositories;
+            state.totalRepos = page.Total;
             renderRepos(state.allRepos);
         } catch (error) {
             repoListContainer.innerHTML = `<p>Error loading repositories: ${error.message}</p>`;
@@ -89,7 +169,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
     async function fetchLists() {
         try {
-            const response = await fetch('/api/lists');
+            const response = await api('/api/lists');
             if (!response.ok) throw new Error(`HTTP error! status: ${response.status}`);
             state.allLists = await response.json();
             renderLists(state.allLists);
@@ -100,7 +180,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
     async function createList(name, prompt) {
         try {
-            const response = await fetch('/api/lists', {
+            const response = await api('/api/lists', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ name, prompt }),
@@ -109,14 +189,82 @@ document.addEventListener('DOMContentLoaded', () => {
             if (!response.ok) {
                 throw new Error(result.error || `HTTP error! status: ${response.status}`);
             }
-            alert('List creation started! It will appear in the list shortly.');
             closeModal();
-            fetchLists(); // Refresh the list view
+            if (result.warning) {
+                alert(`List created, but ${result.warning}`);
+            }
+            fetchLists(); // Repositories are added live once classification finishes
         } catch (error) {
             alert(`Error creating list: ${error.message}`);
         }
     }
 
+    // --- LIVE UPDATES ---
+
+    // Syncs save many repositories in a row; reload once they calm down.
+    let repoRefreshTimer;
+    function scheduleRepoRefresh() {
+        clearTimeout(repoRefreshTimer);
+        repoRefreshTimer = setTimeout(() => fetchRepos(false, true), 1000);
+    }
+
+    function refreshCurrentView() {
+        if (state.currentView === 'lists') {
+            fetchLists();
+        } else {
+            fetchRepos(false, true);
+        }
+    }
+
+    // subscribeEvents keeps the page up to date with the server, including
+    // changes made in other tabs. EventSource reconnects by itself; events
+    // missed in between are not replayed, so the view is reloaded instead.
+    function subscribeEvents() {
+        const source = new EventSource('/api/events');
+        let disconnected = false;
+
+        source.onopen = () => {
+            if (disconnected) {
+                disconnected = false;
+                state.activeJobs = {};
+                renderJobStatus();
+                refreshCurrentView();
+            }
+        };
+        source.onerror = () => {
+            disconnected = true;
+        };
+
+        source.addEventListener('job.updated', (e) => {
+            const job = JSON.parse(e.data);
+            state.activeJobs[job.ID] = job;
+            renderJobStatus();
+        });
+        source.addEventListener('job.finished', (e) => {
+            const job = JSON.parse(e.data);
+            delete state.activeJobs[job.ID];
+            renderJobStatus();
+            if (job.Type === 'classify_list' && state.currentView === 'lists') {
+                fetchLists();
+            }
+        });
+        source.addEventListener('repo.added', scheduleRepoRefresh);
+        source.addEventListener('repo.updated', scheduleRepoRefresh);
+        source.addEventListener('summary.written', (e) => {
+            const data = JSON.parse(e.data);
+            const repo = state.allRepos.find(r => r.ID === data.repo_id);
+            if (repo) {
+                repo.Summary = data.summary;
+                renderRepos(state.allRepos);
+            }
+        });
+        source.addEventListener('list.changed', () => {
+            if (state.currentView === 'lists') {
+                fetchLists();
+            }
+        });
+    }
+
     // --- VIEW & MODAL MANAGEMENT ---
 
     function showView(viewName) {
@@ -133,6 +281,18 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    function showLogin() {
+        loginModal.classList.remove('hidden');
+        loginTokenInput.focus();
+    }
+
+    // applySession shows the controls that fit the current login.
+    function applySession() {
+        const session = state.session;
+        nav.logout.classList.toggle('hidden', !session.auth_enabled || !session.authenticated);
+        createListBtn.classList.toggle('hidden', session.scope !== 'write');
+    }
+
     function openModal() {
         modal.classList.remove('hidden');
     }
@@ -144,14 +304,10 @@ document.addEventListener('DOMContentLoaded', () => {
 
     // --- EVENT LISTENERS ---
 
+    let searchTimer;
     function filterRepos() {
-        const query = searchBox.value.toLowerCase();
-        const filteredRepos = state.allRepos.filter(repo => {
-            return repo.FullName.toLowerCase().includes(query) ||
-                   (repo.Description && repo.Description.toLowerCase().includes(query)) ||
-                   (repo.Summary && repo.Summary.toLowerCase().includes(query));
-        });
-        renderRepos(filteredRepos);
+        clearTimeout(searchTimer);
+        searchTimer = setTimeout(() => fetchRepos(), 250);
     }
 
     nav.repos.addEventListener('click', (e) => {
@@ -165,6 +321,11 @@ document.addEventListener('DOMContentLoaded', () => {
     });
 
     searchBox.addEventListener('input', filterRepos);
+    sortSelect.addEventListener('change', () => {
+        sortSelect.dataset.touched = 'true';
+        fetchRepos();
+    });
+    loadMoreBtn.addEventListener('click', () => fetchRepos(true));
 
     createListBtn.addEventListener('click', openModal);
     closeModalBtn.addEventListener('click', closeModal);
@@ -172,6 +333,23 @@ document.addEventListener('DOMContentLoaded', () => {
         if (e.target === modal) closeModal();
     });
 
+    nav.logout.addEventListener('click', (e) => {
+        e.preventDefault();
+        logout();
+    });
+
+    loginForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        try {
+            await login(loginTokenInput.value.trim());
+            // Start over with the new session, including the event stream.
+            window.location.reload();
+        } catch (error) {
+            loginError.textContent = error.message;
+            loginError.classList.remove('hidden');
+        }
+    });
+
     createListForm.addEventListener('submit', (e) => {
         e.preventDefault();
         const name = listNameInput.value.trim();
@@ -183,9 +361,21 @@ document.addEventListener('DOMContentLoaded', () => {
 
     // --- INITIALIZATION ---
 
-    function init() {
+    async function init() {
+        try {
+            await fetchSession();
+        } catch (error) {
+            repoListContainer.innerHTML = `<p>Error connecting to the server: ${error.message}</p>`;
+            return;
+        }
+        if (!state.session.authenticated) {
+            showLogin();
+            return;
+        }
+        applySession();
         showView('repositories');
         fetchRepos();
+        subscribeEvents();
     }
 
     init();
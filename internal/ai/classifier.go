@@ -16,7 +16,9 @@ const (
 
 // ClassifyRepositories uses an AI provider to classify repositories based on a user prompt.
 // It handles chunking the repositories to fit within the AI model's context window.
-func ClassifyRepositories(ctx context.Context, provider Provider, userPrompt string, repos []db.Repository) ([]int64, error) {
+// onChunk, if not nil, is called before each chunk is sent with its 1-based
+// index and the number of chunks.
+func ClassifyRepositories(ctx context.Context, provider Provider, userPrompt string, repos []db.Repository, onChunk func(chunk, chunks int)) ([]int64, error) {
 	var finalRepoIDs []int64
 
 	chunks, err := chunkRepositories(repos)
@@ -25,7 +27,9 @@ func ClassifyRepositories(ctx context.Context, provider Provider, userPrompt str
 	}
 
 	for i, chunk := range chunks {
-		fmt.Printf("Processing chunk %d/%d...\n", i+1, len(chunks))
+		if onChunk != nil {
+			onChunk(i+1, len(chunks))
+		}
 		prompt, err := buildClassificationPrompt(userPrompt, chunk)
 		if err != nil {
 			return nil, fmt.Errorf("could not build prompt for chunk %d: %w", i, err)
@@ -46,6 +50,55 @@ func ClassifyRepositories(ctx context.Context, provider Provider, userPrompt str
 	return finalRepoIDs, nil
 }
 
+// ClassifyOptions reports the progress of ClassifyList. Both callbacks are
+// optional.
+type ClassifyOptions struct {
+	// OnStart is called with the number of repositories to classify.
+	OnStart func(repos int)
+	// OnChunk is passed to ClassifyRepositories.
+	OnChunk func(chunk, chunks int)
+}
+
+// ClassifyList classifies the starred repositories for list with its prompt
+// and stores the result as the AI members of the list. Repositories pinned to
+// or excluded from the list by hand are left as they are. It returns the IDs
+// of the matching repositories; IDs the provider made up are dropped.
+func ClassifyList(ctx context.Context, store db.ListStore, provider Provider, list *db.List, opts ClassifyOptions) ([]int64, error) {
+	repos, err := store.GetReposForListClassification(list.ID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OnStart != nil {
+		opts.OnStart(len(repos))
+	}
+	ids, err := ClassifyRepositories(ctx, provider, list.Prompt, repos, opts.OnChunk)
+	if err != nil {
+		return nil, fmt.Errorf("ai classification failed: %w", err)
+	}
+	ids = candidateIDs(ids, repos)
+	if err := store.ReplaceListClassification(list.ID, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// candidateIDs returns the IDs in ids that belong to one of repos, without
+// duplicates.
+func candidateIDs(ids []int64, repos []db.Repository) []int64 {
+	candidates := make(map[int64]bool, len(repos))
+	for _, r := range repos {
+		candidates[r.ID] = true
+	}
+	var kept []int64
+	for _, id := range ids {
+		if candidates[id] {
+			kept = append(kept, id)
+			delete(candidates, id)
+		}
+	}
+	return kept
+}
+
 // chunkRepositories splits a slice of repositories into smaller chunks based on estimated token count.
 func chunkRepositories(repos []db.Repository) ([][]db.Repository, error) {
 	var chunks [][]db.Repository
@@ -115,13 +168,7 @@ func buildClassificationPrompt(userPrompt string, repos []db.Repository) (string
 
 // parseAIResponse extracts the JSON array of repository IDs from the AI's text response.
 func parseAIResponse(response string) ([]int64, error) {
-	// The AI might sometimes wrap the JSON in markdown code blocks.
-	response = strings.TrimSpace(response)
-	if strings.HasPrefix(response, "```json") {
-		response = strings.TrimPrefix(response, "```json")
-		response = strings.TrimSuffix(response, "```")
-		response = strings.TrimSpace(response)
-	}
+	response = trimCodeFence(response)
 
 	var repoIDs []int64
 	err := json.Unmarshal([]byte(response), &repoIDs)
@@ -131,3 +178,15 @@ func parseAIResponse(response string) ([]int64, error) {
 
 	return repoIDs, nil
 }
+
+// trimCodeFence removes the markdown code block the AI sometimes wraps JSON in.
+func trimCodeFence(response string) string {
+	response = strings.TrimSpace(response)
+	if strings.HasPrefix(response, "```") {
+		response = strings.TrimPrefix(response, "```json")
+		response = strings.TrimPrefix(response, "```")
+		response = strings.TrimSuffix(response, "```")
+		response = strings.TrimSpace(response)
+	}
+	return response
+}
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+// fakeProvider answers every prompt with the same response.
+type fakeProvider struct {
+	response string
+}
+
+func (p fakeProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.response, nil
+}
+
+func listMembers(t *testing.T, store db.Store, listID int64) map[string]string {
+	t.Helper()
+	repos, err := store.GetReposByListID(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := make(map[string]string)
+	for _, r := range repos {
+		members[r.FullName] = r.Membership
+	}
+	return members
+}
+
+func TestClassifyList(t *testing.T) {
+	dbtest.ForEach(t, testClassifyList)
+}
+
+func testClassifyList(t *testing.T, store dbtest.Store) {
+	var ids []int64
+	for _, name := range []string{"pinned", "excluded", "stale", "match", "other"} {
+		ids = append(ids, store.AddRepository(db.Repository{FullName: name}))
+	}
+	pinned, excluded, stale, match := ids[0], ids[1], ids[2], ids[3]
+
+	listID, err := store.CreateList("Tools", "developer tools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PinRepoToList(listID, pinned); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ExcludeRepoFromList(listID, excluded); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReplaceListClassification(listID, []int64{stale}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.GetListByID(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider repeats a match, names the excluded repository and makes
+	// up an ID; only the real candidate is kept.
+	provider := fakeProvider{response: jsonIDs(match, match, excluded, 9999)}
+	got, err := ClassifyList(context.Background(), store, provider, list, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{match}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClassifyList returned %v, want %v", got, want)
+	}
+
+	want := map[string]string{"pinned": db.ListMembershipPinned, "match": db.ListMembershipAI}
+	if members := listMembers(t, store, listID); !reflect.DeepEqual(members, want) {
+		t.Errorf("list members = %v, want %v", members, want)
+	}
+
+	// The exclusion survives the classification.
+	candidates, err := store.GetReposForListClassification(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range candidates {
+		names = append(names, r.FullName)
+	}
+	sort.Strings(names)
+	if want := []string{"match", "other", "stale"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("classification candidates = %v, want %v", names, want)
+	}
+}
+
+func jsonIDs(ids ...int64) string {
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
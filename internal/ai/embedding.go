@@ -0,0 +1,37 @@
+package ai
+
+import (
+	"star-sage/internal/db"
+	"strings"
+)
+
+// maxEmbeddedReadmeRunes limits how much of a README goes into the embedded
+// text; the beginning of a README usually says what the project is about.
+const maxEmbeddedReadmeRunes = 2000
+
+// EmbeddingText builds the text embedded for a repository from its name,
+// description, topics, summary and the start of its README.
+func EmbeddingText(repo db.Repository) string {
+	var b strings.Builder
+	b.WriteString(repo.FullName)
+	if repo.Description != "" {
+		b.WriteString("\n")
+		b.WriteString(repo.Description)
+	}
+	if len(repo.Topics) > 0 {
+		b.WriteString("\nTopics: ")
+		b.WriteString(strings.Join(repo.Topics, ", "))
+	}
+	if repo.Summary != "" {
+		b.WriteString("\n")
+		b.WriteString(repo.Summary)
+	}
+	if readme := []rune(repo.ReadmeContent); len(readme) > 0 {
+		if len(readme) > maxEmbeddedReadmeRunes {
+			readme = readme[:maxEmbeddedReadmeRunes]
+		}
+		b.WriteString("\n\n")
+		b.WriteString(string(readme))
+	}
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package ai
+
+import (
+	"star-sage/internal/db"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddingText(t *testing.T) {
+	if got := EmbeddingText(db.Repository{FullName: "owner/bare"}); got != "owner/bare" {
+		t.Errorf("got %q, want only the name", got)
+	}
+
+	readme := strings.Repeat("é", maxEmbeddedReadmeRunes) + "cut"
+	got := EmbeddingText(db.Repository{
+		FullName:      "owner/repo",
+		Description:   "A parser",
+		Topics:        []string{"go", "parsing"},
+		Summary:       "Parses things.",
+		ReadmeContent: readme,
+	})
+	want := "owner/repo\nA parser\nTopics: go, parsing\nParses things.\n\n" + strings.Repeat("é", maxEmbeddedReadmeRunes)
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -45,6 +45,12 @@ type ollamaGenerateResponse struct {
 
 // Generate sends a prompt to the Ollama API and returns the response.
 func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.GenerateStream(ctx, prompt, nil)
+}
+
+// GenerateStream sends a prompt to the Ollama API and calls onToken with each
+// piece of the response as it is streamed back.
+func (p *OllamaProvider) GenerateStream(ctx context.Context, prompt string, onToken func(string)) (string, error) {
 	reqBody, err := json.Marshal(ollamaGenerateRequest{
 		Model:  p.model,
 		Prompt: prompt,
@@ -79,6 +85,9 @@ func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, e
 			continue
 		}
 		summaryBuilder.WriteString(lineResponse.Response)
+		if onToken != nil && lineResponse.Response != "" {
+			onToken(lineResponse.Response)
+		}
 		if lineResponse.Done {
 			break
 		}
@@ -90,3 +99,47 @@ func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, e
 
 	return strings.TrimSpace(summaryBuilder.String()), nil
 }
+
+// ollamaEmbeddingRequest is the request body for the Ollama embeddings API.
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// ollamaEmbeddingResponse is the response of the Ollama embeddings API.
+type ollamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// Embed sends text to the Ollama embeddings API and returns its vector.
+func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	reqBody, err := json.Marshal(ollamaEmbeddingRequest{Model: p.model, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/embeddings", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("could not create ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned non-200 status: %s", resp.Status)
+	}
+
+	var embResp ollamaEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, fmt.Errorf("could not decode ollama response: %w", err)
+	}
+	if len(embResp.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama returned an empty embedding (does model %s support embeddings?)", p.model)
+	}
+	return embResp.Embedding, nil
+}
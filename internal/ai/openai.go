@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultOpenAIBaseURL is used when no base URL is configured.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIOptions configures an OpenAIProvider.
+type OpenAIOptions struct {
+	// BaseURL is the API root including the version, e.g. http://localhost:1234/v1.
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Temperature is sent only when set, so servers can apply their own default.
+	Temperature *float64
+	// MaxTokens limits the length of the response; 0 means no limit.
+	MaxTokens int
+}
+
+// OpenAIProvider implements the Provider interface for any server speaking the
+// OpenAI chat completions protocol (OpenAI, vLLM, LM Studio, llama.cpp, DeepSeek, ...).
+type OpenAIProvider struct {
+	opts   OpenAIOptions
+	client *http.Client
+}
+
+// NewOpenAIProvider creates a new provider for an OpenAI-compatible API.
+// opts.BaseURL defaults to DefaultOpenAIBaseURL if empty.
+func NewOpenAIProvider(opts OpenAIOptions, client *http.Client) *OpenAIProvider {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultOpenAIBaseURL
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	return &OpenAIProvider{opts: opts, client: client}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIChatRequest is the request body for /chat/completions.
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Stream      bool            `json:"stream"`
+}
+
+// openAIChatResponse covers both complete responses and streamed chunks.
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+// openAIErrorResponse is the error body returned by OpenAI-compatible servers.
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Generate sends a prompt to the chat completions API and returns the full response.
+func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := p.chat(ctx, prompt, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("could not decode openai response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("openai response contained no choices")
+	}
+	return strings.TrimSpace(chatResp.Choices[0].Message.Content), nil
+}
+
+// GenerateStream sends a prompt to the chat completions API with streaming
+// enabled. onToken is called with every content delta as it arrives; the
+// complete response is returned at the end.
+func (p *OpenAIProvider) GenerateStream(ctx context.Context, prompt string, onToken func(string)) (string, error) {
+	resp, err := p.chat(ctx, prompt, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var builder strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Server-sent events: "data: {...}" lines, terminated by "data: [DONE]".
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			// Ignore events that are not valid JSON
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			builder.WriteString(choice.Delta.Content)
+			if onToken != nil {
+				onToken(choice.Delta.Content)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading openai stream: %w", err)
+	}
+
+	return strings.TrimSpace(builder.String()), nil
+}
+
+// openAIEmbeddingRequest is the request body for /embeddings.
+type openAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// openAIEmbeddingResponse is the response of /embeddings.
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed sends text to the embeddings API and returns its vector.
+func (p *OpenAIProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	resp, err := p.post(ctx, "/embeddings", openAIEmbeddingRequest{Model: p.opts.Model, Input: text}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embResp openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, fmt.Errorf("could not decode openai response: %w", err)
+	}
+	if len(embResp.Data) == 0 || len(embResp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("openai response contained no embedding")
+	}
+	return embResp.Data[0].Embedding, nil
+}
+
+// chat posts a single-message conversation and returns the successful response.
+func (p *OpenAIProvider) chat(ctx context.Context, prompt string, stream bool) (*http.Response, error) {
+	return p.post(ctx, "/chat/completions", openAIChatRequest{
+		Model:       p.opts.Model,
+		Messages:    []openAIMessage{{Role: "user", Content: prompt}},
+		Temperature: p.opts.Temperature,
+		MaxTokens:   p.opts.MaxTokens,
+		Stream:      stream,
+	}, stream)
+}
+
+// post sends body as JSON to path and returns the successful response.
+func (p *OpenAIProvider) post(ctx context.Context, path string, body interface{}, stream bool) (*http.Response, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal openai request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.opts.BaseURL+path, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("could not create openai request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if p.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.opts.APIKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to openai: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var apiErr openAIErrorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("openai returned %s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("openai returned non-200 status: %s", resp.Status)
+	}
+	return resp, nil
+}
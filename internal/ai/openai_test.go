@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestOpenAI returns a provider talking to handler. Requests to it are
+// decoded into req.
+func newTestOpenAI(t *testing.T, opts OpenAIOptions, req *openAIChatRequest, handler func(w http.ResponseWriter, r *http.Request)) *OpenAIProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req != nil {
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				t.Errorf("could not decode request: %v", err)
+			}
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	opts.BaseURL = srv.URL + "/v1/"
+	return NewOpenAIProvider(opts, srv.Client())
+}
+
+func TestOpenAIGenerateStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+		tokens []string
+	}{
+		{
+			name: "deltas",
+			stream: "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\", world\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want:   "Hello, world",
+			tokens: []string{"Hello", ", world"},
+		},
+		{
+			name: "comments, other fields and invalid events",
+			stream: ": keep-alive\n\n" +
+				"event: message\n" +
+				"data:{\"choices\":[{\"delta\":{\"content\":\" A\"}}]}\n\n" +
+				"data: not json\n\n" +
+				"data: {\"choices\":[]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"B \"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want:   "AB",
+			tokens: []string{" A", "B "},
+		},
+		{
+			name: "stops at done",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"A\"}}]}\n\n" +
+				"data: [DONE]\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"B\"}}]}\n\n",
+			want:   "A",
+			tokens: []string{"A"},
+		},
+		{
+			name:   "ends without done",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"A\"}}]}\n\n",
+			want:   "A",
+			tokens: []string{"A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req openAIChatRequest
+			temperature := 0.2
+			p := newTestOpenAI(t, OpenAIOptions{APIKey: "key", Model: "gpt-test", Temperature: &temperature}, &req, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" || r.Header.Get("Accept") != "text/event-stream" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte(tt.stream))
+			})
+
+			var tokens []string
+			got, err := p.GenerateStream(context.Background(), "Say hello", func(s string) { tokens = append(tokens, s) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || strings.Join(tokens, "|") != strings.Join(tt.tokens, "|") {
+				t.Errorf("got %q from tokens %q, want %q from %q", got, tokens, tt.want, tt.tokens)
+			}
+			if !req.Stream || req.Model != "gpt-test" || req.Temperature == nil || *req.Temperature != 0.2 ||
+				len(req.Messages) != 1 || req.Messages[0].Content != "Say hello" {
+				t.Errorf("request = %+v", req)
+			}
+		})
+	}
+}
+
+func TestOpenAIGenerate(t *testing.T) {
+	var req openAIChatRequest
+	p := newTestOpenAI(t, OpenAIOptions{Model: "local", MaxTokens: 100}, &req, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("request without an API key sent an Authorization header")
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "  Hi there \n"}}]}`))
+	})
+	got, err := p.Generate(context.Background(), "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hi there" {
+		t.Errorf("got %q, want %q", got, "Hi there")
+	}
+	if req.Stream || req.MaxTokens != 100 || req.Temperature != nil {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"error body", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`, "401 Unauthorized: Incorrect API key provided"},
+		{"plain body", http.StatusBadGateway, "upstream unavailable", "non-200 status: 502 Bad Gateway"},
+		{"empty error", http.StatusTooManyRequests, `{"error": {}}`, "non-200 status: 429 Too Many Requests"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices"},
+		{"invalid json", http.StatusOK, `{"choices": [`, "could not decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestOpenAI(t, OpenAIOptions{Model: "gpt-test"}, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			_, err := p.Generate(context.Background(), "Hello")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate: got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if tt.status != http.StatusOK {
+				_, err := p.GenerateStream(context.Background(), "Hello", nil)
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GenerateStream: got error %v, want one containing %q", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	p := newTestOpenAI(t, OpenAIOptions{Model: "embed"}, nil, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data": [{"embedding": [0.5, -1, 2]}]}`))
+	})
+	got, err := p.Embed(context.Background(), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != 0.5 || got[1] != -1 || got[2] != 2 {
+		t.Errorf("got %v, want [0.5 -1 2]", got)
+	}
+}
@@ -7,3 +7,19 @@ type Provider interface {
 	// Generate takes a prompt and returns a text-based response from the AI model.
 	Generate(ctx context.Context, prompt string) (string, error)
 }
+
+// StreamingProvider is implemented by providers that can return a response
+// incrementally as it is generated.
+type StreamingProvider interface {
+	Provider
+	// GenerateStream calls onToken with each piece of the response as it
+	// arrives and returns the complete response.
+	GenerateStream(ctx context.Context, prompt string, onToken func(string)) (string, error)
+}
+
+// Embedder is implemented by providers that can turn text into a vector for
+// semantic search.
+type Embedder interface {
+	// Embed returns the embedding vector of text.
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"star-sage/internal/config"
+)
+
+// Tasks a provider profile can be the default for.
+const (
+	TaskSummarize = "summarize"
+	TaskClassify  = "classify"
+	TaskEmbed     = "embed"
+	TaskChat      = "chat"
+)
+
+// Factory creates a provider from a profile.
+type Factory func(cfg config.ProviderConfig, client *http.Client) (Provider, error)
+
+var factories = map[string]Factory{
+	"ollama": func(cfg config.ProviderConfig, client *http.Client) (Provider, error) {
+		return NewOllamaProvider(cfg.Model, cfg.BaseURL, client), nil
+	},
+	"openai": func(cfg config.ProviderConfig, client *http.Client) (Provider, error) {
+		return NewOpenAIProvider(OpenAIOptions{
+			BaseURL:     cfg.BaseURL,
+			APIKey:      cfg.ResolveAPIKey(),
+			Model:       cfg.Model,
+			Temperature: cfg.Temperature,
+			MaxTokens:   cfg.MaxTokens,
+		}, client), nil
+	},
+}
+
+// Register makes a provider type available to profiles. It is meant to be
+// called from init functions and replaces any factory of the same type.
+func Register(typ string, f Factory) {
+	factories[typ] = f
+}
+
+// Registry resolves provider profiles from the ai section of the config file.
+type Registry struct {
+	cfg config.AIConfig
+}
+
+// NewRegistry creates a registry for cfg, as returned by config.GetAIConfig.
+func NewRegistry(cfg config.AIConfig) *Registry {
+	return &Registry{cfg: cfg}
+}
+
+// LoadRegistry creates a registry from the current configuration.
+func LoadRegistry() (*Registry, error) {
+	cfg, err := config.GetAIConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(cfg), nil
+}
+
+// Profile returns the provider profile called name.
+func (r *Registry) Profile(name string) (config.ProviderConfig, error) {
+	p, ok := r.cfg.Providers[name]
+	if !ok {
+		return p, fmt.Errorf("unknown AI provider profile: %s (known: %v)", name, r.ProfileNames())
+	}
+	return p, nil
+}
+
+// ProfileForTask returns the default provider profile for task.
+func (r *Registry) ProfileForTask(task string) (config.ProviderConfig, error) {
+	name, ok := r.cfg.Defaults[task]
+	if !ok {
+		return config.ProviderConfig{}, fmt.Errorf("no AI provider configured for task %s", task)
+	}
+	return r.Profile(name)
+}
+
+// ProfileNames returns the names of all known profiles, sorted.
+func (r *Registry) ProfileNames() []string {
+	names := make([]string, 0, len(r.cfg.Providers))
+	for name := range r.cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Provider creates the provider of the profile called name.
+func (r *Registry) Provider(name string) (Provider, error) {
+	p, err := r.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(p)
+}
+
+// ForTask creates the default provider for task.
+func (r *Registry) ForTask(task string) (Provider, error) {
+	p, err := r.ProfileForTask(task)
+	if err != nil {
+		return nil, err
+	}
+	return New(p)
+}
+
+// NewEmbedder creates an embedder from a profile whose type supports embeddings.
+func NewEmbedder(cfg config.ProviderConfig) (Embedder, error) {
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := p.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("AI provider type %q in profile %s does not support embeddings", cfg.Type, cfg.Name)
+	}
+	return e, nil
+}
+
+// New creates a provider from a profile.
+func New(cfg config.ProviderConfig) (Provider, error) {
+	f, ok := factories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported AI provider type %q in profile %s", cfg.Type, cfg.Name)
+	}
+	// The AI provider uses its own http client (without auth or proxy).
+	client := &http.Client{Timeout: cfg.Timeout}
+	return f(cfg, client)
+}
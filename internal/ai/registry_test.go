@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"net/http"
+	"reflect"
+	"star-sage/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRegistry() *Registry {
+	temperature := 0.5
+	return NewRegistry(config.AIConfig{
+		Providers: map[string]config.ProviderConfig{
+			"local":  {Name: "local", Type: "ollama", Model: "llama3:8b", BaseURL: "http://gpu-box:11434"},
+			"remote": {Name: "remote", Type: "openai", Model: "gpt-4o", APIKeyEnv: "TEST_REGISTRY_KEY", Timeout: time.Minute, Temperature: &temperature, MaxTokens: 100},
+			"broken": {Name: "broken", Type: "telepathy"},
+		},
+		Defaults: map[string]string{TaskSummarize: "local", TaskChat: "remote", TaskClassify: "missing", TaskEmbed: "broken"},
+	})
+}
+
+func TestRegistryForTask(t *testing.T) {
+	t.Setenv("TEST_REGISTRY_KEY", "secret")
+	r := newTestRegistry()
+
+	p, err := r.ForTask(TaskSummarize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ollama, ok := p.(*OllamaProvider); !ok || ollama.model != "llama3:8b" || ollama.baseURL != "http://gpu-box:11434" {
+		t.Errorf("summarize provider is %#v, want the local Ollama profile", p)
+	}
+
+	p, err = r.ForTask(TaskChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openai, ok := p.(*OpenAIProvider)
+	if !ok {
+		t.Fatalf("chat provider is %#v, want an OpenAI provider", p)
+	}
+	if openai.opts.Model != "gpt-4o" || openai.opts.APIKey != "secret" || *openai.opts.Temperature != 0.5 || openai.opts.MaxTokens != 100 {
+		t.Errorf("got options %+v", openai.opts)
+	}
+	if openai.client.Timeout != time.Minute {
+		t.Errorf("got timeout %s, want 1m", openai.client.Timeout)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	r := newTestRegistry()
+	tests := []struct {
+		task    string
+		wantErr string
+	}{
+		{TaskClassify, "unknown AI provider profile: missing (known: [broken local remote])"},
+		{TaskEmbed, `unsupported AI provider type "telepathy" in profile broken`},
+		{"translate", "no AI provider configured for task translate"},
+	}
+	for _, tt := range tests {
+		if _, err := r.ForTask(tt.task); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("ForTask(%s): got %v, want %q", tt.task, err, tt.wantErr)
+		}
+	}
+	if names := r.ProfileNames(); !reflect.DeepEqual(names, []string{"broken", "local", "remote"}) {
+		t.Errorf("got profile names %v", names)
+	}
+}
+
+func TestRegisterAndNewEmbedder(t *testing.T) {
+	Register("fake", func(cfg config.ProviderConfig, client *http.Client) (Provider, error) {
+		return &fakeProvider{response: cfg.Model}, nil
+	})
+	t.Cleanup(func() { delete(factories, "fake") })
+
+	p, err := New(config.ProviderConfig{Name: "custom", Type: "fake", Model: "echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake, ok := p.(*fakeProvider); !ok || fake.response != "echo" {
+		t.Errorf("got %#v, want the registered fake provider", p)
+	}
+
+	_, err = NewEmbedder(config.ProviderConfig{Name: "custom", Type: "fake"})
+	if err == nil || !strings.Contains(err.Error(), "does not support embeddings") {
+		t.Errorf("got %v, want an error for a provider without embeddings", err)
+	}
+	if _, err := NewEmbedder(config.ProviderConfig{Name: "embed", Type: "ollama", Model: "nomic-embed-text"}); err != nil {
+		t.Errorf("Ollama embedder: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package ai
+
+import "fmt"
+
+// BuildSummaryPrompt creates the prompt asking for a summary of a README.
+func BuildSummaryPrompt(readme string) string {
+	return fmt.Sprintf("Please provide a concise summary of the following project's README, focusing on its purpose and key features. Output only the summary text:\n\n---\n\n%s", readme)
+}
@@ -0,0 +1,343 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"star-sage/internal/db"
+	"strings"
+)
+
+const (
+	// DefaultMaxTopics is the default size of the discovered vocabulary.
+	DefaultMaxTopics = 30
+	// DefaultMinTopicConfidence drops assignments the model is unsure about.
+	DefaultMinTopicConfidence = 0.5
+	// DefaultMinSeedRepos is how many repositories must share a GitHub topic
+	// before it is offered to the model as a seed label.
+	DefaultMinSeedRepos = 2
+
+	// maxSeedTopics bounds the number of seed labels put into prompts.
+	maxSeedTopics = 100
+)
+
+// Stages of DiscoverTopics reported through TopicOptions.OnProgress.
+const (
+	// TopicStageSeeds passes the number of seed labels as chunk and 0 chunks.
+	TopicStageSeeds = "seeds"
+	// TopicStagePropose starts proposing topics for a chunk.
+	TopicStagePropose = "propose"
+	// TopicStageConsolidate passes the number of candidate topics as chunk
+	// and 0 chunks.
+	TopicStageConsolidate = "consolidate"
+	// TopicStageAssign starts assigning the repositories of a chunk.
+	TopicStageAssign = "assign"
+)
+
+// TopicOptions configures DiscoverTopics. Zero values select the defaults.
+type TopicOptions struct {
+	MaxTopics     int
+	MinConfidence float64
+	MinSeedRepos  int
+	// OnProgress, if set, is called at each stage, for the chunk stages with
+	// the 1-based chunk and the number of chunks.
+	OnProgress func(stage string, chunk, chunks int)
+}
+
+// TopicDiscovery is the result of DiscoverTopics.
+type TopicDiscovery struct {
+	Topics      []db.Topic
+	Assignments []db.TopicAssignment
+}
+
+type topicProposal struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type topicAssignmentResponse struct {
+	ID     int64 `json:"id"`
+	Topics []struct {
+		Name       string  `json:"name"`
+		Confidence float64 `json:"confidence"`
+	} `json:"topics"`
+}
+
+// DiscoverTopics lets the AI derive a controlled vocabulary of topics from the
+// whole collection, using the repositories' own GitHub topics as seed labels,
+// and then assigns every repository to topics with a confidence. Repositories
+// whose GitHub topics match a vocabulary entry are assigned with confidence 1.
+func DiscoverTopics(ctx context.Context, provider Provider, repos []db.Repository, opts TopicOptions) (*TopicDiscovery, error) {
+	if opts.MaxTopics <= 0 {
+		opts.MaxTopics = DefaultMaxTopics
+	}
+	if opts.MinConfidence <= 0 {
+		opts.MinConfidence = DefaultMinTopicConfidence
+	}
+	if opts.MinSeedRepos <= 0 {
+		opts.MinSeedRepos = DefaultMinSeedRepos
+	}
+
+	chunks, err := chunkRepositories(repos)
+	if err != nil {
+		return nil, fmt.Errorf("could not chunk repositories: %w", err)
+	}
+	seeds := seedTopics(repos, opts.MinSeedRepos)
+	progress := func(stage string, chunk, chunks int) {
+		if opts.OnProgress != nil {
+			opts.OnProgress(stage, chunk, chunks)
+		}
+	}
+	progress(TopicStageSeeds, len(seeds), 0)
+
+	// Step 1: propose candidate topics for every chunk.
+	candidates := make(map[string]topicProposal)
+	counts := make(map[string]int)
+	for i, chunk := range chunks {
+		progress(TopicStagePropose, i+1, len(chunks))
+		resp, err := provider.Generate(ctx, buildTopicProposalPrompt(seeds, chunk, opts.MaxTopics))
+		if err != nil {
+			return nil, fmt.Errorf("ai generation failed for chunk %d: %w", i, err)
+		}
+		var proposals []topicProposal
+		if err := json.Unmarshal([]byte(trimCodeFence(resp)), &proposals); err != nil {
+			return nil, fmt.Errorf("could not parse topic proposals for chunk %d: %w (response was: %s)", i, err, resp)
+		}
+		for _, p := range proposals {
+			p.Name = normalizeTopic(p.Name)
+			if p.Name == "" {
+				continue
+			}
+			if _, ok := candidates[p.Name]; !ok {
+				candidates[p.Name] = p
+			}
+			counts[p.Name]++
+		}
+	}
+
+	// Step 2: merge the candidates of all chunks into one vocabulary.
+	vocabulary := make([]topicProposal, 0, len(candidates))
+	for _, p := range candidates {
+		vocabulary = append(vocabulary, p)
+	}
+	sort.Slice(vocabulary, func(i, j int) bool {
+		if counts[vocabulary[i].Name] != counts[vocabulary[j].Name] {
+			return counts[vocabulary[i].Name] > counts[vocabulary[j].Name]
+		}
+		return vocabulary[i].Name < vocabulary[j].Name
+	})
+	if len(chunks) > 1 || len(vocabulary) > opts.MaxTopics {
+		progress(TopicStageConsolidate, len(vocabulary), 0)
+		resp, err := provider.Generate(ctx, buildTopicConsolidationPrompt(vocabulary, counts, opts.MaxTopics))
+		if err != nil {
+			return nil, fmt.Errorf("ai generation failed while consolidating topics: %w", err)
+		}
+		var merged []topicProposal
+		if err := json.Unmarshal([]byte(trimCodeFence(resp)), &merged); err != nil {
+			return nil, fmt.Errorf("could not parse consolidated topics: %w (response was: %s)", err, resp)
+		}
+		vocabulary = vocabulary[:0]
+		for _, p := range merged {
+			if p.Name = normalizeTopic(p.Name); p.Name != "" {
+				vocabulary = append(vocabulary, p)
+			}
+		}
+	}
+	if len(vocabulary) > opts.MaxTopics {
+		vocabulary = vocabulary[:opts.MaxTopics]
+	}
+	if len(vocabulary) == 0 {
+		return nil, fmt.Errorf("the AI did not propose any topics")
+	}
+
+	result := &TopicDiscovery{}
+	known := make(map[string]bool, len(vocabulary))
+	for _, p := range vocabulary {
+		if known[p.Name] {
+			continue
+		}
+		known[p.Name] = true
+		result.Topics = append(result.Topics, db.Topic{Name: p.Name, Description: p.Description})
+	}
+
+	// GitHub topics that made it into the vocabulary are trusted labels.
+	for _, repo := range repos {
+		for _, t := range repo.Topics {
+			if name := normalizeTopic(t); known[name] {
+				result.Assignments = append(result.Assignments, db.TopicAssignment{
+					RepoID: repo.ID, Topic: name, Confidence: 1, Source: db.TopicSourceGitHub,
+				})
+			}
+		}
+	}
+
+	// Step 3: assign the repositories of every chunk to the vocabulary.
+	for i, chunk := range chunks {
+		progress(TopicStageAssign, i+1, len(chunks))
+		resp, err := provider.Generate(ctx, buildTopicAssignmentPrompt(result.Topics, chunk))
+		if err != nil {
+			return nil, fmt.Errorf("ai generation failed for chunk %d: %w", i, err)
+		}
+		var assigned []topicAssignmentResponse
+		if err := json.Unmarshal([]byte(trimCodeFence(resp)), &assigned); err != nil {
+			return nil, fmt.Errorf("could not parse topic assignments for chunk %d: %w (response was: %s)", i, err, resp)
+		}
+		inChunk := make(map[int64]bool, len(chunk))
+		for _, repo := range chunk {
+			inChunk[repo.ID] = true
+		}
+		for _, a := range assigned {
+			if !inChunk[a.ID] {
+				continue
+			}
+			for _, t := range a.Topics {
+				name := normalizeTopic(t.Name)
+				if !known[name] || t.Confidence < opts.MinConfidence {
+					continue
+				}
+				result.Assignments = append(result.Assignments, db.TopicAssignment{
+					RepoID: a.ID, Topic: name, Confidence: t.Confidence, Source: db.TopicSourceAI,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// seedTopics returns the GitHub topics shared by at least minRepos repositories, most used first.
+func seedTopics(repos []db.Repository, minRepos int) []string {
+	counts := make(map[string]int)
+	for _, repo := range repos {
+		for _, t := range repo.Topics {
+			if name := normalizeTopic(t); name != "" {
+				counts[name]++
+			}
+		}
+	}
+	var seeds []string
+	for name, n := range counts {
+		if n >= minRepos {
+			seeds = append(seeds, name)
+		}
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		if counts[seeds[i]] != counts[seeds[j]] {
+			return counts[seeds[i]] > counts[seeds[j]]
+		}
+		return seeds[i] < seeds[j]
+	})
+	if len(seeds) > maxSeedTopics {
+		seeds = seeds[:maxSeedTopics]
+	}
+	return seeds
+}
+
+// normalizeTopic converts a topic name to the lowercase, hyphenated form GitHub uses.
+func normalizeTopic(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var b strings.Builder
+	lastHyphen := true
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r > 127:
+			b.WriteRune(r)
+			lastHyphen = false
+		case !lastHyphen:
+			b.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// topicRepoInfos describes repositories compactly for topic prompts.
+func topicRepoInfos(repos []db.Repository) ([]byte, error) {
+	type repoInfo struct {
+		ID          int64    `json:"id"`
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Summary     string   `json:"summary,omitempty"`
+		Topics      []string `json:"topics,omitempty"`
+	}
+	infos := make([]repoInfo, 0, len(repos))
+	for _, r := range repos {
+		infos = append(infos, repoInfo{
+			ID:          r.ID,
+			Name:        r.FullName,
+			Description: r.Description,
+			Summary:     r.Summary,
+			Topics:      r.Topics,
+		})
+	}
+	return json.Marshal(infos)
+}
+
+func buildTopicProposalPrompt(seeds []string, repos []db.Repository, maxTopics int) string {
+	jsonData, err := topicRepoInfos(repos)
+	if err != nil {
+		jsonData = []byte("[]")
+	}
+
+	promptTemplate := `你是一个软件项目的分类专家。
+下面是用户收藏的一批 GitHub 项目（JSON 格式），以及这些项目作者常用的 GitHub topics（作为参考标签）。
+请为这批项目提出最多 %d 个主题，用于组织整个收藏。主题应该是有区分度的技术领域或用途，
+例如 "web-framework"、"data-visualization"、"cli"，不要使用过于宽泛（如 "software"）或过于具体（只适用于一个项目）的主题。
+参考标签合适时请优先使用它们的名称。
+
+参考标签: %s
+
+项目列表如下:
+%s
+
+请只返回一个 JSON 数组，每个元素包含 name（小写英文，单词之间用连字符连接）和 description（一句中文说明）。
+例如: [{"name": "web-framework", "description": "用于构建 Web 应用和 API 的框架"}]
+确保你的回答中除了这个 JSON 数组外，不包含任何其他文字、解释或代码块标记。`
+
+	return fmt.Sprintf(promptTemplate, maxTopics, strings.Join(seeds, ", "), string(jsonData))
+}
+
+func buildTopicConsolidationPrompt(candidates []topicProposal, counts map[string]int, maxTopics int) string {
+	var b strings.Builder
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "- %s (%d): %s\n", c.Name, counts[c.Name], c.Description)
+	}
+
+	promptTemplate := `你是一个软件项目的分类专家。
+下面是为一个 GitHub 收藏的不同部分分别提出的候选主题，括号中是提出该主题的次数。
+请将它们合并为一个最多 %d 个主题的统一词表：合并同义或高度重叠的主题，去掉过于宽泛或过于具体的主题，优先保留出现次数多的主题。
+
+候选主题:
+%s
+请只返回一个 JSON 数组，每个元素包含 name（小写英文，单词之间用连字符连接）和 description（一句中文说明）。
+确保你的回答中除了这个 JSON 数组外，不包含任何其他文字、解释或代码块标记。`
+
+	return fmt.Sprintf(promptTemplate, maxTopics, b.String())
+}
+
+func buildTopicAssignmentPrompt(topics []db.Topic, repos []db.Repository) string {
+	var b strings.Builder
+	for _, t := range topics {
+		fmt.Fprintf(&b, "- %s: %s\n", t.Name, t.Description)
+	}
+	jsonData, err := topicRepoInfos(repos)
+	if err != nil {
+		jsonData = []byte("[]")
+	}
+
+	promptTemplate := `你是一个精准的软件项目分类助手。
+请根据下面的主题词表，为每个项目选择所属的主题（可以是零个、一个或多个），并给出 0 到 1 之间的置信度。
+只能使用词表中的主题。
+
+主题词表:
+%s
+项目列表如下:
+%s
+
+请只返回一个 JSON 数组，每个元素包含项目的 id 和 topics 数组。
+例如: [{"id": 12345, "topics": [{"name": "web-framework", "confidence": 0.9}]}]
+确保你的回答中除了这个 JSON 数组外，不包含任何其他文字、解释或代码块标记。`
+
+	return fmt.Sprintf(promptTemplate, b.String(), string(jsonData))
+}
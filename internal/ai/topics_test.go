@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"star-sage/internal/db"
+	"strings"
+	"testing"
+)
+
+// scriptedProvider answers prompts with its responses in order and records the prompts.
+type scriptedProvider struct {
+	responses []string
+	prompts   []string
+}
+
+func (p *scriptedProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+func TestDiscoverTopics(t *testing.T) {
+	repos := []db.Repository{
+		{ID: 1, FullName: "owner/one", Topics: []string{"CLI", "go"}},
+		{ID: 2, FullName: "owner/two", Topics: []string{"cli"}},
+		{ID: 3, FullName: "owner/three"},
+	}
+	provider := &scriptedProvider{responses: []string{
+		`[{"name": "Command Line", "description": "x"}, {"name": "cli", "description": "Terminal tools"}, {"name": "web"}, {"name": " "}]`,
+		// More candidates than MaxTopics are consolidated.
+		"```json\n" + `[{"name": "CLI", "description": "Terminal tools"}, {"name": "web", "description": "Web"}, {"name": "extra"}]` + "\n```",
+		`[
+			{"id": 1, "topics": [{"name": "cli", "confidence": 0.9}]},
+			{"id": 3, "topics": [{"name": "Web", "confidence": 0.7}, {"name": "cli", "confidence": 0.2}, {"name": "unknown", "confidence": 1}]},
+			{"id": 99, "topics": [{"name": "web", "confidence": 1}]}
+		]`,
+	}}
+
+	var stages []string
+	got, err := DiscoverTopics(context.Background(), provider, repos, TopicOptions{
+		MaxTopics: 2,
+		OnProgress: func(stage string, chunk, chunks int) {
+			stages = append(stages, fmt.Sprintf("%s %d/%d", stage, chunk, chunks))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTopics := []db.Topic{{Name: "cli", Description: "Terminal tools"}, {Name: "web", Description: "Web"}}
+	if !reflect.DeepEqual(got.Topics, wantTopics) {
+		t.Errorf("got topics %+v, want %+v", got.Topics, wantTopics)
+	}
+	// GitHub topics in the vocabulary come first with full confidence; AI
+	// assignments below the minimum confidence, to unknown topics or to
+	// repositories outside the chunk are dropped.
+	wantAssignments := []db.TopicAssignment{
+		{RepoID: 1, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+		{RepoID: 2, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+		{RepoID: 1, Topic: "cli", Confidence: 0.9, Source: db.TopicSourceAI},
+		{RepoID: 3, Topic: "web", Confidence: 0.7, Source: db.TopicSourceAI},
+	}
+	if !reflect.DeepEqual(got.Assignments, wantAssignments) {
+		t.Errorf("got assignments %+v, want %+v", got.Assignments, wantAssignments)
+	}
+
+	wantStages := []string{"seeds 1/0", "propose 1/1", "consolidate 3/0", "assign 1/1"}
+	if !reflect.DeepEqual(stages, wantStages) {
+		t.Errorf("got stages %q, want %q", stages, wantStages)
+	}
+
+	if len(provider.prompts) != 3 {
+		t.Fatalf("got %d prompts, want 3", len(provider.prompts))
+	}
+	// Only GitHub topics shared by two repositories are seed labels.
+	if !strings.Contains(provider.prompts[0], "参考标签: cli\n") {
+		t.Errorf("proposal prompt does not have cli as the only seed:\n%s", provider.prompts[0])
+	}
+	if !strings.Contains(provider.prompts[1], "- command-line (1): x\n") {
+		t.Errorf("consolidation prompt does not list the normalized candidates:\n%s", provider.prompts[1])
+	}
+}
+
+func TestDiscoverTopicsWithoutProposals(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{"[]"}}
+	_, err := DiscoverTopics(context.Background(), provider, []db.Repository{{ID: 1, FullName: "owner/one"}}, TopicOptions{})
+	if err == nil || !strings.Contains(err.Error(), "did not propose any topics") {
+		t.Errorf("got %v, want an error for an empty vocabulary", err)
+	}
+}
+
+func TestNormalizeTopic(t *testing.T) {
+	for name, want := range map[string]string{
+		"Web Framework":       "web-framework",
+		"  machine_learning ": "machine-learning",
+		"C++":                 "c",
+		"--data--viz--":       "data-viz",
+		"数据库":                 "数据库",
+		"!!!":                 "",
+	} {
+		if got := normalizeTopic(name); got != want {
+			t.Errorf("normalizeTopic(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
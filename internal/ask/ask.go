@@ -0,0 +1,159 @@
+package ask
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"star-sage/internal/ai"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+	"star-sage/internal/search"
+)
+
+const (
+	// DefaultLimit is the number of repositories given to the model as context.
+	DefaultLimit = 8
+
+	// maxReadmeExcerptRunes limits how much of each README goes into the prompt.
+	maxReadmeExcerptRunes = 1500
+)
+
+// Options configures how a question is answered.
+type Options struct {
+	// Limit is the number of candidate repositories put into the prompt.
+	Limit int
+	// Provider generates the answer.
+	Provider ai.Provider
+	// Embedding is the profile used for the semantic part of retrieval.
+	Embedding config.ProviderConfig
+	// OnSources is called with the retrieved repositories before generation starts.
+	OnSources func([]Source)
+	// OnToken receives the answer as it is generated, if the provider can stream.
+	OnToken func(string)
+}
+
+// Source is a repository given to the model as context.
+type Source struct {
+	ID          int64
+	FullName    string
+	URL         string
+	Description string
+	Summary     string
+	MatchedBy   []string
+}
+
+// Answer is the response to a question.
+type Answer struct {
+	Question string
+	Answer   string
+	// Sources are all repositories given to the model.
+	Sources []Source
+	// Citations are the full names of the sources the answer refers to.
+	Citations []string
+	// Warning reports degraded retrieval, e.g. when embeddings are unavailable.
+	Warning string `json:",omitempty"`
+}
+
+// Ask retrieves the starred repositories most relevant to question and has
+// the provider answer it based on them, citing the repositories it used.
+func Ask(ctx context.Context, store db.Store, question string, opts Options) (*Answer, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit
+	}
+
+	resp, err := search.Search(ctx, store, question, search.Options{
+		Mode:      search.ModeHybrid,
+		Limit:     opts.Limit,
+		MatchAny:  true,
+		Embedding: opts.Embedding,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve repositories: %w", err)
+	}
+
+	answer := &Answer{Question: question, Warning: resp.Warning}
+	if len(resp.Results) == 0 {
+		return answer, fmt.Errorf("no starred repositories match the question")
+	}
+
+	readmes := make([]string, len(resp.Results))
+	for i, r := range resp.Results {
+		answer.Sources = append(answer.Sources, Source{
+			ID:          r.ID,
+			FullName:    r.FullName,
+			URL:         r.URL,
+			Description: r.Description,
+			Summary:     r.Summary,
+			MatchedBy:   r.MatchedBy,
+		})
+		if readmes[i], err = store.GetRepoReadme(r.ID); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OnSources != nil {
+		opts.OnSources(answer.Sources)
+	}
+
+	prompt := buildPrompt(question, answer.Sources, readmes)
+	if streamer, ok := opts.Provider.(ai.StreamingProvider); ok && opts.OnToken != nil {
+		answer.Answer, err = streamer.GenerateStream(ctx, prompt, opts.OnToken)
+	} else {
+		answer.Answer, err = opts.Provider.Generate(ctx, prompt)
+		if err == nil && opts.OnToken != nil {
+			opts.OnToken(answer.Answer)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ai generation failed: %w", err)
+	}
+
+	answer.Citations = citations(answer.Answer, answer.Sources)
+	return answer, nil
+}
+
+// buildPrompt creates a prompt that restricts the model to the given sources.
+func buildPrompt(question string, sources []Source, readmes []string) string {
+	var b strings.Builder
+	for i, s := range sources {
+		fmt.Fprintf(&b, "### [%s]\n", s.FullName)
+		if s.Description != "" {
+			fmt.Fprintf(&b, "描述: %s\n", s.Description)
+		}
+		if s.Summary != "" {
+			fmt.Fprintf(&b, "摘要: %s\n", s.Summary)
+		}
+		if readme := []rune(strings.TrimSpace(readmes[i])); len(readme) > 0 {
+			if len(readme) > maxReadmeExcerptRunes {
+				readme = append(readme[:maxReadmeExcerptRunes], []rune("...")...)
+			}
+			fmt.Fprintf(&b, "README 节选:\n%s\n", string(readme))
+		}
+		b.WriteString("\n")
+	}
+
+	promptTemplate := `你是一个帮助用户了解自己收藏的 GitHub 项目的助手。
+下面是从用户收藏中检索到的项目资料，每个项目以 "### [owner/repo]" 开头。
+
+%s
+请只根据上面的资料回答用户的问题。
+引用项目时，请用方括号写出它的完整名称，例如 [owner/repo]。
+如果资料不足以回答问题，请直接说明，不要编造项目。
+请使用与问题相同的语言回答。
+
+问题: %s`
+
+	return fmt.Sprintf(promptTemplate, b.String(), question)
+}
+
+// citations returns the full names of the sources mentioned in answer, in source order.
+func citations(answer string, sources []Source) []string {
+	lower := strings.ToLower(answer)
+	var cited []string
+	for _, s := range sources {
+		if strings.Contains(lower, strings.ToLower(s.FullName)) {
+			cited = append(cited, s.FullName)
+		}
+	}
+	return cited
+}
@@ -0,0 +1,149 @@
+package ask
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"strings"
+	"testing"
+)
+
+// fakeProvider answers every prompt with the same response and remembers the
+// last prompt.
+type fakeProvider struct {
+	response string
+	err      error
+	prompt   string
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.prompt = prompt
+	return p.response, p.err
+}
+
+// streamingProvider sends its response word by word.
+type streamingProvider struct {
+	fakeProvider
+}
+
+func (p *streamingProvider) GenerateStream(ctx context.Context, prompt string, onToken func(string)) (string, error) {
+	p.prompt = prompt
+	for _, word := range strings.SplitAfter(p.response, " ") {
+		onToken(word)
+	}
+	return p.response, nil
+}
+
+// addTestRepos adds repositories about JWT, OAuth and image editing.
+func addTestRepos(store dbtest.Store) {
+	store.AddRepository(db.Repository{
+		FullName:      "owner/jwt-go",
+		Description:   "JWT auth library for Go",
+		Summary:       "Signs and verifies JSON web tokens.",
+		ReadmeContent: "  " + strings.Repeat("a", maxReadmeExcerptRunes) + "TAIL",
+	})
+	store.AddRepository(db.Repository{FullName: "owner/oauth", Description: "OAuth client for Go"})
+	store.AddRepository(db.Repository{FullName: "owner/paint", Description: "Image editor"})
+}
+
+func TestAsk(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		addTestRepos(store)
+		provider := &fakeProvider{response: "Use [Owner/JWT-Go]; it handles tokens."}
+		var sources []Source
+		var tokens []string
+		opts := Options{
+			Provider: provider,
+			OnSources: func(s []Source) {
+				if provider.prompt != "" {
+					t.Error("sources were reported after generation started")
+				}
+				sources = s
+			},
+			OnToken: func(token string) { tokens = append(tokens, token) },
+		}
+		question := "Which Go library handles JWT auth?"
+		answer, err := Ask(context.Background(), store, question, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, s := range answer.Sources {
+			names = append(names, s.FullName)
+		}
+		if want := []string{"owner/jwt-go", "owner/oauth"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("got sources %v, want %v", names, want)
+		}
+		if !reflect.DeepEqual(sources, answer.Sources) {
+			t.Errorf("OnSources got %+v, want %+v", sources, answer.Sources)
+		}
+		if answer.Question != question || answer.Answer != provider.response || answer.Warning != "" {
+			t.Errorf("got answer %+v", answer)
+		}
+		// Citations match case-insensitively, with the names of the sources.
+		if want := []string{"owner/jwt-go"}; !reflect.DeepEqual(answer.Citations, want) {
+			t.Errorf("got citations %v, want %v", answer.Citations, want)
+		}
+		// A provider that cannot stream sends the whole answer at once.
+		if want := []string{provider.response}; !reflect.DeepEqual(tokens, want) {
+			t.Errorf("got tokens %q, want %q", tokens, want)
+		}
+
+		prompt := provider.prompt
+		for _, want := range []string{
+			"### [owner/jwt-go]\n描述: JWT auth library for Go\n摘要: Signs and verifies JSON web tokens.\nREADME 节选:\n" +
+				strings.Repeat("a", maxReadmeExcerptRunes) + "...\n",
+			"### [owner/oauth]\n描述: OAuth client for Go\n\n",
+			"问题: " + question,
+		} {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("prompt does not contain %q:\n%s", want, prompt)
+			}
+		}
+		for _, unwanted := range []string{"TAIL", "owner/paint"} {
+			if strings.Contains(prompt, unwanted) {
+				t.Errorf("prompt contains %q:\n%s", unwanted, prompt)
+			}
+		}
+	})
+}
+
+func TestAskStreams(t *testing.T) {
+	store := dbtest.NewMemory()
+	addTestRepos(store)
+	provider := &streamingProvider{fakeProvider{response: "See owner/oauth and owner/jwt-go."}}
+	var tokens []string
+	answer, err := Ask(context.Background(), store, "go auth", Options{
+		Provider: provider,
+		OnToken:  func(token string) { tokens = append(tokens, token) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"See ", "owner/oauth ", "and ", "owner/jwt-go."}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got tokens %q, want %q", tokens, want)
+	}
+	// Citations are in source order, not in the order of the answer.
+	if want := []string{"owner/jwt-go", "owner/oauth"}; !reflect.DeepEqual(answer.Citations, want) {
+		t.Errorf("got citations %v, want %v", answer.Citations, want)
+	}
+}
+
+func TestAskErrors(t *testing.T) {
+	store := dbtest.NewMemory()
+	addTestRepos(store)
+
+	answer, err := Ask(context.Background(), store, "rust compiler", Options{Provider: &fakeProvider{}})
+	if err == nil || answer == nil || len(answer.Sources) != 0 {
+		t.Errorf("got %+v, %v, want an error for a question without matches", answer, err)
+	}
+
+	errDown := errors.New("provider down")
+	_, err = Ask(context.Background(), store, "go", Options{Provider: &fakeProvider{err: errDown}})
+	if !errors.Is(err, errDown) || !strings.Contains(err.Error(), "ai generation failed") {
+		t.Errorf("got %v, want the provider error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Provider profiles that exist even when the config file has no ai section.
+// A profile with the same name in the config file replaces them.
+const (
+	DefaultAIProfile    = "ollama"
+	DefaultEmbedProfile = "ollama-embed"
+)
+
+// AIConfig is the ai section of the config file.
+type AIConfig struct {
+	// Providers holds named provider profiles.
+	Providers map[string]ProviderConfig `mapstructure:"providers"`
+	// Defaults maps a task (summarize, classify, embed, chat) to a profile name.
+	Defaults map[string]string `mapstructure:"defaults"`
+}
+
+// ProviderConfig is a provider profile, configured under ai.providers.<name>.
+type ProviderConfig struct {
+	Name string `mapstructure:"-"`
+	// Type is the provider implementation, e.g. ollama or openai.
+	Type    string `mapstructure:"type"`
+	BaseURL string `mapstructure:"base_url"`
+	Model   string `mapstructure:"model"`
+	// APIKeyEnv names the environment variable holding the API key.
+	APIKeyEnv string `mapstructure:"api_key_env"`
+	// APIKey overrides APIKeyEnv; it is never read from the config file.
+	APIKey string `mapstructure:"-"`
+	// Timeout bounds a single request, e.g. "2m". Zero means no timeout.
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Temperature *float64      `mapstructure:"temperature"`
+	MaxTokens   int           `mapstructure:"max_tokens"`
+}
+
+// ResolveAPIKey returns the API key of the profile, reading it from the
+// environment unless it was set explicitly.
+func (p ProviderConfig) ResolveAPIKey() string {
+	if p.APIKey != "" || p.APIKeyEnv == "" {
+		return p.APIKey
+	}
+	return os.Getenv(p.APIKeyEnv)
+}
+
+// builtinProviders keeps the CLI usable with a local Ollama and no configuration.
+func builtinProviders() map[string]ProviderConfig {
+	return map[string]ProviderConfig{
+		DefaultAIProfile:    {Type: "ollama", Model: "llama3:8b"},
+		DefaultEmbedProfile: {Type: "ollama", Model: "nomic-embed-text"},
+		"openai":            {Type: "openai", Model: "gpt-4o-mini", APIKeyEnv: "OPENAI_API_KEY"},
+	}
+}
+
+// GetAIConfig returns the ai section of the config file merged with the
+// built-in profiles. Tasks without a configured default use the built-in ones.
+func GetAIConfig() (AIConfig, error) {
+	var cfg AIConfig
+	if err := viper.UnmarshalKey("ai", &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid ai configuration: %w", err)
+	}
+
+	providers := builtinProviders()
+	for name, p := range cfg.Providers {
+		providers[name] = p
+	}
+	for name, p := range providers {
+		p.Name = name
+		providers[name] = p
+	}
+	cfg.Providers = providers
+
+	if cfg.Defaults == nil {
+		cfg.Defaults = make(map[string]string)
+	}
+	for _, task := range []string{"summarize", "classify", "chat"} {
+		if cfg.Defaults[task] == "" {
+			cfg.Defaults[task] = DefaultAIProfile
+		}
+	}
+	if cfg.Defaults["embed"] == "" {
+		cfg.Defaults["embed"] = DefaultEmbedProfile
+	}
+	return cfg, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// readConfig makes viper read the YAML config until the test ends.
+func readConfig(t *testing.T, yaml string) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader(yaml)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAIConfig(t *testing.T) {
+	readConfig(t, `
+ai:
+  providers:
+    ollama:
+      type: ollama
+      base_url: http://gpu-box:11434
+      model: qwen2.5:14b
+    work:
+      type: openai
+      base_url: https://llm.example.com/v1
+      model: gpt-4o
+      api_key_env: WORK_API_KEY
+      timeout: 2m
+      temperature: 0.2
+      max_tokens: 500
+  defaults:
+    chat: work
+`)
+	cfg, err := GetAIConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	temperature := 0.2
+	want := map[string]ProviderConfig{
+		// A profile in the config file replaces the built-in one of that name.
+		"ollama":       {Name: "ollama", Type: "ollama", BaseURL: "http://gpu-box:11434", Model: "qwen2.5:14b"},
+		"ollama-embed": {Name: "ollama-embed", Type: "ollama", Model: "nomic-embed-text"},
+		"openai":       {Name: "openai", Type: "openai", Model: "gpt-4o-mini", APIKeyEnv: "OPENAI_API_KEY"},
+		"work": {Name: "work", Type: "openai", BaseURL: "https://llm.example.com/v1", Model: "gpt-4o",
+			APIKeyEnv: "WORK_API_KEY", Timeout: 2 * time.Minute, Temperature: &temperature, MaxTokens: 500},
+	}
+	if !reflect.DeepEqual(cfg.Providers, want) {
+		t.Errorf("got providers\n%+v\nwant\n%+v", cfg.Providers, want)
+	}
+	wantDefaults := map[string]string{"summarize": "ollama", "classify": "ollama", "chat": "work", "embed": "ollama-embed"}
+	if !reflect.DeepEqual(cfg.Defaults, wantDefaults) {
+		t.Errorf("got defaults %v, want %v", cfg.Defaults, wantDefaults)
+	}
+}
+
+func TestGetAIConfigWithoutSection(t *testing.T) {
+	readConfig(t, "github_token: secret\n")
+	cfg, err := GetAIConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Providers) != 3 || cfg.Providers[DefaultAIProfile].Model != "llama3:8b" {
+		t.Errorf("got providers %+v, want the built-in ones", cfg.Providers)
+	}
+	if cfg.Defaults["summarize"] != DefaultAIProfile || cfg.Defaults["embed"] != DefaultEmbedProfile {
+		t.Errorf("got defaults %v", cfg.Defaults)
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "from-env")
+	tests := []struct {
+		profile ProviderConfig
+		want    string
+	}{
+		{ProviderConfig{}, ""},
+		{ProviderConfig{APIKeyEnv: "TEST_API_KEY"}, "from-env"},
+		{ProviderConfig{APIKeyEnv: "TEST_API_KEY_UNSET"}, ""},
+		{ProviderConfig{APIKeyEnv: "TEST_API_KEY", APIKey: "explicit"}, "explicit"},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.ResolveAPIKey(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,19 +15,69 @@ const (
 	appName    = "starsage"
 )
 
+// DefaultSourceName is the built-in github.com source.
+const DefaultSourceName = "github"
+
 // Config holds the application's configuration
 type Config struct {
-	GitHubToken string `mapstructure:"github_token"`
+	GitHubToken string                  `mapstructure:"github_token"`
+	Sources     map[string]SourceConfig `mapstructure:"sources"`
+}
+
+// SourceConfig describes a place stars are synced from, configured under
+// sources.<name> in the config file.
+type SourceConfig struct {
+	Name string `mapstructure:"-"`
+	// Type is one of github, gitea, forgejo or gitlab.
+	Type string `mapstructure:"type"`
+	// BaseURL is the web root of the instance, e.g. https://git.example.com.
+	BaseURL string `mapstructure:"base_url"`
+	Token   string `mapstructure:"token"`
+	// ClientID is the OAuth App used for the GitHub device flow.
+	ClientID string `mapstructure:"client_id"`
+}
+
+// ServerConfig holds the settings of 'starsage serve', configured under
+// server in the config file.
+type ServerConfig struct {
+	// Bind is the address the server listens on, e.g. 0.0.0.0 to accept
+	// connections from other machines.
+	Bind string `mapstructure:"bind"`
+	// ReadTimeout, WriteTimeout and IdleTimeout override the HTTP timeouts.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	// Auth requires an API token or a login for the API.
+	Auth bool `mapstructure:"auth"`
+	// SyncInterval makes the server sync SyncSource in the background this
+	// often, e.g. "6h". Zero disables the scheduler.
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+	// SyncSource is the name of the source to sync; empty means github.
+	SyncSource string `mapstructure:"sync_source"`
 }
 
-// InitConfig initializes viper to read from the config file.
+// DefaultSnapshotKeep is the number of automatic snapshots kept by default.
+const DefaultSnapshotKeep = 10
+
+// SnapshotConfig sets how long the automatic snapshots taken before
+// destructive operations are kept, configured under snapshots in the config
+// file.
+type SnapshotConfig struct {
+	// Keep is the number of snapshots kept; the oldest are deleted first.
+	// Zero means DefaultSnapshotKeep.
+	Keep int `mapstructure:"keep"`
+	// MaxAge deletes snapshots older than this, e.g. "720h". Zero keeps
+	// snapshots regardless of their age.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// InitConfig initializes viper to read from the config file of the selected
+// profile, creating it if needed.
 func InitConfig() error {
-	home, err := os.UserHomeDir()
+	configPath, err := Dir()
 	if err != nil {
-		return fmt.Errorf("could not get user home directory: %w", err)
+		return err
 	}
-
-	configPath := filepath.Join(home, ".config", appName)
 	viper.AddConfigPath(configPath)
 	viper.SetConfigName(configName)
 	viper.SetConfigType(configType)
@@ -59,3 +110,79 @@ func SaveToken(token string) error {
 func GetToken() string {
 	return viper.GetString("github_token")
 }
+
+// GetProxy returns the HTTP proxy stored under proxy, or "" if none is set.
+func GetProxy() string {
+	return viper.GetString("proxy")
+}
+
+// GetServerConfig returns the server settings.
+func GetServerConfig() (ServerConfig, error) {
+	var cfg ServerConfig
+	if err := viper.UnmarshalKey("server", &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid server configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// GetSnapshotConfig returns the snapshot retention settings.
+func GetSnapshotConfig() (SnapshotConfig, error) {
+	var cfg SnapshotConfig
+	if err := viper.UnmarshalKey("snapshots", &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid snapshots configuration: %w", err)
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = DefaultSnapshotKeep
+	}
+	return cfg, nil
+}
+
+// GetSource returns the source configured under name. The default "github"
+// source exists even when it is not configured and uses github_token.
+func GetSource(name string) (SourceConfig, error) {
+	if name == "" {
+		name = DefaultSourceName
+	}
+	key := "sources." + name
+
+	var cfg SourceConfig
+	if viper.IsSet(key) {
+		if err := viper.UnmarshalKey(key, &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid configuration for source %s: %w", name, err)
+		}
+	} else if name != DefaultSourceName {
+		return cfg, fmt.Errorf("source %s is not configured (add it under sources.%s in the config file)", name, name)
+	}
+
+	cfg.Name = name
+	if name == DefaultSourceName {
+		if cfg.Type == "" {
+			cfg.Type = "github"
+		}
+		if cfg.Token == "" {
+			cfg.Token = GetToken()
+		}
+	}
+	return cfg, nil
+}
+
+// SourceNames returns the names of all configured sources, including the default one.
+func SourceNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("sources") {
+		if name != DefaultSourceName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultSourceName}, names...)
+}
+
+// SaveSourceToken saves the access token of the named source to the config file.
+func SaveSourceToken(name, token string) error {
+	if name == "" || name == DefaultSourceName {
+		return SaveToken(token)
+	}
+	viper.Set("sources."+name+".token", token)
+	return viper.WriteConfig()
+}
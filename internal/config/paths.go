@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const dbFileName = "stars.db"
+
+// Location settings given on the command line. They must be set before
+// InitConfig.
+var (
+	profile      string
+	dbPathOption string
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SetProfile selects a named profile. A profile has its own config file,
+// and with it its own tokens and AI settings, and its own database. The
+// empty name selects the default profile.
+func SetProfile(name string) error {
+	if name != "" && !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, - and _", name)
+	}
+	profile = name
+	return nil
+}
+
+// Profile returns the name of the selected profile, or "" for the default one.
+func Profile() string {
+	return profile
+}
+
+// SetDBPath makes DBPath return path instead of the profile's database.
+func SetDBPath(path string) {
+	dbPathOption = path
+}
+
+// Dir returns the directory of the config file of the selected profile:
+// $STARSAGE_HOME if set, else $XDG_CONFIG_HOME/starsage or
+// ~/.config/starsage. Named profiles live in profiles/<name> below it.
+func Dir() (string, error) {
+	dir, err := configRoot()
+	if err != nil {
+		return "", err
+	}
+	return profileDir(dir), nil
+}
+
+// DataDir returns the directory of the database of the selected profile:
+// $STARSAGE_HOME if set, else $XDG_DATA_HOME/starsage if XDG_DATA_HOME is
+// set, else the config directory, where earlier versions kept it.
+func DataDir() (string, error) {
+	if home := os.Getenv("STARSAGE_HOME"); home != "" {
+		return profileDir(home), nil
+	}
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return profileDir(filepath.Join(data, appName)), nil
+	}
+	return Dir()
+}
+
+// DBPath returns the location of the database file: the one given with
+// SetDBPath, else stars.db in DataDir. A database already kept in the config
+// directory is still used until one exists in $XDG_DATA_HOME.
+func DBPath() (string, error) {
+	if dbPathOption != "" {
+		return filepath.Abs(dbPathOption)
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, dbFileName)
+	if configDir, err := Dir(); err == nil && configDir != dataDir {
+		legacy := filepath.Join(configDir, dbFileName)
+		if !fileExists(path) && fileExists(legacy) {
+			return legacy, nil
+		}
+	}
+	return path, nil
+}
+
+// configRoot returns the config directory of the default profile.
+func configRoot() (string, error) {
+	if home := os.Getenv("STARSAGE_HOME"); home != "" {
+		return home, nil
+	}
+	if config := os.Getenv("XDG_CONFIG_HOME"); config != "" {
+		return filepath.Join(config, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", appName), nil
+}
+
+// profileDir returns the directory of the selected profile below root.
+func profileDir(root string) string {
+	if profile == "" {
+		return root
+	}
+	return filepath.Join(root, "profiles", profile)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	relativeDB, err := filepath.Abs("custom.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Paths in env, files and the wanted paths are relative to a temporary
+	// directory; HOME is always set to it. The --db path is relative to the
+	// working directory.
+	tests := []struct {
+		name     string
+		env      map[string]string
+		profile  string
+		dbPath   string
+		files    []string
+		wantDir  string
+		wantData string
+		wantDB   string
+	}{
+		{
+			name:     "defaults",
+			wantDir:  ".config/starsage",
+			wantData: ".config/starsage",
+			wantDB:   ".config/starsage/stars.db",
+		},
+		{
+			name:     "XDG_CONFIG_HOME",
+			env:      map[string]string{"XDG_CONFIG_HOME": "config"},
+			wantDir:  "config/starsage",
+			wantData: "config/starsage",
+			wantDB:   "config/starsage/stars.db",
+		},
+		{
+			name:     "XDG_DATA_HOME",
+			env:      map[string]string{"XDG_CONFIG_HOME": "config", "XDG_DATA_HOME": "data"},
+			wantDir:  "config/starsage",
+			wantData: "data/starsage",
+			wantDB:   "data/starsage/stars.db",
+		},
+		{
+			name:     "database left in the config directory",
+			env:      map[string]string{"XDG_DATA_HOME": "data"},
+			files:    []string{".config/starsage/stars.db"},
+			wantDir:  ".config/starsage",
+			wantData: "data/starsage",
+			wantDB:   ".config/starsage/stars.db",
+		},
+		{
+			name:     "database moved to XDG_DATA_HOME",
+			env:      map[string]string{"XDG_DATA_HOME": "data"},
+			files:    []string{".config/starsage/stars.db", "data/starsage/stars.db"},
+			wantDir:  ".config/starsage",
+			wantData: "data/starsage",
+			wantDB:   "data/starsage/stars.db",
+		},
+		{
+			name:     "STARSAGE_HOME overrides XDG",
+			env:      map[string]string{"STARSAGE_HOME": "home", "XDG_CONFIG_HOME": "config", "XDG_DATA_HOME": "data"},
+			files:    []string{"config/starsage/stars.db"},
+			wantDir:  "home",
+			wantData: "home",
+			wantDB:   "home/stars.db",
+		},
+		{
+			name:     "profile in STARSAGE_HOME",
+			env:      map[string]string{"STARSAGE_HOME": "home"},
+			profile:  "work",
+			wantDir:  "home/profiles/work",
+			wantData: "home/profiles/work",
+			wantDB:   "home/profiles/work/stars.db",
+		},
+		{
+			name:     "profile with XDG",
+			env:      map[string]string{"XDG_CONFIG_HOME": "config", "XDG_DATA_HOME": "data"},
+			profile:  "work",
+			files:    []string{"config/starsage/stars.db"},
+			wantDir:  "config/starsage/profiles/work",
+			wantData: "data/starsage/profiles/work",
+			wantDB:   "data/starsage/profiles/work/stars.db",
+		},
+		{
+			name:     "--db overrides everything",
+			env:      map[string]string{"STARSAGE_HOME": "home"},
+			profile:  "work",
+			dbPath:   "custom.db",
+			files:    []string{"home/profiles/work/stars.db"},
+			wantDir:  "home/profiles/work",
+			wantData: "home/profiles/work",
+			wantDB:   relativeDB,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			abs := func(path string) string {
+				if filepath.IsAbs(path) {
+					return path
+				}
+				return filepath.Join(root, path)
+			}
+			t.Setenv("HOME", root)
+			for _, name := range []string{"STARSAGE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME"} {
+				t.Setenv(name, "")
+				if value, ok := tt.env[name]; ok {
+					t.Setenv(name, abs(value))
+				}
+			}
+			for _, file := range tt.files {
+				if err := os.MkdirAll(filepath.Dir(abs(file)), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(abs(file), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := SetProfile(tt.profile); err != nil {
+				t.Fatal(err)
+			}
+			SetDBPath(tt.dbPath)
+			t.Cleanup(func() {
+				SetProfile("")
+				SetDBPath("")
+			})
+
+			for _, check := range []struct {
+				what string
+				get  func() (string, error)
+				want string
+			}{
+				{"Dir", Dir, tt.wantDir},
+				{"DataDir", DataDir, tt.wantData},
+				{"DBPath", DBPath, tt.wantDB},
+			} {
+				got, err := check.get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := abs(check.want); got != want {
+					t.Errorf("%s() = %s, want %s", check.what, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	t.Cleanup(func() { SetProfile("") })
+	for _, name := range []string{"", "work", "my_profile-2"} {
+		if err := SetProfile(name); err != nil {
+			t.Errorf("SetProfile(%q) = %v", name, err)
+		}
+		if Profile() != name {
+			t.Errorf("Profile() = %q, want %q", Profile(), name)
+		}
+	}
+	for _, name := range []string{"../work", "a/b", "with space", "."} {
+		if err := SetProfile(name); err == nil {
+			t.Errorf("SetProfile(%q) accepted an invalid name", name)
+		}
+	}
+}
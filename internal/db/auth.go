@@ -0,0 +1,184 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes of API tokens and the sessions created with them.
+const (
+	// ScopeRead allows reading the library but no changes.
+	ScopeRead = "read"
+	// ScopeWrite allows everything, including changes and AI jobs.
+	ScopeWrite = "write"
+)
+
+// ErrTokenExists is returned when a token is created with the name of another token.
+var ErrTokenExists = errors.New("a token with this name already exists")
+
+// APIToken is a token accepted by the server. The token itself is only known
+// when it is created.
+type APIToken struct {
+	ID         int64
+	Name       string
+	Scope      string
+	CreatedAt  string
+	LastUsedAt string
+}
+
+// Session is a browser login.
+type Session struct {
+	TokenID int64
+	// Scope is the scope of the token used to log in.
+	Scope     string
+	CSRFToken string
+	ExpiresAt string
+}
+
+// tokenUseResolution is how precisely last_used_at is tracked, to avoid a
+// write on every request.
+const tokenUseResolution = time.Minute
+
+// CreateAPIToken stores a new token by its hash and returns its ID.
+func CreateAPIToken(db *sql.DB, name, scope, tokenHash string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("token name must not be empty")
+	}
+	if scope != ScopeRead && scope != ScopeWrite {
+		return 0, fmt.Errorf("invalid scope %q, must be %s or %s", scope, ScopeRead, ScopeWrite)
+	}
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE name = ?;", name).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("could not look up token %s: %w", name, err)
+	}
+	if exists > 0 {
+		return 0, ErrTokenExists
+	}
+
+	res, err := db.Exec("INSERT INTO api_tokens (name, token_hash, scope, created_at) VALUES (?, ?, ?, ?);",
+		name, tokenHash, scope, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("could not insert token: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetAPITokens returns all tokens, oldest first.
+func GetAPITokens(db *sql.DB) ([]APIToken, error) {
+	rows, err := db.Query("SELECT id, name, scope, created_at, last_used_at FROM api_tokens ORDER BY id;")
+	if err != nil {
+		return nil, fmt.Errorf("could not query tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		var lastUsed sql.NullString
+		if err := rows.Scan(&t.ID, &t.Name, &t.Scope, &t.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("could not scan token row: %w", err)
+		}
+		t.LastUsedAt = lastUsed.String
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// CountAPITokens returns the number of tokens.
+func CountAPITokens(db *sql.DB) (int, error) {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_tokens;").Scan(&n); err != nil {
+		return 0, fmt.Errorf("could not count tokens: %w", err)
+	}
+	return n, nil
+}
+
+// UseAPIToken returns the token with the given hash and records that it was
+// used, or returns nil if there is no such token.
+func UseAPIToken(db *sql.DB, tokenHash string) (*APIToken, error) {
+	var t APIToken
+	var lastUsed sql.NullString
+	err := db.QueryRow("SELECT id, name, scope, created_at, last_used_at FROM api_tokens WHERE token_hash = ?;", tokenHash).
+		Scan(&t.ID, &t.Name, &t.Scope, &t.CreatedAt, &lastUsed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not look up token: %w", err)
+	}
+	t.LastUsedAt = lastUsed.String
+
+	now := time.Now().UTC()
+	_, err = db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?);",
+		now, t.ID, now.Add(-tokenUseResolution))
+	if err != nil {
+		return nil, fmt.Errorf("could not record use of token %d: %w", t.ID, err)
+	}
+	return &t, nil
+}
+
+// DeleteAPIToken revokes a token and ends the sessions created with it.
+func DeleteAPIToken(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE token_id = ?;", id); err != nil {
+		return fmt.Errorf("could not delete sessions of token %d: %w", id, err)
+	}
+	res, err := tx.Exec("DELETE FROM api_tokens WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("could not delete token %d: %w", id, err)
+	}
+	if err := requireRow(res, "token", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateSession stores a session by the hash of its ID. Expired sessions are
+// removed on the way.
+func CreateSession(db *sql.DB, idHash string, tokenID int64, csrfToken string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	if _, err := db.Exec("DELETE FROM sessions WHERE expires_at < ?;", now); err != nil {
+		return fmt.Errorf("could not delete expired sessions: %w", err)
+	}
+	_, err := db.Exec("INSERT INTO sessions (id_hash, token_id, csrf_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?);",
+		idHash, tokenID, csrfToken, now, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("could not insert session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns the session with the given ID hash, or nil if it does
+// not exist or has expired.
+func GetSession(db *sql.DB, idHash string) (*Session, error) {
+	var s Session
+	err := db.QueryRow(`
+		SELECT s.token_id, t.scope, s.csrf_token, s.expires_at
+		FROM sessions s JOIN api_tokens t ON t.id = s.token_id
+		WHERE s.id_hash = ? AND s.expires_at >= ?;
+	`, idHash, time.Now().UTC()).Scan(&s.TokenID, &s.Scope, &s.CSRFToken, &s.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not look up session: %w", err)
+	}
+	return &s, nil
+}
+
+// DeleteSession ends a session.
+func DeleteSession(db *sql.DB, idHash string) error {
+	if _, err := db.Exec("DELETE FROM sessions WHERE id_hash = ?;", idHash); err != nil {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+	return nil
+}
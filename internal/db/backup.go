@@ -0,0 +1,253 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"star-sage/internal/config"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Reasons for automatic snapshots.
+const (
+	SnapshotMigrate = "migrate"
+	SnapshotReset   = "reset"
+	SnapshotPrune   = "prune"
+	SnapshotRestore = "restore"
+)
+
+// snapshotTimeFormat is the timestamp in snapshot file names, in local time.
+const snapshotTimeFormat = "20060102-150405.000"
+
+// Snapshot is an automatic copy of the database taken before a destructive
+// operation.
+type Snapshot struct {
+	Path string
+	// Reason is the operation the snapshot was taken for, e.g. SnapshotReset.
+	Reason    string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Backup writes a consistent copy of the database to dest with VACUUM INTO.
+// It may run while other connections, such as the server's, use the
+// database. An existing file at dest is not overwritten.
+func Backup(db *sql.DB, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("could not create backup directory: %w", err)
+	}
+	if _, err := db.Exec("VACUUM INTO ?;", dest); err != nil {
+		return fmt.Errorf("could not back up database to %s: %w", dest, err)
+	}
+	return nil
+}
+
+// CheckBackup verifies that the file at path is an intact StarSage database
+// this build can use, and returns its schema status.
+func CheckBackup(path string) (*SchemaStatus, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("could not read backup: %w", err)
+	}
+	backup, err := sql.Open("sqlite", readOnlyURI(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not open backup: %w", err)
+	}
+	defer backup.Close()
+
+	rows, err := backup.Query("PRAGMA integrity_check;")
+	if err != nil {
+		return nil, fmt.Errorf("could not check integrity of %s: %w", path, err)
+	}
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan integrity check row: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not check integrity of %s: %w", path, err)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s is corrupt: %s", path, strings.Join(problems, "; "))
+	}
+
+	status, err := GetSchemaStatus(backup)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status.Version == 0 && !status.Legacy:
+		return nil, fmt.Errorf("%s is not a StarSage database", path)
+	case status.Version > status.Latest:
+		return nil, fmt.Errorf("%s has schema version %d, newer than the latest version %d known to this build of starsage", path, status.Version, status.Latest)
+	}
+	return status, nil
+}
+
+// Restore replaces the contents of the database with the backup at path
+// after checking it with CheckBackup. The current contents are saved as a
+// snapshot first, whose path is returned. The copy uses SQLite's backup API
+// on the open database, so other connections see the restored data as soon
+// as it is complete. The restored schema may need migrating.
+func Restore(db *sql.DB, path string) (string, error) {
+	if _, err := CheckBackup(path); err != nil {
+		return "", err
+	}
+	snapshot, err := TakeSnapshot(db, SnapshotRestore)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return snapshot, fmt.Errorf("could not get database connection: %w", err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(driverConn interface{}) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("the database driver does not support restoring backups")
+		}
+		backup, err := restorer.NewRestore(readOnlyURI(path))
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+		}
+		return backup.Finish()
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("could not restore %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// readOnlyURI returns the SQLite URI opening the file at path read-only.
+func readOnlyURI(path string) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(filepath.ToSlash(path))
+	return "file:" + escaped + "?mode=ro"
+}
+
+// TakeSnapshot saves a copy of the database in the snapshots directory next
+// to it, named after the time and reason, and then deletes the snapshots
+// beyond the retention set in the config file. It returns the path of the
+// new snapshot.
+func TakeSnapshot(db *sql.DB, reason string) (string, error) {
+	var dbPath string
+	if err := db.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main';").Scan(&dbPath); err != nil {
+		return "", fmt.Errorf("could not get database file: %w", err)
+	}
+	return takeSnapshot(db, dbPath, reason)
+}
+
+func takeSnapshot(db *sql.DB, dbPath, reason string) (string, error) {
+	cfg, err := config.GetSnapshotConfig()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s%s-%s.db", snapshotPrefix(dbPath), time.Now().Format(snapshotTimeFormat), reason)
+	path := filepath.Join(snapshotDir(dbPath), name)
+	if err := Backup(db, path); err != nil {
+		return "", fmt.Errorf("could not take snapshot: %w", err)
+	}
+	if err := pruneSnapshots(dbPath, path, cfg); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// snapshotDir returns the directory of the snapshots of the database at dbPath.
+func snapshotDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "snapshots")
+}
+
+// snapshotPrefix returns the start of the snapshot file names of the database
+// at dbPath, so databases sharing a directory keep their snapshots apart.
+func snapshotPrefix(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath)) + "-"
+}
+
+// ListSnapshots returns the snapshots of the database at dbPath, newest first.
+func ListSnapshots(dbPath string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshots: %w", err)
+	}
+
+	prefix := snapshotPrefix(dbPath)
+	var snapshots []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		// The rest of the name is <time>-<reason>.db.
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db")
+		if len(rest) < len(snapshotTimeFormat)+2 || rest[len(snapshotTimeFormat)] != '-' {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(snapshotTimeFormat, rest[:len(snapshotTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		reason := rest[len(snapshotTimeFormat)+1:]
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Path:      filepath.Join(snapshotDir(dbPath), name),
+			Reason:    reason,
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt) })
+	return snapshots, nil
+}
+
+// pruneSnapshots deletes the snapshots of the database at dbPath beyond
+// cfg.Keep or older than cfg.MaxAge, but never the newest one or the one at
+// keep. The two differ if the clock was turned back, e.g. at the end of
+// daylight saving time.
+func pruneSnapshots(dbPath, keep string, cfg config.SnapshotConfig) error {
+	snapshots, err := ListSnapshots(dbPath)
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		if i == 0 || s.Path == keep {
+			continue
+		}
+		if i >= cfg.Keep || (cfg.MaxAge > 0 && time.Since(s.CreatedAt) > cfg.MaxAge) {
+			if err := os.Remove(s.Path); err != nil {
+				return fmt.Errorf("could not delete old snapshot: %w", err)
+			}
+		}
+	}
+	return nil
+}
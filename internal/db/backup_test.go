@@ -0,0 +1,231 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"star-sage/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countRepos returns the number of repositories in database.
+func countRepos(t *testing.T, database *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM repositories;").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBackupRestore(t *testing.T) {
+	database := openQueryTestDB(t)
+	dest := filepath.Join(t.TempDir(), "backups", "stars.db")
+	if err := Backup(database, dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := Backup(database, dest); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("backing up over an existing file: got %v, want an error", err)
+	}
+
+	if _, err := database.Exec("DELETE FROM repositories;"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := Restore(database, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countRepos(t, database); n != 5 {
+		t.Errorf("restored %d repositories, want 5", n)
+	}
+	// The snapshot keeps the contents replaced by the restore.
+	if !strings.HasSuffix(snapshot, "-"+SnapshotRestore+".db") {
+		t.Errorf("snapshot %s is not named after the restore", snapshot)
+	}
+	saved, err := sql.Open("sqlite", readOnlyURI(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Close()
+	if n := countRepos(t, saved); n != 0 {
+		t.Errorf("snapshot has %d repositories, want 0", n)
+	}
+}
+
+func TestCheckBackup(t *testing.T) {
+	database := openQueryTestDB(t)
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.db")
+	if err := Backup(database, valid); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writeFile writes a file for a test case and returns its path.
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// backupWith returns a backup of database changed by query.
+	backupWith := func(name, query string) string {
+		path := filepath.Join(dir, name)
+		if err := Backup(database, path); err != nil {
+			t.Fatal(err)
+		}
+		changed, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer changed.Close()
+		if _, err := changed.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	other, _ := openMigrateTestDB(t, "CREATE TABLE notes (text TEXT); INSERT INTO notes VALUES ('hi');")
+	foreign := filepath.Join(dir, "foreign.db")
+	if err := Backup(other, foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "valid", path: valid},
+		{name: "missing", path: filepath.Join(dir, "missing.db"), wantErr: "could not read backup"},
+		{name: "not a database", path: writeFile("text.db", []byte(strings.Repeat("not a database\n", 100))), wantErr: "not a database"},
+		{name: "truncated", path: writeFile("truncated.db", content[:len(content)/2]), wantErr: "malformed"},
+		{name: "other application", path: foreign, wantErr: "is not a StarSage database"},
+		{name: "newer schema", path: backupWith("newer.db", "INSERT INTO schema_version (version, name, applied_at) VALUES (9999, 'future', CURRENT_TIMESTAMP);"), wantErr: "has schema version 9999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := CheckBackup(tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status.Version != status.Latest {
+					t.Errorf("got schema version %d, want %d", status.Version, status.Latest)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			// Restore refuses the file without touching the database.
+			if _, err := Restore(database, tt.path); err == nil {
+				t.Error("Restore accepted the file")
+			}
+			if n := countRepos(t, database); n != 5 {
+				t.Errorf("database has %d repositories after a refused restore, want 5", n)
+			}
+		})
+	}
+}
+
+func TestListAndPruneSnapshots(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	tests := []struct {
+		name string
+		cfg  config.SnapshotConfig
+		// keep is the index of the snapshot that must be kept, like the one
+		// just taken.
+		keep int
+		want []int
+	}{
+		{name: "keep", cfg: config.SnapshotConfig{Keep: 2}, want: []int{0, 1}},
+		{name: "keep all", cfg: config.SnapshotConfig{Keep: 10}, want: []int{0, 1, 2, 3, 4}},
+		{name: "max age", cfg: config.SnapshotConfig{Keep: 10, MaxAge: 90 * time.Minute}, want: []int{0, 1}},
+		{name: "keep and max age", cfg: config.SnapshotConfig{Keep: 1, MaxAge: 90 * time.Minute}, want: []int{0}},
+		{name: "all too old", cfg: config.SnapshotConfig{Keep: 10, MaxAge: time.Millisecond}, want: []int{0}},
+		// After the clock was turned back, the new snapshot is not the newest.
+		{name: "kept snapshot is older", cfg: config.SnapshotConfig{Keep: 1, MaxAge: time.Millisecond}, keep: 3, want: []int{0, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "stars.db")
+			dir := snapshotDir(dbPath)
+			if err := os.MkdirAll(filepath.Join(dir, "nested.db"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for i := 0; i < 5; i++ {
+				created := now.Add(-time.Duration(i) * time.Hour)
+				paths = append(paths, filepath.Join(dir, "stars-"+created.Format(snapshotTimeFormat)+"-"+SnapshotMigrate+".db"))
+			}
+			// Files of other databases and other names are left alone.
+			others := []string{
+				filepath.Join(dir, "other-"+now.Add(-10*time.Hour).Format(snapshotTimeFormat)+"-reset.db"),
+				filepath.Join(dir, "stars-backup.db"),
+				filepath.Join(dir, "stars-"+now.Format(snapshotTimeFormat)+"-reset.txt"),
+			}
+			for _, path := range append(append([]string{}, paths...), others...) {
+				if err := os.WriteFile(path, []byte("db"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			snapshots, err := ListSnapshots(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed []string
+			for _, s := range snapshots {
+				listed = append(listed, s.Path)
+				if s.Reason != SnapshotMigrate || s.Size != 2 {
+					t.Errorf("snapshot %s: got reason %q and size %d", s.Path, s.Reason, s.Size)
+				}
+			}
+			if !reflect.DeepEqual(listed, paths) {
+				t.Fatalf("listed %v, want %v newest first", listed, paths)
+			}
+			if !snapshots[1].CreatedAt.Equal(now.Add(-time.Hour)) {
+				t.Errorf("created at %s, want %s", snapshots[1].CreatedAt, now.Add(-time.Hour))
+			}
+
+			if err := pruneSnapshots(dbPath, paths[tt.keep], tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, i := range tt.want {
+				want = append(want, paths[i])
+			}
+			snapshots, err = ListSnapshots(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left []string
+			for _, s := range snapshots {
+				left = append(left, s.Path)
+			}
+			if !reflect.DeepEqual(left, want) {
+				t.Errorf("left %v, want %v", left, want)
+			}
+			for _, path := range others {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("unrelated file was deleted: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestListSnapshotsWithoutDirectory(t *testing.T) {
+	snapshots, err := ListSnapshots(filepath.Join(t.TempDir(), "stars.db"))
+	if err != nil || snapshots != nil {
+		t.Errorf("got %v, %v, want no snapshots", snapshots, err)
+	}
+}
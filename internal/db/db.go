@@ -2,19 +2,27 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"star-sage/internal/config"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-const dbFileName = "stars.db"
+// DefaultSource is the source key of repositories starred on github.com.
+const DefaultSource = "github"
 
 // Repository represents a starred GitHub repository.
 type Repository struct {
-	ID              int64
+	ID int64
+	// Source identifies where the repository was starred, e.g. "github".
+	Source string
+	// SourceID is the repository ID on its source.
+	SourceID        int64
 	FullName        string
 	Description     string
 	URL             string
@@ -24,6 +32,15 @@ type Repository struct {
 	Summary         string
 	ETag            string
 	LastSyncedAt    string
+	StarredAt       string
+	PushedAt        string
+	Topics          []string
+	License         string
+	IsArchived      bool
+	IsFork          bool
+	UnstarredAt     string
+	// Tags are the names of the user's tags on the repository.
+	Tags []string
 }
 
 // List represents a user-created list of repositories.
@@ -35,119 +52,143 @@ type List struct {
 	RepoCount int // For holding counts in joins
 }
 
-// InitDB initializes the SQLite database and creates tables if they don't exist.
-func InitDB() (*sql.DB, error) {
-	home, err := os.UserHomeDir()
+// Path returns the location of the database file, see config.DBPath.
+func Path() (string, error) {
+	return config.DBPath()
+}
+
+// Open opens the database at Path without migrating it, creating its
+// directory if needed.
+func Open() (*sql.DB, string, error) {
+	dbPath, err := Path()
 	if err != nil {
-		return nil, fmt.Errorf("could not get user home directory: %w", err)
+		return nil, "", err
 	}
-	dbPath := filepath.Join(home, ".config", "starsage", dbFileName)
-
-	db, err := sql.Open("sqlite", dbPath)
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, "", fmt.Errorf("could not create database directory: %w", err)
+	}
+	// Background jobs write concurrently with request handlers; wait for locks
+	// instead of failing with SQLITE_BUSY.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
-		return nil, fmt.Errorf("could not open database: %w", err)
+		return nil, "", fmt.Errorf("could not open database: %w", err)
 	}
+	return db, dbPath, nil
+}
 
-	if err = createTables(db); err != nil {
-		return nil, fmt.Errorf("could not create tables: %w", err)
+// InitDB opens the database and applies any pending migrations. A database
+// that is migrated is snapshotted first, which is reported on stderr.
+func InitDB() (*sql.DB, error) {
+	db, dbPath, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	result, err := Migrate(db, dbPath)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate database: %w", err)
+	}
+	if result.Backup != "" {
+		fmt.Fprintf(os.Stderr, "Migrated database from schema version %d to %d (snapshot: %s)\n", result.From, result.To, result.Backup)
 	}
-
 	return db, nil
 }
 
-func createTables(db *sql.DB) error {
-	// Main table for repository data
-	const createRepoTableSQL = `
-	CREATE TABLE IF NOT EXISTS repositories (
-		id INTEGER NOT NULL PRIMARY KEY,
-		full_name TEXT NOT NULL UNIQUE,
-		description TEXT,
-		url TEXT,
-		language TEXT,
-		stargazers_count INTEGER,
-		readme_content TEXT,
-		summary TEXT,
-		etag TEXT,
-		last_synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// FTS5 virtual table for full-text search
-	const createFtsTableSQL = `
-	CREATE VIRTUAL TABLE IF NOT EXISTS repos_fts USING fts5(
-		full_name,
-		description,
-		readme_content,
-		content='repositories',
-		content_rowid='id'
-	);`
-
-	// Triggers to keep the FTS table in sync with the repositories table
-	const createTriggersSQL = `
-	CREATE TRIGGER IF NOT EXISTS repos_ai AFTER INSERT ON repositories BEGIN
-		INSERT INTO repos_fts(rowid, full_name, description, readme_content)
-		VALUES (new.id, new.full_name, new.description, new.readme_content);
-	END;
-	CREATE TRIGGER IF NOT EXISTS repos_ad AFTER DELETE ON repositories BEGIN
-		INSERT INTO repos_fts(repos_fts, rowid, full_name, description, readme_content)
-		VALUES ('delete', old.id, old.full_name, old.description, old.readme_content);
-	END;
-	CREATE TRIGGER IF NOT EXISTS repos_au AFTER UPDATE ON repositories BEGIN
-		INSERT INTO repos_fts(repos_fts, rowid, full_name, description, readme_content)
-		VALUES ('delete', old.id, old.full_name, old.description, old.readme_content);
-		INSERT INTO repos_fts(rowid, full_name, description, readme_content)
-		VALUES (new.id, new.full_name, new.description, new.readme_content);
-	END;
-	`
+// repoSelectColumns is the column list read by scanRepository, qualified with the alias r.
+const repoSelectColumns = `r.id, r.source, r.source_id, r.full_name, r.description, r.url, r.language, r.stargazers_count, r.summary, r.etag,
+	r.starred_at, r.pushed_at, r.topics, r.license, r.is_archived, r.is_fork, r.unstarred_at, r.tags`
 
-	// Tables for AI-managed lists
-	const createListsTableSQL = `
-	CREATE TABLE IF NOT EXISTS lists (
-		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		prompt TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	const createListReposTableSQL = `
-	CREATE TABLE IF NOT EXISTS list_repositories (
-		list_id INTEGER NOT NULL,
-		repository_id INTEGER NOT NULL,
-		PRIMARY KEY (list_id, repository_id),
-		FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
-		FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
-	);`
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+// scanRepository scans a row selected with repoSelectColumns, followed by any extra columns.
+func scanRepository(sc rowScanner, extra ...interface{}) (Repository, error) {
+	var repo Repository
+	var desc, url, language, summary, etag, starredAt, pushedAt, topics, license, unstarredAt, tags sql.NullString
+	var stars sql.NullInt64
+	dest := []interface{}{
+		&repo.ID,
+		&repo.Source,
+		&repo.SourceID,
+		&repo.FullName,
+		&desc,
+		&url,
+		&language,
+		&stars,
+		&summary,
+		&etag,
+		&starredAt,
+		&pushedAt,
+		&topics,
+		&license,
+		&repo.IsArchived,
+		&repo.IsFork,
+		&unstarredAt,
+		&tags,
 	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(createRepoTableSQL); err != nil {
-		return err
+	if err := sc.Scan(append(dest, extra...)...); err != nil {
+		return repo, err
 	}
-	if _, err := tx.Exec(createFtsTableSQL); err != nil {
-		return err
-	}
-	if _, err := tx.Exec(createTriggersSQL); err != nil {
-		return err
-	}
-	if _, err := tx.Exec(createListsTableSQL); err != nil {
-		return err
-	}
-	if _, err := tx.Exec(createListReposTableSQL); err != nil {
-		return err
+	repo.Description = desc.String
+	repo.URL = url.String
+	repo.Language = language.String
+	repo.StargazersCount = int(stars.Int64)
+	repo.Summary = summary.String
+	repo.ETag = etag.String
+	repo.StarredAt = starredAt.String
+	repo.PushedAt = pushedAt.String
+	repo.Topics = splitTopics(topics.String)
+	repo.License = license.String
+	repo.UnstarredAt = unstarredAt.String
+	repo.Tags = splitTopics(tags.String)
+	return repo, nil
+}
+
+// joinTopics encodes repository topics for the topics column.
+func joinTopics(topics []string) string {
+	return strings.Join(topics, ",")
+}
+
+// splitTopics decodes the topics column, and the tags column which uses the same format.
+func splitTopics(s string) []string {
+	if s == "" {
+		return nil
 	}
+	return strings.Split(s, ",")
+}
 
-	return tx.Commit()
+// nullIfEmpty maps an empty string to NULL so timestamp columns stay unset.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
 }
 
+// UpsertOutcome tells what UpsertRepository did with a repository.
+type UpsertOutcome int
+
+const (
+	RepoAdded UpsertOutcome = iota
+	RepoUpdated
+	RepoUnchanged
+)
+
 // UpsertRepository inserts or updates a single repository in the database.
-func UpsertRepository(db *sql.DB, repo Repository) error {
+// It reports whether the repository was new, changed or already up to date.
+func UpsertRepository(db *sql.DB, repo Repository) (UpsertOutcome, error) {
+	outcome, err := compareRepository(db, repo)
+	if err != nil {
+		return outcome, err
+	}
+
 	stmt, err := db.Prepare(`
-		INSERT INTO repositories (id, full_name, description, url, language, stargazers_count, readme_content, etag, last_synced_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
+		INSERT INTO repositories (source, source_id, full_name, description, url, language, stargazers_count, readme_content, etag,
+			last_synced_at, starred_at, pushed_at, topics, license, is_archived, is_fork)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(source, source_id) DO UPDATE SET
 			full_name=excluded.full_name,
 			description=excluded.description,
 			url=excluded.url,
@@ -155,15 +196,25 @@ func UpsertRepository(db *sql.DB, repo Repository) error {
 			stargazers_count=excluded.stargazers_count,
 			readme_content=excluded.readme_content,
 			etag=excluded.etag,
-			last_synced_at=excluded.last_synced_at;
+			last_synced_at=excluded.last_synced_at,
+			starred_at=COALESCE(excluded.starred_at, repositories.starred_at),
+			pushed_at=excluded.pushed_at,
+			topics=excluded.topics,
+			license=excluded.license,
+			is_archived=excluded.is_archived,
+			is_fork=excluded.is_fork,
+			unstarred_at=NULL,
+			embedding=CASE WHEN ` + embeddedTextUnchangedSQL + ` THEN repositories.embedding END,
+			embedding_model=CASE WHEN ` + embeddedTextUnchangedSQL + ` THEN repositories.embedding_model END;
 	`)
 	if err != nil {
-		return fmt.Errorf("could not prepare statement: %w", err)
+		return outcome, fmt.Errorf("could not prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	_, err = stmt.Exec(
-		repo.ID,
+		repoSource(repo),
+		repo.SourceID,
 		repo.FullName,
 		repo.Description,
 		repo.URL,
@@ -172,18 +223,185 @@ func UpsertRepository(db *sql.DB, repo Repository) error {
 		repo.ReadmeContent,
 		repo.ETag,
 		time.Now(),
+		nullIfEmpty(repo.StarredAt),
+		nullIfEmpty(repo.PushedAt),
+		joinTopics(repo.Topics),
+		repo.License,
+		repo.IsArchived,
+		repo.IsFork,
 	)
 	if err != nil {
-		return fmt.Errorf("could not execute statement for repo %s: %w", repo.FullName, err)
+		return outcome, fmt.Errorf("could not execute statement for repo %s: %w", repo.FullName, err)
 	}
 
-	return nil
+	return outcome, nil
 }
 
-// GetAllReposWithETags retrieves all repositories with their ID, ETag, and ReadmeContent.
-func GetAllReposWithETags(db *sql.DB) ([]Repository, error) {
-	query := `SELECT id, etag, readme_content FROM repositories;`
-	rows, err := db.Query(query)
+// repoSource returns the source of repo, defaulting to github.com.
+func repoSource(repo Repository) string {
+	if repo.Source == "" {
+		return DefaultSource
+	}
+	return repo.Source
+}
+
+// compareRepository checks how repo differs from the stored row with the same ID.
+func compareRepository(db *sql.DB, repo Repository) (UpsertOutcome, error) {
+	var same bool
+	err := db.QueryRow(`
+		SELECT full_name IS ? AND description IS ? AND url IS ? AND language IS ?
+			AND stargazers_count IS ? AND readme_content IS ? AND pushed_at IS ?
+			AND topics IS ? AND license IS ? AND is_archived IS ? AND is_fork IS ?
+			AND unstarred_at IS NULL
+		FROM repositories WHERE source = ? AND source_id = ?;
+	`,
+		repo.FullName,
+		repo.Description,
+		repo.URL,
+		repo.Language,
+		repo.StargazersCount,
+		repo.ReadmeContent,
+		nullIfEmpty(repo.PushedAt),
+		joinTopics(repo.Topics),
+		repo.License,
+		repo.IsArchived,
+		repo.IsFork,
+		repoSource(repo),
+		repo.SourceID,
+	).Scan(&same)
+	if err == sql.ErrNoRows {
+		return RepoAdded, nil
+	}
+	if err != nil {
+		return RepoAdded, fmt.Errorf("could not compare repo %s: %w", repo.FullName, err)
+	}
+	if same {
+		return RepoUnchanged, nil
+	}
+	return RepoUpdated, nil
+}
+
+// ErrEmptyReconcile is returned by MarkUnstarred when no starred repositories
+// were seen although the source still has some.
+var ErrEmptyReconcile = errors.New("refusing to reconcile an empty sync")
+
+// MarkUnstarred reconciles the repositories of source against the full list of
+// starred repository IDs on that source. Repositories missing from starredIDs are
+// stamped with unstarred_at instead of being deleted, so their summaries and list
+// memberships are kept. It returns the number of newly unstarred repositories,
+// or ErrEmptyReconcile if starredIDs is empty while the source has starred ones.
+func MarkUnstarred(db *sql.DB, source string, starredIDs []int64) (int64, error) {
+	if len(starredIDs) == 0 {
+		// An empty set is far more likely to come from a broken sync than from
+		// the user unstarring everything, and reconciling it would clear (or,
+		// with pruning, delete) the whole library.
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM repositories WHERE source = ? AND unstarred_at IS NULL;", source).Scan(&n); err != nil {
+			return 0, fmt.Errorf("could not count starred repos: %w", err)
+		}
+		if n > 0 {
+			return 0, fmt.Errorf("%w: no starred repositories were seen, keeping the %d of source %s", ErrEmptyReconcile, n, source)
+		}
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("CREATE TEMP TABLE IF NOT EXISTS sync_starred (id INTEGER PRIMARY KEY);"); err != nil {
+		return 0, fmt.Errorf("could not create temp table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM sync_starred;"); err != nil {
+		return 0, fmt.Errorf("could not clear temp table: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO sync_starred (id) VALUES (?)")
+	if err != nil {
+		return 0, fmt.Errorf("could not prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, id := range starredIDs {
+		if _, err := stmt.Exec(id); err != nil {
+			return 0, fmt.Errorf("could not record starred repo %d: %w", id, err)
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE repositories SET unstarred_at = ?
+		WHERE source = ? AND unstarred_at IS NULL AND source_id NOT IN (SELECT id FROM sync_starred);
+	`, time.Now(), source)
+	if err != nil {
+		return 0, fmt.Errorf("could not mark unstarred repos: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DROP TABLE sync_starred;"); err != nil {
+		return 0, fmt.Errorf("could not drop temp table: %w", err)
+	}
+	return n, tx.Commit()
+}
+
+// CountUnstarred returns the number of repositories of source marked as
+// unstarred, which PruneUnstarred would delete.
+func CountUnstarred(db *sql.DB, source string) (int64, error) {
+	var n int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM repositories WHERE source = ? AND unstarred_at IS NOT NULL;", source).Scan(&n); err != nil {
+		return 0, fmt.Errorf("could not count unstarred repos: %w", err)
+	}
+	return n, nil
+}
+
+// PruneUnstarred permanently deletes the repositories of source marked as
+// unstarred, together with their list memberships. The FTS index is cleaned up
+// by the delete trigger. It returns the number of deleted repositories.
+func PruneUnstarred(db *sql.DB, source string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Foreign keys are not enforced on this connection, so cascade by hand.
+	if _, err := tx.Exec(`
+		DELETE FROM list_repositories
+		WHERE repository_id IN (SELECT id FROM repositories WHERE source = ? AND unstarred_at IS NOT NULL);
+	`, source); err != nil {
+		return 0, fmt.Errorf("could not delete list memberships of unstarred repos: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM repository_topics
+		WHERE repository_id IN (SELECT id FROM repositories WHERE source = ? AND unstarred_at IS NOT NULL);
+	`, source); err != nil {
+		return 0, fmt.Errorf("could not delete topic assignments of unstarred repos: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM repository_tags
+		WHERE repository_id IN (SELECT id FROM repositories WHERE source = ? AND unstarred_at IS NOT NULL);
+	`, source); err != nil {
+		return 0, fmt.Errorf("could not delete tags of unstarred repos: %w", err)
+	}
+
+	res, err := tx.Exec("DELETE FROM repositories WHERE source = ? AND unstarred_at IS NOT NULL;", source)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete unstarred repos: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// GetAllReposWithETags retrieves all repositories of source with their IDs, ETag, and ReadmeContent.
+func GetAllReposWithETags(db *sql.DB, source string) ([]Repository, error) {
+	query := `SELECT id, source_id, etag, readme_content FROM repositories WHERE source = ?;`
+	rows, err := db.Query(query, source)
 	if err != nil {
 		return nil, fmt.Errorf("could not query repos for etags: %w", err)
 	}
@@ -194,7 +412,8 @@ func GetAllReposWithETags(db *sql.DB) ([]Repository, error) {
 		var repo Repository
 		var etag sql.NullString
 		var readme sql.NullString
-		if err := rows.Scan(&repo.ID, &etag, &readme); err != nil {
+		repo.Source = source
+		if err := rows.Scan(&repo.ID, &repo.SourceID, &etag, &readme); err != nil {
 			return nil, fmt.Errorf("could not scan repo etag row: %w", err)
 		}
 		if etag.Valid {
@@ -216,6 +435,7 @@ func GetReposForSummarization(db *sql.DB, limit int) ([]Repository, error) {
 		FROM repositories
 		WHERE readme_content IS NOT NULL AND readme_content != ''
 		AND (summary IS NULL OR summary = '')
+		AND unstarred_at IS NULL
 		LIMIT ?;
 	`
 	rows, err := db.Query(query, limit)
@@ -239,9 +459,10 @@ func GetReposForSummarization(db *sql.DB, limit int) ([]Repository, error) {
 // GetAllRepositories retrieves all repositories from the database.
 func GetAllRepositories(db *sql.DB) ([]Repository, error) {
 	query := `
-		SELECT id, full_name, description, url, language, stargazers_count, summary, etag
-		FROM repositories
-		ORDER BY stargazers_count DESC;
+		SELECT ` + repoSelectColumns + `
+		FROM repositories r
+		WHERE r.unstarred_at IS NULL
+		ORDER BY r.stargazers_count DESC;
 	`
 	rows, err := db.Query(query)
 	if err != nil {
@@ -251,32 +472,68 @@ func GetAllRepositories(db *sql.DB) ([]Repository, error) {
 
 	var repos []Repository
 	for rows.Next() {
-		var repo Repository
-		var desc, summary, etag sql.NullString
-		if err := rows.Scan(
-			&repo.ID,
-			&repo.FullName,
-			&desc,
-			&repo.URL,
-			&repo.Language,
-			&repo.StargazersCount,
-			&summary,
-			&etag,
-		); err != nil {
+		repo, err := scanRepository(rows)
+		if err != nil {
 			return nil, fmt.Errorf("could not scan repo row: %w", err)
 		}
-		repo.Description = desc.String
-		repo.Summary = summary.String
-		repo.ETag = etag.String
 		repos = append(repos, repo)
 	}
 
 	return repos, nil
 }
 
+// GetRepositoryByID retrieves a single repository, or nil if it does not exist.
+func GetRepositoryByID(db *sql.DB, id int64) (*Repository, error) {
+	row := db.QueryRow(`SELECT `+repoSelectColumns+` FROM repositories r WHERE r.id = ?;`, id)
+	repo, err := scanRepository(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get repo %d: %w", id, err)
+	}
+	return &repo, nil
+}
+
+// FindRepositoriesByName retrieves the repositories called fullName
+// (case-insensitive). Repositories from different sources can share a name.
+func FindRepositoriesByName(db *sql.DB, fullName string) ([]Repository, error) {
+	rows, err := db.Query(`
+		SELECT `+repoSelectColumns+`
+		FROM repositories r
+		WHERE r.full_name = ? COLLATE NOCASE
+		ORDER BY r.source;
+	`, fullName)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos named %s: %w", fullName, err)
+	}
+	defer rows.Close()
+
+	var repos []Repository
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row: %w", err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+// GetRepoReadme returns the stored README of a repository, or "" if it has none.
+func GetRepoReadme(db *sql.DB, repoID int64) (string, error) {
+	var readme sql.NullString
+	err := db.QueryRow("SELECT readme_content FROM repositories WHERE id = ?;", repoID).Scan(&readme)
+	if err != nil {
+		return "", fmt.Errorf("could not get README of repo %d: %w", repoID, err)
+	}
+	return readme.String, nil
+}
+
 // UpdateRepoSummary updates the summary for a given repository.
+// The summary is part of the embedded text, so the embedding is cleared.
 func UpdateRepoSummary(db *sql.DB, repoID int64, summary string) error {
-	query := `UPDATE repositories SET summary = ? WHERE id = ?;`
+	query := `UPDATE repositories SET summary = ?, embedding = NULL, embedding_model = NULL WHERE id = ?;`
 	_, err := db.Exec(query, summary, repoID)
 	if err != nil {
 		return fmt.Errorf("could not update summary for repo %d: %w", repoID, err)
@@ -284,25 +541,27 @@ func UpdateRepoSummary(db *sql.DB, repoID int64, summary string) error {
 	return nil
 }
 
+// SearchResult is a repository matched by a full-text search.
+type SearchResult struct {
+	Repository
+	// Snippet is an excerpt of the best matching column with the search terms wrapped in <b></b>.
+	Snippet string
+	// Rank is the bm25 score of the match; lower is better.
+	Rank float64
+}
+
 // SearchRepositories performs a full-text search on the repositories.
-func SearchRepositories(db *sql.DB, query string, limit int) ([]Repository, error) {
-	// The snippet function highlights the search terms in the results.
+func SearchRepositories(db *sql.DB, query string, limit int) ([]SearchResult, error) {
+	// The snippet function highlights the search terms in the column that matched best.
 	// The bm25 function provides relevancy ranking.
-	// Use COALESCE to handle NULL summary values gracefully.
 	searchSQL := `
 		SELECT
-			r.id,
-			r.full_name,
-			COALESCE(r.description, ''),
-			r.url,
-			r.language,
-			r.stargazers_count,
-			COALESCE(r.summary, ''),
-			snippet(repos_fts, 1, '<b>', '</b>', '...', 15) as desc_snippet,
+			` + repoSelectColumns + `,
+			snippet(repos_fts, -1, '<b>', '</b>', '...', 15) as snippet,
 			bm25(repos_fts) as rank
 		FROM repositories r
 		JOIN repos_fts ON r.id = repos_fts.rowid
-		WHERE repos_fts MATCH ?
+		WHERE repos_fts MATCH ? AND r.unstarred_at IS NULL
 		ORDER BY rank
 	`
 	var args []interface{}
@@ -321,36 +580,32 @@ func SearchRepositories(db *sql.DB, query string, limit int) ([]Repository, erro
 	}
 	defer rows.Close()
 
-	var repos []Repository
+	var results []SearchResult
 	for rows.Next() {
-		var repo Repository
-		var descSnippet string
-		var rank float64
-		if err := rows.Scan(
-			&repo.ID,
-			&repo.FullName,
-			&repo.Description,
-			&repo.URL,
-			&repo.Language,
-			&repo.StargazersCount,
-			&repo.Summary,
-			&descSnippet,
-			&rank,
-		); err != nil {
+		var result SearchResult
+		var snippet sql.NullString
+		result.Repository, err = scanRepository(rows, &snippet, &result.Rank)
+		if err != nil {
 			return nil, fmt.Errorf("could not scan search result row: %w", err)
 		}
-		// If the original description was empty, we can use the snippet as a fallback.
-		if repo.Description == "" {
-			repo.Description = descSnippet
-		}
-		repos = append(repos, repo)
+		result.Snippet = snippet.String
+		results = append(results, result)
 	}
 
-	return repos, nil
+	return results, rows.Err()
 }
 
 // CreateList creates a new list and returns its ID.
 func CreateList(db *sql.DB, name, prompt string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("list name must not be empty")
+	}
+	if existing, err := GetListByName(db, name); err != nil {
+		return 0, err
+	} else if existing != nil {
+		return 0, ErrListExists
+	}
 	res, err := db.Exec("INSERT INTO lists (name, prompt) VALUES (?, ?)", name, prompt)
 	if err != nil {
 		return 0, fmt.Errorf("could not insert list: %w", err)
@@ -358,7 +613,8 @@ func CreateList(db *sql.DB, name, prompt string) (int64, error) {
 	return res.LastInsertId()
 }
 
-// AddReposToList adds multiple repositories to a list.
+// AddReposToList adds multiple repositories to a list as classified by the AI.
+// Repositories pinned to or excluded from the list keep their membership.
 func AddReposToList(db *sql.DB, listID int64, repoIDs []int64) error {
 	tx, err := db.Begin()
 	if err != nil {
@@ -366,7 +622,15 @@ func AddReposToList(db *sql.DB, listID int64, repoIDs []int64) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO list_repositories (list_id, repository_id) VALUES (?, ?)")
+	if err := addReposToList(tx, listID, repoIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addReposToList does the work of AddReposToList in tx.
+func addReposToList(tx *sql.Tx, listID int64, repoIDs []int64) error {
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO list_repositories (list_id, repository_id) VALUES (?, ?)")
 	if err != nil {
 		return fmt.Errorf("could not prepare statement: %w", err)
 	}
@@ -377,8 +641,7 @@ func AddReposToList(db *sql.DB, listID int64, repoIDs []int64) error {
 			return fmt.Errorf("could not add repo %d to list %d: %w", repoID, listID, err)
 		}
 	}
-
-	return tx.Commit()
+	return nil
 }
 
 // GetLists retrieves all lists with a count of repositories in each.
@@ -386,7 +649,7 @@ func GetLists(db *sql.DB) ([]List, error) {
 	query := `
 		SELECT l.id, l.name, l.prompt, l.created_at, COUNT(lr.repository_id) as repo_count
 		FROM lists l
-		LEFT JOIN list_repositories lr ON l.id = lr.list_id
+		LEFT JOIN list_repositories lr ON l.id = lr.list_id AND lr.membership != 'excluded'
 		GROUP BY l.id
 		ORDER BY l.name;
 	`
@@ -406,40 +669,3 @@ func GetLists(db *sql.DB) ([]List, error) {
 	}
 	return lists, nil
 }
-
-// GetReposByListID retrieves all repositories for a given list ID.
-func GetReposByListID(db *sql.DB, listID int64) ([]Repository, error) {
-	query := `
-		SELECT r.id, r.full_name, r.description, r.url, r.language, r.stargazers_count, r.summary
-		FROM repositories r
-		JOIN list_repositories lr ON r.id = lr.repository_id
-		WHERE lr.list_id = ?
-		ORDER BY r.stargazers_count DESC;
-	`
-	rows, err := db.Query(query, listID)
-	if err != nil {
-		return nil, fmt.Errorf("could not query repos by list ID: %w", err)
-	}
-	defer rows.Close()
-
-	var repos []Repository
-	for rows.Next() {
-		var repo Repository
-		var desc, summary sql.NullString
-		if err := rows.Scan(
-			&repo.ID,
-			&repo.FullName,
-			&desc,
-			&repo.URL,
-			&repo.Language,
-			&repo.StargazersCount,
-			&summary,
-		); err != nil {
-			return nil, fmt.Errorf("could not scan repo row for list: %w", err)
-		}
-		repo.Description = desc.String
-		repo.Summary = summary.String
-		repos = append(repos, repo)
-	}
-	return repos, nil
-}
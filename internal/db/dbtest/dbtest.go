@@ -0,0 +1,84 @@
+// Package dbtest runs tests against every implementation of db.Store.
+package dbtest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"star-sage/internal/db"
+	"testing"
+)
+
+// Store is a db.Store that tests can fill with repositories.
+type Store interface {
+	db.Store
+	// AddRepository stores repo as starred and returns its ID. Leave repo.ID
+	// unset: only MemoryStore keeps it.
+	AddRepository(repo db.Repository) int64
+}
+
+// ForEach runs test as a subtest with a new, empty store of each
+// implementation.
+func ForEach(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Helper()
+	t.Run("sqlite", func(t *testing.T) { test(t, NewSQLite(t)) })
+	t.Run("memory", func(t *testing.T) { test(t, NewMemory()) })
+}
+
+// NewMemory returns an empty MemoryStore.
+func NewMemory() Store {
+	return db.NewMemoryStore()
+}
+
+// NewSQLite returns a SQLiteStore on a new, migrated database that is closed
+// when the test ends.
+func NewSQLite(t *testing.T) Store {
+	t.Helper()
+	return &sqliteStore{SQLiteStore: db.NewSQLiteStore(OpenDB(t)), t: t}
+}
+
+// OpenDB opens a new, migrated database that is closed when the test ends.
+func OpenDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stars.db")
+	database, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := db.Migrate(database, path); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+type sqliteStore struct {
+	*db.SQLiteStore
+	t *testing.T
+}
+
+func (s *sqliteStore) AddRepository(repo db.Repository) int64 {
+	s.t.Helper()
+	if repo.Source == "" {
+		repo.Source = db.DefaultSource
+	}
+	if repo.SourceID == 0 {
+		if err := s.DB().QueryRow("SELECT COALESCE(MAX(source_id), 0) + 1 FROM repositories;").Scan(&repo.SourceID); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	if _, err := s.UpsertRepository(repo); err != nil {
+		s.t.Fatal(err)
+	}
+	var id int64
+	err := s.DB().QueryRow("SELECT id FROM repositories WHERE source = ? AND source_id = ?;", repo.Source, repo.SourceID).Scan(&id)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	// Summaries are written by the summarizer, not by syncing.
+	if repo.Summary != "" {
+		if err := s.UpdateRepoSummary(id, repo.Summary); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	return id
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// embeddedTextUnchangedSQL is true inside an upsert when none of the columns
+// that feed the embedding text changed, so the stored embedding is still valid.
+const embeddedTextUnchangedSQL = `repositories.full_name IS excluded.full_name
+				AND repositories.description IS excluded.description
+				AND repositories.topics IS excluded.topics
+				AND repositories.readme_content IS excluded.readme_content`
+
+// ScoredRepository is a repository together with its relevance to a query.
+type ScoredRepository struct {
+	Repository
+	// Score is the cosine similarity between the query and the repository.
+	Score float64
+}
+
+// GetReposForEmbedding retrieves starred repositories that have no embedding
+// from model yet, including the fields used to build the embedded text.
+func GetReposForEmbedding(db *sql.DB, model string, limit int) ([]Repository, error) {
+	query := `
+		SELECT ` + repoSelectColumns + `, COALESCE(r.readme_content, '')
+		FROM repositories r
+		WHERE r.unstarred_at IS NULL
+		AND (r.embedding IS NULL OR r.embedding_model IS NOT ?)
+		ORDER BY r.id
+	`
+	args := []interface{}{model}
+	if limit > 0 {
+		query += " LIMIT ?;"
+		args = append(args, limit)
+	} else {
+		query += ";"
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos for embedding: %w", err)
+	}
+	defer rows.Close()
+
+	var repos []Repository
+	for rows.Next() {
+		var readme string
+		repo, err := scanRepository(rows, &readme)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row: %w", err)
+		}
+		repo.ReadmeContent = readme
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+// UpdateRepoEmbedding stores the embedding of a repository computed with model.
+func UpdateRepoEmbedding(db *sql.DB, repoID int64, model string, embedding []float32) error {
+	_, err := db.Exec("UPDATE repositories SET embedding = ?, embedding_model = ? WHERE id = ?;",
+		encodeEmbedding(embedding), model, repoID)
+	if err != nil {
+		return fmt.Errorf("could not update embedding for repo %d: %w", repoID, err)
+	}
+	return nil
+}
+
+// CountEmbeddings returns how many starred repositories have an embedding from model.
+func CountEmbeddings(db *sql.DB, model string) (int, error) {
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM repositories
+		WHERE unstarred_at IS NULL AND embedding IS NOT NULL AND embedding_model = ?;
+	`, model).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("could not count embeddings: %w", err)
+	}
+	return n, nil
+}
+
+// SemanticSearch ranks the starred repositories embedded with model by their
+// cosine similarity to the query embedding, most similar first.
+func SemanticSearch(db *sql.DB, model string, query []float32, limit int) ([]ScoredRepository, error) {
+	rows, err := db.Query(`
+		SELECT id, embedding FROM repositories
+		WHERE unstarred_at IS NULL AND embedding IS NOT NULL AND embedding_model = ?;
+	`, model)
+	if err != nil {
+		return nil, fmt.Errorf("could not query embeddings: %w", err)
+	}
+
+	type hit struct {
+		id    int64
+		score float64
+	}
+	var hits []hit
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan embedding row: %w", err)
+		}
+		hits = append(hits, hit{id, cosineSimilarity(query, decodeEmbedding(blob))})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	ids := make([]int64, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	repos, err := getRepositoriesByIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ScoredRepository, 0, len(hits))
+	for _, h := range hits {
+		if repo, ok := repos[h.id]; ok {
+			results = append(results, ScoredRepository{Repository: repo, Score: h.score})
+		}
+	}
+	return results, nil
+}
+
+// getRepositoriesByIDs loads the repositories with the given local IDs, keyed by ID.
+func getRepositoriesByIDs(db *sql.DB, ids []int64) (map[int64]Repository, error) {
+	repos := make(map[int64]Repository, len(ids))
+	if len(ids) == 0 {
+		return repos, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(`
+		SELECT `+repoSelectColumns+`
+		FROM repositories r
+		WHERE r.id IN (`+placeholders+`);
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos by ID: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row: %w", err)
+		}
+		repos[repo.ID] = repo
+	}
+	return repos, rows.Err()
+}
+
+// encodeEmbedding stores a vector as little-endian float32 values.
+func encodeEmbedding(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// decodeEmbedding is the inverse of encodeEmbedding.
+func decodeEmbedding(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// their dimensions differ or either is zero.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,153 @@
+package db_test
+
+import (
+	"math"
+	"reflect"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+func TestSemanticSearch(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		embeddings := []struct {
+			name      string
+			model     string
+			embedding []float32
+		}{
+			{"owner/exact", "m", []float32{1, 0}},
+			{"owner/close", "m", []float32{0.6, 0.8}},
+			{"owner/opposite", "m", []float32{-0.6, 0.8}},
+			// A vector of another dimension is never similar.
+			{"owner/resized", "m", []float32{1, 0, 0}},
+			{"owner/other-model", "other", []float32{1, 0}},
+		}
+		for _, e := range embeddings {
+			id := store.AddRepository(db.Repository{FullName: e.name})
+			if err := store.UpdateRepoEmbedding(id, e.model, e.embedding); err != nil {
+				t.Fatal(err)
+			}
+		}
+		store.AddRepository(db.Repository{FullName: "owner/unembedded"})
+
+		results, err := store.SemanticSearch("m", []float32{2, 0}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		var scores []float64
+		for _, r := range results {
+			names = append(names, r.FullName)
+			scores = append(scores, r.Score)
+		}
+		wantNames := []string{"owner/exact", "owner/close", "owner/resized", "owner/opposite"}
+		if !reflect.DeepEqual(names, wantNames) {
+			t.Fatalf("got %v, want %v", names, wantNames)
+		}
+		for i, want := range []float64{1, 0.6, 0, -0.6} {
+			if math.Abs(scores[i]-want) > 1e-6 {
+				t.Errorf("%s scored %f, want %f", names[i], scores[i], want)
+			}
+		}
+
+		results, err = store.SemanticSearch("m", []float32{1, 0}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[1].FullName != "owner/close" {
+			t.Errorf("got %+v, want the two most similar repositories", results)
+		}
+		if results, err := store.SemanticSearch("unknown", []float32{1, 0}, 0); err != nil || len(results) != 0 {
+			t.Errorf("searching an unused model: got %v, %v", results, err)
+		}
+
+		for model, want := range map[string]int{"m": 4, "other": 1, "unknown": 0} {
+			if n, err := store.CountEmbeddings(model); err != nil || n != want {
+				t.Errorf("CountEmbeddings(%s) = %d, %v, want %d", model, n, err, want)
+			}
+		}
+	})
+}
+
+func TestGetReposForEmbedding(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	repo := db.Repository{
+		SourceID:      1,
+		FullName:      "owner/repo",
+		Description:   "A parser",
+		Topics:        []string{"parsing"},
+		ReadmeContent: "# Repo",
+	}
+	upsert := func(repo db.Repository) {
+		t.Helper()
+		if _, err := db.UpsertRepository(database, repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pending returns the names of the repositories waiting for an embedding
+	// from model.
+	pending := func(model string) []string {
+		t.Helper()
+		repos, err := db.GetReposForEmbedding(database, model, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, r := range repos {
+			names = append(names, r.FullName)
+		}
+		return names
+	}
+
+	upsert(repo)
+	upsert(db.Repository{SourceID: 2, FullName: "owner/other"})
+	repos, err := db.GetReposForEmbedding(database, "m", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].ReadmeContent != "# Repo" || !reflect.DeepEqual(repos[0].Topics, []string{"parsing"}) {
+		t.Fatalf("got %+v, want the first repository with its README and topics", repos)
+	}
+	if err := db.UpdateRepoEmbedding(database, repos[0].ID, "m", []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending("m"); !reflect.DeepEqual(got, []string{"owner/other"}) {
+		t.Errorf("pending for m: got %v", got)
+	}
+	if got := pending("other"); len(got) != 2 {
+		t.Errorf("pending for another model: got %v, want both repositories", got)
+	}
+
+	// Changes to fields that are not embedded keep the embedding.
+	repo.StargazersCount = 100
+	repo.Language = "Go"
+	upsert(repo)
+	if got := pending("m"); !reflect.DeepEqual(got, []string{"owner/other"}) {
+		t.Errorf("after a star count change: got %v, want the embedding kept", got)
+	}
+	repo.Description = "A faster parser"
+	upsert(repo)
+	if got := pending("m"); !reflect.DeepEqual(got, []string{"owner/repo", "owner/other"}) {
+		t.Errorf("after a description change: got %v, want the embedding cleared", got)
+	}
+	if n, err := db.CountEmbeddings(database, "m"); err != nil || n != 0 {
+		t.Errorf("CountEmbeddings = %d, %v, want 0", n, err)
+	}
+
+	// Unstarred repositories are neither embedded nor searched.
+	if err := db.UpdateRepoEmbedding(database, repos[0].ID, "m", []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.MarkUnstarred(database, db.DefaultSource, []int64{2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pending("other"); !reflect.DeepEqual(got, []string{"owner/other"}) {
+		t.Errorf("after unstarring: got %v", got)
+	}
+	if n, err := db.CountEmbeddings(database, "m"); err != nil || n != 0 {
+		t.Errorf("CountEmbeddings = %d, %v, want 0 after unstarring", n, err)
+	}
+	if results, err := db.SemanticSearch(database, "m", []float32{1, 0}, 0); err != nil || len(results) != 0 {
+		t.Errorf("SemanticSearch after unstarring: got %v, %v", results, err)
+	}
+}
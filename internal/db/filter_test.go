@@ -0,0 +1,87 @@
+package db_test
+
+import (
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+func TestQueryRepositoriesFilters(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		cli := store.AddRepository(db.Repository{FullName: "owner/go-cli", Language: "Go", StargazersCount: 10, IsArchived: true,
+			Summary: "A command line tool", StarredAt: "2024-01-15T00:00:00Z", Description: "Command line tool"})
+		web := store.AddRepository(db.Repository{FullName: "owner/go-web", Language: "go", StargazersCount: 200,
+			StarredAt: "2024-03-01T00:00:00Z", Description: "Web framework"})
+		rust := store.AddRepository(db.Repository{FullName: "owner/rust-db", Language: "Rust", StargazersCount: 50,
+			Summary: "An embedded database", Description: "Embedded database"})
+
+		tagID, err := store.CreateTag("cli", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.TagRepository(cli, tagID); err != nil {
+			t.Fatal(err)
+		}
+		listID, err := store.CreateList("Tools", "developer tools")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.ReplaceListClassification(listID, []int64{cli, rust}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.PinRepoToList(listID, web); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.ExcludeRepoFromList(listID, rust); err != nil {
+			t.Fatal(err)
+		}
+
+		ten, fifty, yes, no := 10, 50, true, false
+		tests := []struct {
+			name   string
+			filter db.RepoFilter
+			want   []string
+		}{
+			{"none", db.RepoFilter{}, []string{"owner/go-cli", "owner/go-web", "owner/rust-db"}},
+			{"language ignores case", db.RepoFilter{Language: "GO"}, []string{"owner/go-cli", "owner/go-web"}},
+			{"unknown language", db.RepoFilter{Language: "Zig"}, nil},
+			{"min stars", db.RepoFilter{MinStars: &fifty}, []string{"owner/go-web", "owner/rust-db"}},
+			{"max stars", db.RepoFilter{MaxStars: &fifty}, []string{"owner/go-cli", "owner/rust-db"}},
+			{"star range", db.RepoFilter{MinStars: &ten, MaxStars: &ten}, []string{"owner/go-cli"}},
+			{"archived", db.RepoFilter{Archived: &yes}, []string{"owner/go-cli"}},
+			{"not archived", db.RepoFilter{Archived: &no}, []string{"owner/go-web", "owner/rust-db"}},
+			{"summarized", db.RepoFilter{HasSummary: &yes}, []string{"owner/go-cli", "owner/rust-db"}},
+			{"not summarized", db.RepoFilter{HasSummary: &no}, []string{"owner/go-web"}},
+			// Repositories without a starred date match neither bound.
+			{"starred after", db.RepoFilter{StarredAfter: "2024-02-01"}, []string{"owner/go-web"}},
+			{"starred after is inclusive", db.RepoFilter{StarredAfter: "2024-01-15T00:00:00Z"}, []string{"owner/go-cli", "owner/go-web"}},
+			{"starred before", db.RepoFilter{StarredBefore: "2024-02-01"}, []string{"owner/go-cli"}},
+			{"tag", db.RepoFilter{TagID: tagID}, []string{"owner/go-cli"}},
+			{"unknown tag", db.RepoFilter{TagID: tagID + 100}, nil},
+			{"list without excluded", db.RepoFilter{ListID: listID}, []string{"owner/go-cli", "owner/go-web"}},
+			{"text", db.RepoFilter{Text: "framew"}, []string{"owner/go-web"}},
+			{"text and language", db.RepoFilter{Text: "embedded", Language: "rust"}, []string{"owner/rust-db"}},
+			{"all filters", db.RepoFilter{Language: "go", MinStars: &ten, Archived: &yes, TagID: tagID, ListID: listID, HasSummary: &yes}, []string{"owner/go-cli"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				page, err := store.QueryRepositories(db.RepoQuery{RepoFilter: tt.filter, Sort: db.SortName})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, r := range page.Repositories {
+					got = append(got, r.FullName)
+				}
+				if len(got) != len(tt.want) || page.Total != len(tt.want) {
+					t.Fatalf("got %v of %d, want %v", got, page.Total, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Fatalf("got %v, want %v", got, tt.want)
+					}
+				}
+			})
+		}
+	})
+}
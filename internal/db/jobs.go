@@ -0,0 +1,290 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// Job is a background task and its persisted state.
+type Job struct {
+	ID     int64
+	Type   string
+	Params json.RawMessage
+	Status string
+	// Progress and Total count the units of work done so far and overall;
+	// Total is 0 while unknown.
+	Progress int
+	Total    int
+	// Message describes the current step.
+	Message string
+	Error   string
+	Result  json.RawMessage
+	// Attempts is the number of times the job was started. Failed jobs are
+	// retried until MaxAttempts is reached.
+	Attempts    int
+	MaxAttempts int
+	// RunAfter delays a retry; queued jobs are not started before it.
+	RunAfter   string
+	CreatedAt  string
+	StartedAt  string
+	FinishedAt string
+}
+
+// Finished reports whether the job will not run again by itself.
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+const jobSelectColumns = `id, type, params, status, progress, total, message, error, result, attempts, max_attempts,
+	run_after, created_at, started_at, finished_at`
+
+// scanJob scans a row selected with jobSelectColumns.
+func scanJob(sc rowScanner) (*Job, error) {
+	var j Job
+	var params, message, jobErr, result, runAfter, startedAt, finishedAt sql.NullString
+	if err := sc.Scan(&j.ID, &j.Type, &params, &j.Status, &j.Progress, &j.Total, &message, &jobErr, &result,
+		&j.Attempts, &j.MaxAttempts, &runAfter, &j.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if params.Valid {
+		j.Params = json.RawMessage(params.String)
+	}
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	j.Message = message.String
+	j.Error = jobErr.String
+	j.RunAfter = runAfter.String
+	j.StartedAt = startedAt.String
+	j.FinishedAt = finishedAt.String
+	return &j, nil
+}
+
+// CreateJob queues a job of the given type. params is stored as JSON.
+func CreateJob(db *sql.DB, jobType string, params interface{}, maxAttempts int) (int64, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("could not encode job params: %w", err)
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	res, err := db.Exec("INSERT INTO jobs (type, params, status, max_attempts, created_at) VALUES (?, ?, ?, ?, ?)",
+		jobType, string(data), JobQueued, maxAttempts, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("could not insert job: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetJob returns the job with the given ID, or nil if it does not exist.
+func GetJob(db *sql.DB, id int64) (*Job, error) {
+	job, err := scanJob(db.QueryRow("SELECT "+jobSelectColumns+" FROM jobs WHERE id = ?;", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// GetJobs returns the most recent jobs, newest first. Empty jobType and
+// status match all jobs; limit <= 0 returns all of them.
+func GetJobs(db *sql.DB, jobType, status string, limit int) ([]Job, error) {
+	query := "SELECT " + jobSelectColumns + " FROM jobs WHERE 1 = 1"
+	var args []interface{}
+	if jobType != "" {
+		query += " AND type = ?"
+		args = append(args, jobType)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan job row: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetActiveJob returns the oldest queued or running job of the given type, or
+// nil if there is none.
+func GetActiveJob(db *sql.DB, jobType string) (*Job, error) {
+	job, err := scanJob(db.QueryRow("SELECT "+jobSelectColumns+" FROM jobs WHERE type = ? AND status IN (?, ?) ORDER BY id LIMIT 1;",
+		jobType, JobQueued, JobRunning))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get active %s job: %w", jobType, err)
+	}
+	return job, nil
+}
+
+// ClaimNextJob marks the oldest queued job that is due as running and returns
+// it, or returns nil if there is none. Only jobs of the given types are claimed.
+func ClaimNextJob(db *sql.DB, types []string) (*Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	args := []interface{}{JobRunning, now, JobQueued, now}
+	placeholders := ""
+	for i, t := range types {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, t)
+	}
+
+	job, err := scanJob(db.QueryRow(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, started_at = ?, error = NULL
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND (run_after IS NULL OR run_after <= ?) AND type IN (`+placeholders+`)
+			ORDER BY id LIMIT 1
+		)
+		RETURNING `+jobSelectColumns+`;
+	`, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not claim job: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateJobProgress records the progress of a running job.
+func UpdateJobProgress(db *sql.DB, id int64, progress, total int, message string) error {
+	_, err := db.Exec("UPDATE jobs SET progress = ?, total = ?, message = ? WHERE id = ?;", progress, total, nullIfEmpty(message), id)
+	if err != nil {
+		return fmt.Errorf("could not update progress of job %d: %w", id, err)
+	}
+	return nil
+}
+
+// FinishJob records the final status of a job. result is stored as JSON if not nil.
+func FinishJob(db *sql.DB, id int64, status string, result interface{}, jobErr error) error {
+	var resultJSON interface{}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("could not encode result of job %d: %w", id, err)
+		}
+		resultJSON = string(data)
+	}
+	var errText interface{}
+	if jobErr != nil {
+		errText = jobErr.Error()
+	}
+	_, err := db.Exec("UPDATE jobs SET status = ?, result = ?, error = ?, finished_at = ? WHERE id = ?;",
+		status, resultJSON, errText, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("could not finish job %d: %w", id, err)
+	}
+	return nil
+}
+
+// RetryJob queues a job again to run no earlier than runAfter. The error of
+// the last attempt is kept until the job is started again.
+func RetryJob(db *sql.DB, id int64, runAfter time.Time, jobErr error) error {
+	var errText interface{}
+	if jobErr != nil {
+		errText = jobErr.Error()
+	}
+	_, err := db.Exec("UPDATE jobs SET status = ?, error = ?, run_after = ? WHERE id = ?;",
+		JobQueued, errText, runAfter.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("could not retry job %d: %w", id, err)
+	}
+	return nil
+}
+
+// RequeueJob queues a failed or canceled job again with a fresh set of
+// attempts. It returns an error wrapping sql.ErrNoRows if the job does not
+// exist or has not finished unsuccessfully.
+func RequeueJob(db *sql.DB, id int64) error {
+	res, err := db.Exec(`
+		UPDATE jobs SET status = ?, attempts = 0, progress = 0, total = 0, message = NULL, error = NULL,
+			result = NULL, run_after = NULL, started_at = NULL, finished_at = NULL
+		WHERE id = ? AND status IN (?, ?);
+	`, JobQueued, id, JobFailed, JobCanceled)
+	if err != nil {
+		return fmt.Errorf("could not requeue job %d: %w", id, err)
+	}
+	return requireRow(res, "failed or canceled job", id)
+}
+
+// CancelQueuedJob cancels a job that has not started yet. It reports whether
+// the job was queued.
+func CancelQueuedJob(db *sql.DB, id int64) (bool, error) {
+	res, err := db.Exec("UPDATE jobs SET status = ?, finished_at = ? WHERE id = ? AND status = ?;",
+		JobCanceled, time.Now().UTC(), id, JobQueued)
+	if err != nil {
+		return false, fmt.Errorf("could not cancel job %d: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteJob deletes a finished job.
+func DeleteJob(db *sql.DB, id int64) error {
+	res, err := db.Exec("DELETE FROM jobs WHERE id = ? AND status IN (?, ?, ?);", id, JobSucceeded, JobFailed, JobCanceled)
+	if err != nil {
+		return fmt.Errorf("could not delete job %d: %w", id, err)
+	}
+	return requireRow(res, "finished job", id)
+}
+
+// InterruptJob queues a running job again because the server is shutting
+// down. The interrupted attempt is not counted and its progress is kept, so
+// the job can resume.
+func InterruptJob(db *sql.DB, id int64) error {
+	_, err := db.Exec("UPDATE jobs SET status = ?, attempts = MAX(attempts - 1, 0) WHERE id = ? AND status = ?;", JobQueued, id, JobRunning)
+	if err != nil {
+		return fmt.Errorf("could not requeue interrupted job %d: %w", id, err)
+	}
+	return nil
+}
+
+// RequeueInterruptedJobs queues the jobs left running by a previous process
+// again, so they resume after a restart. The interrupted attempt is not
+// counted. It returns the number of requeued jobs.
+func RequeueInterruptedJobs(db *sql.DB) (int64, error) {
+	res, err := db.Exec("UPDATE jobs SET status = ?, attempts = MAX(attempts - 1, 0) WHERE status = ?;", JobQueued, JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("could not requeue interrupted jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
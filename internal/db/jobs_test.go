@@ -0,0 +1,201 @@
+package db_test
+
+import (
+	"database/sql"
+	"errors"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+// createJob queues a job and returns its ID.
+func createJob(t *testing.T, store db.JobStore, jobType string) int64 {
+	t.Helper()
+	id, err := store.CreateJob(jobType, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// getJob returns a job that must exist.
+func getJob(t *testing.T, store db.JobStore, id int64) *db.Job {
+	t.Helper()
+	job, err := store.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatalf("job %d not found", id)
+	}
+	return job
+}
+
+// claim claims the next job of types and returns its ID, or 0 if there is none.
+func claim(t *testing.T, store db.JobStore, types ...string) int64 {
+	t.Helper()
+	job, err := store.ClaimNextJob(types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		return 0
+	}
+	if job.Status != db.JobRunning || job.StartedAt == "" {
+		t.Errorf("claimed job %d is %s, started at %q", job.ID, job.Status, job.StartedAt)
+	}
+	return job.ID
+}
+
+func TestClaimNextJob(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		a := createJob(t, store, "a")
+		b := createJob(t, store, "b")
+		c := createJob(t, store, "a")
+
+		if got := claim(t, store); got != 0 {
+			t.Errorf("claiming no types got job %d", got)
+		}
+		if got := claim(t, store, "b"); got != b {
+			t.Errorf("claimed job %d, want %d", got, b)
+		}
+		// The oldest queued job of the types comes first.
+		if got := claim(t, store, "a", "b"); got != a {
+			t.Errorf("claimed job %d, want %d", got, a)
+		}
+		if got := claim(t, store, "a"); got != c {
+			t.Errorf("claimed job %d, want %d", got, c)
+		}
+		if got := claim(t, store, "a", "b"); got != 0 {
+			t.Errorf("claimed job %d, want none", got)
+		}
+		if job := getJob(t, store, a); job.Attempts != 1 {
+			t.Errorf("attempts = %d, want 1", job.Attempts)
+		}
+	})
+}
+
+func TestRetryJob(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		id := createJob(t, store, "a")
+		claim(t, store, "a")
+
+		if err := store.RetryJob(id, time.Now().Add(time.Hour), errors.New("boom")); err != nil {
+			t.Fatal(err)
+		}
+		job := getJob(t, store, id)
+		if job.Status != db.JobQueued || job.Error != "boom" || job.RunAfter == "" {
+			t.Errorf("got status %s, error %q, run after %q", job.Status, job.Error, job.RunAfter)
+		}
+		if got := claim(t, store, "a"); got != 0 {
+			t.Fatalf("claimed job %d before it is due", got)
+		}
+
+		if err := store.RetryJob(id, time.Now().Add(-time.Minute), errors.New("boom")); err != nil {
+			t.Fatal(err)
+		}
+		if got := claim(t, store, "a"); got != id {
+			t.Fatalf("claimed job %d, want %d", got, id)
+		}
+		// The error of the last attempt is cleared once the job runs again.
+		if job := getJob(t, store, id); job.Attempts != 2 || job.Error != "" {
+			t.Errorf("got %d attempts and error %q, want 2 and none", job.Attempts, job.Error)
+		}
+	})
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		queued := createJob(t, store, "a")
+		running := createJob(t, store, "b")
+		claim(t, store, "b")
+
+		for _, tt := range []struct {
+			id   int64
+			want bool
+		}{{queued, true}, {queued, false}, {running, false}, {999, false}} {
+			got, err := store.CancelQueuedJob(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CancelQueuedJob(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+		}
+		if job := getJob(t, store, queued); job.Status != db.JobCanceled || job.FinishedAt == "" || !job.Finished() {
+			t.Errorf("canceled job is %s, finished at %q", job.Status, job.FinishedAt)
+		}
+		if job := getJob(t, store, running); job.Status != db.JobRunning {
+			t.Errorf("running job is %s", job.Status)
+		}
+	})
+}
+
+func TestRequeueJob(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		failed := createJob(t, store, "a")
+		claim(t, store, "a")
+		if err := store.UpdateJobProgress(failed, 2, 5, "step"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.FinishJob(failed, db.JobFailed, nil, errors.New("boom")); err != nil {
+			t.Fatal(err)
+		}
+		queued := createJob(t, store, "a")
+
+		if err := store.RequeueJob(failed); err != nil {
+			t.Fatal(err)
+		}
+		job := getJob(t, store, failed)
+		if job.Status != db.JobQueued || job.Attempts != 0 || job.Progress != 0 || job.Total != 0 ||
+			job.Message != "" || job.Error != "" || job.StartedAt != "" || job.FinishedAt != "" {
+			t.Errorf("requeued job was not reset: %+v", job)
+		}
+		if job.MaxAttempts != 3 {
+			t.Errorf("max attempts = %d, want 3", job.MaxAttempts)
+		}
+
+		for _, id := range []int64{queued, failed, 999} {
+			if err := store.RequeueJob(id); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("RequeueJob(%d) = %v, want sql.ErrNoRows", id, err)
+			}
+		}
+	})
+}
+
+func TestRequeueInterruptedJobs(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		a := createJob(t, store, "a")
+		b := createJob(t, store, "a")
+		queued := createJob(t, store, "b")
+		claim(t, store, "a")
+		claim(t, store, "a")
+		if err := store.UpdateJobProgress(a, 4, 10, "halfway"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.InterruptJob(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.InterruptJob(queued); err != nil {
+			t.Fatal(err)
+		}
+		n, err := store.RequeueInterruptedJobs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("requeued %d jobs, want 1", n)
+		}
+		// Interrupted attempts are not counted and the progress is kept.
+		for _, id := range []int64{a, b, queued} {
+			if job := getJob(t, store, id); job.Status != db.JobQueued || job.Attempts != 0 {
+				t.Errorf("job %d is %s after %d attempts, want queued after 0", id, job.Status, job.Attempts)
+			}
+		}
+		if job := getJob(t, store, a); job.Progress != 4 || job.Message != "halfway" {
+			t.Errorf("progress of job %d = %d (%q), want 4 (halfway)", a, job.Progress, job.Message)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Databases created before versioned migrations have no schema_version table.
+// Their schema was created and upgraded in place on every start, so it may be
+// in any of the states shipped over time. baselineLegacySchema brings it to
+// the state of migration 1, which is then recorded as applied.
+
+// repoTableBodySQL is the column list of the repositories table as of
+// migration 1. It must not change; later migrations alter the table instead.
+const repoTableBodySQL = `(
+		id INTEGER NOT NULL PRIMARY KEY,
+		source TEXT NOT NULL DEFAULT 'github',
+		source_id INTEGER NOT NULL,
+		full_name TEXT NOT NULL,
+		description TEXT,
+		url TEXT,
+		language TEXT,
+		stargazers_count INTEGER,
+		readme_content TEXT,
+		summary TEXT,
+		etag TEXT,
+		last_synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		starred_at TIMESTAMP,
+		pushed_at TIMESTAMP,
+		topics TEXT,
+		license TEXT,
+		is_archived BOOLEAN NOT NULL DEFAULT 0,
+		is_fork BOOLEAN NOT NULL DEFAULT 0,
+		unstarred_at TIMESTAMP,
+		embedding BLOB,
+		embedding_model TEXT,
+		tags TEXT,
+		UNIQUE (source, source_id),
+		UNIQUE (source, full_name)
+	)`
+
+// baselineLegacySchema upgrades a database created before versioned
+// migrations to the schema of migration 1, whose statements are given as
+// initialSQL. They only create what is missing, so the columns added to
+// existing tables over time are added here first.
+func baselineLegacySchema(tx *sql.Tx, initialSQL string) error {
+	if err := upgradeRepoTable(tx); err != nil {
+		return err
+	}
+	if err := rebuildRepoTableForSources(tx); err != nil {
+		return err
+	}
+	rebuildFts, err := dropOutdatedFtsTable(tx)
+	if err != nil {
+		return err
+	}
+	for _, col := range []struct{ table, name, decl string }{
+		{"list_repositories", "membership", "TEXT NOT NULL DEFAULT 'ai'"},
+		{"sync_runs", "source", "TEXT NOT NULL DEFAULT 'github'"},
+	} {
+		ok, err := hasTable(tx, col.table)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := addColumnIfMissing(tx, col.table, col.name, col.decl); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(initialSQL); err != nil {
+		return fmt.Errorf("could not create missing tables: %w", err)
+	}
+	if rebuildFts {
+		if _, err := tx.Exec("INSERT INTO repos_fts(repos_fts) VALUES ('rebuild');"); err != nil {
+			return fmt.Errorf("could not rebuild full-text index: %w", err)
+		}
+	}
+	return nil
+}
+
+// repoColumnUpgrades lists columns added to the repositories table after its
+// first release. Databases created by older versions get them via ALTER TABLE.
+var repoColumnUpgrades = []struct{ name, decl string }{
+	{"starred_at", "TIMESTAMP"},
+	{"pushed_at", "TIMESTAMP"},
+	{"topics", "TEXT"},
+	{"license", "TEXT"},
+	{"is_archived", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"is_fork", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"unstarred_at", "TIMESTAMP"},
+	{"embedding", "BLOB"},
+	{"embedding_model", "TEXT"},
+	{"tags", "TEXT"},
+}
+
+// upgradeRepoTable adds any missing columns to an existing repositories table.
+func upgradeRepoTable(tx *sql.Tx) error {
+	for _, col := range repoColumnUpgrades {
+		if err := addColumnIfMissing(tx, "repositories", col.name, col.decl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildRepoTableForSources migrates a repositories table created before
+// multiple sources were supported. SQLite cannot change the old UNIQUE
+// constraint on full_name in place, so the table is copied. Existing rows all
+// came from github.com and keep their id, so FTS rowids and list memberships
+// stay valid.
+func rebuildRepoTableForSources(tx *sql.Tx) error {
+	ok, err := hasColumn(tx, "repositories", "source")
+	if err != nil || ok {
+		return err
+	}
+
+	const columns = `full_name, description, url, language, stargazers_count, readme_content, summary, etag,
+		last_synced_at, starred_at, pushed_at, topics, license, is_archived, is_fork, unstarred_at`
+	stmts := []string{
+		`CREATE TABLE repositories_new ` + repoTableBodySQL + `;`,
+		`INSERT INTO repositories_new (id, source, source_id, ` + columns + `)
+			SELECT id, 'github', id, ` + columns + ` FROM repositories;`,
+		// Dropping the table also drops its triggers; migration 1 recreates them.
+		`DROP TABLE repositories;`,
+		`ALTER TABLE repositories_new RENAME TO repositories;`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("could not rebuild repositories table: %w", err)
+		}
+	}
+	return nil
+}
+
+// dropOutdatedFtsTable drops a full-text index created before tags were
+// indexed, together with its triggers, and reports whether it has to be rebuilt.
+func dropOutdatedFtsTable(tx *sql.Tx) (bool, error) {
+	exists, err := hasTable(tx, "repos_fts")
+	if err != nil || !exists {
+		return false, err
+	}
+	ok, err := hasColumn(tx, "repos_fts", "tags")
+	if err != nil || ok {
+		return false, err
+	}
+
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS repos_ai;",
+		"DROP TRIGGER IF EXISTS repos_ad;",
+		"DROP TRIGGER IF EXISTS repos_au;",
+		"DROP TABLE repos_fts;",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return false, fmt.Errorf("could not drop outdated full-text index: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// addColumnIfMissing runs ALTER TABLE ADD COLUMN unless the column already exists.
+func addColumnIfMissing(tx *sql.Tx, table, column, decl string) error {
+	ok, err := hasColumn(tx, table, column)
+	if err != nil || ok {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, decl)); err != nil {
+		return fmt.Errorf("could not add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// hasColumn reports whether table has the given column.
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return false, fmt.Errorf("could not inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("could not scan table info for %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// hasTable reports whether a table of the given name exists.
+func hasTable(q queryer, table string) (bool, error) {
+	var n int
+	if err := q.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", table).Scan(&n); err != nil {
+		return false, fmt.Errorf("could not inspect table %s: %w", table, err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// List memberships record how a repository came to be in a list.
+const (
+	// ListMembershipAI marks repositories put into a list by AI classification.
+	// They are replaced whenever the list is classified again.
+	ListMembershipAI = "ai"
+	// ListMembershipPinned marks repositories added by hand. They stay in the
+	// list regardless of classification.
+	ListMembershipPinned = "pinned"
+	// ListMembershipExcluded marks repositories removed by hand. They are
+	// hidden from the list and never added back by classification.
+	ListMembershipExcluded = "excluded"
+)
+
+// ErrListExists is returned when a list is created or renamed to the name of another list.
+var ErrListExists = errors.New("a list with this name already exists")
+
+// ListRepository is a repository of a list together with its membership.
+type ListRepository struct {
+	Repository
+	// Membership is ListMembershipAI or ListMembershipPinned.
+	Membership string
+}
+
+// GetListByID returns the list with the given ID, or nil if it does not exist.
+func GetListByID(db *sql.DB, id int64) (*List, error) {
+	return getList(db, "id", id)
+}
+
+// GetListByName returns the list called name, or nil if it does not exist.
+func GetListByName(db *sql.DB, name string) (*List, error) {
+	return getList(db, "name", strings.TrimSpace(name))
+}
+
+// getList returns the list whose column equals value, or nil.
+func getList(db *sql.DB, column string, value interface{}) (*List, error) {
+	var l List
+	var prompt sql.NullString
+	err := db.QueryRow(`
+		SELECT l.id, l.name, l.prompt, l.created_at,
+			(SELECT COUNT(*) FROM list_repositories lr WHERE lr.list_id = l.id AND lr.membership != 'excluded')
+		FROM lists l WHERE l.`+column+` = ?;
+	`, value).Scan(&l.ID, &l.Name, &prompt, &l.CreatedAt, &l.RepoCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get list %v: %w", value, err)
+	}
+	l.Prompt = prompt.String
+	return &l, nil
+}
+
+// UpdateList changes the name and the classification prompt of a list; nil
+// leaves a field as it is. Both change together or not at all. The current
+// members stay until the list is classified again.
+func UpdateList(db *sql.DB, id int64, name, prompt *string) error {
+	var newName interface{}
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return fmt.Errorf("list name must not be empty")
+		}
+		newName = trimmed
+	}
+	var newPrompt interface{}
+	if prompt != nil {
+		newPrompt = *prompt
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if newName != nil {
+		var existing int64
+		err := tx.QueryRow("SELECT id FROM lists WHERE name = ?;", newName).Scan(&existing)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("could not check list name: %w", err)
+		}
+		if err == nil && existing != id {
+			return ErrListExists
+		}
+	}
+	res, err := tx.Exec("UPDATE lists SET name = COALESCE(?, name), prompt = COALESCE(?, prompt) WHERE id = ?;", newName, newPrompt, id)
+	if err != nil {
+		return fmt.Errorf("could not update list %d: %w", id, err)
+	}
+	if err := requireRow(res, "list", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteList deletes a list and its memberships.
+func DeleteList(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Foreign keys are not enforced on this connection, so cascade by hand.
+	if _, err := tx.Exec("DELETE FROM list_repositories WHERE list_id = ?;", id); err != nil {
+		return fmt.Errorf("could not delete repositories of list %d: %w", id, err)
+	}
+	res, err := tx.Exec("DELETE FROM lists WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("could not delete list %d: %w", id, err)
+	}
+	if err := requireRow(res, "list", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PinRepoToList adds a repository to a list by hand.
+func PinRepoToList(db *sql.DB, listID, repoID int64) error {
+	return setListMembership(db, listID, repoID, ListMembershipPinned)
+}
+
+// ExcludeRepoFromList removes a repository from a list by hand, so that
+// classification does not add it back.
+func ExcludeRepoFromList(db *sql.DB, listID, repoID int64) error {
+	return setListMembership(db, listID, repoID, ListMembershipExcluded)
+}
+
+// setListMembership records a manual membership after checking that the list
+// and the repository exist.
+func setListMembership(db *sql.DB, listID, repoID int64, membership string) error {
+	var lists, repos int
+	err := db.QueryRow("SELECT (SELECT COUNT(*) FROM lists WHERE id = ?), (SELECT COUNT(*) FROM repositories WHERE id = ?);", listID, repoID).
+		Scan(&lists, &repos)
+	if err != nil {
+		return fmt.Errorf("could not look up list %d and repo %d: %w", listID, repoID, err)
+	}
+	if lists == 0 {
+		return fmt.Errorf("list %d not found: %w", listID, sql.ErrNoRows)
+	}
+	if repos == 0 {
+		return fmt.Errorf("repository %d not found: %w", repoID, sql.ErrNoRows)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO list_repositories (list_id, repository_id, membership) VALUES (?, ?, ?)
+		ON CONFLICT(list_id, repository_id) DO UPDATE SET membership = excluded.membership;
+	`, listID, repoID, membership)
+	if err != nil {
+		return fmt.Errorf("could not set membership of repo %d in list %d: %w", repoID, listID, err)
+	}
+	return nil
+}
+
+// ReplaceListClassification replaces the AI-classified repositories of a list
+// with repoIDs. Pinned and excluded repositories are left as they are.
+func ReplaceListClassification(db *sql.DB, listID int64, repoIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM list_repositories WHERE list_id = ? AND membership = 'ai';", listID); err != nil {
+		return fmt.Errorf("could not clear classification of list %d: %w", listID, err)
+	}
+	if err := addReposToList(tx, listID, repoIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetReposForListClassification retrieves the starred repositories that AI
+// classification should consider for a list: all except those pinned to or
+// excluded from it by hand.
+func GetReposForListClassification(db *sql.DB, listID int64) ([]Repository, error) {
+	query := `
+		SELECT ` + repoSelectColumns + `
+		FROM repositories r
+		WHERE r.unstarred_at IS NULL
+		AND r.id NOT IN (SELECT repository_id FROM list_repositories WHERE list_id = ? AND membership != 'ai')
+		ORDER BY r.stargazers_count DESC;
+	`
+	rows, err := db.Query(query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos for list classification: %w", err)
+	}
+	defer rows.Close()
+
+	var repos []Repository
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row: %w", err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+// GetReposByListID retrieves all repositories for a given list ID, leaving out
+// those excluded by hand.
+func GetReposByListID(db *sql.DB, listID int64) ([]ListRepository, error) {
+	query := `
+		SELECT ` + repoSelectColumns + `, lr.membership
+		FROM repositories r
+		JOIN list_repositories lr ON r.id = lr.repository_id
+		WHERE lr.list_id = ? AND lr.membership != 'excluded'
+		ORDER BY r.stargazers_count DESC;
+	`
+	rows, err := db.Query(query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos by list ID: %w", err)
+	}
+	defer rows.Close()
+
+	var repos []ListRepository
+	for rows.Next() {
+		var lr ListRepository
+		lr.Repository, err = scanRepository(rows, &lr.Membership)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row for list: %w", err)
+		}
+		repos = append(repos, lr)
+	}
+	return repos, rows.Err()
+}
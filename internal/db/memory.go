@@ -0,0 +1,1684 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryStore is a Store that keeps everything in memory, for tests of code
+// that depends on a Store. It behaves like SQLiteStore, except that full-text
+// queries only support the syntax this program generates (words, quoted
+// phrases, prefixes marked with * and OR), ignore the order of the words of a
+// phrase and rank by the number of matching words, and that it takes no
+// snapshots.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	repos map[int64]*memoryRepo
+	// lists, tags and topics hold the rows without their repository counts.
+	lists       map[int64]*List
+	listMembers map[int64]map[int64]string // list ID -> repository ID -> membership
+	tags        map[int64]*Tag
+	repoTags    map[int64]map[int64]bool // repository ID -> tag IDs
+	topics      map[int64]*Topic
+	topicRepos  map[int64]map[int64]memoryAssignment // topic ID -> repository ID -> assignment
+	jobs        map[int64]*Job
+	tokens      map[int64]*memoryToken
+	sessions    map[string]*memorySession
+	syncRuns    map[int64]*SyncRun
+	syncSeen    map[int64]map[int64]bool // sync run ID -> source IDs seen
+
+	lastRepoID, lastListID, lastTagID, lastTopicID, lastJobID, lastTokenID, lastSyncRunID int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// memoryRepo is a stored repository with the columns not in Repository.
+type memoryRepo struct {
+	Repository
+	embedding      []float32
+	embeddingModel string
+}
+
+type memoryAssignment struct {
+	confidence float64
+	source     string
+}
+
+type memoryToken struct {
+	APIToken
+	hash     string
+	lastUsed time.Time
+}
+
+type memorySession struct {
+	Session
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		repos:       make(map[int64]*memoryRepo),
+		lists:       make(map[int64]*List),
+		listMembers: make(map[int64]map[int64]string),
+		tags:        make(map[int64]*Tag),
+		repoTags:    make(map[int64]map[int64]bool),
+		topics:      make(map[int64]*Topic),
+		topicRepos:  make(map[int64]map[int64]memoryAssignment),
+		jobs:        make(map[int64]*Job),
+		tokens:      make(map[int64]*memoryToken),
+		sessions:    make(map[string]*memorySession),
+		syncRuns:    make(map[int64]*SyncRun),
+		syncSeen:    make(map[int64]map[int64]bool),
+	}
+}
+
+// memoryNow returns the current time the way the database returns timestamps.
+func memoryNow() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// memoryCreatedAt returns the current time the way the database returns
+// columns defaulting to CURRENT_TIMESTAMP.
+func memoryCreatedAt() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// AddRepository stores repo, including its README, and returns its ID. A repo
+// without an ID gets the next free one; one with an existing ID replaces it.
+func (m *MemoryStore) AddRepository(repo Repository) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if repo.ID == 0 {
+		repo.ID = m.lastRepoID + 1
+	}
+	m.lastRepoID = max(m.lastRepoID, repo.ID)
+	repo.Source = repoSource(repo)
+	repo.Tags = nil
+	repo.Topics = append([]string(nil), repo.Topics...)
+	m.repos[repo.ID] = &memoryRepo{Repository: repo}
+	return repo.ID
+}
+
+func (m *MemoryStore) ReplaceTopics(topics []Topic, assignments []TopicAssignment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make(map[string]int64, len(topics))
+	for _, t := range topics {
+		var existing *Topic
+		for _, old := range m.topics {
+			if old.Name == t.Name {
+				existing = old
+			}
+		}
+		if existing == nil {
+			m.lastTopicID++
+			existing = &Topic{ID: m.lastTopicID, Name: t.Name, CreatedAt: memoryCreatedAt()}
+			m.topics[existing.ID] = existing
+		}
+		existing.Description = t.Description
+		ids[t.Name] = existing.ID
+	}
+	for id, t := range m.topics {
+		if _, ok := ids[t.Name]; !ok {
+			delete(m.topics, id)
+		}
+	}
+
+	m.topicRepos = make(map[int64]map[int64]memoryAssignment)
+	for _, a := range assignments {
+		id, ok := ids[a.Topic]
+		if !ok {
+			continue
+		}
+		if m.topicRepos[id] == nil {
+			m.topicRepos[id] = make(map[int64]memoryAssignment)
+		}
+		// A GitHub assignment wins over an AI one for the same repository and topic.
+		old, exists := m.topicRepos[id][a.RepoID]
+		if exists {
+			a.Confidence = max(a.Confidence, old.confidence)
+			if a.Source != TopicSourceGitHub {
+				a.Source = old.source
+			}
+		}
+		m.topicRepos[id][a.RepoID] = memoryAssignment{confidence: a.Confidence, source: a.Source}
+	}
+	return nil
+}
+
+// repository returns a copy of the stored repository as the database returns
+// it: with its tags but without its README.
+func (m *MemoryStore) repository(r *memoryRepo) Repository {
+	repo := r.Repository
+	repo.ReadmeContent = ""
+	repo.Topics = append([]string(nil), r.Topics...)
+	repo.Tags = nil
+	for _, t := range m.sortedTags(m.repoTags[r.ID]) {
+		repo.Tags = append(repo.Tags, t.Name)
+	}
+	return repo
+}
+
+// starred returns the repositories that are not unstarred and satisfy keep,
+// ordered by stars, most first.
+func (m *MemoryStore) starred(keep func(r *memoryRepo) bool) []Repository {
+	var repos []Repository
+	for _, r := range m.sortedRepos() {
+		if r.UnstarredAt == "" && (keep == nil || keep(r)) {
+			repos = append(repos, m.repository(r))
+		}
+	}
+	return repos
+}
+
+// sortedRepos returns all stored repositories ordered by stars, most first.
+func (m *MemoryStore) sortedRepos() []*memoryRepo {
+	repos := make([]*memoryRepo, 0, len(m.repos))
+	for _, r := range m.repos {
+		repos = append(repos, r)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].StargazersCount != repos[j].StargazersCount {
+			return repos[i].StargazersCount > repos[j].StargazersCount
+		}
+		return repos[i].ID < repos[j].ID
+	})
+	return repos
+}
+
+func (m *MemoryStore) QueryRepositories(q RepoQuery) (*RepoPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if q.Sort == "" {
+		q.Sort = SortStars
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		}
+	}
+	order, ok := sortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key: %s", q.Sort)
+	}
+	if q.Sort == SortRelevance && q.Text == "" {
+		return nil, fmt.Errorf("sorting by relevance requires a text query")
+	}
+	desc := order.desc
+	switch q.Order {
+	case "":
+	case OrderAsc:
+		desc = false
+	case OrderDesc:
+		desc = true
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", q.Order)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	var text memoryQuery
+	if q.Text != "" {
+		text = parseMemoryQuery(prefixTermsQuery(q.Text))
+	}
+	relevance := make(map[int64]int)
+	matches := m.starred(func(r *memoryRepo) bool {
+		if q.Text != "" {
+			score := text.score(m.searchColumns(r))
+			if score == 0 {
+				return false
+			}
+			relevance[r.ID] = score
+		}
+		return m.matchesFilter(r, q.RepoFilter)
+	})
+
+	// key returns the sort value of a repository, or nil for a missing one.
+	key := func(r Repository) interface{} {
+		switch q.Sort {
+		case SortStars:
+			return r.StargazersCount
+		case SortStarredAt:
+			return nilIfEmpty(r.StarredAt)
+		case SortPushedAt:
+			return nilIfEmpty(r.PushedAt)
+		case SortName:
+			return strings.ToLower(r.FullName)
+		default:
+			return relevance[r.ID]
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := key(matches[i]), key(matches[j])
+		// Missing values sort last in either direction; ties are broken by ID.
+		if (a == nil) != (b == nil) {
+			return b == nil
+		}
+		if c := compareValues(a, b); c != 0 {
+			return (c < 0) != desc
+		}
+		return (matches[i].ID < matches[j].ID) != desc
+	})
+
+	page := &RepoPage{Repositories: []Repository{}, Total: len(matches), Limit: q.Limit, Offset: q.Offset}
+	if q.Offset < len(matches) {
+		matches = matches[q.Offset:]
+		if q.Limit > 0 && len(matches) > q.Limit {
+			matches = matches[:q.Limit]
+		}
+		page.Repositories = append(page.Repositories, matches...)
+	}
+	return page, nil
+}
+
+// nilIfEmpty returns nil for an empty string, like a NULL column.
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// compareValues compares two non-nil sort values of the same type.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return a - b.(int)
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// matchesFilter reports whether r matches all conditions of f except Text.
+func (m *MemoryStore) matchesFilter(r *memoryRepo, f RepoFilter) bool {
+	if f.Language != "" && !strings.EqualFold(r.Language, f.Language) {
+		return false
+	}
+	if f.TagID != 0 && !m.repoTags[r.ID][f.TagID] {
+		return false
+	}
+	if f.TopicID != 0 {
+		a, ok := m.topicRepos[f.TopicID][r.ID]
+		if !ok || a.confidence < f.MinTopicConfidence {
+			return false
+		}
+	}
+	if f.ListID != 0 {
+		membership, ok := m.listMembers[f.ListID][r.ID]
+		if !ok || membership == ListMembershipExcluded {
+			return false
+		}
+	}
+	if f.MinStars != nil && r.StargazersCount < *f.MinStars {
+		return false
+	}
+	if f.MaxStars != nil && r.StargazersCount > *f.MaxStars {
+		return false
+	}
+	if f.Archived != nil && r.IsArchived != *f.Archived {
+		return false
+	}
+	if f.HasSummary != nil && (r.Summary != "") != *f.HasSummary {
+		return false
+	}
+	if f.StarredAfter != "" && (r.StarredAt == "" || r.StarredAt < f.StarredAfter) {
+		return false
+	}
+	if f.StarredBefore != "" && (r.StarredAt == "" || r.StarredAt >= f.StarredBefore) {
+		return false
+	}
+	return true
+}
+
+func (m *MemoryStore) GetRepositoryByID(id int64) (*Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.repos[id]
+	if !ok {
+		return nil, nil
+	}
+	repo := m.repository(r)
+	return &repo, nil
+}
+
+func (m *MemoryStore) FindRepositoriesByName(fullName string) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []Repository
+	for _, r := range m.reposByID() {
+		if strings.EqualFold(r.FullName, fullName) {
+			repos = append(repos, m.repository(r))
+		}
+	}
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].Source < repos[j].Source })
+	return repos, nil
+}
+
+func (m *MemoryStore) GetAllRepositories() ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.starred(nil), nil
+}
+
+func (m *MemoryStore) GetRepoReadme(repoID int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.repos[repoID]
+	if !ok {
+		return "", fmt.Errorf("could not get README of repo %d: %w", repoID, sql.ErrNoRows)
+	}
+	return r.ReadmeContent, nil
+}
+
+// reposByID returns all stored repositories ordered by ID.
+func (m *MemoryStore) reposByID() []*memoryRepo {
+	repos := make([]*memoryRepo, 0, len(m.repos))
+	for _, r := range m.repos {
+		repos = append(repos, r)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].ID < repos[j].ID })
+	return repos
+}
+
+func (m *MemoryStore) CreateSyncRun(source, api string) (*SyncRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSyncRunID++
+	run := &SyncRun{ID: m.lastSyncRunID, Source: source, StartedAt: time.Now().Format(time.RFC3339), Status: SyncRunning, API: api}
+	stored := *run
+	m.syncRuns[run.ID] = &stored
+	return run, nil
+}
+
+func (m *MemoryStore) GetResumableSyncRun(source string) (*SyncRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last *SyncRun
+	for _, run := range m.syncRuns {
+		if run.Source == source && (last == nil || run.ID > last.ID) {
+			last = run
+		}
+	}
+	if last == nil || last.Status == SyncCompleted {
+		return nil, nil
+	}
+	run := *last
+	return &run, nil
+}
+
+func (m *MemoryStore) CheckpointSyncRun(run *SyncRun, seenIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.syncRuns[run.ID]
+	if !ok {
+		return fmt.Errorf("could not update sync run %d: %w", run.ID, sql.ErrNoRows)
+	}
+	stored.Status = SyncRunning
+	stored.API = run.API
+	stored.Cursor = run.Cursor
+	stored.Fetched = run.Fetched
+	stored.ReposDone = run.ReposDone
+	stored.Added = run.Added
+	stored.Updated = run.Updated
+	stored.Unchanged = run.Unchanged
+	stored.Failed = run.Failed
+	stored.Error = ""
+	if m.syncSeen[run.ID] == nil {
+		m.syncSeen[run.ID] = make(map[int64]bool)
+	}
+	for _, id := range seenIDs {
+		m.syncSeen[run.ID][id] = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) FinishSyncRun(run *SyncRun, status string, runErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run.Status = status
+	run.Error = ""
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	run.FinishedAt = time.Now().Format(time.RFC3339)
+
+	stored, ok := m.syncRuns[run.ID]
+	if !ok {
+		return fmt.Errorf("could not finish sync run %d: %w", run.ID, sql.ErrNoRows)
+	}
+	stored.Status = run.Status
+	stored.FinishedAt = run.FinishedAt
+	stored.Cursor = run.Cursor
+	stored.ReposDone = run.ReposDone
+	stored.Added = run.Added
+	stored.Updated = run.Updated
+	stored.Unchanged = run.Unchanged
+	stored.Failed = run.Failed
+	stored.Removed = run.Removed
+	stored.Error = run.Error
+	if status == SyncCompleted {
+		delete(m.syncSeen, run.ID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetSyncRunRepoIDs(runID int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int64
+	for id := range m.syncSeen[runID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (m *MemoryStore) GetSyncRuns(limit int) ([]SyncRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []SyncRun
+	for _, run := range m.syncRuns {
+		runs = append(runs, *run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (m *MemoryStore) GetAllReposWithETags(source string) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []Repository
+	for _, r := range m.reposByID() {
+		if r.Source == source {
+			repos = append(repos, Repository{ID: r.ID, Source: source, SourceID: r.SourceID, ETag: r.ETag, ReadmeContent: r.ReadmeContent})
+		}
+	}
+	return repos, nil
+}
+
+func (m *MemoryStore) UpsertRepository(repo Repository) (UpsertOutcome, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	repo.Source = repoSource(repo)
+	repo.Topics = append([]string(nil), repo.Topics...)
+	repo.LastSyncedAt = memoryNow()
+
+	var old *memoryRepo
+	for _, r := range m.repos {
+		if r.Source == repo.Source && r.SourceID == repo.SourceID {
+			old = r
+		}
+	}
+	if old == nil {
+		// Summaries and tags are not synced.
+		m.lastRepoID++
+		repo.ID = m.lastRepoID
+		repo.Summary = ""
+		repo.UnstarredAt = ""
+		repo.Tags = nil
+		m.repos[repo.ID] = &memoryRepo{Repository: repo}
+		return RepoAdded, nil
+	}
+
+	outcome := RepoUnchanged
+	if old.FullName != repo.FullName || old.Description != repo.Description || old.URL != repo.URL ||
+		old.Language != repo.Language || old.StargazersCount != repo.StargazersCount ||
+		old.ReadmeContent != repo.ReadmeContent || old.PushedAt != repo.PushedAt ||
+		joinTopics(old.Topics) != joinTopics(repo.Topics) || old.License != repo.License ||
+		old.IsArchived != repo.IsArchived || old.IsFork != repo.IsFork || old.UnstarredAt != "" {
+		outcome = RepoUpdated
+	}
+	// The embedding is kept only if the embedded text is unchanged.
+	if old.FullName != repo.FullName || old.Description != repo.Description ||
+		joinTopics(old.Topics) != joinTopics(repo.Topics) || old.ReadmeContent != repo.ReadmeContent {
+		old.embedding = nil
+		old.embeddingModel = ""
+	}
+	repo.ID = old.ID
+	repo.Summary = old.Summary
+	repo.Tags = nil
+	repo.UnstarredAt = ""
+	if repo.StarredAt == "" {
+		repo.StarredAt = old.StarredAt
+	}
+	old.Repository = repo
+	return outcome, nil
+}
+
+func (m *MemoryStore) MarkUnstarred(source string, starredIDs []int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(starredIDs) == 0 {
+		n := 0
+		for _, r := range m.repos {
+			if r.Source == source && r.UnstarredAt == "" {
+				n++
+			}
+		}
+		if n > 0 {
+			return 0, fmt.Errorf("%w: no starred repositories were seen, keeping the %d of source %s", ErrEmptyReconcile, n, source)
+		}
+		return 0, nil
+	}
+
+	starred := make(map[int64]bool, len(starredIDs))
+	for _, id := range starredIDs {
+		starred[id] = true
+	}
+	now := memoryNow()
+	var n int64
+	for _, r := range m.repos {
+		if r.Source == source && r.UnstarredAt == "" && !starred[r.SourceID] {
+			r.UnstarredAt = now
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) CountUnstarred(source string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, r := range m.repos {
+		if r.Source == source && r.UnstarredAt != "" {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) PruneUnstarred(source string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, r := range m.repos {
+		if r.Source != source || r.UnstarredAt == "" {
+			continue
+		}
+		for _, members := range m.listMembers {
+			delete(members, id)
+		}
+		for _, assigned := range m.topicRepos {
+			delete(assigned, id)
+		}
+		delete(m.repoTags, id)
+		delete(m.repos, id)
+		n++
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) TakeSnapshot(reason string) (string, error) {
+	return "", nil
+}
+
+func (m *MemoryStore) GetReposForSummarization(limit int) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []Repository
+	for _, r := range m.reposByID() {
+		if len(repos) == limit {
+			break
+		}
+		if r.ReadmeContent != "" && r.Summary == "" && r.UnstarredAt == "" {
+			repos = append(repos, Repository{ID: r.ID, FullName: r.FullName, ReadmeContent: r.ReadmeContent})
+		}
+	}
+	return repos, nil
+}
+
+func (m *MemoryStore) UpdateRepoSummary(repoID int64, summary string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.repos[repoID]
+	if !ok {
+		return fmt.Errorf("could not update summary for repo %d: %w", repoID, sql.ErrNoRows)
+	}
+	// The summary is part of the embedded text.
+	r.Summary = summary
+	r.embedding = nil
+	r.embeddingModel = ""
+	return nil
+}
+
+func (m *MemoryStore) GetReposForEmbedding(model string, limit int) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []Repository
+	for _, r := range m.reposByID() {
+		if limit > 0 && len(repos) == limit {
+			break
+		}
+		if r.UnstarredAt == "" && (r.embedding == nil || r.embeddingModel != model) {
+			repo := m.repository(r)
+			repo.ReadmeContent = r.ReadmeContent
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+func (m *MemoryStore) UpdateRepoEmbedding(repoID int64, model string, embedding []float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.repos[repoID]
+	if !ok {
+		return fmt.Errorf("could not update embedding for repo %d: %w", repoID, sql.ErrNoRows)
+	}
+	r.embedding = append([]float32(nil), embedding...)
+	r.embeddingModel = model
+	return nil
+}
+
+// list returns a copy of a stored list with its repository count.
+func (m *MemoryStore) list(l *List) List {
+	list := *l
+	list.RepoCount = 0
+	for _, membership := range m.listMembers[l.ID] {
+		if membership != ListMembershipExcluded {
+			list.RepoCount++
+		}
+	}
+	return list
+}
+
+func (m *MemoryStore) GetLists() ([]List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lists []List
+	for _, l := range m.lists {
+		lists = append(lists, m.list(l))
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Name < lists[j].Name })
+	return lists, nil
+}
+
+func (m *MemoryStore) GetListByID(id int64) (*List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.lists[id]
+	if !ok {
+		return nil, nil
+	}
+	list := m.list(l)
+	return &list, nil
+}
+
+func (m *MemoryStore) GetListByName(name string) (*List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l := m.listByName(name); l != nil {
+		list := m.list(l)
+		return &list, nil
+	}
+	return nil, nil
+}
+
+// listByName returns the stored list called name, or nil.
+func (m *MemoryStore) listByName(name string) *List {
+	name = strings.TrimSpace(name)
+	for _, l := range m.lists {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateList(name, prompt string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("list name must not be empty")
+	}
+	if m.listByName(name) != nil {
+		return 0, ErrListExists
+	}
+	m.lastListID++
+	m.lists[m.lastListID] = &List{ID: m.lastListID, Name: name, Prompt: prompt, CreatedAt: memoryCreatedAt()}
+	return m.lastListID, nil
+}
+
+func (m *MemoryStore) UpdateList(id int64, name, prompt *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var newName string
+	if name != nil {
+		if newName = strings.TrimSpace(*name); newName == "" {
+			return fmt.Errorf("list name must not be empty")
+		}
+		if existing := m.listByName(newName); existing != nil && existing.ID != id {
+			return ErrListExists
+		}
+	}
+	l, ok := m.lists[id]
+	if !ok {
+		return fmt.Errorf("list %d not found: %w", id, sql.ErrNoRows)
+	}
+	if name != nil {
+		l.Name = newName
+	}
+	if prompt != nil {
+		l.Prompt = *prompt
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteList(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lists[id]; !ok {
+		return fmt.Errorf("list %d not found: %w", id, sql.ErrNoRows)
+	}
+	delete(m.lists, id)
+	delete(m.listMembers, id)
+	return nil
+}
+
+func (m *MemoryStore) GetReposByListID(listID int64) ([]ListRepository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []ListRepository
+	for _, r := range m.sortedRepos() {
+		membership, ok := m.listMembers[listID][r.ID]
+		if ok && membership != ListMembershipExcluded {
+			repos = append(repos, ListRepository{Repository: m.repository(r), Membership: membership})
+		}
+	}
+	return repos, nil
+}
+
+func (m *MemoryStore) PinRepoToList(listID, repoID int64) error {
+	return m.setListMembership(listID, repoID, ListMembershipPinned)
+}
+
+func (m *MemoryStore) ExcludeRepoFromList(listID, repoID int64) error {
+	return m.setListMembership(listID, repoID, ListMembershipExcluded)
+}
+
+func (m *MemoryStore) setListMembership(listID, repoID int64, membership string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lists[listID]; !ok {
+		return fmt.Errorf("list %d not found: %w", listID, sql.ErrNoRows)
+	}
+	if _, ok := m.repos[repoID]; !ok {
+		return fmt.Errorf("repository %d not found: %w", repoID, sql.ErrNoRows)
+	}
+	if m.listMembers[listID] == nil {
+		m.listMembers[listID] = make(map[int64]string)
+	}
+	m.listMembers[listID][repoID] = membership
+	return nil
+}
+
+func (m *MemoryStore) GetReposForListClassification(listID int64) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.starred(func(r *memoryRepo) bool {
+		membership, ok := m.listMembers[listID][r.ID]
+		return !ok || membership == ListMembershipAI
+	}), nil
+}
+
+func (m *MemoryStore) ReplaceListClassification(listID int64, repoIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := m.listMembers[listID]
+	if members == nil {
+		members = make(map[int64]string)
+		m.listMembers[listID] = members
+	}
+	for id, membership := range members {
+		if membership == ListMembershipAI {
+			delete(members, id)
+		}
+	}
+	for _, id := range repoIDs {
+		if _, ok := members[id]; !ok {
+			members[id] = ListMembershipAI
+		}
+	}
+	return nil
+}
+
+// sortedTags returns the tags with the given IDs ordered by name, ignoring case.
+func (m *MemoryStore) sortedTags(ids map[int64]bool) []Tag {
+	var tags []Tag
+	for id := range ids {
+		if t, ok := m.tags[id]; ok {
+			tags = append(tags, *t)
+		}
+	}
+	sortTags(tags)
+	return tags
+}
+
+// sortTags orders tags by name, ignoring case like the name column.
+func sortTags(tags []Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i].Name), strings.ToLower(tags[j].Name)
+		if a != b {
+			return a < b
+		}
+		return tags[i].ID < tags[j].ID
+	})
+}
+
+// tagByName returns the stored tag called name, ignoring case, or nil.
+func (m *MemoryStore) tagByName(name string) *Tag {
+	name = strings.TrimSpace(name)
+	for _, t := range m.tags {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetTags() ([]Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tags []Tag
+	for _, t := range m.tags {
+		tag := *t
+		for repoID, ids := range m.repoTags {
+			if r, ok := m.repos[repoID]; ok && ids[t.ID] && r.UnstarredAt == "" {
+				tag.RepoCount++
+			}
+		}
+		tags = append(tags, tag)
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+func (m *MemoryStore) GetTagByID(id int64) (*Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tags[id]
+	if !ok {
+		return nil, nil
+	}
+	tag := *t
+	return &tag, nil
+}
+
+func (m *MemoryStore) GetTagByName(name string) (*Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := m.tagByName(name); t != nil {
+		tag := *t
+		return &tag, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) CreateTag(name, color string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createTag(name, color)
+}
+
+func (m *MemoryStore) createTag(name, color string) (int64, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return 0, err
+	}
+	if m.tagByName(name) != nil {
+		return 0, ErrTagExists
+	}
+	m.lastTagID++
+	m.tags[m.lastTagID] = &Tag{ID: m.lastTagID, Name: name, Color: color, CreatedAt: memoryCreatedAt()}
+	return m.lastTagID, nil
+}
+
+func (m *MemoryStore) GetOrCreateTag(name string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := m.tagByName(name); t != nil {
+		return t.ID, nil
+	}
+	return m.createTag(name, "")
+}
+
+func (m *MemoryStore) UpdateTag(id int64, name, color *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var newName string
+	if name != nil {
+		var err error
+		if newName, err = normalizeTagName(*name); err != nil {
+			return err
+		}
+		if existing := m.tagByName(newName); existing != nil && existing.ID != id {
+			return ErrTagExists
+		}
+	}
+	t, ok := m.tags[id]
+	if !ok {
+		return fmt.Errorf("tag %d not found: %w", id, sql.ErrNoRows)
+	}
+	if name != nil {
+		t.Name = newName
+	}
+	if color != nil {
+		t.Color = *color
+	}
+	return nil
+}
+
+func (m *MemoryStore) MergeTags(fromID, intoID int64) error {
+	if fromID == intoID {
+		return fmt.Errorf("cannot merge tag %d into itself", fromID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, fromOK := m.tags[fromID]
+	_, intoOK := m.tags[intoID]
+	if !fromOK || !intoOK {
+		return fmt.Errorf("could not merge tag %d into %d: %w", fromID, intoID, sql.ErrNoRows)
+	}
+	for _, ids := range m.repoTags {
+		if ids[fromID] {
+			delete(ids, fromID)
+			ids[intoID] = true
+		}
+	}
+	delete(m.tags, fromID)
+	return nil
+}
+
+func (m *MemoryStore) DeleteTag(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tags[id]; !ok {
+		return fmt.Errorf("tag %d not found: %w", id, sql.ErrNoRows)
+	}
+	for _, ids := range m.repoTags {
+		delete(ids, id)
+	}
+	delete(m.tags, id)
+	return nil
+}
+
+func (m *MemoryStore) TagRepository(repoID, tagID int64) error {
+	return m.changeRepoTag(repoID, tagID, true)
+}
+
+func (m *MemoryStore) UntagRepository(repoID, tagID int64) error {
+	return m.changeRepoTag(repoID, tagID, false)
+}
+
+func (m *MemoryStore) changeRepoTag(repoID, tagID int64, tagged bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tags[tagID]; !ok {
+		return fmt.Errorf("tag %d not found: %w", tagID, sql.ErrNoRows)
+	}
+	if _, ok := m.repos[repoID]; !ok {
+		return fmt.Errorf("repository %d not found: %w", repoID, sql.ErrNoRows)
+	}
+	if m.repoTags[repoID] == nil {
+		m.repoTags[repoID] = make(map[int64]bool)
+	}
+	if tagged {
+		m.repoTags[repoID][tagID] = true
+	} else {
+		delete(m.repoTags[repoID], tagID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetRepoTags(repoID int64) ([]Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedTags(m.repoTags[repoID]), nil
+}
+
+func (m *MemoryStore) GetReposByTagID(tagID int64) ([]Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.starred(func(r *memoryRepo) bool { return m.repoTags[r.ID][tagID] }), nil
+}
+
+func (m *MemoryStore) GetTopics() ([]Topic, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var topics []Topic
+	for _, t := range m.topics {
+		topic := *t
+		for repoID := range m.topicRepos[t.ID] {
+			if r, ok := m.repos[repoID]; ok && r.UnstarredAt == "" {
+				topic.RepoCount++
+			}
+		}
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].RepoCount != topics[j].RepoCount {
+			return topics[i].RepoCount > topics[j].RepoCount
+		}
+		return topics[i].Name < topics[j].Name
+	})
+	return topics, nil
+}
+
+func (m *MemoryStore) GetTopicByID(id int64) (*Topic, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.topics[id]
+	if !ok {
+		return nil, nil
+	}
+	topic := *t
+	return &topic, nil
+}
+
+func (m *MemoryStore) GetTopicByName(name string) (*Topic, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.topics {
+		if t.Name == name {
+			topic := *t
+			return &topic, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) GetReposByTopicID(topicID int64, minConfidence float64) ([]TopicRepository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []TopicRepository
+	for _, repo := range m.starred(func(r *memoryRepo) bool {
+		a, ok := m.topicRepos[topicID][r.ID]
+		return ok && a.confidence >= minConfidence
+	}) {
+		a := m.topicRepos[topicID][repo.ID]
+		repos = append(repos, TopicRepository{Repository: repo, Confidence: a.confidence, AssignedBy: a.source})
+	}
+	// starred already orders by stars; a stable sort keeps that for ties.
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].Confidence > repos[j].Confidence })
+	return repos, nil
+}
+
+// job returns a copy of a stored job.
+func (m *MemoryStore) job(j *Job) *Job {
+	job := *j
+	job.Params = append(json.RawMessage(nil), j.Params...)
+	job.Result = append(json.RawMessage(nil), j.Result...)
+	return &job
+}
+
+func (m *MemoryStore) CreateJob(jobType string, params interface{}, maxAttempts int) (int64, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("could not encode job params: %w", err)
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastJobID++
+	m.jobs[m.lastJobID] = &Job{
+		ID:          m.lastJobID,
+		Type:        jobType,
+		Params:      data,
+		Status:      JobQueued,
+		MaxAttempts: maxAttempts,
+		CreatedAt:   memoryNow(),
+	}
+	return m.lastJobID, nil
+}
+
+func (m *MemoryStore) GetJob(id int64) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return m.job(j), nil
+}
+
+func (m *MemoryStore) GetJobs(jobType, status string, limit int) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []Job{}
+	for _, j := range m.jobs {
+		if (jobType == "" || j.Type == jobType) && (status == "" || j.Status == status) {
+			jobs = append(jobs, *m.job(j))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (m *MemoryStore) GetActiveJob(jobType string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active *Job
+	for _, j := range m.jobs {
+		if j.Type == jobType && (j.Status == JobQueued || j.Status == JobRunning) && (active == nil || j.ID < active.ID) {
+			active = j
+		}
+	}
+	if active == nil {
+		return nil, nil
+	}
+	return m.job(active), nil
+}
+
+func (m *MemoryStore) ClaimNextJob(types []string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := memoryNow()
+	var next *Job
+	for _, j := range m.jobs {
+		if j.Status != JobQueued || (j.RunAfter != "" && j.RunAfter > now) || (next != nil && j.ID > next.ID) {
+			continue
+		}
+		for _, t := range types {
+			if j.Type == t {
+				next = j
+				break
+			}
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	next.Status = JobRunning
+	next.Attempts++
+	next.StartedAt = now
+	next.Error = ""
+	return m.job(next), nil
+}
+
+func (m *MemoryStore) UpdateJobProgress(id int64, progress, total int, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		j.Progress, j.Total, j.Message = progress, total, message
+	}
+	return nil
+}
+
+func (m *MemoryStore) FinishJob(id int64, status string, result interface{}, jobErr error) error {
+	var data json.RawMessage
+	if result != nil {
+		var err error
+		if data, err = json.Marshal(result); err != nil {
+			return fmt.Errorf("could not encode result of job %d: %w", id, err)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+	j.Status = status
+	j.Result = data
+	j.Error = ""
+	if jobErr != nil {
+		j.Error = jobErr.Error()
+	}
+	j.FinishedAt = memoryNow()
+	return nil
+}
+
+func (m *MemoryStore) RetryJob(id int64, runAfter time.Time, jobErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+	j.Status = JobQueued
+	j.Error = ""
+	if jobErr != nil {
+		j.Error = jobErr.Error()
+	}
+	j.RunAfter = runAfter.UTC().Format(time.RFC3339Nano)
+	return nil
+}
+
+func (m *MemoryStore) RequeueJob(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || (j.Status != JobFailed && j.Status != JobCanceled) {
+		return fmt.Errorf("failed or canceled job %d not found: %w", id, sql.ErrNoRows)
+	}
+	*j = Job{
+		ID:          j.ID,
+		Type:        j.Type,
+		Params:      j.Params,
+		Status:      JobQueued,
+		MaxAttempts: j.MaxAttempts,
+		CreatedAt:   j.CreatedAt,
+	}
+	return nil
+}
+
+func (m *MemoryStore) CancelQueuedJob(id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.Status != JobQueued {
+		return false, nil
+	}
+	j.Status = JobCanceled
+	j.FinishedAt = memoryNow()
+	return true, nil
+}
+
+func (m *MemoryStore) InterruptJob(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok && j.Status == JobRunning {
+		m.interrupt(j)
+	}
+	return nil
+}
+
+func (m *MemoryStore) RequeueInterruptedJobs() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, j := range m.jobs {
+		if j.Status == JobRunning {
+			m.interrupt(j)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// interrupt queues a running job again without counting the attempt.
+func (m *MemoryStore) interrupt(j *Job) {
+	j.Status = JobQueued
+	if j.Attempts > 0 {
+		j.Attempts--
+	}
+}
+
+func (m *MemoryStore) DeleteJob(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || !j.Finished() {
+		return fmt.Errorf("finished job %d not found: %w", id, sql.ErrNoRows)
+	}
+	delete(m.jobs, id)
+	return nil
+}
+
+// searchColumns returns the texts of r covered by the full-text index.
+func (m *MemoryStore) searchColumns(r *memoryRepo) []string {
+	var tags []string
+	for _, t := range m.sortedTags(m.repoTags[r.ID]) {
+		tags = append(tags, t.Name)
+	}
+	return []string{r.FullName, r.Description, r.ReadmeContent, strings.Join(tags, ",")}
+}
+
+func (m *MemoryStore) SearchRepositories(query string, limit int) ([]SearchResult, error) {
+	q := parseMemoryQuery(query)
+	if q.err != nil {
+		return nil, fmt.Errorf("could not execute search query: %w", q.err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var results []SearchResult
+	for _, r := range m.sortedRepos() {
+		if r.UnstarredAt != "" {
+			continue
+		}
+		columns := m.searchColumns(r)
+		if score := q.score(columns); score > 0 {
+			results = append(results, SearchResult{
+				Repository: m.repository(r),
+				Snippet:    q.snippet(columns),
+				Rank:       -float64(score),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank < results[j].Rank })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) CountEmbeddings(model string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, r := range m.repos {
+		if r.UnstarredAt == "" && r.embedding != nil && r.embeddingModel == model {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) SemanticSearch(model string, query []float32, limit int) ([]ScoredRepository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var results []ScoredRepository
+	for _, r := range m.repos {
+		if r.UnstarredAt == "" && r.embedding != nil && r.embeddingModel == model {
+			results = append(results, ScoredRepository{Repository: m.repository(r), Score: cosineSimilarity(query, r.embedding)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) CreateAPIToken(name, scope, tokenHash string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("token name must not be empty")
+	}
+	if scope != ScopeRead && scope != ScopeWrite {
+		return 0, fmt.Errorf("invalid scope %q, must be %s or %s", scope, ScopeRead, ScopeWrite)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.Name == name {
+			return 0, ErrTokenExists
+		}
+		if t.hash == tokenHash {
+			return 0, fmt.Errorf("could not insert token: duplicate token hash")
+		}
+	}
+	m.lastTokenID++
+	m.tokens[m.lastTokenID] = &memoryToken{
+		APIToken: APIToken{ID: m.lastTokenID, Name: name, Scope: scope, CreatedAt: memoryNow()},
+		hash:     tokenHash,
+	}
+	return m.lastTokenID, nil
+}
+
+func (m *MemoryStore) GetAPITokens() ([]APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tokens []APIToken
+	for _, t := range m.tokens {
+		tokens = append(tokens, t.APIToken)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+func (m *MemoryStore) CountAPITokens() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tokens), nil
+}
+
+func (m *MemoryStore) DeleteAPIToken(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tokens[id]; !ok {
+		return fmt.Errorf("token %d not found: %w", id, sql.ErrNoRows)
+	}
+	delete(m.tokens, id)
+	for idHash, s := range m.sessions {
+		if s.TokenID == id {
+			delete(m.sessions, idHash)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UseAPIToken(tokenHash string) (*APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.hash != tokenHash {
+			continue
+		}
+		token := t.APIToken
+		now := time.Now().UTC()
+		if t.lastUsed.IsZero() || t.lastUsed.Before(now.Add(-tokenUseResolution)) {
+			t.lastUsed = now
+			t.LastUsedAt = now.Format(time.RFC3339Nano)
+		}
+		return &token, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) CreateSession(idHash string, tokenID int64, csrfToken string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, s := range m.sessions {
+		if s.expiresAt.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+	if _, ok := m.sessions[idHash]; ok {
+		return fmt.Errorf("could not insert session: duplicate session ID")
+	}
+	m.sessions[idHash] = &memorySession{
+		Session:   Session{TokenID: tokenID, CSRFToken: csrfToken, ExpiresAt: expiresAt.UTC().Format(time.RFC3339Nano)},
+		expiresAt: expiresAt,
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetSession(idHash string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[idHash]
+	if !ok || s.expiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	t, ok := m.tokens[s.TokenID]
+	if !ok {
+		return nil, nil
+	}
+	session := s.Session
+	session.Scope = t.Scope
+	return &session, nil
+}
+
+func (m *MemoryStore) DeleteSession(idHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, idHash)
+	return nil
+}
+
+// memoryTerm is a word or phrase of a full-text query. The last word of a
+// prefix term matches any word it starts.
+type memoryTerm struct {
+	words  []string
+	prefix bool
+}
+
+// memoryQuery is a parsed full-text query: any of its groups must match, and
+// a group matches if all of its terms do.
+type memoryQuery struct {
+	groups [][]memoryTerm
+	err    error
+}
+
+// parseMemoryQuery parses the subset of the FTS5 query syntax described at
+// MemoryStore.
+func parseMemoryQuery(query string) memoryQuery {
+	var q memoryQuery
+	var group []memoryTerm
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var term memoryTerm
+		var text string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for {
+				i := strings.Index(rest[end:], `"`)
+				if i < 0 {
+					return memoryQuery{err: fmt.Errorf("unterminated string in %q", query)}
+				}
+				end += i + 1
+				if !strings.HasPrefix(rest[end:], `"`) {
+					break
+				}
+				end++ // "" is an escaped quote
+			}
+			text = strings.ReplaceAll(rest[1:end-1], `""`, `"`)
+			rest = rest[end:]
+		} else {
+			i := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '*' })
+			if i < 0 {
+				i = len(rest)
+			}
+			text, rest = rest[:i], rest[i:]
+			if text == "OR" {
+				if len(group) > 0 {
+					q.groups = append(q.groups, group)
+				}
+				group = nil
+				rest = strings.TrimSpace(rest)
+				continue
+			}
+			if text == "AND" {
+				rest = strings.TrimSpace(rest)
+				continue
+			}
+		}
+		if strings.HasPrefix(rest, "*") {
+			term.prefix = true
+			rest = rest[1:]
+		}
+		rest = strings.TrimSpace(rest)
+		if term.words = memoryWords(text); len(term.words) > 0 {
+			group = append(group, term)
+		}
+	}
+	if len(group) > 0 {
+		q.groups = append(q.groups, group)
+	}
+	return q
+}
+
+// memoryWords splits text into lowercase words like the full-text tokenizer.
+func memoryWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matches reports whether word is matched by the i-th word of t.
+func (t memoryTerm) matches(i int, word string) bool {
+	if t.prefix && i == len(t.words)-1 {
+		return strings.HasPrefix(word, t.words[i])
+	}
+	return word == t.words[i]
+}
+
+// score returns the number of words in columns matched by the query, or 0 if
+// the query does not match.
+func (q memoryQuery) score(columns []string) int {
+	var words []string
+	for _, c := range columns {
+		words = append(words, memoryWords(c)...)
+	}
+	best := 0
+	for _, group := range q.groups {
+		score := 0
+		for _, term := range group {
+			found := 0
+			for i := range term.words {
+				n := 0
+				for _, w := range words {
+					if term.matches(i, w) {
+						n++
+					}
+				}
+				if n == 0 {
+					found = 0
+					break
+				}
+				found += n
+			}
+			if found == 0 {
+				score = 0
+				break
+			}
+			score += found
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// snippet returns the first column containing a matched word with the matched
+// words wrapped in <b></b>.
+func (q memoryQuery) snippet(columns []string) string {
+	for _, c := range columns {
+		fields := strings.Fields(c)
+		hit := false
+		for i, f := range fields {
+			for _, w := range memoryWords(f) {
+				if q.matchesWord(w) {
+					fields[i] = "<b>" + f + "</b>"
+					hit = true
+					break
+				}
+			}
+		}
+		if hit {
+			return strings.Join(fields, " ")
+		}
+	}
+	return ""
+}
+
+// matchesWord reports whether any term of the query matches word.
+func (q memoryQuery) matchesWord(word string) bool {
+	for _, group := range q.groups {
+		for _, term := range group {
+			for i := range term.words {
+				if term.matches(i, word) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
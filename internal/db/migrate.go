@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the up-migrations of the schema. Each file is named
+// NNNN_description.sql, where NNNN is its version; versions start at 1 and
+// have no gaps. A migration must never change once released: to change the
+// schema, add a file with the next version.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// schema_version records the migrations applied to the database.
+const createSchemaVersionTableSQL = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+);`
+
+// Migration is a step of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationState is a migration and whether it has been applied.
+type MigrationState struct {
+	Migration
+	// AppliedAt is empty while the migration is pending.
+	AppliedAt string
+}
+
+// SchemaStatus describes where the schema of a database stands.
+type SchemaStatus struct {
+	// Version is the version of the last applied migration, 0 for an empty
+	// database.
+	Version int
+	// Latest is the version of the last migration known to this build.
+	Latest int
+	// Legacy is set for a database created before versioned migrations; it
+	// is baselined on its next migration.
+	Legacy     bool
+	Migrations []MigrationState
+}
+
+// Pending returns the number of migrations not yet applied.
+func (s SchemaStatus) Pending() int {
+	n := 0
+	for _, m := range s.Migrations {
+		if m.AppliedAt == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// MigrationResult reports what Migrate did.
+type MigrationResult struct {
+	From, To int
+	// Backup is the path of the snapshot taken before migrating, or empty if
+	// the database was new or already up to date.
+	Backup string
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadMigrations returns the embedded migrations ordered by version.
+func loadMigrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("could not list migrations: %w", err)
+	}
+	var migrations []Migration
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, desc, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		content, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: desc, SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must start at 1 without gaps, found %04d_%s", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// GetSchemaStatus returns the applied and pending migrations without changing
+// the database.
+func GetSchemaStatus(db *sql.DB) (*SchemaStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	status := &SchemaStatus{Latest: len(migrations)}
+
+	applied := make(map[int]string)
+	ok, err := hasTable(db, "schema_version")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		rows, err := db.Query("SELECT version, applied_at FROM schema_version ORDER BY version;")
+		if err != nil {
+			return nil, fmt.Errorf("could not query schema version: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			var appliedAt string
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return nil, fmt.Errorf("could not scan schema version row: %w", err)
+			}
+			applied[version] = appliedAt
+			status.Version = max(status.Version, version)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	} else if status.Legacy, err = hasTable(db, "repositories"); err != nil {
+		return nil, err
+	}
+
+	for _, m := range migrations {
+		status.Migrations = append(status.Migrations, MigrationState{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return status, nil
+}
+
+// Migrate applies the pending migrations to the database stored at dbPath,
+// each in its own transaction. Unless the database is new, a snapshot is
+// taken first, so a failed migration loses nothing. A database migrated by a
+// newer build is refused.
+func Migrate(db *sql.DB, dbPath string) (*MigrationResult, error) {
+	status, err := GetSchemaStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	result := &MigrationResult{From: status.Version, To: status.Version}
+	if status.Version > status.Latest {
+		return nil, fmt.Errorf("database schema version %d is newer than the latest version %d known to this build of starsage", status.Version, status.Latest)
+	}
+	if status.Pending() == 0 {
+		return result, nil
+	}
+
+	if status.Version > 0 || status.Legacy {
+		if result.Backup, err = takeSnapshot(db, dbPath, SnapshotMigrate); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := db.Exec(createSchemaVersionTableSQL); err != nil {
+		return nil, fmt.Errorf("could not create schema_version table: %w", err)
+	}
+	for _, m := range status.Migrations {
+		if m.AppliedAt != "" {
+			continue
+		}
+		if err := applyMigration(db, m.Migration, status.Legacy && m.Version == 1); err != nil {
+			return result, err
+		}
+		result.To = m.Version
+	}
+	return result, nil
+}
+
+// applyMigration runs m and records it in one transaction. With baseline set,
+// the first migration is applied to a legacy database by baselineLegacySchema.
+func applyMigration(db *sql.DB, m Migration, baseline bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if baseline {
+		err = baselineLegacySchema(tx, m.SQL)
+	} else {
+		_, err = tx.Exec(m.SQL)
+	}
+	if err != nil {
+		return fmt.Errorf("could not apply migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?);",
+		m.Version, m.Name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("could not record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// originalSchemaSQL is the schema of the first release, before sources, tags,
+// list memberships and sync runs.
+const originalSchemaSQL = `
+CREATE TABLE repositories (
+	id INTEGER NOT NULL PRIMARY KEY,
+	full_name TEXT NOT NULL UNIQUE,
+	description TEXT,
+	url TEXT,
+	language TEXT,
+	stargazers_count INTEGER,
+	readme_content TEXT,
+	summary TEXT,
+	etag TEXT,
+	last_synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE VIRTUAL TABLE repos_fts USING fts5(
+	full_name,
+	description,
+	readme_content,
+	content='repositories',
+	content_rowid='id'
+);
+CREATE TRIGGER repos_ai AFTER INSERT ON repositories BEGIN
+	INSERT INTO repos_fts(rowid, full_name, description, readme_content)
+	VALUES (new.id, new.full_name, new.description, new.readme_content);
+END;
+CREATE TABLE lists (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	prompt TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE list_repositories (
+	list_id INTEGER NOT NULL,
+	repository_id INTEGER NOT NULL,
+	PRIMARY KEY (list_id, repository_id)
+);
+INSERT INTO repositories (id, full_name, description) VALUES (7, 'owner/parser', 'A fast parser');
+INSERT INTO lists (id, name, prompt) VALUES (1, 'Tools', 'developer tools');
+INSERT INTO list_repositories (list_id, repository_id) VALUES (1, 7);
+`
+
+// openMigrateTestDB opens a new database file, runs setup on it and returns
+// it with its path.
+func openMigrateTestDB(t *testing.T, setup string) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stars.db")
+	database, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if setup != "" {
+		if _, err := database.Exec(setup); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+	}
+	return database, path
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := len(migrations)
+	initialSQL := migrations[0].SQL
+
+	tests := []struct {
+		name  string
+		setup string
+		// setupVersions are recorded in schema_version after setup.
+		setupVersions []int
+		wantFrom      int
+		wantBackup    bool
+		// wantRepo is a repository the migrated database must find by
+		// full-text search for "parser".
+		wantRepo string
+		wantErr  string
+	}{
+		{name: "fresh", wantFrom: 0},
+		{name: "original legacy", setup: originalSchemaSQL, wantFrom: 0, wantBackup: true, wantRepo: "owner/parser"},
+		{name: "latest legacy", setup: initialSQL, wantFrom: 0, wantBackup: true},
+		{name: "partially migrated", setup: initialSQL, setupVersions: []int{1}, wantFrom: 1, wantBackup: latest > 1},
+		{name: "up to date", setup: initialSQL + strings.Join(migrationSQL(migrations[1:]), "\n"), setupVersions: versions(latest), wantFrom: latest},
+		{name: "newer than build", setup: initialSQL, setupVersions: versions(latest + 1), wantErr: "newer than the latest version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, path := openMigrateTestDB(t, tt.setup)
+			if tt.setupVersions != nil {
+				if _, err := database.Exec(createSchemaVersionTableSQL); err != nil {
+					t.Fatal(err)
+				}
+				for _, v := range tt.setupVersions {
+					if _, err := database.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?);",
+						v, fmt.Sprint("migration", v), "2026-01-01T00:00:00Z"); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			result, err := Migrate(database, path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				status, err := GetSchemaStatus(database)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status.Version != latest+1 {
+					t.Errorf("refused migration changed the schema version to %d", status.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.From != tt.wantFrom || result.To != latest {
+				t.Errorf("migrated from %d to %d, want %d to %d", result.From, result.To, tt.wantFrom, latest)
+			}
+			if (result.Backup != "") != tt.wantBackup {
+				t.Errorf("got backup %q, want one: %v", result.Backup, tt.wantBackup)
+			}
+			if result.Backup != "" {
+				if _, err := os.Stat(result.Backup); err != nil {
+					t.Errorf("backup not written: %v", err)
+				}
+			}
+
+			status, err := GetSchemaStatus(database)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Version != latest || status.Pending() != 0 || status.Legacy {
+				t.Errorf("after migrating: version %d of %d with %d pending (legacy %v)", status.Version, latest, status.Pending(), status.Legacy)
+			}
+
+			// The migrated schema works with the current queries.
+			if _, err := UpsertRepository(database, Repository{Source: DefaultSource, SourceID: 100, FullName: "owner/new"}); err != nil {
+				t.Errorf("could not store a repository: %v", err)
+			}
+			if _, err := GetSyncRuns(database, 0); err != nil {
+				t.Errorf("could not read sync runs: %v", err)
+			}
+			if tt.wantRepo != "" {
+				results, err := SearchRepositories(database, "parser", 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != 1 || results[0].FullName != tt.wantRepo || results[0].Source != DefaultSource {
+					t.Errorf("search found %+v, want %s", results, tt.wantRepo)
+				}
+				members, err := GetReposByListID(database, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(members) != 1 || members[0].FullName != tt.wantRepo || members[0].Membership != ListMembershipAI {
+					t.Errorf("list members %+v, want %s classified by AI", members, tt.wantRepo)
+				}
+			}
+
+			// Migrating again does nothing.
+			again, err := Migrate(database, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.From != latest || again.To != latest || again.Backup != "" {
+				t.Errorf("second migration: %+v, want no changes", again)
+			}
+		})
+	}
+}
+
+// versions returns 1 to n.
+func versions(n int) []int {
+	v := make([]int, n)
+	for i := range v {
+		v[i] = i + 1
+	}
+	return v
+}
+
+func migrationSQL(migrations []Migration) []string {
+	var stmts []string
+	for _, m := range migrations {
+		stmts = append(stmts, m.SQL)
+	}
+	return stmts
+}
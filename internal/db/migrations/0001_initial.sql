@@ -0,0 +1,179 @@
+-- The schema as of the introduction of versioned migrations. Databases created
+-- before that are brought to this state by baselineLegacySchema instead.
+
+-- Repositories are keyed by their source (e.g. "github", "gitea:git.example.com")
+-- plus the ID on that source; id is a local key shared with the FTS index and lists.
+CREATE TABLE IF NOT EXISTS repositories (
+	id INTEGER NOT NULL PRIMARY KEY,
+	source TEXT NOT NULL DEFAULT 'github',
+	source_id INTEGER NOT NULL,
+	full_name TEXT NOT NULL,
+	description TEXT,
+	url TEXT,
+	language TEXT,
+	stargazers_count INTEGER,
+	readme_content TEXT,
+	summary TEXT,
+	etag TEXT,
+	last_synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	starred_at TIMESTAMP,
+	pushed_at TIMESTAMP,
+	topics TEXT,
+	license TEXT,
+	is_archived BOOLEAN NOT NULL DEFAULT 0,
+	is_fork BOOLEAN NOT NULL DEFAULT 0,
+	unstarred_at TIMESTAMP,
+	embedding BLOB,
+	embedding_model TEXT,
+	tags TEXT,
+	UNIQUE (source, source_id),
+	UNIQUE (source, full_name)
+);
+
+-- Full-text index over the repositories, kept in sync by triggers.
+CREATE VIRTUAL TABLE IF NOT EXISTS repos_fts USING fts5(
+	full_name,
+	description,
+	readme_content,
+	tags,
+	content='repositories',
+	content_rowid='id'
+);
+
+CREATE TRIGGER IF NOT EXISTS repos_ai AFTER INSERT ON repositories BEGIN
+	INSERT INTO repos_fts(rowid, full_name, description, readme_content, tags)
+	VALUES (new.id, new.full_name, new.description, new.readme_content, new.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS repos_ad AFTER DELETE ON repositories BEGIN
+	INSERT INTO repos_fts(repos_fts, rowid, full_name, description, readme_content, tags)
+	VALUES ('delete', old.id, old.full_name, old.description, old.readme_content, old.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS repos_au AFTER UPDATE OF full_name, description, readme_content, tags ON repositories BEGIN
+	INSERT INTO repos_fts(repos_fts, rowid, full_name, description, readme_content, tags)
+	VALUES ('delete', old.id, old.full_name, old.description, old.readme_content, old.tags);
+	INSERT INTO repos_fts(rowid, full_name, description, readme_content, tags)
+	VALUES (new.id, new.full_name, new.description, new.readme_content, new.tags);
+END;
+
+-- tags are labels the user puts on repositories. The names of a repository's
+-- tags are also kept in repositories.tags so the full-text index covers them.
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	color TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS repository_tags (
+	repository_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (repository_id, tag_id),
+	FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- AI-managed lists.
+CREATE TABLE IF NOT EXISTS lists (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	prompt TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS list_repositories (
+	list_id INTEGER NOT NULL,
+	repository_id INTEGER NOT NULL,
+	membership TEXT NOT NULL DEFAULT 'ai',
+	PRIMARY KEY (list_id, repository_id),
+	FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+	FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+);
+
+-- topics is the controlled vocabulary produced by 'starsage topics discover'.
+-- It is separate from repositories.topics, which holds the labels set by the
+-- repository owners on GitHub.
+CREATE TABLE IF NOT EXISTS topics (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS repository_topics (
+	repository_id INTEGER NOT NULL,
+	topic_id INTEGER NOT NULL,
+	confidence_score REAL,
+	source TEXT NOT NULL DEFAULT 'ai',
+	PRIMARY KEY (repository_id, topic_id),
+	FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+	FOREIGN KEY (topic_id) REFERENCES topics(id) ON DELETE CASCADE
+);
+
+-- sync_runs records every sync with its checkpoint. While a run is unfinished,
+-- cursor points at the next page to fetch so it can be resumed.
+CREATE TABLE IF NOT EXISTS sync_runs (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	source TEXT NOT NULL DEFAULT 'github',
+	started_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP,
+	status TEXT NOT NULL,
+	api TEXT NOT NULL,
+	cursor TEXT,
+	repos_done INTEGER NOT NULL DEFAULT 0,
+	added INTEGER NOT NULL DEFAULT 0,
+	updated INTEGER NOT NULL DEFAULT 0,
+	unchanged INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	removed INTEGER NOT NULL DEFAULT 0,
+	error TEXT
+);
+
+-- sync_run_repos remembers which starred repositories a run has already seen,
+-- so unstarred detection works across resumed runs. repository_id holds the ID
+-- on the run's source, not the local repositories.id.
+CREATE TABLE IF NOT EXISTS sync_run_repos (
+	run_id INTEGER NOT NULL,
+	repository_id INTEGER NOT NULL,
+	PRIMARY KEY (run_id, repository_id),
+	FOREIGN KEY (run_id) REFERENCES sync_runs(id) ON DELETE CASCADE
+);
+
+-- jobs is the queue of background tasks run by the server. params and result
+-- hold JSON documents whose shape depends on the job type.
+CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	type TEXT NOT NULL,
+	params TEXT,
+	status TEXT NOT NULL DEFAULT 'queued',
+	progress INTEGER NOT NULL DEFAULT 0,
+	total INTEGER NOT NULL DEFAULT 0,
+	message TEXT,
+	error TEXT,
+	result TEXT,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 1,
+	run_after TIMESTAMP,
+	created_at TIMESTAMP NOT NULL,
+	started_at TIMESTAMP,
+	finished_at TIMESTAMP
+);
+
+-- api_tokens holds the tokens accepted by the server; only their SHA-256
+-- hashes are stored. sessions are browser logins made with a token and end
+-- with it.
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	token_hash TEXT NOT NULL UNIQUE,
+	scope TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id_hash TEXT NOT NULL PRIMARY KEY,
+	token_id INTEGER NOT NULL,
+	csrf_token TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
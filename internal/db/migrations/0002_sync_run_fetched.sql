@@ -0,0 +1,4 @@
+-- fetched records that a sync run has stored its last page, so a resumed run
+-- can go straight to reconciling. Runs interrupted before this column existed
+-- resume from their cursor, or from the first page if they have none.
+ALTER TABLE sync_runs ADD COLUMN fetched BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Sort keys accepted by QueryRepositories.
+const (
+	SortStars     = "stars"
+	SortStarredAt = "starred_at"
+	SortPushedAt  = "pushed_at"
+	SortName      = "name"
+	// SortRelevance orders by full-text rank and requires RepoFilter.Text.
+	SortRelevance = "relevance"
+)
+
+// Sort orders accepted by QueryRepositories.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// sortColumns maps sort keys to the ORDER BY expression and its natural direction.
+var sortColumns = map[string]struct {
+	expr string
+	desc bool
+}{
+	SortStars:     {"r.stargazers_count", true},
+	SortStarredAt: {"r.starred_at", true},
+	SortPushedAt:  {"r.pushed_at", true},
+	SortName:      {"r.full_name COLLATE NOCASE", false},
+	// bm25 is lower for better matches; negate it so larger means more relevant.
+	SortRelevance: {"-bm25(repos_fts)", true},
+}
+
+// RepoFilter selects starred repositories. Zero values do not filter.
+type RepoFilter struct {
+	// Language matches the primary language, ignoring case.
+	Language string
+	TagID    int64
+	TopicID  int64
+	// MinTopicConfidence applies to TopicID.
+	MinTopicConfidence float64
+	ListID             int64
+	MinStars           *int
+	MaxStars           *int
+	Archived           *bool
+	HasSummary         *bool
+	// StarredAfter and StarredBefore are dates or timestamps in ISO 8601 form.
+	StarredAfter  string
+	StarredBefore string
+	// Text is free text matched against the full-text index. Every word must
+	// match, as a prefix.
+	Text string
+}
+
+// RepoQuery is a filtered, sorted and paginated query for repositories.
+type RepoQuery struct {
+	RepoFilter
+	// Sort is one of the Sort constants. It defaults to SortRelevance when
+	// Text is set and to SortStars otherwise.
+	Sort string
+	// Order is OrderAsc or OrderDesc. It defaults to the natural direction of
+	// the sort key: names ascend, relevance is best first and everything else
+	// is largest or newest first.
+	Order string
+	// Limit is the page size; 0 returns all matching repositories.
+	Limit  int
+	Offset int
+}
+
+// RepoPage is one page of the result of QueryRepositories.
+type RepoPage struct {
+	Repositories []Repository
+	// Total is the number of matching repositories across all pages.
+	Total  int
+	Limit  int
+	Offset int
+}
+
+// queryBuilder collects the joins and conditions of a repository query.
+type queryBuilder struct {
+	joins []string
+	where []string
+	args  []interface{}
+}
+
+// join adds a join clause.
+func (b *queryBuilder) join(clause string) {
+	b.joins = append(b.joins, clause)
+}
+
+// filter adds a condition; args are bound to its placeholders.
+func (b *queryBuilder) filter(cond string, args ...interface{}) {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+}
+
+// from returns the FROM clause with joins and conditions and its arguments.
+func (b *queryBuilder) from() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("FROM repositories r")
+	for _, j := range b.joins {
+		sb.WriteString("\n\t\t")
+		sb.WriteString(j)
+	}
+	sb.WriteString("\n\t\tWHERE r.unstarred_at IS NULL")
+	for _, w := range b.where {
+		sb.WriteString(" AND ")
+		sb.WriteString(w)
+	}
+	return sb.String(), append([]interface{}{}, b.args...)
+}
+
+// apply adds the conditions of the filter to b.
+func (f RepoFilter) apply(b *queryBuilder) {
+	if f.Text != "" {
+		b.join("JOIN repos_fts ON r.id = repos_fts.rowid")
+		b.filter("repos_fts MATCH ?", prefixTermsQuery(f.Text))
+	}
+	if f.Language != "" {
+		b.filter("r.language = ? COLLATE NOCASE", f.Language)
+	}
+	if f.TagID != 0 {
+		b.filter("r.id IN (SELECT repository_id FROM repository_tags WHERE tag_id = ?)", f.TagID)
+	}
+	if f.TopicID != 0 {
+		b.filter("r.id IN (SELECT repository_id FROM repository_topics WHERE topic_id = ? AND COALESCE(confidence_score, 0) >= ?)",
+			f.TopicID, f.MinTopicConfidence)
+	}
+	if f.ListID != 0 {
+		b.filter("r.id IN (SELECT repository_id FROM list_repositories WHERE list_id = ? AND membership != 'excluded')", f.ListID)
+	}
+	if f.MinStars != nil {
+		b.filter("r.stargazers_count >= ?", *f.MinStars)
+	}
+	if f.MaxStars != nil {
+		b.filter("r.stargazers_count <= ?", *f.MaxStars)
+	}
+	if f.Archived != nil {
+		b.filter("r.is_archived = ?", *f.Archived)
+	}
+	if f.HasSummary != nil {
+		if *f.HasSummary {
+			b.filter("COALESCE(r.summary, '') != ''")
+		} else {
+			b.filter("COALESCE(r.summary, '') = ''")
+		}
+	}
+	if f.StarredAfter != "" {
+		b.filter("r.starred_at >= ?", f.StarredAfter)
+	}
+	if f.StarredBefore != "" {
+		b.filter("r.starred_at < ?", f.StarredBefore)
+	}
+}
+
+// prefixTermsQuery turns free text into an FTS5 query requiring every word
+// as a prefix. Words are quoted, so FTS5 operators in the text are harmless.
+func prefixTermsQuery(text string) string {
+	var terms []string
+	for _, w := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// QueryRepositories returns one page of the starred repositories matching
+// q, together with the total number of matches.
+func QueryRepositories(db *sql.DB, q RepoQuery) (*RepoPage, error) {
+	if q.Sort == "" {
+		q.Sort = SortStars
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		}
+	}
+	order, ok := sortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key: %s", q.Sort)
+	}
+	if q.Sort == SortRelevance && q.Text == "" {
+		return nil, fmt.Errorf("sorting by relevance requires a text query")
+	}
+	desc := order.desc
+	switch q.Order {
+	case "":
+	case OrderAsc:
+		desc = false
+	case OrderDesc:
+		desc = true
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", q.Order)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+
+	var b queryBuilder
+	q.RepoFilter.apply(&b)
+	from, args := b.from()
+
+	page := &RepoPage{Limit: q.Limit, Offset: q.Offset}
+	if err := db.QueryRow("SELECT COUNT(*) "+from+";", args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("could not count repos: %w", err)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	// Missing values sort last in either direction; ties are broken by ID so
+	// pages do not overlap.
+	query := "SELECT " + repoSelectColumns + "\n\t\t" + from + "\n\t\tORDER BY " +
+		order.expr + " IS NULL, " + order.expr + " " + direction + ", r.id " + direction
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	} else if q.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, q.Offset)
+	}
+
+	rows, err := db.Query(query+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos: %w", err)
+	}
+	defer rows.Close()
+
+	page.Repositories = []Repository{}
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row: %w", err)
+		}
+		page.Repositories = append(page.Repositories, repo)
+	}
+	return page, rows.Err()
+}
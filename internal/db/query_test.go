@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestPrefixTermsQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"go", `"go"*`},
+		{"  go   web ", `"go"* "web"*`},
+		{"c++", `"c++"*`},
+		{"go OR rust", `"go"* "OR"* "rust"*`},
+		{"NOT go", `"NOT"* "go"*`},
+		{`say "hi"`, `"say"* """hi"""*`},
+		{"description:go", `"description:go"*`},
+		{"(web) -cli", `"(web)"* "-cli"*`},
+		{"日本語 ツール", `"日本語"* "ツール"*`},
+	}
+	for _, tt := range tests {
+		if got := prefixTermsQuery(tt.text); got != tt.want {
+			t.Errorf("prefixTermsQuery(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+// openQueryTestDB returns a migrated database with repositories to query.
+// Their IDs follow the order of the list.
+func openQueryTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, path := openMigrateTestDB(t, "")
+	if _, err := Migrate(database, path); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range []Repository{
+		{FullName: "owner/alpha", Language: "Go", StargazersCount: 100, StarredAt: "2024-03-01T00:00:00Z", Description: "A fast parser"},
+		{FullName: "owner/Beta", Language: "Go", StargazersCount: 100, StarredAt: "2024-01-01T00:00:00Z", Description: "A web server"},
+		{FullName: "owner/gamma", Language: "Rust", StargazersCount: 50, Description: "A parser generator and parser"},
+		{FullName: "owner/delta", Language: "go", StargazersCount: 500, StarredAt: "2024-02-01T00:00:00Z", Description: "Fast web framework"},
+		{FullName: "owner/gone", Language: "Go", StargazersCount: 1000, Description: "A parser nobody stars anymore"},
+	} {
+		r.Source, r.SourceID = DefaultSource, int64(i+1)
+		if _, err := UpsertRepository(database, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := database.Exec("UPDATE repositories SET unstarred_at = CURRENT_TIMESTAMP WHERE full_name = 'owner/gone';"); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestQueryRepositories(t *testing.T) {
+	database := openQueryTestDB(t)
+	tests := []struct {
+		name      string
+		query     RepoQuery
+		want      []string
+		wantTotal int
+		wantErr   bool
+	}{
+		// Ties on stars are broken by ID in the direction of the sort.
+		{name: "default", query: RepoQuery{}, want: []string{"owner/delta", "owner/Beta", "owner/alpha", "owner/gamma"}, wantTotal: 4},
+		{name: "stars ascending", query: RepoQuery{Sort: SortStars, Order: OrderAsc}, want: []string{"owner/gamma", "owner/alpha", "owner/Beta", "owner/delta"}, wantTotal: 4},
+		{name: "name ignores case", query: RepoQuery{Sort: SortName}, want: []string{"owner/alpha", "owner/Beta", "owner/delta", "owner/gamma"}, wantTotal: 4},
+		{name: "name descending", query: RepoQuery{Sort: SortName, Order: OrderDesc}, want: []string{"owner/gamma", "owner/delta", "owner/Beta", "owner/alpha"}, wantTotal: 4},
+		// Repositories without a starred date come last in both directions.
+		{name: "starred newest", query: RepoQuery{Sort: SortStarredAt}, want: []string{"owner/alpha", "owner/delta", "owner/Beta", "owner/gamma"}, wantTotal: 4},
+		{name: "starred oldest", query: RepoQuery{Sort: SortStarredAt, Order: OrderAsc}, want: []string{"owner/Beta", "owner/delta", "owner/alpha", "owner/gamma"}, wantTotal: 4},
+		{name: "language ignores case", query: RepoQuery{RepoFilter: RepoFilter{Language: "GO"}}, want: []string{"owner/delta", "owner/Beta", "owner/alpha"}, wantTotal: 3},
+		{name: "text as prefixes", query: RepoQuery{RepoFilter: RepoFilter{Text: "pars"}, Sort: SortName}, want: []string{"owner/alpha", "owner/gamma"}, wantTotal: 2},
+		{name: "text needs every word", query: RepoQuery{RepoFilter: RepoFilter{Text: "fast web"}}, want: []string{"owner/delta"}, wantTotal: 1},
+		{name: "text with operators", query: RepoQuery{RepoFilter: RepoFilter{Text: "web OR"}}, want: []string{}, wantTotal: 0},
+		{name: "text without matches", query: RepoQuery{RepoFilter: RepoFilter{Text: "c++"}}, want: []string{}, wantTotal: 0},
+		{name: "page", query: RepoQuery{Limit: 2, Offset: 1}, want: []string{"owner/Beta", "owner/alpha"}, wantTotal: 4},
+		{name: "offset without limit", query: RepoQuery{Offset: 3}, want: []string{"owner/gamma"}, wantTotal: 4},
+		{name: "past the end", query: RepoQuery{Limit: 2, Offset: 10}, want: []string{}, wantTotal: 4},
+		{name: "unknown sort", query: RepoQuery{Sort: "size"}, wantErr: true},
+		{name: "unknown order", query: RepoQuery{Order: "up"}, wantErr: true},
+		{name: "relevance without text", query: RepoQuery{Sort: SortRelevance}, wantErr: true},
+		{name: "negative limit", query: RepoQuery{Limit: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := QueryRepositories(database, tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, r := range page.Repositories {
+				names = append(names, r.FullName)
+			}
+			if !reflect.DeepEqual(names, tt.want) || page.Total != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", names, page.Total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestQueryRepositoriesRelevance(t *testing.T) {
+	database := openQueryTestDB(t)
+	page, err := QueryRepositories(database, RepoQuery{RepoFilter: RepoFilter{Text: "parser"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Repositories) != 2 {
+		t.Fatalf("got %d matches, want 2", len(page.Repositories))
+	}
+	// The repository that mentions the word twice ranks first.
+	if page.Repositories[0].FullName != "owner/gamma" {
+		t.Errorf("best match is %s, want owner/gamma", page.Repositories[0].FullName)
+	}
+}
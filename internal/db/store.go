@@ -0,0 +1,449 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Store is the data access of the web server, the background jobs and the AI
+// classifier. The package-level functions work on a *sql.DB directly;
+// SQLiteStore exposes them through this interface and MemoryStore implements
+// it without a database, for tests.
+type Store interface {
+	RepositoryStore
+	SyncStore
+	SummaryStore
+	EmbeddingStore
+	ListStore
+	TagStore
+	TopicStore
+	JobStore
+	SearchStore
+	AuthStore
+}
+
+// RepositoryStore reads starred repositories.
+type RepositoryStore interface {
+	QueryRepositories(q RepoQuery) (*RepoPage, error)
+	GetRepositoryByID(id int64) (*Repository, error)
+	FindRepositoriesByName(fullName string) ([]Repository, error)
+	GetAllRepositories() ([]Repository, error)
+	GetRepoReadme(repoID int64) (string, error)
+}
+
+// SyncStore records sync runs and saves the repositories they fetch.
+type SyncStore interface {
+	CreateSyncRun(source, api string) (*SyncRun, error)
+	GetResumableSyncRun(source string) (*SyncRun, error)
+	CheckpointSyncRun(run *SyncRun, seenIDs []int64) error
+	FinishSyncRun(run *SyncRun, status string, runErr error) error
+	GetSyncRunRepoIDs(runID int64) ([]int64, error)
+	GetSyncRuns(limit int) ([]SyncRun, error)
+	GetAllReposWithETags(source string) ([]Repository, error)
+	UpsertRepository(repo Repository) (UpsertOutcome, error)
+	MarkUnstarred(source string, starredIDs []int64) (int64, error)
+	CountUnstarred(source string) (int64, error)
+	PruneUnstarred(source string) (int64, error)
+	// TakeSnapshot saves a copy of the library before a destructive change
+	// and returns its path, or "" if the store keeps no snapshots.
+	TakeSnapshot(reason string) (string, error)
+}
+
+// SummaryStore reads the READMEs to summarize and saves the summaries.
+type SummaryStore interface {
+	GetReposForSummarization(limit int) ([]Repository, error)
+	UpdateRepoSummary(repoID int64, summary string) error
+}
+
+// EmbeddingStore reads the repositories to embed and saves their embeddings.
+type EmbeddingStore interface {
+	GetReposForEmbedding(model string, limit int) ([]Repository, error)
+	UpdateRepoEmbedding(repoID int64, model string, embedding []float32) error
+}
+
+// ListStore manages lists and their members.
+type ListStore interface {
+	GetLists() ([]List, error)
+	GetListByID(id int64) (*List, error)
+	GetListByName(name string) (*List, error)
+	CreateList(name, prompt string) (int64, error)
+	UpdateList(id int64, name, prompt *string) error
+	DeleteList(id int64) error
+	GetReposByListID(listID int64) ([]ListRepository, error)
+	PinRepoToList(listID, repoID int64) error
+	ExcludeRepoFromList(listID, repoID int64) error
+	GetReposForListClassification(listID int64) ([]Repository, error)
+	ReplaceListClassification(listID int64, repoIDs []int64) error
+}
+
+// TagStore manages tags and the repositories carrying them.
+type TagStore interface {
+	GetTags() ([]Tag, error)
+	GetTagByID(id int64) (*Tag, error)
+	GetTagByName(name string) (*Tag, error)
+	CreateTag(name, color string) (int64, error)
+	GetOrCreateTag(name string) (int64, error)
+	UpdateTag(id int64, name, color *string) error
+	MergeTags(fromID, intoID int64) error
+	DeleteTag(id int64) error
+	TagRepository(repoID, tagID int64) error
+	UntagRepository(repoID, tagID int64) error
+	GetRepoTags(repoID int64) ([]Tag, error)
+	GetReposByTagID(tagID int64) ([]Repository, error)
+}
+
+// TopicStore keeps the topic vocabulary.
+type TopicStore interface {
+	ReplaceTopics(topics []Topic, assignments []TopicAssignment) error
+	GetTopics() ([]Topic, error)
+	GetTopicByID(id int64) (*Topic, error)
+	GetTopicByName(name string) (*Topic, error)
+	GetReposByTopicID(topicID int64, minConfidence float64) ([]TopicRepository, error)
+}
+
+// JobStore records background jobs and their state transitions. Running them
+// is up to the jobs package.
+type JobStore interface {
+	CreateJob(jobType string, params interface{}, maxAttempts int) (int64, error)
+	GetJob(id int64) (*Job, error)
+	GetJobs(jobType, status string, limit int) ([]Job, error)
+	GetActiveJob(jobType string) (*Job, error)
+	ClaimNextJob(types []string) (*Job, error)
+	UpdateJobProgress(id int64, progress, total int, message string) error
+	FinishJob(id int64, status string, result interface{}, jobErr error) error
+	RetryJob(id int64, runAfter time.Time, jobErr error) error
+	RequeueJob(id int64) error
+	CancelQueuedJob(id int64) (bool, error)
+	InterruptJob(id int64) error
+	RequeueInterruptedJobs() (int64, error)
+	DeleteJob(id int64) error
+}
+
+// SearchStore runs keyword and semantic searches.
+type SearchStore interface {
+	// SearchRepositories takes an FTS5 query.
+	SearchRepositories(query string, limit int) ([]SearchResult, error)
+	CountEmbeddings(model string) (int, error)
+	SemanticSearch(model string, query []float32, limit int) ([]ScoredRepository, error)
+}
+
+// AuthStore keeps the API tokens and browser sessions of the server.
+type AuthStore interface {
+	CreateAPIToken(name, scope, tokenHash string) (int64, error)
+	GetAPITokens() ([]APIToken, error)
+	CountAPITokens() (int, error)
+	DeleteAPIToken(id int64) error
+	UseAPIToken(tokenHash string) (*APIToken, error)
+	CreateSession(idHash string, tokenID int64, csrfToken string, expiresAt time.Time) error
+	GetSession(idHash string) (*Session, error)
+	DeleteSession(idHash string) error
+}
+
+// SQLiteStore is the Store backed by the SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// NewSQLiteStore returns a Store using db.
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// DB returns the underlying database.
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLiteStore) QueryRepositories(q RepoQuery) (*RepoPage, error) {
+	return QueryRepositories(s.db, q)
+}
+
+func (s *SQLiteStore) GetRepositoryByID(id int64) (*Repository, error) {
+	return GetRepositoryByID(s.db, id)
+}
+
+func (s *SQLiteStore) FindRepositoriesByName(fullName string) ([]Repository, error) {
+	return FindRepositoriesByName(s.db, fullName)
+}
+
+func (s *SQLiteStore) GetAllRepositories() ([]Repository, error) {
+	return GetAllRepositories(s.db)
+}
+
+func (s *SQLiteStore) GetRepoReadme(repoID int64) (string, error) {
+	return GetRepoReadme(s.db, repoID)
+}
+
+func (s *SQLiteStore) CreateSyncRun(source, api string) (*SyncRun, error) {
+	return CreateSyncRun(s.db, source, api)
+}
+
+func (s *SQLiteStore) GetResumableSyncRun(source string) (*SyncRun, error) {
+	return GetResumableSyncRun(s.db, source)
+}
+
+func (s *SQLiteStore) CheckpointSyncRun(run *SyncRun, seenIDs []int64) error {
+	return CheckpointSyncRun(s.db, run, seenIDs)
+}
+
+func (s *SQLiteStore) FinishSyncRun(run *SyncRun, status string, runErr error) error {
+	return FinishSyncRun(s.db, run, status, runErr)
+}
+
+func (s *SQLiteStore) GetSyncRunRepoIDs(runID int64) ([]int64, error) {
+	return GetSyncRunRepoIDs(s.db, runID)
+}
+
+func (s *SQLiteStore) GetSyncRuns(limit int) ([]SyncRun, error) {
+	return GetSyncRuns(s.db, limit)
+}
+
+func (s *SQLiteStore) GetAllReposWithETags(source string) ([]Repository, error) {
+	return GetAllReposWithETags(s.db, source)
+}
+
+func (s *SQLiteStore) UpsertRepository(repo Repository) (UpsertOutcome, error) {
+	return UpsertRepository(s.db, repo)
+}
+
+func (s *SQLiteStore) MarkUnstarred(source string, starredIDs []int64) (int64, error) {
+	return MarkUnstarred(s.db, source, starredIDs)
+}
+
+func (s *SQLiteStore) CountUnstarred(source string) (int64, error) {
+	return CountUnstarred(s.db, source)
+}
+
+func (s *SQLiteStore) PruneUnstarred(source string) (int64, error) {
+	return PruneUnstarred(s.db, source)
+}
+
+func (s *SQLiteStore) TakeSnapshot(reason string) (string, error) {
+	return TakeSnapshot(s.db, reason)
+}
+
+func (s *SQLiteStore) GetReposForSummarization(limit int) ([]Repository, error) {
+	return GetReposForSummarization(s.db, limit)
+}
+
+func (s *SQLiteStore) UpdateRepoSummary(repoID int64, summary string) error {
+	return UpdateRepoSummary(s.db, repoID, summary)
+}
+
+func (s *SQLiteStore) GetReposForEmbedding(model string, limit int) ([]Repository, error) {
+	return GetReposForEmbedding(s.db, model, limit)
+}
+
+func (s *SQLiteStore) UpdateRepoEmbedding(repoID int64, model string, embedding []float32) error {
+	return UpdateRepoEmbedding(s.db, repoID, model, embedding)
+}
+
+func (s *SQLiteStore) GetLists() ([]List, error) {
+	return GetLists(s.db)
+}
+
+func (s *SQLiteStore) GetListByID(id int64) (*List, error) {
+	return GetListByID(s.db, id)
+}
+
+func (s *SQLiteStore) GetListByName(name string) (*List, error) {
+	return GetListByName(s.db, name)
+}
+
+func (s *SQLiteStore) CreateList(name, prompt string) (int64, error) {
+	return CreateList(s.db, name, prompt)
+}
+
+func (s *SQLiteStore) UpdateList(id int64, name, prompt *string) error {
+	return UpdateList(s.db, id, name, prompt)
+}
+
+func (s *SQLiteStore) DeleteList(id int64) error {
+	return DeleteList(s.db, id)
+}
+
+func (s *SQLiteStore) GetReposByListID(listID int64) ([]ListRepository, error) {
+	return GetReposByListID(s.db, listID)
+}
+
+func (s *SQLiteStore) PinRepoToList(listID, repoID int64) error {
+	return PinRepoToList(s.db, listID, repoID)
+}
+
+func (s *SQLiteStore) ExcludeRepoFromList(listID, repoID int64) error {
+	return ExcludeRepoFromList(s.db, listID, repoID)
+}
+
+func (s *SQLiteStore) GetReposForListClassification(listID int64) ([]Repository, error) {
+	return GetReposForListClassification(s.db, listID)
+}
+
+func (s *SQLiteStore) ReplaceListClassification(listID int64, repoIDs []int64) error {
+	return ReplaceListClassification(s.db, listID, repoIDs)
+}
+
+func (s *SQLiteStore) GetTags() ([]Tag, error) {
+	return GetTags(s.db)
+}
+
+func (s *SQLiteStore) GetTagByID(id int64) (*Tag, error) {
+	return GetTagByID(s.db, id)
+}
+
+func (s *SQLiteStore) GetTagByName(name string) (*Tag, error) {
+	return GetTagByName(s.db, name)
+}
+
+func (s *SQLiteStore) CreateTag(name, color string) (int64, error) {
+	return CreateTag(s.db, name, color)
+}
+
+func (s *SQLiteStore) GetOrCreateTag(name string) (int64, error) {
+	return GetOrCreateTag(s.db, name)
+}
+
+func (s *SQLiteStore) UpdateTag(id int64, name, color *string) error {
+	return UpdateTag(s.db, id, name, color)
+}
+
+func (s *SQLiteStore) MergeTags(fromID, intoID int64) error {
+	return MergeTags(s.db, fromID, intoID)
+}
+
+func (s *SQLiteStore) DeleteTag(id int64) error {
+	return DeleteTag(s.db, id)
+}
+
+func (s *SQLiteStore) TagRepository(repoID, tagID int64) error {
+	return TagRepository(s.db, repoID, tagID)
+}
+
+func (s *SQLiteStore) UntagRepository(repoID, tagID int64) error {
+	return UntagRepository(s.db, repoID, tagID)
+}
+
+func (s *SQLiteStore) GetRepoTags(repoID int64) ([]Tag, error) {
+	return GetRepoTags(s.db, repoID)
+}
+
+func (s *SQLiteStore) GetReposByTagID(tagID int64) ([]Repository, error) {
+	return GetReposByTagID(s.db, tagID)
+}
+
+func (s *SQLiteStore) ReplaceTopics(topics []Topic, assignments []TopicAssignment) error {
+	return ReplaceTopics(s.db, topics, assignments)
+}
+
+func (s *SQLiteStore) GetTopics() ([]Topic, error) {
+	return GetTopics(s.db)
+}
+
+func (s *SQLiteStore) GetTopicByID(id int64) (*Topic, error) {
+	return GetTopicByID(s.db, id)
+}
+
+func (s *SQLiteStore) GetTopicByName(name string) (*Topic, error) {
+	return GetTopicByName(s.db, name)
+}
+
+func (s *SQLiteStore) GetReposByTopicID(topicID int64, minConfidence float64) ([]TopicRepository, error) {
+	return GetReposByTopicID(s.db, topicID, minConfidence)
+}
+
+func (s *SQLiteStore) CreateJob(jobType string, params interface{}, maxAttempts int) (int64, error) {
+	return CreateJob(s.db, jobType, params, maxAttempts)
+}
+
+func (s *SQLiteStore) GetJob(id int64) (*Job, error) {
+	return GetJob(s.db, id)
+}
+
+func (s *SQLiteStore) GetJobs(jobType, status string, limit int) ([]Job, error) {
+	return GetJobs(s.db, jobType, status, limit)
+}
+
+func (s *SQLiteStore) GetActiveJob(jobType string) (*Job, error) {
+	return GetActiveJob(s.db, jobType)
+}
+
+func (s *SQLiteStore) ClaimNextJob(types []string) (*Job, error) {
+	return ClaimNextJob(s.db, types)
+}
+
+func (s *SQLiteStore) UpdateJobProgress(id int64, progress, total int, message string) error {
+	return UpdateJobProgress(s.db, id, progress, total, message)
+}
+
+func (s *SQLiteStore) FinishJob(id int64, status string, result interface{}, jobErr error) error {
+	return FinishJob(s.db, id, status, result, jobErr)
+}
+
+func (s *SQLiteStore) RetryJob(id int64, runAfter time.Time, jobErr error) error {
+	return RetryJob(s.db, id, runAfter, jobErr)
+}
+
+func (s *SQLiteStore) RequeueJob(id int64) error {
+	return RequeueJob(s.db, id)
+}
+
+func (s *SQLiteStore) CancelQueuedJob(id int64) (bool, error) {
+	return CancelQueuedJob(s.db, id)
+}
+
+func (s *SQLiteStore) InterruptJob(id int64) error {
+	return InterruptJob(s.db, id)
+}
+
+func (s *SQLiteStore) RequeueInterruptedJobs() (int64, error) {
+	return RequeueInterruptedJobs(s.db)
+}
+
+func (s *SQLiteStore) DeleteJob(id int64) error {
+	return DeleteJob(s.db, id)
+}
+
+func (s *SQLiteStore) SearchRepositories(query string, limit int) ([]SearchResult, error) {
+	return SearchRepositories(s.db, query, limit)
+}
+
+func (s *SQLiteStore) CountEmbeddings(model string) (int, error) {
+	return CountEmbeddings(s.db, model)
+}
+
+func (s *SQLiteStore) SemanticSearch(model string, query []float32, limit int) ([]ScoredRepository, error) {
+	return SemanticSearch(s.db, model, query, limit)
+}
+
+func (s *SQLiteStore) CreateAPIToken(name, scope, tokenHash string) (int64, error) {
+	return CreateAPIToken(s.db, name, scope, tokenHash)
+}
+
+func (s *SQLiteStore) GetAPITokens() ([]APIToken, error) {
+	return GetAPITokens(s.db)
+}
+
+func (s *SQLiteStore) CountAPITokens() (int, error) {
+	return CountAPITokens(s.db)
+}
+
+func (s *SQLiteStore) DeleteAPIToken(id int64) error {
+	return DeleteAPIToken(s.db, id)
+}
+
+func (s *SQLiteStore) UseAPIToken(tokenHash string) (*APIToken, error) {
+	return UseAPIToken(s.db, tokenHash)
+}
+
+func (s *SQLiteStore) CreateSession(idHash string, tokenID int64, csrfToken string, expiresAt time.Time) error {
+	return CreateSession(s.db, idHash, tokenID, csrfToken, expiresAt)
+}
+
+func (s *SQLiteStore) GetSession(idHash string) (*Session, error) {
+	return GetSession(s.db, idHash)
+}
+
+func (s *SQLiteStore) DeleteSession(idHash string) error {
+	return DeleteSession(s.db, idHash)
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Sync run statuses.
+const (
+	SyncRunning     = "running"
+	SyncCompleted   = "completed"
+	SyncFailed      = "failed"
+	SyncInterrupted = "interrupted"
+)
+
+// SyncRun is one execution of the sync command.
+type SyncRun struct {
+	ID         int64
+	Source     string
+	StartedAt  string
+	FinishedAt string
+	Status     string
+	API        string
+	Cursor     string
+	// Fetched is set once every page has been stored, so that only the
+	// reconciliation is left.
+	Fetched   bool
+	ReposDone int
+	Added     int
+	Updated   int
+	Unchanged int
+	Failed    int
+	Removed   int
+	Error     string
+}
+
+// HasCheckpoint reports whether run has stored at least one page, so resuming
+// it skips work.
+func (run *SyncRun) HasCheckpoint() bool {
+	return run.Cursor != "" || run.Fetched
+}
+
+// CreateSyncRun starts a new sync run of source using the given API (e.g. "graphql" or "rest").
+func CreateSyncRun(db *sql.DB, source, api string) (*SyncRun, error) {
+	now := time.Now()
+	res, err := db.Exec("INSERT INTO sync_runs (source, started_at, status, api) VALUES (?, ?, ?, ?)", source, now, SyncRunning, api)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert sync run: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &SyncRun{ID: id, Source: source, StartedAt: now.Format(time.RFC3339), Status: SyncRunning, API: api}, nil
+}
+
+// GetResumableSyncRun returns the most recent run of source if it did not complete,
+// or nil if the last run finished (or there are no runs at all).
+func GetResumableSyncRun(db *sql.DB, source string) (*SyncRun, error) {
+	runs, err := getSyncRuns(db, source, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 || runs[0].Status == SyncCompleted {
+		return nil, nil
+	}
+	return &runs[0], nil
+}
+
+// CheckpointSyncRun persists the progress of run after a page has been stored,
+// together with the IDs of the repositories on that page. Only checkpointed
+// progress survives an interruption; FinishSyncRun keeps the counters of the
+// last checkpoint for unfinished runs.
+func CheckpointSyncRun(db *sql.DB, run *SyncRun, seenIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE sync_runs SET status = ?, api = ?, cursor = ?, fetched = ?, repos_done = ?, added = ?, updated = ?,
+			unchanged = ?, failed = ?, error = NULL
+		WHERE id = ?;
+	`, SyncRunning, run.API, run.Cursor, run.Fetched, run.ReposDone, run.Added, run.Updated, run.Unchanged, run.Failed, run.ID); err != nil {
+		return fmt.Errorf("could not update sync run %d: %w", run.ID, err)
+	}
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO sync_run_repos (run_id, repository_id) VALUES (?, ?)")
+	if err != nil {
+		return fmt.Errorf("could not prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, id := range seenIDs {
+		if _, err := stmt.Exec(run.ID, id); err != nil {
+			return fmt.Errorf("could not record repo %d for sync run %d: %w", id, run.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FinishSyncRun records the final status of run. The checkpoint is kept for
+// failed and interrupted runs so they can be resumed.
+func FinishSyncRun(db *sql.DB, run *SyncRun, status string, runErr error) error {
+	run.Status = status
+	run.Error = ""
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	run.FinishedAt = time.Now().Format(time.RFC3339)
+
+	_, err := db.Exec(`
+		UPDATE sync_runs SET status = ?, finished_at = ?, cursor = ?, repos_done = ?, added = ?, updated = ?,
+			unchanged = ?, failed = ?, removed = ?, error = ?
+		WHERE id = ?;
+	`, status, time.Now(), run.Cursor, run.ReposDone, run.Added, run.Updated, run.Unchanged, run.Failed, run.Removed,
+		nullIfEmpty(run.Error), run.ID)
+	if err != nil {
+		return fmt.Errorf("could not finish sync run %d: %w", run.ID, err)
+	}
+
+	// The seen list is only needed while the run can still be resumed.
+	if status == SyncCompleted {
+		if _, err := db.Exec("DELETE FROM sync_run_repos WHERE run_id = ?", run.ID); err != nil {
+			return fmt.Errorf("could not clear repos of sync run %d: %w", run.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetSyncRunRepoIDs returns the IDs of all repositories seen by run so far.
+func GetSyncRunRepoIDs(db *sql.DB, runID int64) ([]int64, error) {
+	rows, err := db.Query("SELECT repository_id FROM sync_run_repos WHERE run_id = ?", runID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos of sync run %d: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("could not scan sync run repo row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetSyncRuns returns the most recent sync runs of all sources, newest first (limit <= 0 for all).
+func GetSyncRuns(db *sql.DB, limit int) ([]SyncRun, error) {
+	return getSyncRuns(db, "", limit)
+}
+
+// getSyncRuns returns the most recent sync runs of source, or of all sources if source is empty.
+func getSyncRuns(db *sql.DB, source string, limit int) ([]SyncRun, error) {
+	query := `
+		SELECT id, source, started_at, finished_at, status, api, cursor, fetched, repos_done,
+			added, updated, unchanged, failed, removed, error
+		FROM sync_runs
+	`
+	var args []interface{}
+	if source != "" {
+		query += " WHERE source = ?"
+		args = append(args, source)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?;"
+		args = append(args, limit)
+	} else {
+		query += ";"
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query sync runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []SyncRun
+	for rows.Next() {
+		var run SyncRun
+		var finishedAt, cursor, runErr sql.NullString
+		if err := rows.Scan(
+			&run.ID,
+			&run.Source,
+			&run.StartedAt,
+			&finishedAt,
+			&run.Status,
+			&run.API,
+			&cursor,
+			&run.Fetched,
+			&run.ReposDone,
+			&run.Added,
+			&run.Updated,
+			&run.Unchanged,
+			&run.Failed,
+			&run.Removed,
+			&runErr,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan sync run row: %w", err)
+		}
+		run.FinishedAt = finishedAt.String
+		run.Cursor = cursor.String
+		run.Error = runErr.String
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
@@ -0,0 +1,31 @@
+package db_test
+
+import (
+	"errors"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+func TestMarkUnstarredRefusesEmptySet(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		store.AddRepository(db.Repository{Source: db.DefaultSource, SourceID: 1, FullName: "owner/one"})
+		store.AddRepository(db.Repository{Source: db.DefaultSource, SourceID: 2, FullName: "owner/two"})
+
+		if _, err := store.MarkUnstarred(db.DefaultSource, nil); !errors.Is(err, db.ErrEmptyReconcile) {
+			t.Errorf("got error %v, want ErrEmptyReconcile", err)
+		}
+		if n, err := store.CountUnstarred(db.DefaultSource); err != nil || n != 0 {
+			t.Errorf("got %d unstarred, %v, want 0", n, err)
+		}
+
+		// A source without starred repositories has nothing to keep.
+		if n, err := store.MarkUnstarred("other", nil); err != nil || n != 0 {
+			t.Errorf("empty source: got %d, %v, want 0, nil", n, err)
+		}
+
+		if n, err := store.MarkUnstarred(db.DefaultSource, []int64{1}); err != nil || n != 1 {
+			t.Errorf("got %d, %v, want 1 unstarred", n, err)
+		}
+	})
+}
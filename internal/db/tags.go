@@ -0,0 +1,350 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTagName is returned for empty tag names and names containing commas.
+var ErrInvalidTagName = errors.New("tag names must not be empty or contain commas")
+
+// ErrTagExists is returned when a tag is created or renamed to the name of another tag.
+var ErrTagExists = errors.New("a tag with this name already exists")
+
+// refreshRepoTagsSQL recomputes the denormalized tags column of the
+// repositories matched by the WHERE clause appended to it.
+const refreshRepoTagsSQL = `
+	UPDATE repositories SET tags = (
+		SELECT group_concat(name, ',') FROM (
+			SELECT t.name FROM tags t
+			JOIN repository_tags rt ON t.id = rt.tag_id
+			WHERE rt.repository_id = repositories.id
+			ORDER BY t.name
+		)
+	)
+`
+
+// Tag is a user-defined label.
+type Tag struct {
+	ID        int64
+	Name      string
+	Color     string
+	CreatedAt string
+	RepoCount int // For holding counts in joins
+}
+
+// normalizeTagName trims name and checks that it can be stored in the tags column.
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, ",") {
+		return "", ErrInvalidTagName
+	}
+	return name, nil
+}
+
+// CreateTag creates a new tag and returns its ID.
+func CreateTag(db *sql.DB, name, color string) (int64, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return 0, err
+	}
+	if existing, err := GetTagByName(db, name); err != nil {
+		return 0, err
+	} else if existing != nil {
+		return 0, ErrTagExists
+	}
+	res, err := db.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", name, nullIfEmpty(color))
+	if err != nil {
+		return 0, fmt.Errorf("could not insert tag: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// GetTags retrieves all tags with a count of the starred repositories carrying each.
+func GetTags(db *sql.DB) ([]Tag, error) {
+	query := `
+		SELECT t.id, t.name, COALESCE(t.color, ''), t.created_at, COUNT(r.id) as repo_count
+		FROM tags t
+		LEFT JOIN repository_tags rt ON t.id = rt.tag_id
+		LEFT JOIN repositories r ON r.id = rt.repository_id AND r.unstarred_at IS NULL
+		GROUP BY t.id
+		ORDER BY t.name;
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("could not query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt, &t.RepoCount); err != nil {
+			return nil, fmt.Errorf("could not scan tag row: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetTagByID returns the tag with the given ID, or nil if it does not exist.
+func GetTagByID(db *sql.DB, id int64) (*Tag, error) {
+	return getTag(db, "id", id)
+}
+
+// GetTagByName returns the tag called name (case-insensitive), or nil if it does not exist.
+func GetTagByName(db *sql.DB, name string) (*Tag, error) {
+	return getTag(db, "name", strings.TrimSpace(name))
+}
+
+// getTag returns the tag whose column equals value, or nil.
+func getTag(db *sql.DB, column string, value interface{}) (*Tag, error) {
+	var t Tag
+	err := db.QueryRow("SELECT id, name, COALESCE(color, ''), created_at FROM tags WHERE "+column+" = ?;", value).
+		Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get tag %v: %w", value, err)
+	}
+	return &t, nil
+}
+
+// GetOrCreateTag returns the ID of the tag called name, creating it if needed.
+func GetOrCreateTag(db *sql.DB, name string) (int64, error) {
+	tag, err := GetTagByName(db, name)
+	if err != nil {
+		return 0, err
+	}
+	if tag != nil {
+		return tag.ID, nil
+	}
+	return CreateTag(db, name, "")
+}
+
+// UpdateTag changes the name and the color of a tag; nil leaves a field as it
+// is and an empty color removes it. Both change together or not at all. A new
+// name is also updated in the repositories carrying the tag.
+func UpdateTag(db *sql.DB, id int64, name, color *string) error {
+	var newName interface{}
+	if name != nil {
+		normalized, err := normalizeTagName(*name)
+		if err != nil {
+			return err
+		}
+		newName = normalized
+	}
+	var newColor interface{}
+	if color != nil {
+		newColor = nullIfEmpty(*color)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if newName != nil {
+		var existing int64
+		err := tx.QueryRow("SELECT id FROM tags WHERE name = ?;", newName).Scan(&existing)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("could not check tag name: %w", err)
+		}
+		if err == nil && existing != id {
+			return ErrTagExists
+		}
+	}
+	res, err := tx.Exec("UPDATE tags SET name = COALESCE(?, name), color = CASE WHEN ? THEN ? ELSE color END WHERE id = ?;",
+		newName, color != nil, newColor, id)
+	if err != nil {
+		return fmt.Errorf("could not update tag %d: %w", id, err)
+	}
+	if err := requireRow(res, "tag", id); err != nil {
+		return err
+	}
+	if newName != nil {
+		if _, err := tx.Exec(refreshRepoTagsSQL+"WHERE id IN (SELECT repository_id FROM repository_tags WHERE tag_id = ?);", id); err != nil {
+			return fmt.Errorf("could not update tags of repositories: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// MergeTags moves all repositories of the tag fromID to the tag intoID and
+// deletes fromID.
+func MergeTags(db *sql.DB, fromID, intoID int64) error {
+	if fromID == intoID {
+		return fmt.Errorf("cannot merge tag %d into itself", fromID)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE id IN (?, ?);", fromID, intoID).Scan(&n); err != nil {
+		return fmt.Errorf("could not look up tags: %w", err)
+	}
+	if n != 2 {
+		return fmt.Errorf("could not merge tag %d into %d: %w", fromID, intoID, sql.ErrNoRows)
+	}
+
+	stmts := []string{
+		"INSERT OR IGNORE INTO repository_tags (repository_id, tag_id) SELECT repository_id, ? FROM repository_tags WHERE tag_id = ?;",
+		"DELETE FROM repository_tags WHERE tag_id = ?;",
+		"DELETE FROM tags WHERE id = ?;",
+	}
+	if _, err := tx.Exec(stmts[0], intoID, fromID); err != nil {
+		return fmt.Errorf("could not move repositories to tag %d: %w", intoID, err)
+	}
+	for _, stmt := range stmts[1:] {
+		if _, err := tx.Exec(stmt, fromID); err != nil {
+			return fmt.Errorf("could not delete tag %d: %w", fromID, err)
+		}
+	}
+	if _, err := tx.Exec(refreshRepoTagsSQL+"WHERE id IN (SELECT repository_id FROM repository_tags WHERE tag_id = ?);", intoID); err != nil {
+		return fmt.Errorf("could not update tags of repositories: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteTag deletes a tag and removes it from all repositories.
+func DeleteTag(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Foreign keys are not enforced on this connection, so cascade by hand.
+	if _, err := tx.Exec("CREATE TEMP TABLE IF NOT EXISTS untagged_repos (id INTEGER PRIMARY KEY);"); err != nil {
+		return fmt.Errorf("could not create temp table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM untagged_repos;"); err != nil {
+		return fmt.Errorf("could not clear temp table: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO untagged_repos SELECT repository_id FROM repository_tags WHERE tag_id = ?;", id); err != nil {
+		return fmt.Errorf("could not collect repositories of tag %d: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM repository_tags WHERE tag_id = ?;", id); err != nil {
+		return fmt.Errorf("could not remove tag %d from repositories: %w", id, err)
+	}
+	res, err := tx.Exec("DELETE FROM tags WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("could not delete tag %d: %w", id, err)
+	}
+	if err := requireRow(res, "tag", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(refreshRepoTagsSQL + "WHERE id IN (SELECT id FROM untagged_repos);"); err != nil {
+		return fmt.Errorf("could not update tags of repositories: %w", err)
+	}
+	return tx.Commit()
+}
+
+// TagRepository adds a tag to a repository. Adding a tag twice is not an error.
+func TagRepository(db *sql.DB, repoID, tagID int64) error {
+	return changeRepoTag(db, "INSERT OR IGNORE INTO repository_tags (repository_id, tag_id) VALUES (?, ?);", repoID, tagID)
+}
+
+// UntagRepository removes a tag from a repository.
+func UntagRepository(db *sql.DB, repoID, tagID int64) error {
+	return changeRepoTag(db, "DELETE FROM repository_tags WHERE repository_id = ? AND tag_id = ?;", repoID, tagID)
+}
+
+// changeRepoTag runs stmt with repoID and tagID and refreshes the tags column of the repository.
+func changeRepoTag(db *sql.DB, stmt string, repoID, tagID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE id = ?;", tagID).Scan(&n); err != nil {
+		return fmt.Errorf("could not look up tag %d: %w", tagID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("tag %d not found: %w", tagID, sql.ErrNoRows)
+	}
+	if _, err := tx.Exec(stmt, repoID, tagID); err != nil {
+		return fmt.Errorf("could not change tag %d of repo %d: %w", tagID, repoID, err)
+	}
+	res, err := tx.Exec(refreshRepoTagsSQL+"WHERE id = ?;", repoID)
+	if err != nil {
+		return fmt.Errorf("could not update tags of repo %d: %w", repoID, err)
+	}
+	if err := requireRow(res, "repository", repoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRepoTags retrieves the tags of a repository.
+func GetRepoTags(db *sql.DB, repoID int64) ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.name, COALESCE(t.color, ''), t.created_at
+		FROM tags t
+		JOIN repository_tags rt ON t.id = rt.tag_id
+		WHERE rt.repository_id = ?
+		ORDER BY t.name;
+	`, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query tags of repo %d: %w", repoID, err)
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan tag row: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetReposByTagID retrieves the starred repositories carrying a tag.
+func GetReposByTagID(db *sql.DB, tagID int64) ([]Repository, error) {
+	query := `
+		SELECT ` + repoSelectColumns + `
+		FROM repositories r
+		JOIN repository_tags rt ON r.id = rt.repository_id
+		WHERE rt.tag_id = ? AND r.unstarred_at IS NULL
+		ORDER BY r.stargazers_count DESC;
+	`
+	rows, err := db.Query(query, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos by tag ID: %w", err)
+	}
+	defer rows.Close()
+
+	var repos []Repository
+	for rows.Next() {
+		repo, err := scanRepository(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row for tag: %w", err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+// requireRow returns an error wrapping sql.ErrNoRows if res affected no rows.
+func requireRow(res sql.Result, what string, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %d not found: %w", what, id, sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Topic sources.
+const (
+	// TopicSourceAI marks topics and assignments proposed by the AI.
+	TopicSourceAI = "ai"
+	// TopicSourceGitHub marks assignments taken from a repository's own topics.
+	TopicSourceGitHub = "github"
+)
+
+// Topic is an entry of the topic vocabulary.
+type Topic struct {
+	ID          int64
+	Name        string
+	Description string
+	CreatedAt   string
+	RepoCount   int // For holding counts in joins
+}
+
+// TopicAssignment places a repository in a topic of the vocabulary.
+type TopicAssignment struct {
+	RepoID     int64
+	Topic      string
+	Confidence float64
+	// Source is TopicSourceAI or TopicSourceGitHub.
+	Source string
+}
+
+// TopicRepository is a repository together with its confidence for a topic.
+type TopicRepository struct {
+	Repository
+	Confidence float64
+	// AssignedBy is TopicSourceAI or TopicSourceGitHub.
+	AssignedBy string
+}
+
+// ReplaceTopics stores a newly discovered vocabulary and its assignments.
+// Topics keep their ID when their name is rediscovered; topics missing from
+// the new vocabulary are deleted together with all previous assignments.
+func ReplaceTopics(db *sql.DB, topics []Topic, assignments []TopicAssignment) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM repository_topics;"); err != nil {
+		return fmt.Errorf("could not clear topic assignments: %w", err)
+	}
+	if _, err := tx.Exec("CREATE TEMP TABLE IF NOT EXISTS discovered_topics (name TEXT PRIMARY KEY);"); err != nil {
+		return fmt.Errorf("could not create temp table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM discovered_topics;"); err != nil {
+		return fmt.Errorf("could not clear temp table: %w", err)
+	}
+
+	ids := make(map[string]int64, len(topics))
+	for _, t := range topics {
+		if _, err := tx.Exec(`
+			INSERT INTO topics (name, description) VALUES (?, ?)
+			ON CONFLICT(name) DO UPDATE SET description = excluded.description;
+		`, t.Name, nullIfEmpty(t.Description)); err != nil {
+			return fmt.Errorf("could not save topic %s: %w", t.Name, err)
+		}
+		var id int64
+		if err := tx.QueryRow("SELECT id FROM topics WHERE name = ?;", t.Name).Scan(&id); err != nil {
+			return fmt.Errorf("could not get ID of topic %s: %w", t.Name, err)
+		}
+		ids[t.Name] = id
+		if _, err := tx.Exec("INSERT OR IGNORE INTO discovered_topics (name) VALUES (?);", t.Name); err != nil {
+			return fmt.Errorf("could not record topic %s: %w", t.Name, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM topics WHERE name NOT IN (SELECT name FROM discovered_topics);"); err != nil {
+		return fmt.Errorf("could not delete old topics: %w", err)
+	}
+
+	// A GitHub assignment wins over an AI one for the same repository and topic.
+	stmt, err := tx.Prepare(`
+		INSERT INTO repository_topics (repository_id, topic_id, confidence_score, source) VALUES (?, ?, ?, ?)
+		ON CONFLICT(repository_id, topic_id) DO UPDATE SET
+			confidence_score = MAX(confidence_score, excluded.confidence_score),
+			source = CASE WHEN excluded.source = 'github' THEN 'github' ELSE source END;
+	`)
+	if err != nil {
+		return fmt.Errorf("could not prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, a := range assignments {
+		id, ok := ids[a.Topic]
+		if !ok {
+			continue
+		}
+		if _, err := stmt.Exec(a.RepoID, id, a.Confidence, a.Source); err != nil {
+			return fmt.Errorf("could not assign repo %d to topic %s: %w", a.RepoID, a.Topic, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTopics retrieves all topics with a count of the starred repositories in each.
+func GetTopics(db *sql.DB) ([]Topic, error) {
+	query := `
+		SELECT t.id, t.name, COALESCE(t.description, ''), t.created_at, COUNT(r.id) as repo_count
+		FROM topics t
+		LEFT JOIN repository_topics rt ON t.id = rt.topic_id
+		LEFT JOIN repositories r ON r.id = rt.repository_id AND r.unstarred_at IS NULL
+		GROUP BY t.id
+		ORDER BY repo_count DESC, t.name;
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("could not query topics: %w", err)
+	}
+	defer rows.Close()
+
+	var topics []Topic
+	for rows.Next() {
+		var t Topic
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.CreatedAt, &t.RepoCount); err != nil {
+			return nil, fmt.Errorf("could not scan topic row: %w", err)
+		}
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// GetTopicByID returns the topic with the given ID, or nil if it does not exist.
+func GetTopicByID(db *sql.DB, id int64) (*Topic, error) {
+	return getTopic(db, "id", id)
+}
+
+// GetTopicByName returns the topic called name, or nil if it does not exist.
+func GetTopicByName(db *sql.DB, name string) (*Topic, error) {
+	return getTopic(db, "name", name)
+}
+
+// getTopic returns the topic whose column equals value, or nil.
+func getTopic(db *sql.DB, column string, value interface{}) (*Topic, error) {
+	var t Topic
+	err := db.QueryRow("SELECT id, name, COALESCE(description, ''), created_at FROM topics WHERE "+column+" = ?;", value).
+		Scan(&t.ID, &t.Name, &t.Description, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get topic %v: %w", value, err)
+	}
+	return &t, nil
+}
+
+// GetReposByTopicID retrieves the starred repositories assigned to a topic with
+// at least minConfidence, most confident first.
+func GetReposByTopicID(db *sql.DB, topicID int64, minConfidence float64) ([]TopicRepository, error) {
+	query := `
+		SELECT ` + repoSelectColumns + `, COALESCE(rt.confidence_score, 0), rt.source
+		FROM repositories r
+		JOIN repository_topics rt ON r.id = rt.repository_id
+		WHERE rt.topic_id = ? AND COALESCE(rt.confidence_score, 0) >= ? AND r.unstarred_at IS NULL
+		ORDER BY rt.confidence_score DESC, r.stargazers_count DESC;
+	`
+	rows, err := db.Query(query, topicID, minConfidence)
+	if err != nil {
+		return nil, fmt.Errorf("could not query repos by topic ID: %w", err)
+	}
+	defer rows.Close()
+
+	var repos []TopicRepository
+	for rows.Next() {
+		var tr TopicRepository
+		tr.Repository, err = scanRepository(rows, &tr.Confidence, &tr.AssignedBy)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan repo row for topic: %w", err)
+		}
+		repos = append(repos, tr)
+	}
+	return repos, rows.Err()
+}
@@ -0,0 +1,103 @@
+package db_test
+
+import (
+	"fmt"
+	"reflect"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+// topicID returns the ID of the topic called name, or 0 if it does not exist.
+func topicID(t *testing.T, store db.Store, name string) int64 {
+	t.Helper()
+	topic, err := store.GetTopicByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic == nil {
+		return 0
+	}
+	return topic.ID
+}
+
+func TestReplaceTopics(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		a := store.AddRepository(db.Repository{FullName: "owner/a", StargazersCount: 10})
+		b := store.AddRepository(db.Repository{FullName: "owner/b", StargazersCount: 20})
+
+		err := store.ReplaceTopics(
+			[]db.Topic{{Name: "cli", Description: "Old"}, {Name: "web"}},
+			[]db.TopicAssignment{{RepoID: a, Topic: "web", Confidence: 0.8, Source: db.TopicSourceAI}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cli, web := topicID(t, store, "cli"), topicID(t, store, "web")
+
+		// A rediscovered topic keeps its ID and a dropped one disappears with
+		// its assignments.
+		err = store.ReplaceTopics(
+			[]db.Topic{{Name: "cli", Description: "Command line tools"}, {Name: "parsing"}},
+			[]db.TopicAssignment{
+				{RepoID: a, Topic: "cli", Confidence: 0.6, Source: db.TopicSourceAI},
+				{RepoID: a, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+				{RepoID: b, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+				{RepoID: b, Topic: "cli", Confidence: 0.7, Source: db.TopicSourceAI},
+				{RepoID: b, Topic: "parsing", Confidence: 0.5, Source: db.TopicSourceAI},
+				{RepoID: a, Topic: "unknown", Confidence: 1, Source: db.TopicSourceAI},
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := topicID(t, store, "cli"); got != cli {
+			t.Errorf("cli has ID %d after rediscovery, want %d", got, cli)
+		}
+		if got := topicID(t, store, "web"); got != 0 {
+			t.Error("the dropped topic web still exists")
+		}
+		if topic, err := store.GetTopicByID(web); err != nil || topic != nil {
+			t.Errorf("GetTopicByID(web) = %+v, %v, want nil", topic, err)
+		}
+		topic, err := store.GetTopicByID(cli)
+		if err != nil || topic == nil || topic.Description != "Command line tools" {
+			t.Errorf("GetTopicByID(cli) = %+v, %v, want the new description", topic, err)
+		}
+
+		topics, err := store.GetTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts []string
+		for _, topic := range topics {
+			counts = append(counts, fmt.Sprintf("%s:%d", topic.Name, topic.RepoCount))
+		}
+		if want := []string{"cli:2", "parsing:1"}; !reflect.DeepEqual(counts, want) {
+			t.Errorf("got topics %v, want %v", counts, want)
+		}
+
+		repos, err := store.GetReposByTopicID(cli, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A GitHub assignment wins over an AI one, whatever their order.
+		var got []db.TopicRepository
+		for _, r := range repos {
+			got = append(got, db.TopicRepository{Repository: db.Repository{FullName: r.FullName}, Confidence: r.Confidence, AssignedBy: r.AssignedBy})
+		}
+		want := []db.TopicRepository{
+			{Repository: db.Repository{FullName: "owner/b"}, Confidence: 1, AssignedBy: db.TopicSourceGitHub},
+			{Repository: db.Repository{FullName: "owner/a"}, Confidence: 1, AssignedBy: db.TopicSourceGitHub},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got cli repositories %+v, want %+v", got, want)
+		}
+
+		parsing := topicID(t, store, "parsing")
+		for minConfidence, want := range map[float64]int{0.5: 1, 0.6: 0} {
+			repos, err := store.GetReposByTopicID(parsing, minConfidence)
+			if err != nil || len(repos) != want {
+				t.Errorf("GetReposByTopicID(parsing, %v) = %d repositories, %v, want %d", minConfidence, len(repos), err, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Package events broadcasts changes of the library and of background jobs to
+// interested listeners, such as the server's event stream.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	// JobUpdated is published when a job is queued, started, makes progress or
+	// is scheduled for a retry. Its data is the *db.Job.
+	JobUpdated = "job.updated"
+	// JobFinished is published when a job succeeded, failed or was canceled.
+	// Its data is the *db.Job.
+	JobFinished = "job.finished"
+	// JobDeleted is published when a finished job is deleted. Its data is a JobRef.
+	JobDeleted = "job.deleted"
+	// RepoAdded and RepoUpdated are published when a sync saves a new or changed
+	// repository. Their data is a RepoRef.
+	RepoAdded   = "repo.added"
+	RepoUpdated = "repo.updated"
+	// SummaryWritten is published when an AI summary is saved. Its data is a Summary.
+	SummaryWritten = "summary.written"
+	// ListChanged is published when a list is created, edited or deleted, or
+	// its repositories change. Its data is a ListRef.
+	ListChanged = "list.changed"
+	// FrontendChanged is published when a file of the web UI changes while it
+	// is served from a directory for development. It has no data.
+	FrontendChanged = "frontend.changed"
+)
+
+// subscriberBuffer is the number of events a subscriber may fall behind
+// before it is dropped.
+const subscriberBuffer = 64
+
+// Event is a change published on a Bus.
+type Event struct {
+	// ID increases with every event published on the bus.
+	ID   int64
+	Type string
+	Data interface{}
+	Time time.Time
+}
+
+// JobRef identifies a job.
+type JobRef struct {
+	ID int64 `json:"id"`
+}
+
+// RepoRef identifies a repository saved by a sync.
+type RepoRef struct {
+	Source   string `json:"source"`
+	FullName string `json:"full_name"`
+}
+
+// Summary is the data of a SummaryWritten event.
+type Summary struct {
+	RepoID   int64  `json:"repo_id"`
+	FullName string `json:"full_name"`
+	Summary  string `json:"summary"`
+}
+
+// ListRef identifies a list.
+type ListRef struct {
+	ID int64 `json:"id"`
+}
+
+// Bus delivers published events to all current subscribers. A nil *Bus is
+// valid and discards events, so publishers need not check for one.
+type Bus struct {
+	mu     sync.Mutex
+	nextID int64
+	subs   map[chan Event]struct{}
+}
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Publish sends an event to every subscriber without blocking. A subscriber
+// that has fallen too far behind is dropped: its channel is closed, so it can
+// reload the current state and subscribe again instead of missing events
+// silently.
+func (b *Bus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e := Event{ID: b.nextID, Type: eventType, Data: data, Time: time.Now().UTC()}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the events published from now on and
+// a function ending the subscription. The channel is closed when the
+// subscription ends or the subscriber is dropped.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	if b == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
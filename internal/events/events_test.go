@@ -0,0 +1,70 @@
+package events
+
+import "testing"
+
+// receive returns the next event of ch, or false if ch is closed or empty.
+func receive(ch <-chan Event) (Event, bool) {
+	select {
+	case e, ok := <-ch:
+		return e, ok
+	default:
+		return Event{}, false
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	first, unsubscribeFirst := bus.Subscribe()
+	second, unsubscribeSecond := bus.Subscribe()
+	defer unsubscribeSecond()
+
+	bus.Publish(ListChanged, ListRef{ID: 1})
+	bus.Publish(JobDeleted, JobRef{ID: 2})
+	for _, ch := range []<-chan Event{first, second} {
+		for i, want := range []Event{{ID: 1, Type: ListChanged, Data: ListRef{ID: 1}}, {ID: 2, Type: JobDeleted, Data: JobRef{ID: 2}}} {
+			e, ok := receive(ch)
+			if !ok || e.ID != want.ID || e.Type != want.Type || e.Data != want.Data || e.Time.IsZero() {
+				t.Errorf("event %d: got %+v, want %+v", i, e, want)
+			}
+		}
+	}
+
+	// Ending a subscription closes its channel, also when done twice.
+	unsubscribeFirst()
+	unsubscribeFirst()
+	bus.Publish(FrontendChanged, nil)
+	if e, ok := <-first; ok {
+		t.Errorf("got %+v after unsubscribing", e)
+	}
+	if e, ok := receive(second); !ok || e.ID != 3 {
+		t.Errorf("got %+v, want event 3 for the remaining subscriber", e)
+	}
+}
+
+func TestBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	slow, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		bus.Publish(JobUpdated, nil)
+	}
+	// The buffered events are still delivered, then the channel is closed.
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("received %d events, want %d", n, subscriberBuffer)
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	bus.Publish(JobUpdated, nil)
+	ch, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Error("a nil bus delivered an event")
+	}
+}
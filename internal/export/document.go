@@ -0,0 +1,183 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"star-sage/internal/db"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// writeMarkdown writes an awesome-list: a table of contents followed by a
+// section per group, with a line per repository.
+func writeMarkdown(w io.Writer, lib *library) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Awesome Stars\n\n")
+	noun := "repositories"
+	if len(lib.repos) == 1 {
+		noun = "repository"
+	}
+	fmt.Fprintf(bw, "> %d starred %s grouped by %s, exported from StarSage on %s.\n\n",
+		len(lib.repos), noun, lib.groupBy, lib.exportedAt.Format("2006-01-02"))
+
+	anchors := make(map[string]int)
+	if len(lib.groups) > 0 {
+		fmt.Fprintf(bw, "## Contents\n\n")
+		for _, g := range lib.groups {
+			fmt.Fprintf(bw, "- [%s](#%s)\n", markdownEscape(g.Name), anchor(g.Name, anchors))
+		}
+	}
+	for _, g := range lib.groups {
+		fmt.Fprintf(bw, "\n## %s\n\n", markdownEscape(g.Name))
+		if g.Description != "" {
+			fmt.Fprintf(bw, "_%s_\n\n", markdownEscape(strings.Join(strings.Fields(g.Description), " ")))
+		}
+		for _, r := range g.Repos {
+			fmt.Fprintf(bw, "- [%s](%s)", markdownEscape(r.FullName), repoURL(r))
+			if text := blurb(r); text != "" {
+				fmt.Fprintf(bw, " - %s", markdownEscape(text))
+			}
+			var details []string
+			if r.Language != "" {
+				details = append(details, r.Language)
+			}
+			details = append(details, "★ "+formatStars(r.StargazersCount))
+			if r.IsArchived {
+				details = append(details, "archived")
+			}
+			fmt.Fprintf(bw, " (%s)\n", strings.Join(details, ", "))
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownEscape escapes the characters that would start links or emphasis.
+var markdownEscape = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;").Replace
+
+// anchor returns the link target GitHub gives a heading with text name;
+// repeated headings get a numbered suffix, counted in seen.
+func anchor(name string, seen map[string]int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	slug := b.String()
+	n := seen[slug]
+	seen[slug]++
+	if n > 0 {
+		return fmt.Sprintf("%s-%d", slug, n)
+	}
+	return slug
+}
+
+// formatStars abbreviates a star count, e.g. 12345 as 12.3k.
+func formatStars(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprint(n)
+}
+
+// writeHTMLBookmarks writes a Netscape bookmark file, which browsers import
+// as a StarSage folder with a subfolder per group.
+func writeHTMLBookmarks(w io.Writer, lib *library) error {
+	bw := bufio.NewWriter(w)
+	now := lib.exportedAt.Unix()
+	fmt.Fprint(bw, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`)
+	fmt.Fprintf(bw, "    <DT><H3 ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\">StarSage</H3>\n    <DL><p>\n", now, now)
+	for _, g := range lib.groups {
+		fmt.Fprintf(bw, "        <DT><H3 ADD_DATE=\"%d\">%s</H3>\n        <DL><p>\n", now, html.EscapeString(g.Name))
+		for _, r := range g.Repos {
+			attrs := fmt.Sprintf(` HREF="%s"`, html.EscapeString(repoURL(r)))
+			if t, err := time.Parse(time.RFC3339, r.StarredAt); err == nil {
+				attrs += fmt.Sprintf(` ADD_DATE="%d"`, t.Unix())
+			}
+			if len(r.Tags) > 0 {
+				attrs += fmt.Sprintf(` TAGS="%s"`, html.EscapeString(strings.Join(r.Tags, ",")))
+			}
+			fmt.Fprintf(bw, "            <DT><A%s>%s</A>\n", attrs, html.EscapeString(r.FullName))
+			if text := blurb(r); text != "" {
+				fmt.Fprintf(bw, "            <DD>%s\n", html.EscapeString(text))
+			}
+		}
+		fmt.Fprint(bw, "        </DL><p>\n")
+	}
+	fmt.Fprint(bw, "    </DL><p>\n</DL><p>\n")
+	return bw.Flush()
+}
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated"`
+	} `xml:"head"`
+	Body []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text        string        `xml:"text,attr"`
+	Type        string        `xml:"type,attr,omitempty"`
+	XMLURL      string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL     string        `xml:"htmlUrl,attr,omitempty"`
+	URL         string        `xml:"url,attr,omitempty"`
+	Description string        `xml:"description,attr,omitempty"`
+	Outlines    []opmlOutline `xml:"outline"`
+}
+
+// writeOPML writes an OPML 2.0 outline with a folder per group. GitHub
+// repositories are feeds of their releases, so the file can be imported
+// into a feed reader; others are plain links.
+func writeOPML(w io.Writer, lib *library) error {
+	doc := opmlDocument{Version: "2.0"}
+	doc.Head.Title = "StarSage stars"
+	doc.Head.DateCreated = lib.exportedAt.Format(time.RFC1123Z)
+	for _, g := range lib.groups {
+		folder := opmlOutline{Text: g.Name, Description: g.Description}
+		for _, r := range g.Repos {
+			o := opmlOutline{Text: r.FullName, Description: blurb(r)}
+			if r.Source == "" || r.Source == db.DefaultSource {
+				o.Type = "rss"
+				o.XMLURL = repoURL(r) + "/releases.atom"
+				o.HTMLURL = repoURL(r)
+			} else {
+				o.Type = "link"
+				o.URL = repoURL(r)
+			}
+			folder.Outlines = append(folder.Outlines, o)
+		}
+		doc.Body = append(doc.Body, folder)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownRepositoryCount(t *testing.T) {
+	tests := []struct {
+		repos int
+		want  string
+	}{
+		{0, "> 0 starred repositories grouped by list"},
+		{1, "> 1 starred repository grouped by list"},
+		{2, "> 2 starred repositories grouped by list"},
+	}
+	for _, tt := range tests {
+		store := dbtest.NewMemory()
+		for i := 0; i < tt.repos; i++ {
+			store.AddRepository(db.Repository{FullName: fmt.Sprintf("owner/repo%d", i)})
+		}
+		var buf bytes.Buffer
+		if err := Export(&buf, store, Options{Format: FormatMarkdown, GroupBy: GroupByList}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("export of %d repositories does not contain %q:\n%s", tt.repos, tt.want, buf.String())
+		}
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"[link](url)", `\[link\](url)`},
+		{"*bold* and _it_", `\*bold\* and \_it\_`},
+		{"`code`", "\\`code\\`"},
+		{`back\slash`, `back\\slash`},
+		{"<script>", "&lt;script>"},
+	}
+	for _, tt := range tests {
+		if got := markdownEscape(tt.text); got != tt.want {
+			t.Errorf("markdownEscape(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	seen := make(map[string]int)
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"Tools & <Utils>", "tools--utils"},
+		{"Web", "web"},
+		{"web", "web-1"},
+		{"WEB", "web-2"},
+		{"C++ / Rust_2", "c--rust_2"},
+		{"日本語 ツール", "日本語-ツール"},
+		{"Other", "other"},
+		{"Other", "other-1"},
+	} {
+		if got := anchor(tt.name, seen); got != tt.want {
+			t.Errorf("anchor(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	lib := newTestLibrary(t)
+	// A list called Other shares its heading with the group of the
+	// repositories in no list.
+	other, err := lib.store.CreateList("Other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.store.PinRepoToList(other, lib.beta); err != nil {
+		t.Fatal(err)
+	}
+	out := lib.export(t, Options{Format: FormatMarkdown})
+
+	for _, want := range []string{
+		"> 3 starred repositories grouped by list",
+		"- [Other](#other)\n",
+		"- [Tools & &lt;Utils>](#tools--utils)\n",
+		"- [Web](#web)\n",
+		"- [Other](#other-1)\n",
+		"\n## Tools & &lt;Utils>\n\n_Developer tools_\n\n",
+		"- [owner/alpha](https://github.com/owner/alpha) - A \"fast\" parser, with commas (Go, ★ 3.0k)\n",
+		"- [owner/beta](https://codeberg.org/owner/beta) - Reads &lt;b>bold&lt;/b> & \\[links\\](x) with \\*stars\\* and \\_under\\_scores\\_ (★ 200, archived)\n",
+		"- [owner/gamma](https://github.com/owner/gamma) (★ 10)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Markdown does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportHTMLBookmarks(t *testing.T) {
+	lib := newTestLibrary(t)
+	out := lib.export(t, Options{Format: FormatHTMLBookmarks})
+
+	for _, want := range []string{
+		"<!DOCTYPE NETSCAPE-Bookmark-file-1>",
+		`>Tools &amp; &lt;Utils&gt;</H3>`,
+		`<DT><A HREF="https://github.com/owner/alpha" ADD_DATE="1704164645" TAGS="a&#34;b,zeta">owner/alpha</A>`,
+		`<DD>A &#34;fast&#34; parser, with commas`,
+		`<DT><A HREF="https://codeberg.org/owner/beta">owner/beta</A>`,
+		`<DD>Reads &lt;b&gt;bold&lt;/b&gt; &amp; [links](x) with *stars* and _under_scores_`,
+		`>Other</H3>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("bookmarks do not contain %q:\n%s", want, out)
+		}
+	}
+	// Every folder and the file itself are closed.
+	if opened, closed := strings.Count(out, "<DL><p>"), strings.Count(out, "</DL><p>"); opened != 5 || closed != opened {
+		t.Errorf("got %d folders opened and %d closed, want 5", opened, closed)
+	}
+}
+
+func TestExportOPML(t *testing.T) {
+	lib := newTestLibrary(t)
+	out := lib.export(t, Options{Format: FormatOPML, GroupBy: GroupByTopic})
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Errorf("OPML does not start with the XML header:\n%s", out)
+	}
+
+	var doc opmlDocument
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("could not parse OPML: %v\n%s", err, out)
+	}
+	if doc.Version != "2.0" || doc.Head.Title != "StarSage stars" || doc.Head.DateCreated == "" {
+		t.Errorf("got version %q, title %q, created %q", doc.Version, doc.Head.Title, doc.Head.DateCreated)
+	}
+	want := []opmlOutline{
+		{Text: "cli", Description: "Command line tools", Outlines: []opmlOutline{
+			{Text: "owner/alpha", Type: "rss", XMLURL: "https://github.com/owner/alpha/releases.atom",
+				HTMLURL: "https://github.com/owner/alpha", Description: `A "fast" parser, with commas`},
+			{Text: "owner/beta", Type: "link", URL: "https://codeberg.org/owner/beta",
+				Description: "Reads <b>bold</b> & [links](x) with *stars* and _under_scores_"},
+		}},
+		{Text: "Other", Outlines: []opmlOutline{
+			{Text: "owner/gamma", Type: "rss", XMLURL: "https://github.com/owner/gamma/releases.atom",
+				HTMLURL: "https://github.com/owner/gamma"},
+		}},
+	}
+	if !reflect.DeepEqual(doc.Body, want) {
+		t.Errorf("got outlines\n%+v\nwant\n%+v", doc.Body, want)
+	}
+}
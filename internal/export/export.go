@@ -0,0 +1,314 @@
+// Package export writes the starred repositories, with their summaries, tags
+// and lists, in formats other tools can read.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"star-sage/internal/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatJSON          = "json"
+	FormatCSV           = "csv"
+	FormatMarkdown      = "markdown"
+	FormatHTMLBookmarks = "html-bookmarks"
+	FormatOPML          = "opml"
+)
+
+// Formats lists the export formats.
+var Formats = []string{FormatJSON, FormatCSV, FormatMarkdown, FormatHTMLBookmarks, FormatOPML}
+
+var formatInfo = map[string]struct {
+	contentType string
+	fileName    string
+}{
+	FormatJSON:          {"application/json", "stars.json"},
+	FormatCSV:           {"text/csv; charset=utf-8", "stars.csv"},
+	FormatMarkdown:      {"text/markdown; charset=utf-8", "stars.md"},
+	FormatHTMLBookmarks: {"text/html; charset=utf-8", "bookmarks.html"},
+	FormatOPML:          {"text/x-opml; charset=utf-8", "stars.opml"},
+}
+
+// Ways to group repositories in the Markdown, HTML bookmarks and OPML formats.
+const (
+	GroupByList  = "list"
+	GroupByTopic = "topic"
+)
+
+// otherGroup holds the exported repositories that are in no list or topic.
+const otherGroup = "Other"
+
+// Options selects what Export writes.
+type Options struct {
+	// Format is one of Formats.
+	Format string
+	// Filter selects the repositories to export; the zero value exports all
+	// starred repositories.
+	Filter db.RepoFilter
+	// GroupBy is GroupByList (the default) or GroupByTopic.
+	GroupBy string
+}
+
+// ContentType returns the MIME type of format, and false for an unknown format.
+func ContentType(format string) (string, bool) {
+	info, ok := formatInfo[format]
+	return info.contentType, ok
+}
+
+// FileName returns the usual name of a file in format.
+func FileName(format string) string {
+	return formatInfo[format].fileName
+}
+
+// library is the data of an export.
+type library struct {
+	exportedAt time.Time
+	// repos are the exported repositories, most stars first.
+	repos []db.Repository
+	// lists holds the names of the lists each repository is in.
+	lists map[int64][]string
+	// allLists are the lists with exported repositories.
+	allLists []db.List
+	// groups are the sections of the grouped formats.
+	groups  []group
+	groupBy string
+}
+
+// group is a list or topic with the exported repositories in it.
+type group struct {
+	Name        string
+	Description string
+	Repos       []db.Repository
+}
+
+// Export writes the repositories selected by opts to w.
+func Export(w io.Writer, store db.Store, opts Options) error {
+	if _, ok := formatInfo[opts.Format]; !ok {
+		return fmt.Errorf("unknown export format %q, must be one of %s", opts.Format, strings.Join(Formats, ", "))
+	}
+	if opts.GroupBy == "" {
+		opts.GroupBy = GroupByList
+	}
+	if opts.GroupBy != GroupByList && opts.GroupBy != GroupByTopic {
+		return fmt.Errorf("unknown grouping %q, must be %s or %s", opts.GroupBy, GroupByList, GroupByTopic)
+	}
+
+	lib, err := load(store, opts)
+	if err != nil {
+		return err
+	}
+	switch opts.Format {
+	case FormatJSON:
+		return writeJSON(w, lib)
+	case FormatCSV:
+		return writeCSV(w, lib)
+	case FormatMarkdown:
+		return writeMarkdown(w, lib)
+	case FormatHTMLBookmarks:
+		return writeHTMLBookmarks(w, lib)
+	default:
+		return writeOPML(w, lib)
+	}
+}
+
+// load reads the repositories selected by opts with their lists and groups.
+func load(store db.Store, opts Options) (*library, error) {
+	page, err := store.QueryRepositories(db.RepoQuery{RepoFilter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+	lib := &library{
+		exportedAt: time.Now(),
+		repos:      page.Repositories,
+		lists:      make(map[int64][]string),
+		groupBy:    opts.GroupBy,
+	}
+	exported := make(map[int64]db.Repository, len(lib.repos))
+	for _, r := range lib.repos {
+		exported[r.ID] = r
+	}
+	grouped := make(map[int64]bool)
+
+	lists, err := store.GetLists()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lists {
+		members, err := store.GetReposByListID(l.ID)
+		if err != nil {
+			return nil, err
+		}
+		g := group{Name: l.Name, Description: l.Prompt}
+		for _, m := range members {
+			if r, ok := exported[m.ID]; ok {
+				lib.lists[m.ID] = append(lib.lists[m.ID], l.Name)
+				g.Repos = append(g.Repos, r)
+			}
+		}
+		if len(g.Repos) == 0 {
+			continue
+		}
+		lib.allLists = append(lib.allLists, l)
+		// Filtering by a list shows only that list, even if its repositories
+		// are in others too.
+		if opts.GroupBy == GroupByList && (opts.Filter.ListID == 0 || opts.Filter.ListID == l.ID) {
+			lib.addGroup(g, grouped)
+		}
+	}
+
+	if opts.GroupBy == GroupByTopic {
+		topics, err := store.GetTopics()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range topics {
+			if opts.Filter.TopicID != 0 && opts.Filter.TopicID != t.ID {
+				continue
+			}
+			repos, err := store.GetReposByTopicID(t.ID, opts.Filter.MinTopicConfidence)
+			if err != nil {
+				return nil, err
+			}
+			g := group{Name: t.Name, Description: t.Description}
+			for _, tr := range repos {
+				if r, ok := exported[tr.ID]; ok {
+					g.Repos = append(g.Repos, r)
+				}
+			}
+			lib.addGroup(g, grouped)
+		}
+	}
+
+	other := group{Name: otherGroup}
+	for _, r := range lib.repos {
+		if !grouped[r.ID] {
+			other.Repos = append(other.Repos, r)
+		}
+	}
+	lib.addGroup(other, grouped)
+	return lib, nil
+}
+
+// addGroup adds g unless it is empty and records its repositories as grouped.
+func (lib *library) addGroup(g group, grouped map[int64]bool) {
+	if len(g.Repos) == 0 {
+		return
+	}
+	for _, r := range g.Repos {
+		grouped[r.ID] = true
+	}
+	lib.groups = append(lib.groups, g)
+}
+
+// repoURL returns the web page of r.
+func repoURL(r db.Repository) string {
+	if r.URL == "" && (r.Source == "" || r.Source == db.DefaultSource) {
+		return "https://github.com/" + r.FullName
+	}
+	return r.URL
+}
+
+// blurb returns the AI summary of r, or else its description, on one line.
+func blurb(r db.Repository) string {
+	text := r.Summary
+	if text == "" {
+		text = r.Description
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// jsonRepository is a repository in the JSON format.
+type jsonRepository struct {
+	FullName    string   `json:"full_name"`
+	Source      string   `json:"source"`
+	URL         string   `json:"url"`
+	Description string   `json:"description,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Stars       int      `json:"stars"`
+	Topics      []string `json:"topics,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Lists       []string `json:"lists,omitempty"`
+	License     string   `json:"license,omitempty"`
+	Archived    bool     `json:"archived,omitempty"`
+	Fork        bool     `json:"fork,omitempty"`
+	StarredAt   string   `json:"starred_at,omitempty"`
+	PushedAt    string   `json:"pushed_at,omitempty"`
+}
+
+type jsonList struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+func writeJSON(w io.Writer, lib *library) error {
+	doc := struct {
+		ExportedAt   string           `json:"exported_at"`
+		Lists        []jsonList       `json:"lists"`
+		Repositories []jsonRepository `json:"repositories"`
+	}{
+		ExportedAt:   lib.exportedAt.UTC().Format(time.RFC3339),
+		Lists:        []jsonList{},
+		Repositories: []jsonRepository{},
+	}
+	for _, l := range lib.allLists {
+		doc.Lists = append(doc.Lists, jsonList{Name: l.Name, Prompt: l.Prompt})
+	}
+	for _, r := range lib.repos {
+		doc.Repositories = append(doc.Repositories, jsonRepository{
+			FullName:    r.FullName,
+			Source:      r.Source,
+			URL:         repoURL(r),
+			Description: r.Description,
+			Summary:     r.Summary,
+			Language:    r.Language,
+			Stars:       r.StargazersCount,
+			Topics:      r.Topics,
+			Tags:        r.Tags,
+			Lists:       lib.lists[r.ID],
+			License:     r.License,
+			Archived:    r.IsArchived,
+			Fork:        r.IsFork,
+			StarredAt:   r.StarredAt,
+			PushedAt:    r.PushedAt,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeCSV writes one row per repository. Topics, tags and lists are joined
+// with semicolons.
+func writeCSV(w io.Writer, lib *library) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"full_name", "url", "description", "summary", "language", "stars",
+		"topics", "tags", "lists", "license", "archived", "fork", "starred_at", "pushed_at"})
+	for _, r := range lib.repos {
+		cw.Write([]string{
+			r.FullName,
+			repoURL(r),
+			r.Description,
+			r.Summary,
+			r.Language,
+			strconv.Itoa(r.StargazersCount),
+			strings.Join(r.Topics, ";"),
+			strings.Join(r.Tags, ";"),
+			strings.Join(lib.lists[r.ID], ";"),
+			r.License,
+			strconv.FormatBool(r.IsArchived),
+			strconv.FormatBool(r.IsFork),
+			r.StarredAt,
+			r.PushedAt,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,212 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"star-sage/internal/db"
+	"strings"
+	"testing"
+)
+
+// testLibrary is the store of the export tests and the IDs in it.
+type testLibrary struct {
+	store              *db.MemoryStore
+	alpha, beta, gamma int64
+	tools, web         int64
+	cli, empty         int64
+}
+
+// newTestLibrary returns a store with three repositories: alpha in the lists
+// Tools & <Utils> and Web, beta in Tools & <Utils> and gamma in no list. By
+// topic, alpha and beta are about cli, gamma about nothing.
+func newTestLibrary(t *testing.T) *testLibrary {
+	t.Helper()
+	store := db.NewMemoryStore()
+	lib := &testLibrary{store: store}
+	lib.alpha = store.AddRepository(db.Repository{
+		FullName:        "owner/alpha",
+		Description:     `A "fast" parser, with commas`,
+		Language:        "Go",
+		StargazersCount: 3000,
+		Topics:          []string{"cli", "go"},
+		License:         "MIT",
+		StarredAt:       "2024-01-02T03:04:05Z",
+	})
+	lib.beta = store.AddRepository(db.Repository{
+		FullName:        "owner/beta",
+		Source:          "codeberg",
+		URL:             "https://codeberg.org/owner/beta",
+		Description:     "ignored, there is a summary",
+		Summary:         "Reads <b>bold</b> & [links](x)\nwith *stars* and _under_scores_",
+		StargazersCount: 200,
+		IsArchived:      true,
+	})
+	lib.gamma = store.AddRepository(db.Repository{FullName: "owner/gamma", StargazersCount: 10})
+
+	var err error
+	if lib.tools, err = store.CreateList("Tools & <Utils>", "Developer\n tools"); err != nil {
+		t.Fatal(err)
+	}
+	if lib.web, err = store.CreateList("Web", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct{ list, repo int64 }{{lib.tools, lib.alpha}, {lib.tools, lib.beta}, {lib.web, lib.alpha}} {
+		if err := store.PinRepoToList(m.list, m.repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"zeta", `a"b`} {
+		id, err := store.GetOrCreateTag(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.TagRepository(lib.alpha, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = store.ReplaceTopics(
+		[]db.Topic{{Name: "cli", Description: "Command line tools"}, {Name: "empty"}},
+		[]db.TopicAssignment{
+			{RepoID: lib.alpha, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+			{RepoID: lib.beta, Topic: "cli", Confidence: 0.4, Source: db.TopicSourceAI},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []struct {
+		name string
+		id   *int64
+	}{{"cli", &lib.cli}, {"empty", &lib.empty}} {
+		found, err := store.GetTopicByName(topic.name)
+		if err != nil || found == nil {
+			t.Fatalf("topic %s: %v", topic.name, err)
+		}
+		*topic.id = found.ID
+	}
+	return lib
+}
+
+// export exports the test library with opts and returns the output.
+func (lib *testLibrary) export(t *testing.T, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Export(&buf, lib.store, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestExportCSV(t *testing.T) {
+	lib := newTestLibrary(t)
+	out := lib.export(t, Options{Format: FormatCSV})
+
+	// Fields with quotes, commas or line breaks are quoted.
+	for _, want := range []string{`"A ""fast"" parser, with commas"`, "\"Reads <b>bold</b> & [links](x)\nwith"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("CSV does not contain %q:\n%s", want, out)
+		}
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"full_name", "url", "description", "summary", "language", "stars",
+			"topics", "tags", "lists", "license", "archived", "fork", "starred_at", "pushed_at"},
+		{"owner/alpha", "https://github.com/owner/alpha", `A "fast" parser, with commas`, "", "Go", "3000",
+			"cli;go", `a"b;zeta`, "Tools & <Utils>;Web", "MIT", "false", "false", "2024-01-02T03:04:05Z", ""},
+		{"owner/beta", "https://codeberg.org/owner/beta", "ignored, there is a summary",
+			"Reads <b>bold</b> & [links](x)\nwith *stars* and _under_scores_", "", "200",
+			"", "", "Tools & <Utils>", "", "true", "false", "", ""},
+		{"owner/gamma", "https://github.com/owner/gamma", "", "", "", "10",
+			"", "", "", "", "false", "false", "", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows\n%q\nwant\n%q", rows, want)
+	}
+}
+
+func TestLoadGroups(t *testing.T) {
+	lib := newTestLibrary(t)
+	tests := []struct {
+		name string
+		opts Options
+		// want maps the group names, in order, to their repositories.
+		want [][]string
+	}{
+		{
+			name: "by list",
+			opts: Options{GroupBy: GroupByList},
+			want: [][]string{
+				{"Tools & <Utils>", "owner/alpha", "owner/beta"},
+				{"Web", "owner/alpha"},
+				{"Other", "owner/gamma"},
+			},
+		},
+		{
+			// Only the filtered list is shown, although alpha is in Tools too.
+			name: "by list filtered by list",
+			opts: Options{GroupBy: GroupByList, Filter: db.RepoFilter{ListID: lib.web}},
+			want: [][]string{{"Web", "owner/alpha"}},
+		},
+		{
+			name: "by list filtered by language",
+			opts: Options{GroupBy: GroupByList, Filter: db.RepoFilter{Language: "Go"}},
+			want: [][]string{{"Tools & <Utils>", "owner/alpha"}, {"Web", "owner/alpha"}},
+		},
+		{
+			name: "by topic",
+			opts: Options{GroupBy: GroupByTopic},
+			want: [][]string{{"cli", "owner/alpha", "owner/beta"}, {"Other", "owner/gamma"}},
+		},
+		{
+			name: "by topic with confidence",
+			opts: Options{GroupBy: GroupByTopic, Filter: db.RepoFilter{MinTopicConfidence: 0.5}},
+			want: [][]string{{"cli", "owner/alpha"}, {"Other", "owner/beta", "owner/gamma"}},
+		},
+		{
+			name: "by topic filtered by list",
+			opts: Options{GroupBy: GroupByTopic, Filter: db.RepoFilter{ListID: lib.tools}},
+			want: [][]string{{"cli", "owner/alpha", "owner/beta"}},
+		},
+		{
+			name: "by topic filtered by topic",
+			opts: Options{GroupBy: GroupByTopic, Filter: db.RepoFilter{TopicID: lib.empty}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := load(lib.store, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for _, g := range loaded.groups {
+				names := []string{g.Name}
+				for _, r := range g.Repos {
+					names = append(names, r.FullName)
+				}
+				got = append(got, names)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got groups %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportOptions(t *testing.T) {
+	lib := newTestLibrary(t)
+	for _, opts := range []Options{{Format: "yaml"}, {Format: FormatMarkdown, GroupBy: "language"}} {
+		if err := Export(&bytes.Buffer{}, lib.store, opts); err == nil {
+			t.Errorf("Export accepted %+v", opts)
+		}
+	}
+	// Grouping by list is the default.
+	if out := lib.export(t, Options{Format: FormatMarkdown}); !strings.Contains(out, "grouped by list") {
+		t.Errorf("export without grouping is not grouped by list:\n%s", out)
+	}
+}
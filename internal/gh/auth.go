@@ -13,10 +13,9 @@ import (
 
 // Constants for the device flow
 const (
-	clientID       = "Ov23liMsNArPWi0a8BWd" // User-provided Client ID
-	deviceCodeURL  = "https://github.com/login/device/code"
-	accessTokenURL = "https://github.com/login/oauth/access_token"
-	grantType      = "urn:ietf:params:oauth:grant-type:device_code"
+	deviceCodePath  = "/login/device/code"
+	accessTokenPath = "/login/oauth/access_token"
+	grantType       = "urn:ietf:params:oauth:grant-type:device_code"
 )
 
 // DeviceFlowResponse holds the response from the device code request.
@@ -37,8 +36,12 @@ type AccessTokenResponse struct {
 	ErrorDescription string `json:"error_description"`
 }
 
-// PerformDeviceFlow handles the entire GitHub OAuth Device Flow.
-func PerformDeviceFlow(ctx context.Context, proxyAddr string) (string, error) {
+// PerformDeviceFlow handles the entire GitHub OAuth Device Flow against host.
+func PerformDeviceFlow(ctx context.Context, host Host, proxyAddr string) (string, error) {
+	if host.ClientID == "" {
+		return "", fmt.Errorf("no OAuth client ID configured for %s", host.WebURL)
+	}
+
 	// The client for device flow does not need an auth token.
 	client, err := NewClient(proxyAddr, "")
 	if err != nil {
@@ -46,7 +49,7 @@ func PerformDeviceFlow(ctx context.Context, proxyAddr string) (string, error) {
 	}
 
 	// Step 1: Get Device and User Codes
-	deviceFlowResp, err := requestDeviceCode(ctx, client)
+	deviceFlowResp, err := requestDeviceCode(ctx, client, host)
 	if err != nil {
 		return "", fmt.Errorf("failed to request device code: %w", err)
 	}
@@ -56,15 +59,15 @@ func PerformDeviceFlow(ctx context.Context, proxyAddr string) (string, error) {
 	fmt.Printf("Please go to %s to authorize.\n", deviceFlowResp.VerificationURI)
 
 	// Step 3: Poll for the access token
-	return pollForAccessToken(ctx, client, deviceFlowResp)
+	return pollForAccessToken(ctx, client, host, deviceFlowResp)
 }
 
-func requestDeviceCode(ctx context.Context, client *http.Client) (*DeviceFlowResponse, error) {
+func requestDeviceCode(ctx context.Context, client *http.Client, host Host) (*DeviceFlowResponse, error) {
 	data := url.Values{}
-	data.Set("client_id", clientID)
+	data.Set("client_id", host.ClientID)
 	data.Set("scope", "repo,user")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", deviceCodeURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", host.WebURL+deviceCodePath, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +93,7 @@ func requestDeviceCode(ctx context.Context, client *http.Client) (*DeviceFlowRes
 	return &deviceFlowResp, nil
 }
 
-func pollForAccessToken(ctx context.Context, client *http.Client, deviceFlowResp *DeviceFlowResponse) (string, error) {
+func pollForAccessToken(ctx context.Context, client *http.Client, host Host, deviceFlowResp *DeviceFlowResponse) (string, error) {
 	interval := time.Duration(deviceFlowResp.Interval) * time.Second
 	timeout := time.After(time.Duration(deviceFlowResp.ExpiresIn) * time.Second)
 	ticker := time.NewTicker(interval)
@@ -104,11 +107,11 @@ func pollForAccessToken(ctx context.Context, client *http.Client, deviceFlowResp
 			return "", fmt.Errorf("authentication timed out after %d minutes", deviceFlowResp.ExpiresIn/60)
 		case <-ticker.C:
 			data := url.Values{}
-			data.Set("client_id", clientID)
+			data.Set("client_id", host.ClientID)
 			data.Set("device_code", deviceFlowResp.DeviceCode)
 			data.Set("grant_type", grantType)
 
-			req, err := http.NewRequestWithContext(ctx, "POST", accessTokenURL, strings.NewReader(data.Encode()))
+			req, err := http.NewRequestWithContext(ctx, "POST", host.WebURL+accessTokenPath, strings.NewReader(data.Encode()))
 			if err != nil {
 				fmt.Printf("error creating poll request: %v. Retrying...\n", err)
 				continue
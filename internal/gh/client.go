@@ -2,17 +2,18 @@ package gh
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"encoding/base64"
 	"regexp"
 	"strings"
 	"time"
 )
 
 // NewClient creates a new HTTP client, optionally configured with a proxy and auth token.
+// Requests made through it honour rate limit headers; see WithWaitHook.
 func NewClient(proxyAddr, token string) (*http.Client, error) {
 	transport := &http.Transport{}
 
@@ -24,18 +25,19 @@ func NewClient(proxyAddr, token string) (*http.Client, error) {
 		transport.Proxy = http.ProxyURL(proxyURL)
 	}
 
-	client := &http.Client{
-		Transport: transport,
-	}
-
+	var rt http.RoundTripper = transport
 	if token != "" {
 		// Add a wrapper to the transport to inject the auth header.
-		client.Transport = &authTransport{
+		rt = &authTransport{
 			token:     token,
 			transport: transport,
 		}
 	}
 
+	client := &http.Client{
+		Transport: newRateLimitTransport(rt),
+	}
+
 	return client, nil
 }
 
@@ -52,12 +54,45 @@ func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 // GHRepo represents a repository as returned by the GitHub API.
 type GHRepo struct {
-	ID              int64  `json:"id"`
-	FullName        string `json:"full_name"`
-	Description     string `json:"description"`
-	HTMLURL         string `json:"html_url"`
-	Language        string `json:"language"`
-	StargazersCount int    `json:"stargazers_count"`
+	ID              int64      `json:"id"`
+	FullName        string     `json:"full_name"`
+	Description     string     `json:"description"`
+	HTMLURL         string     `json:"html_url"`
+	Language        string     `json:"language"`
+	StargazersCount int        `json:"stargazers_count"`
+	Topics          []string   `json:"topics"`
+	License         *GHLicense `json:"license"`
+	Archived        bool       `json:"archived"`
+	Fork            bool       `json:"fork"`
+	PushedAt        string     `json:"pushed_at"`
+
+	// StarredAt is only set when the star timestamp was requested.
+	StarredAt string `json:"-"`
+	// Readme holds the README text when it was fetched together with the
+	// repository (GraphQL). HasReadme is false when it was not, and the README
+	// must be requested separately.
+	Readme    string `json:"-"`
+	HasReadme bool   `json:"-"`
+}
+
+// GHLicense is the license summary attached to a repository.
+type GHLicense struct {
+	SPDXID string `json:"spdx_id"`
+	Name   string `json:"name"`
+}
+
+// LicenseID returns the SPDX identifier of the repository license, if any.
+func (r GHRepo) LicenseID() string {
+	if r.License == nil || r.License.SPDXID == "NOASSERTION" {
+		return ""
+	}
+	return r.License.SPDXID
+}
+
+// ghStar is the response shape of /user/starred with the star+json media type.
+type ghStar struct {
+	StarredAt string `json:"starred_at"`
+	Repo      GHRepo `json:"repo"`
 }
 
 // GHReadme represents the response for a README file from the GitHub API.
@@ -69,8 +104,8 @@ type GHReadme struct {
 // GetReadme fetches the README content for a single repository.
 // It uses an ETag to avoid re-downloading unchanged content.
 // It returns the new content, the new ETag, and an error.
-func GetReadme(ctx context.Context, client *http.Client, fullName, etag string) (string, string, error) {
-	readmeURL := fmt.Sprintf("https://api.github.com/repos/%s/readme", fullName)
+func GetReadme(ctx context.Context, client *http.Client, host Host, fullName, etag string) (string, string, error) {
+	readmeURL := fmt.Sprintf("%s/repos/%s/readme", host.APIURL, fullName)
 	req, err := http.NewRequestWithContext(ctx, "GET", readmeURL, nil)
 	if err != nil {
 		return "", "", err
@@ -87,7 +122,9 @@ func GetReadme(ctx context.Context, client *http.Client, fullName, etag string)
 		if err == nil {
 			break
 		}
-		time.Sleep(1 * time.Second)
+		if err := sleepContext(ctx, time.Second); err != nil {
+			return "", "", err
+		}
 	}
 	if err != nil {
 		return "", "", err
@@ -127,65 +164,64 @@ func GetReadme(ctx context.Context, client *http.Client, fullName, etag string)
 	return string(decodedContent), newEtag, nil
 }
 
-// GetStarredRepos fetches starred repositories for the authenticated user, up to a given limit.
-func GetStarredRepos(ctx context.Context, token, proxyAddr string, limit int) ([]GHRepo, error) {
-	client, err := NewClient(proxyAddr, token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create http client: %w", err)
-	}
+// StarPage is one page of starred repositories. NextCursor is empty on the last page.
+type StarPage struct {
+	Repos      []GHRepo
+	NextCursor string
+	// TotalCount is the number of stars reported by the API, or 0 if unknown.
+	TotalCount int
+}
 
-	var allRepos []GHRepo
-	// We can fetch up to 100 per page.
-	nextURL := "https://api.github.com/user/starred?per_page=100"
+// starredPerPage is the REST page size; we can fetch up to 100 per page.
+const starredPerPage = 100
 
-	for nextURL != "" {
-		var req *http.Request
-		var err error
-		req, err = http.NewRequestWithContext(ctx, "GET", nextURL, nil)
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-		var resp *http.Response
-		const maxRetries = 3
-		for i := 0; i < maxRetries; i++ {
-			resp, err = client.Do(req)
-			if err == nil {
-				break
-			}
-			fmt.Printf("Request failed (attempt %d/%d): %v. Retrying in 2 seconds...\n", i+1, maxRetries, err)
-			time.Sleep(2 * time.Second)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("request failed after %d retries: %w", maxRetries, err)
-		}
+// FetchStarredPageREST fetches one page of stars from the REST API.
+// The cursor is the URL of the page to fetch; an empty cursor starts at the first page.
+func FetchStarredPageREST(ctx context.Context, client *http.Client, host Host, cursor string) (*StarPage, error) {
+	pageURL := cursor
+	if pageURL == "" {
+		pageURL = fmt.Sprintf("%s/user/starred?per_page=%d", host.APIURL, starredPerPage)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("github api returned non-200 status: %s", resp.Status)
-		}
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The star+json media type wraps each repo with its starred_at timestamp.
+	req.Header.Set("Accept", "application/vnd.github.star+json")
 
-		var repos []GHRepo
-		if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
-			resp.Body.Close()
+	var resp *http.Response
+	const maxRetries = 3
+	for i := 0; i < maxRetries; i++ {
+		resp, err = client.Do(req)
+		if err == nil {
+			break
+		}
+		if err := sleepContext(ctx, 2*time.Second); err != nil {
 			return nil, err
 		}
-		resp.Body.Close()
-
-		allRepos = append(allRepos, repos...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("request failed after %d retries: %w", maxRetries, err)
+	}
+	defer resp.Body.Close()
 
-		if limit > 0 && len(allRepos) >= limit {
-			// Trim excess repos if we fetched more than the limit on the last page
-			allRepos = allRepos[:limit]
-			break
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github api returned non-200 status: %s", resp.Status)
+	}
 
-		// Handle pagination
-		linkHeader := resp.Header.Get("Link")
-		nextURL = parseNextLink(linkHeader)
+	var stars []ghStar
+	if err := json.NewDecoder(resp.Body).Decode(&stars); err != nil {
+		return nil, err
 	}
 
-	return allRepos, nil
+	page := &StarPage{NextCursor: parseNextLink(resp.Header.Get("Link"))}
+	for _, s := range stars {
+		repo := s.Repo
+		repo.StarredAt = s.StarredAt
+		page.Repos = append(page.Repos, repo)
+	}
+	return page, nil
 }
 
 // parseNextLink extracts the next page URL from the Link header.
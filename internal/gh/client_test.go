@@ -0,0 +1,50 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchRetriesStopOnCancel(t *testing.T) {
+	// Requests to a closed server fail at once, so the fetchers keep retrying
+	// until the context ends.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	host := Host{APIURL: srv.URL, GraphQLURL: srv.URL}
+
+	tests := []struct {
+		name  string
+		fetch func(ctx context.Context) error
+	}{
+		{"graphql", func(ctx context.Context) error {
+			_, err := FetchStarredPageGraphQL(ctx, http.DefaultClient, host, "")
+			return err
+		}},
+		{"rest", func(ctx context.Context) error {
+			_, err := FetchStarredPageREST(ctx, http.DefaultClient, host, "")
+			return err
+		}},
+		{"readme", func(ctx context.Context) error {
+			_, _, err := GetReadme(ctx, http.DefaultClient, host, "owner/repo", "")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := tt.fetch(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("returned after %s, want soon after the deadline", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// graphqlPageSize is kept below the 100 maximum because every node also
+	// carries its README blob, which makes large pages slow to resolve.
+	graphqlPageSize = 50
+)
+
+// starredReposQuery fetches one page of the viewer's stars together with the
+// metadata and README blob of each repository. README lookups try the most
+// common file names; the first non-null blob wins.
+const starredReposQuery = `
+query($first: Int!, $after: String) {
+  viewer {
+    starredRepositories(first: $first, after: $after, orderBy: {field: STARRED_AT, direction: DESC}) {
+      totalCount
+      pageInfo { hasNextPage endCursor }
+      edges {
+        starredAt
+        node {
+          databaseId
+          nameWithOwner
+          description
+          url
+          stargazerCount
+          isArchived
+          isFork
+          pushedAt
+          primaryLanguage { name }
+          licenseInfo { spdxId name }
+          repositoryTopics(first: 20) { nodes { topic { name } } }
+          readmeMd: object(expression: "HEAD:README.md") { ... on Blob { text } }
+          readmeLower: object(expression: "HEAD:readme.md") { ... on Blob { text } }
+          readmeRst: object(expression: "HEAD:README.rst") { ... on Blob { text } }
+          readmePlain: object(expression: "HEAD:README") { ... on Blob { text } }
+        }
+      }
+    }
+  }
+}`
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type gqlBlob struct {
+	Text *string `json:"text"`
+}
+
+type gqlRepoNode struct {
+	DatabaseID      int64  `json:"databaseId"`
+	NameWithOwner   string `json:"nameWithOwner"`
+	Description     string `json:"description"`
+	URL             string `json:"url"`
+	StargazerCount  int    `json:"stargazerCount"`
+	IsArchived      bool   `json:"isArchived"`
+	IsFork          bool   `json:"isFork"`
+	PushedAt        string `json:"pushedAt"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	LicenseInfo *struct {
+		SpdxID string `json:"spdxId"`
+		Name   string `json:"name"`
+	} `json:"licenseInfo"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	ReadmeMd    *gqlBlob `json:"readmeMd"`
+	ReadmeLower *gqlBlob `json:"readmeLower"`
+	ReadmeRst   *gqlBlob `json:"readmeRst"`
+	ReadmePlain *gqlBlob `json:"readmePlain"`
+}
+
+type gqlStarredResponse struct {
+	Data struct {
+		Viewer struct {
+			StarredRepositories struct {
+				TotalCount int `json:"totalCount"`
+				PageInfo   struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Edges []struct {
+					StarredAt string      `json:"starredAt"`
+					Node      gqlRepoNode `json:"node"`
+				} `json:"edges"`
+			} `json:"starredRepositories"`
+		} `json:"viewer"`
+	} `json:"data"`
+	Errors []graphqlError `json:"errors"`
+}
+
+// FetchStarredPageGraphQL fetches one page of stars from the GraphQL API.
+// The cursor is the endCursor of the previous page. Unlike the REST listing it
+// includes the starredAt timestamp, topics, license, archived/fork flags,
+// pushedAt and the README text, so no per-repository README request is needed.
+func FetchStarredPageGraphQL(ctx context.Context, client *http.Client, host Host, cursor string) (*StarPage, error) {
+	resp, err := fetchStarredPage(ctx, client, host, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	stars := resp.Data.Viewer.StarredRepositories
+	page := &StarPage{TotalCount: stars.TotalCount}
+	for _, edge := range stars.Edges {
+		page.Repos = append(page.Repos, edgeToRepo(edge.StarredAt, edge.Node))
+	}
+	if stars.PageInfo.HasNextPage {
+		page.NextCursor = stars.PageInfo.EndCursor
+	}
+	return page, nil
+}
+
+// fetchStarredPage runs the starred repositories query for the page after cursor.
+func fetchStarredPage(ctx context.Context, client *http.Client, host Host, cursor string) (*gqlStarredResponse, error) {
+	vars := map[string]interface{}{"first": graphqlPageSize}
+	if cursor != "" {
+		vars["after"] = cursor
+	}
+	body, err := json.Marshal(graphqlRequest{Query: starredReposQuery, Variables: vars})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal graphql request: %w", err)
+	}
+
+	var resp *http.Response
+	const maxRetries = 3
+	for i := 0; i < maxRetries; i++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "POST", host.GraphQLURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err = client.Do(req)
+		if err == nil {
+			break
+		}
+		if err := sleepContext(ctx, 2*time.Second); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("graphql request failed after %d retries: %w", maxRetries, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github graphql api returned non-200 status: %s", resp.Status)
+	}
+
+	var page gqlStarredResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("could not decode graphql response: %w", err)
+	}
+	if len(page.Errors) > 0 {
+		msgs := make([]string, 0, len(page.Errors))
+		for _, e := range page.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return nil, fmt.Errorf("github graphql api returned errors: %s", strings.Join(msgs, "; "))
+	}
+
+	return &page, nil
+}
+
+// edgeToRepo converts a GraphQL star edge into the GHRepo shape shared with
+// the REST path.
+func edgeToRepo(starredAt string, node gqlRepoNode) GHRepo {
+	repo := GHRepo{
+		ID:              node.DatabaseID,
+		FullName:        node.NameWithOwner,
+		Description:     node.Description,
+		HTMLURL:         node.URL,
+		StargazersCount: node.StargazerCount,
+		Archived:        node.IsArchived,
+		Fork:            node.IsFork,
+		PushedAt:        node.PushedAt,
+		StarredAt:       starredAt,
+	}
+	if node.PrimaryLanguage != nil {
+		repo.Language = node.PrimaryLanguage.Name
+	}
+	if node.LicenseInfo != nil {
+		repo.License = &GHLicense{SPDXID: node.LicenseInfo.SpdxID, Name: node.LicenseInfo.Name}
+	}
+	for _, t := range node.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, t.Topic.Name)
+	}
+	// READMEs under other names or paths are not found by the query; leaving
+	// HasReadme unset makes the caller look them up through REST.
+	for _, blob := range []*gqlBlob{node.ReadmeMd, node.ReadmeLower, node.ReadmeRst, node.ReadmePlain} {
+		if blob != nil && blob.Text != nil {
+			repo.Readme = *blob.Text
+			repo.HasReadme = true
+			break
+		}
+	}
+	return repo
+}
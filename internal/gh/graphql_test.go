@@ -0,0 +1,183 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// starredPageJSON is a GraphQL response with a repository whose README was
+// found, one with every field empty and one whose README has another name.
+const starredPageJSON = `{
+  "data": {
+    "viewer": {
+      "starredRepositories": {
+        "totalCount": 120,
+        "pageInfo": {"hasNextPage": true, "endCursor": "Y3Vyc29yOjUw"},
+        "edges": [
+          {
+            "starredAt": "2024-05-01T10:00:00Z",
+            "node": {
+              "databaseId": 42,
+              "nameWithOwner": "owner/parser",
+              "description": "A fast parser",
+              "url": "https://github.com/owner/parser",
+              "stargazerCount": 1234,
+              "isArchived": true,
+              "isFork": false,
+              "pushedAt": "2024-04-30T09:00:00Z",
+              "primaryLanguage": {"name": "Go"},
+              "licenseInfo": {"spdxId": "MIT", "name": "MIT License"},
+              "repositoryTopics": {"nodes": [{"topic": {"name": "parser"}}, {"topic": {"name": "go"}}]},
+              "readmeMd": null,
+              "readmeLower": {"text": "# parser"},
+              "readmeRst": {"text": "parser\n======"},
+              "readmePlain": null
+            }
+          },
+          {
+            "starredAt": "2024-04-01T10:00:00Z",
+            "node": {
+              "databaseId": 43,
+              "nameWithOwner": "owner/bare",
+              "description": null,
+              "url": "https://github.com/owner/bare",
+              "stargazerCount": 0,
+              "isArchived": false,
+              "isFork": true,
+              "pushedAt": null,
+              "primaryLanguage": null,
+              "licenseInfo": null,
+              "repositoryTopics": {"nodes": []},
+              "readmeMd": null,
+              "readmeLower": null,
+              "readmeRst": null,
+              "readmePlain": null
+            }
+          },
+          {
+            "starredAt": "2024-03-01T10:00:00Z",
+            "node": {
+              "databaseId": 44,
+              "nameWithOwner": "owner/docs",
+              "url": "https://github.com/owner/docs",
+              "repositoryTopics": {"nodes": []},
+              "readmeMd": {},
+              "readmeLower": null,
+              "readmeRst": null,
+              "readmePlain": null
+            }
+          }
+        ]
+      }
+    }
+  }
+}`
+
+func TestFetchStarredPageGraphQL(t *testing.T) {
+	var got graphqlRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Write([]byte(starredPageJSON))
+	}))
+	defer srv.Close()
+
+	page, err := FetchStarredPageGraphQL(context.Background(), srv.Client(), Host{GraphQLURL: srv.URL}, "Y3Vyc29yOjA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Variables["after"] != "Y3Vyc29yOjA=" || got.Variables["first"] != float64(graphqlPageSize) {
+		t.Errorf("request variables = %v", got.Variables)
+	}
+	if page.TotalCount != 120 || page.NextCursor != "Y3Vyc29yOjUw" {
+		t.Errorf("page has %d stars and cursor %q, want 120 and Y3Vyc29yOjUw", page.TotalCount, page.NextCursor)
+	}
+
+	want := []GHRepo{
+		{
+			ID:              42,
+			FullName:        "owner/parser",
+			Description:     "A fast parser",
+			HTMLURL:         "https://github.com/owner/parser",
+			Language:        "Go",
+			StargazersCount: 1234,
+			Topics:          []string{"parser", "go"},
+			License:         &GHLicense{SPDXID: "MIT", Name: "MIT License"},
+			Archived:        true,
+			PushedAt:        "2024-04-30T09:00:00Z",
+			StarredAt:       "2024-05-01T10:00:00Z",
+			Readme:          "# parser",
+			HasReadme:       true,
+		},
+		{
+			ID:        43,
+			FullName:  "owner/bare",
+			HTMLURL:   "https://github.com/owner/bare",
+			Fork:      true,
+			StarredAt: "2024-04-01T10:00:00Z",
+		},
+		// A path that is not a blob, e.g. a README directory, does not count.
+		{
+			ID:        44,
+			FullName:  "owner/docs",
+			HTMLURL:   "https://github.com/owner/docs",
+			StarredAt: "2024-03-01T10:00:00Z",
+		},
+	}
+	if !reflect.DeepEqual(page.Repos, want) {
+		t.Errorf("repos =\n%+v\nwant\n%+v", page.Repos, want)
+	}
+}
+
+func TestFetchStarredPageGraphQLLastPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := req.Variables["after"]; ok {
+			t.Errorf("first page request has an after cursor: %v", req.Variables)
+		}
+		w.Write([]byte(`{"data": {"viewer": {"starredRepositories": {"totalCount": 0, "pageInfo": {"hasNextPage": false, "endCursor": "abc"}, "edges": []}}}}`))
+	}))
+	defer srv.Close()
+
+	page, err := FetchStarredPageGraphQL(context.Background(), srv.Client(), Host{GraphQLURL: srv.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != "" || len(page.Repos) != 0 {
+		t.Errorf("last page = %+v, want no repositories and no cursor", page)
+	}
+}
+
+func TestFetchStarredPageGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"graphql errors", http.StatusOK, `{"errors": [{"message": "Bad credentials"}, {"message": "Something else"}]}`, "Bad credentials; Something else"},
+		{"unauthorized", http.StatusUnauthorized, `{"message": "Bad credentials"}`, "401"},
+		{"invalid json", http.StatusOK, `{"data":`, "could not decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := FetchStarredPageGraphQL(context.Background(), srv.Client(), Host{GraphQLURL: srv.URL}, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
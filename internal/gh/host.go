@@ -0,0 +1,46 @@
+package gh
+
+import "strings"
+
+// defaultClientID is the OAuth App used for the device flow on github.com.
+const defaultClientID = "Ov23liMsNArPWi0a8BWd" // User-provided Client ID
+
+// Host holds the endpoints of a GitHub installation.
+type Host struct {
+	// APIURL is the REST API root, without a trailing slash.
+	APIURL string
+	// GraphQLURL is the GraphQL endpoint.
+	GraphQLURL string
+	// WebURL is the web root used for the OAuth device flow.
+	WebURL string
+	// ClientID is the OAuth App client ID used for the device flow.
+	ClientID string
+}
+
+// DotCom is github.com.
+var DotCom = Host{
+	APIURL:     "https://api.github.com",
+	GraphQLURL: "https://api.github.com/graphql",
+	WebURL:     "https://github.com",
+	ClientID:   defaultClientID,
+}
+
+// NewHost returns the endpoints for the GitHub installation at baseURL.
+// An empty baseURL or https://github.com yields DotCom; anything else is treated
+// as GitHub Enterprise Server, which serves its APIs under /api.
+func NewHost(baseURL, clientID string) Host {
+	base := strings.TrimRight(baseURL, "/")
+	if base == "" || base == DotCom.WebURL || base == DotCom.APIURL {
+		host := DotCom
+		if clientID != "" {
+			host.ClientID = clientID
+		}
+		return host
+	}
+	return Host{
+		APIURL:     base + "/api/v3",
+		GraphQLURL: base + "/api/graphql",
+		WebURL:     base,
+		ClientID:   clientID,
+	}
+}
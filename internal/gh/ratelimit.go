@@ -0,0 +1,192 @@
+package gh
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitRetries bounds how often a single request is retried after
+	// being rejected by a primary or secondary rate limit.
+	maxRateLimitRetries = 3
+
+	// rateLimitLowWater is the remaining-request count below which requests are
+	// spread out evenly over the time left until the limit resets.
+	rateLimitLowWater = 50
+)
+
+// rateLimitTransport tracks the X-RateLimit-* headers returned by GitHub and
+// delays requests instead of letting them fail once the budget runs out.
+// It also retries requests rejected with 403/429 after the advertised delay.
+type rateLimitTransport struct {
+	transport http.RoundTripper
+	// sleep waits for the given duration; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	remaining int // -1 while unknown
+	reset     time.Time
+	next      time.Time // earliest start of the next paced request
+}
+
+func newRateLimitTransport(transport http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{transport: transport, sleep: sleepContext, remaining: -1}
+}
+
+type waitHookKey struct{}
+
+// WithWaitHook returns a context that makes requests report rate limit pauses
+// to onWait. Without a hook, pauses are not reported.
+func WithWaitHook(ctx context.Context, onWait func(time.Duration)) context.Context {
+	return context.WithValue(ctx, waitHookKey{}, onWait)
+}
+
+// notifyWait reports a rate limit pause of d to the hook in ctx.
+func notifyWait(ctx context.Context, d time.Duration) {
+	if onWait, ok := ctx.Value(waitHookKey{}).(func(time.Duration)); ok && onWait != nil {
+		onWait(d)
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := t.throttle(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.update(resp.Header)
+
+		wait, limited := retryDelay(resp)
+		if !limited || attempt >= maxRateLimitRetries {
+			return resp, nil
+		}
+
+		// Requests with a body can only be replayed if it can be recreated.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		notifyWait(ctx, wait)
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		next := req.Clone(ctx)
+		if req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			next.Body = b
+		}
+		req = next
+	}
+}
+
+// throttle blocks while the known budget is exhausted, and paces requests
+// when only a few remain before the reset. Pacing slots are shared by all
+// goroutines using the transport, so concurrent workers slow down together.
+func (t *rateLimitTransport) throttle(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	exhausted := false
+	if t.remaining >= 0 && t.reset.After(now) {
+		untilReset := t.reset.Sub(now)
+		switch {
+		case t.remaining == 0:
+			wait = untilReset + time.Second
+			exhausted = true
+		case t.remaining < rateLimitLowWater:
+			start := now
+			if t.next.After(start) {
+				start = t.next
+			}
+			t.next = start.Add(untilReset / time.Duration(t.remaining))
+			wait = start.Sub(now)
+		}
+	}
+	t.mu.Unlock()
+
+	if exhausted {
+		notifyWait(ctx, wait)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	return t.sleep(ctx, wait)
+}
+
+// update records the latest rate limit headers.
+func (t *rateLimitTransport) update(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetUnix, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining = remaining
+	t.reset = time.Unix(resetUnix, 0)
+}
+
+// retryDelay reports whether resp was rejected by a rate limit and, if so, how
+// long to wait before retrying. Retry-After wins over X-RateLimit-Reset.
+func retryDelay(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(s); err == nil {
+			return time.Until(at), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait := time.Until(time.Unix(resetUnix, 0)) + time.Second
+			if wait < time.Second {
+				wait = time.Second
+			}
+			return wait, true
+		}
+	}
+
+	// A 429 without hints is still a rate limit; a bare 403 is a permission error.
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Minute, true
+	}
+	return 0, false
+}
+
+// sleepContext sleeps for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,274 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers each request with the next of its responses,
+// repeating the last one.
+type scriptedServer struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	requests  int
+	bodies    []string
+}
+
+func newScriptedServer(t *testing.T, responses ...func(w http.ResponseWriter)) (*scriptedServer, string) {
+	s := &scriptedServer{responses: responses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		i := min(s.requests, len(s.responses)-1)
+		s.requests++
+		s.bodies = append(s.bodies, string(body))
+		s.mu.Unlock()
+		s.responses[i](w)
+	}))
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+// respond returns a response with status and the given header pairs.
+func respond(status int, header ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(status)
+	}
+}
+
+// newTestTransport returns a transport that records its waits in sleeps
+// instead of sleeping.
+func newTestTransport(sleeps *[]time.Duration) *rateLimitTransport {
+	t := newRateLimitTransport(http.DefaultTransport)
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return ctx.Err()
+	}
+	return t
+}
+
+func unixIn(d time.Duration) string {
+	return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+}
+
+// near reports whether d is within a second of want, allowing for the
+// whole-second resolution of the reset headers.
+func near(d, want time.Duration) bool {
+	diff := d - want
+	return diff > -1500*time.Millisecond && diff < 1500*time.Millisecond
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		header      []string
+		wantLimited bool
+		wantWait    time.Duration
+	}{
+		{"success", http.StatusOK, []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", unixIn(time.Hour)}, false, 0},
+		{"permission error", http.StatusForbidden, nil, false, 0},
+		{"not found", http.StatusNotFound, []string{"Retry-After", "5"}, false, 0},
+		{"secondary limit", http.StatusForbidden, []string{"Retry-After", "30"}, true, 30 * time.Second},
+		{"too many requests", http.StatusTooManyRequests, []string{"Retry-After", "5"}, true, 5 * time.Second},
+		{"retry-after date", http.StatusTooManyRequests, []string{"Retry-After", time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)}, true, 20 * time.Second},
+		{"primary limit", http.StatusForbidden, []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", unixIn(10 * time.Second)}, true, 11 * time.Second},
+		{"primary limit already reset", http.StatusForbidden, []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", unixIn(-time.Minute)}, true, time.Second},
+		{"budget left", http.StatusForbidden, []string{"X-RateLimit-Remaining", "10", "X-RateLimit-Reset", unixIn(time.Minute)}, false, 0},
+		{"retry-after wins", http.StatusForbidden, []string{"Retry-After", "3", "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", unixIn(time.Hour)}, true, 3 * time.Second},
+		{"invalid retry-after", http.StatusTooManyRequests, []string{"Retry-After", "soon"}, true, time.Minute},
+		{"bare 429", http.StatusTooManyRequests, nil, true, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+			for i := 0; i+1 < len(tt.header); i += 2 {
+				resp.Header.Set(tt.header[i], tt.header[i+1])
+			}
+			wait, limited := retryDelay(resp)
+			if limited != tt.wantLimited {
+				t.Fatalf("limited = %v, want %v", limited, tt.wantLimited)
+			}
+			if !near(wait, tt.wantWait) {
+				t.Errorf("wait = %s, want about %s", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		// body is sent with a POST request if set.
+		body         string
+		wantStatus   int
+		wantRequests int
+		wantSleeps   []time.Duration
+	}{
+		{
+			name:         "success",
+			responses:    []func(w http.ResponseWriter){respond(http.StatusOK)},
+			wantStatus:   http.StatusOK,
+			wantRequests: 1,
+		},
+		{
+			name:         "secondary limit",
+			responses:    []func(w http.ResponseWriter){respond(http.StatusForbidden, "Retry-After", "60"), respond(http.StatusOK)},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantSleeps:   []time.Duration{time.Minute},
+		},
+		{
+			name: "primary limit",
+			responses: []func(w http.ResponseWriter){
+				respond(http.StatusForbidden, "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", unixIn(30*time.Second)),
+				respond(http.StatusOK, "X-RateLimit-Remaining", "4999", "X-RateLimit-Reset", unixIn(time.Hour)),
+			},
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			// The rejected request waits for the reset; throttle then sees the
+			// budget as exhausted until the reset as well.
+			wantSleeps: []time.Duration{31 * time.Second, 31 * time.Second},
+		},
+		{
+			name:         "permission error",
+			responses:    []func(w http.ResponseWriter){respond(http.StatusForbidden)},
+			wantStatus:   http.StatusForbidden,
+			wantRequests: 1,
+		},
+		{
+			name:         "gives up",
+			responses:    []func(w http.ResponseWriter){respond(http.StatusTooManyRequests, "Retry-After", "1")},
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: maxRateLimitRetries + 1,
+			wantSleeps:   []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:         "replays body",
+			responses:    []func(w http.ResponseWriter){respond(http.StatusTooManyRequests, "Retry-After", "2"), respond(http.StatusOK)},
+			body:         `{"query": "stars"}`,
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantSleeps:   []time.Duration{2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, url := newScriptedServer(t, tt.responses...)
+			var sleeps []time.Duration
+			transport := newTestTransport(&sleeps)
+			var notified []time.Duration
+			ctx := WithWaitHook(context.Background(), func(d time.Duration) { notified = append(notified, d) })
+
+			method, body := http.MethodGet, io.Reader(nil)
+			if tt.body != "" {
+				method, body = http.MethodPost, strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequestWithContext(ctx, method, url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if srv.requests != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", srv.requests, tt.wantRequests)
+			}
+			if !nearAll(sleeps, tt.wantSleeps) {
+				t.Errorf("slept %v, want about %v", sleeps, tt.wantSleeps)
+			}
+			if !nearAll(notified, tt.wantSleeps) {
+				t.Errorf("reported waits %v, want about %v", notified, tt.wantSleeps)
+			}
+			for i, b := range srv.bodies {
+				if b != tt.body {
+					t.Errorf("request %d had body %q, want %q", i+1, b, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func nearAll(got, want []time.Duration) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !near(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRateLimitTransportPacing(t *testing.T) {
+	const remaining = 10
+	reset := unixIn(100 * time.Second)
+	srv, url := newScriptedServer(t, respond(http.StatusOK, "X-RateLimit-Remaining", fmt.Sprint(remaining), "X-RateLimit-Reset", reset))
+	var sleeps []time.Duration
+	transport := newTestTransport(&sleeps)
+	var notified []time.Duration
+	ctx := WithWaitHook(context.Background(), func(d time.Duration) { notified = append(notified, d) })
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first request learns the budget. The next ones take the first free
+	// slot of those spread evenly until the reset: the second starts at once,
+	// the third a tenth of the remaining time later.
+	if srv.requests != 3 {
+		t.Fatalf("server got %d requests, want 3", srv.requests)
+	}
+	if len(sleeps) != 1 || !near(sleeps[0], 10*time.Second) {
+		t.Errorf("slept %v, want about [10s]", sleeps)
+	}
+	if len(notified) != 0 {
+		t.Errorf("pacing reported waits %v, want none", notified)
+	}
+}
+
+func TestRateLimitTransportCancelledWait(t *testing.T) {
+	srv, url := newScriptedServer(t, respond(http.StatusTooManyRequests, "Retry-After", "3600"))
+	transport := newRateLimitTransport(http.DefaultTransport)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = WithWaitHook(ctx, func(time.Duration) { cancel() })
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if srv.requests != 1 {
+		t.Errorf("server got %d requests, want 1", srv.requests)
+	}
+}
@@ -0,0 +1,255 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"star-sage/internal/ai"
+	"star-sage/internal/db"
+	"star-sage/internal/events"
+	"star-sage/internal/source"
+	"star-sage/internal/summarizer"
+	"star-sage/internal/syncer"
+)
+
+// Job types registered by RegisterDefaults.
+const (
+	TypeClassifyList = "classify_list"
+	TypeSummarize    = "summarize"
+	TypeEmbed        = "embed"
+	TypeSync         = "sync"
+)
+
+// ClassifyListParams are the params of a classify_list job.
+type ClassifyListParams struct {
+	ListID int64 `json:"list_id"`
+}
+
+// LimitParams are the params of summarize and embed jobs. Limit 0 selects
+// the default: summarizer.DefaultLimit repositories for summarize jobs and all
+// of them for embed jobs.
+type LimitParams struct {
+	Limit int `json:"limit"`
+}
+
+// SyncParams are the params of a sync job.
+type SyncParams struct {
+	// Source is the name of a configured source; empty means github.
+	Source string `json:"source"`
+	Limit  int    `json:"limit"`
+	Prune  bool   `json:"prune"`
+	// Resume continues the last interrupted sync run of the source.
+	Resume bool `json:"resume"`
+}
+
+// Deps are the dependencies of the default job handlers.
+type Deps struct {
+	// Store should be the store the Manager records the jobs in.
+	Store db.Store
+	AI    *ai.Registry
+	// Proxy is used for requests to the sync sources.
+	Proxy string
+	// OpenSource opens the source a sync job syncs from. It defaults to
+	// syncer.OpenSource.
+	OpenSource func(name string, opts source.Options) (source.Source, error)
+	// Events receives the repositories saved by syncs and the summaries
+	// written. It may be nil.
+	Events *events.Bus
+}
+
+// RegisterDefaults registers handlers for list classification, summarization,
+// embedding and sync on m. Summarization, embedding and sync each work through
+// the whole library, so only one job of each may be active at a time.
+func RegisterDefaults(m *Manager, deps Deps) {
+	m.Register(TypeClassifyList, Type{Handler: deps.classifyList, MaxAttempts: 3})
+	m.Register(TypeSummarize, Type{Handler: deps.summarize, MaxAttempts: 3, Exclusive: true})
+	m.Register(TypeEmbed, Type{Handler: deps.embed, MaxAttempts: 3, Exclusive: true})
+	m.Register(TypeSync, Type{Handler: deps.sync, MaxAttempts: 3, Exclusive: true})
+}
+
+// classifyList runs the AI classification of a list. Repositories pinned to
+// or excluded from the list by hand are left as they are.
+func (d Deps) classifyList(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+	var params ClassifyListParams
+	if err := DecodeParams(job, &params); err != nil {
+		return nil, err
+	}
+	list, err := d.Store.GetListByID(params.ListID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, fmt.Errorf("list %d not found", params.ListID)
+	}
+
+	provider, err := d.AI.ForTask(ai.TaskClassify)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	ids, err := ai.ClassifyList(ctx, d.Store, provider, list, ai.ClassifyOptions{
+		OnStart: func(repos int) {
+			report(0, 0, fmt.Sprintf("Classifying %d repositories for %s", repos, list.Name))
+		},
+		OnChunk: func(chunk, chunks int) {
+			total = chunks
+			report(chunk-1, chunks, fmt.Sprintf("chunk %d/%d", chunk, chunks))
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Events.Publish(events.ListChanged, events.ListRef{ID: list.ID})
+	report(total, total, fmt.Sprintf("Found %d matching repositories", len(ids)))
+	return map[string]interface{}{"list_id": list.ID, "matched": len(ids)}, nil
+}
+
+// summarize summarizes repositories that have a README but no summary yet.
+func (d Deps) summarize(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+	var params LimitParams
+	if err := DecodeParams(job, &params); err != nil {
+		return nil, err
+	}
+	provider, err := d.AI.ForTask(ai.TaskSummarize)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := summarizer.Run(ctx, d.Store, summarizer.Options{
+		Provider: provider,
+		Limit:    params.Limit,
+		OnStart: func(repo db.Repository, done, total int) {
+			report(done, total, "Summarizing "+repo.FullName)
+		},
+		OnProgress: func(p summarizer.Progress) {
+			switch {
+			case p.Err != nil:
+				fmt.Printf("[Job %d] Error summarizing %s: %v\n", job.ID, p.Repo.FullName, p.Err)
+			case !p.Skipped:
+				d.Events.Publish(events.SummaryWritten, events.Summary{RepoID: p.Repo.ID, FullName: p.Repo.FullName, Summary: p.Summary})
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	report(result.Total, result.Total, fmt.Sprintf("Summarized %d repositories", result.Summarized))
+	return map[string]int{"summarized": result.Summarized, "skipped": result.Skipped, "failed": result.Failed}, nil
+}
+
+// embed computes the missing embeddings with the embed profile.
+func (d Deps) embed(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+	var params LimitParams
+	if err := DecodeParams(job, &params); err != nil {
+		return nil, err
+	}
+	profile, err := d.AI.ProfileForTask(ai.TaskEmbed)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := ai.NewEmbedder(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := d.Store.GetReposForEmbedding(profile.Model, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	embedded, failed := 0, 0
+	for i, repo := range repos {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report(i, len(repos), "Embedding "+repo.FullName)
+		vec, err := embedder.Embed(ctx, ai.EmbeddingText(repo))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Printf("[Job %d] Error embedding %s: %v\n", job.ID, repo.FullName, err)
+			failed++
+			continue
+		}
+		if err := d.Store.UpdateRepoEmbedding(repo.ID, profile.Model, vec); err != nil {
+			return nil, err
+		}
+		embedded++
+	}
+	report(len(repos), len(repos), fmt.Sprintf("Embedded %d repositories with %s", embedded, profile.Model))
+	if failed > 0 && embedded == 0 {
+		return nil, fmt.Errorf("all %d embeddings failed", failed)
+	}
+	return map[string]interface{}{"model": profile.Model, "embedded": embedded, "failed": failed}, nil
+}
+
+// sync syncs the stars of a source. A job retried after a failure or
+// interrupted by a restart resumes the unfinished sync run of the source if
+// that run has a checkpoint, and starts a new run otherwise.
+func (d Deps) sync(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+	var params SyncParams
+	if err := DecodeParams(job, &params); err != nil {
+		return nil, err
+	}
+	openSource := d.OpenSource
+	if openSource == nil {
+		openSource = syncer.OpenSource
+	}
+	src, err := openSource(params.Source, source.Options{Proxy: d.Proxy})
+	if err != nil {
+		return nil, err
+	}
+
+	resume := params.Resume
+	if !resume && (job.Progress > 0 || job.Attempts > 1) {
+		run, err := d.Store.GetResumableSyncRun(src.Key())
+		if err != nil {
+			return nil, err
+		}
+		resume = run != nil && run.HasCheckpoint()
+	}
+
+	result, err := syncer.Run(ctx, d.Store, syncer.Options{
+		Source: src,
+		Limit:  params.Limit,
+		Prune:  params.Prune,
+		Resume: resume,
+		OnProgress: func(p syncer.Progress) {
+			switch p.Phase {
+			case syncer.PhaseRepository:
+				if p.Err == nil {
+					ref := events.RepoRef{Source: src.Key(), FullName: p.Repo}
+					switch p.Outcome {
+					case db.RepoAdded:
+						d.Events.Publish(events.RepoAdded, ref)
+					case db.RepoUpdated:
+						d.Events.Publish(events.RepoUpdated, ref)
+					}
+				}
+				report(p.Processed, p.Total, "Synced "+p.Repo)
+			case syncer.PhaseWaiting:
+				report(p.Processed, p.Total, fmt.Sprintf("Rate limited, waiting %s", p.Wait))
+			case syncer.PhaseFallback:
+				report(p.Processed, p.Total, fmt.Sprintf("%v, falling back to the %s API", p.Err, p.API))
+			case syncer.PhaseReconciling:
+				report(p.Processed, p.Total, "Checking for unstarred repositories")
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	run := result.Run
+	return map[string]interface{}{
+		"sync_run_id": run.ID,
+		"processed":   result.Processed,
+		"added":       run.Added,
+		"updated":     run.Updated,
+		"unchanged":   run.Unchanged,
+		"failed":      run.Failed,
+		"unstarred":   result.Unstarred,
+		"pruned":      result.Pruned,
+		"snapshot":    result.Snapshot,
+	}, nil
+}
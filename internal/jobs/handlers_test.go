@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"star-sage/internal/ai"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+	"star-sage/internal/source"
+	"testing"
+	"time"
+)
+
+// fakeAI summarizes every README the same way and embeds a text by its length.
+type fakeAI struct{}
+
+func (fakeAI) Generate(ctx context.Context, prompt string) (string, error) {
+	return "A summary", nil
+}
+
+func (fakeAI) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{float32(len(text)), 1}, nil
+}
+
+func init() {
+	ai.Register("fake", func(cfg config.ProviderConfig, client *http.Client) (ai.Provider, error) {
+		return fakeAI{}, nil
+	})
+}
+
+// fakeSource lists a fixed page of stars that include their READMEs.
+type fakeSource struct {
+	repos []source.Repo
+}
+
+func (s *fakeSource) Key() string { return db.DefaultSource }
+func (s *fakeSource) API() string { return "fake" }
+
+func (s *fakeSource) ListStarred(ctx context.Context, cursor string) (*source.Page, error) {
+	return &source.Page{Repos: s.repos, TotalCount: len(s.repos)}, nil
+}
+
+func (s *fakeSource) FetchReadme(ctx context.Context, repo source.Repo, etag string) (string, string, error) {
+	return "", etag, nil
+}
+
+func (s *fakeSource) Authenticate(ctx context.Context) (string, error) {
+	return "", errors.New("not supported")
+}
+
+// runDefaultJob runs a job of one of the default types on store with the
+// fake AI provider for every task and returns its result.
+func runDefaultJob(t *testing.T, store *db.MemoryStore, deps Deps, jobType string, params interface{}) map[string]interface{} {
+	t.Helper()
+	deps.Store = store
+	deps.AI = ai.NewRegistry(config.AIConfig{
+		Providers: map[string]config.ProviderConfig{"fake": {Name: "fake", Type: "fake", Model: "fake-model"}},
+		Defaults:  map[string]string{ai.TaskSummarize: "fake", ai.TaskEmbed: "fake"},
+	})
+	m := NewManager(store, 1, nil)
+	RegisterDefaults(m, deps)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		m.Wait()
+	}()
+
+	job, err := m.Enqueue(jobType, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != db.JobSucceeded {
+		if job.Error != "" {
+			t.Fatalf("%s job failed: %s", jobType, job.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s job is %s, want succeeded", jobType, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if job, err = store.GetJob(job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSummarizeJob(t *testing.T) {
+	store := db.NewMemoryStore()
+	id := store.AddRepository(db.Repository{FullName: "owner/new", ReadmeContent: "readme"})
+	store.AddRepository(db.Repository{FullName: "owner/done", ReadmeContent: "readme", Summary: "Old"})
+	store.AddRepository(db.Repository{FullName: "owner/empty"})
+
+	result := runDefaultJob(t, store, Deps{}, TypeSummarize, LimitParams{})
+	if result["summarized"] != 1.0 || result["failed"] != 0.0 {
+		t.Errorf("got result %v, want one summary", result)
+	}
+	repo, err := store.GetRepositoryByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Summary != "A summary" {
+		t.Errorf("summary = %q", repo.Summary)
+	}
+}
+
+func TestEmbedJob(t *testing.T) {
+	store := db.NewMemoryStore()
+	id := store.AddRepository(db.Repository{FullName: "owner/starred", Description: "A tool"})
+	store.AddRepository(db.Repository{FullName: "owner/gone", UnstarredAt: "2024-01-01T00:00:00Z"})
+
+	result := runDefaultJob(t, store, Deps{}, TypeEmbed, LimitParams{})
+	if result["model"] != "fake-model" || result["embedded"] != 1.0 || result["failed"] != 0.0 {
+		t.Errorf("got result %v, want one embedding", result)
+	}
+	matches, err := store.SemanticSearch("fake-model", []float32{1, 1}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].ID != id {
+		t.Errorf("got matches %+v, want only owner/starred", matches)
+	}
+
+	// A new summary changes the embedded text, so the repository is embedded again.
+	if err := store.UpdateRepoSummary(id, "A summary"); err != nil {
+		t.Fatal(err)
+	}
+	if result := runDefaultJob(t, store, Deps{}, TypeEmbed, LimitParams{}); result["embedded"] != 1.0 {
+		t.Errorf("got result %v after a new summary, want one embedding", result)
+	}
+}
+
+func TestSyncJob(t *testing.T) {
+	store := db.NewMemoryStore()
+	// Starred before but no longer listed by the source.
+	goneID := store.AddRepository(db.Repository{Source: db.DefaultSource, SourceID: 99, FullName: "owner/gone"})
+	listID, err := store.CreateList("Tools", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PinRepoToList(listID, goneID); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &fakeSource{}
+	for i := int64(1); i <= 2; i++ {
+		src.repos = append(src.repos, source.Repo{ID: i, FullName: fmt.Sprintf("owner/repo%d", i), Readme: "readme", HasReadme: true})
+	}
+	var opened string
+	deps := Deps{OpenSource: func(name string, opts source.Options) (source.Source, error) {
+		opened = name
+		return src, nil
+	}}
+
+	result := runDefaultJob(t, store, deps, TypeSync, SyncParams{Source: "home", Prune: true})
+	if opened != "home" {
+		t.Errorf("opened source %q, want home", opened)
+	}
+	if result["added"] != 2.0 || result["unstarred"] != 1.0 || result["pruned"] != 1.0 || result["snapshot"] != "" {
+		t.Errorf("got result %v, want 2 added and 1 unstarred and pruned without a snapshot", result)
+	}
+	if repo, err := store.GetRepositoryByID(goneID); err != nil || repo != nil {
+		t.Errorf("pruned repository: got %+v, %v", repo, err)
+	}
+	if members, err := store.GetReposByListID(listID); err != nil || len(members) != 0 {
+		t.Errorf("list members after pruning: got %+v, %v", members, err)
+	}
+	if page, err := store.QueryRepositories(db.RepoQuery{}); err != nil || page.Total != 2 {
+		t.Errorf("got %+v, %v, want the 2 synced repositories", page, err)
+	}
+
+	// Syncing the same stars again changes nothing.
+	result = runDefaultJob(t, store, deps, TypeSync, SyncParams{})
+	if result["added"] != 0.0 || result["unchanged"] != 2.0 || result["unstarred"] != 0.0 {
+		t.Errorf("got result %v on the second sync, want 2 unchanged", result)
+	}
+}
@@ -0,0 +1,349 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"star-sage/internal/db"
+	"star-sage/internal/events"
+)
+
+const (
+	// DefaultWorkers is the number of jobs run in parallel when not configured.
+	DefaultWorkers = 2
+
+	// pollInterval is how often idle workers look for due jobs, e.g. retries.
+	pollInterval = 2 * time.Second
+	// retryBackoff is the delay before the first retry; it doubles with every attempt.
+	retryBackoff = 10 * time.Second
+)
+
+var (
+	// ErrUnknownType is returned when a job type has no registered handler.
+	ErrUnknownType = errors.New("unknown job type")
+	// ErrAlreadyActive is returned when a job of an exclusive type is queued
+	// or running already.
+	ErrAlreadyActive = errors.New("a job of this type is already queued or running")
+)
+
+// Reporter records the progress of a running job. total is 0 while unknown.
+type Reporter func(progress, total int, message string)
+
+// Handler runs a job. It should return promptly with ctx.Err() once ctx is
+// canceled. The returned result is stored as JSON.
+type Handler func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error)
+
+// Type describes a kind of job.
+type Type struct {
+	Handler Handler
+	// MaxAttempts is how often a failing job is started before it is marked
+	// as failed. Zero means 1, i.e. no automatic retry.
+	MaxAttempts int
+	// Exclusive allows only one job of the type to be queued or running at a time.
+	Exclusive bool
+}
+
+// Manager runs queued jobs on a pool of workers. Jobs are persisted in the
+// store, so their status survives restarts and interrupted jobs resume.
+// Every change of a job is published on the event bus.
+type Manager struct {
+	store   db.JobStore
+	workers int
+	events  *events.Bus
+
+	mu      sync.Mutex
+	types   map[string]Type
+	running map[int64]context.CancelFunc
+	// queueMu serializes the check for an active job of an exclusive type
+	// with queueing the new one.
+	queueMu sync.Mutex
+
+	wake chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewManager creates a manager running up to workers jobs in parallel. bus
+// may be nil.
+func NewManager(store db.JobStore, workers int, bus *events.Bus) *Manager {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Manager{
+		store:   store,
+		workers: workers,
+		events:  bus,
+		types:   make(map[string]Type),
+		running: make(map[int64]context.CancelFunc),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Register makes jobs of the given type runnable.
+func (m *Manager) Register(jobType string, t Type) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.types[jobType] = t
+}
+
+// Enqueue queues a job of the given type with params, which are stored as JSON.
+// For an exclusive type with a job queued or running already, it returns that
+// job together with an error wrapping ErrAlreadyActive.
+func (m *Manager) Enqueue(jobType string, params interface{}) (*db.Job, error) {
+	m.mu.Lock()
+	t, ok := m.types[jobType]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	if active, err := m.activeJob(jobType, t); active != nil || err != nil {
+		return active, err
+	}
+	id, err := m.store.CreateJob(jobType, params, t.MaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	m.signal()
+	return m.publish(id)
+}
+
+// activeJob returns the queued or running job of an exclusive type with an
+// error wrapping ErrAlreadyActive. It returns nil for other types.
+func (m *Manager) activeJob(jobType string, t Type) (*db.Job, error) {
+	if !t.Exclusive {
+		return nil, nil
+	}
+	active, err := m.store.GetActiveJob(jobType)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, fmt.Errorf("%w: %s job %d", ErrAlreadyActive, jobType, active.ID)
+	}
+	return nil, nil
+}
+
+// Start requeues the jobs left running by a previous process that did not
+// shut down cleanly and starts the workers. They stop when ctx is canceled,
+// queueing their jobs again; Wait waits for them.
+func (m *Manager) Start(ctx context.Context) error {
+	n, err := m.store.RequeueInterruptedJobs()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("Resuming %d interrupted jobs.\n", n)
+	}
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.work(ctx)
+	}
+	return nil
+}
+
+// Wait blocks until all workers have stopped.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Cancel cancels a queued or running job. It returns an error wrapping
+// sql.ErrNoRows if the job does not exist.
+func (m *Manager) Cancel(id int64) (*db.Job, error) {
+	m.mu.Lock()
+	cancel, running := m.running[id]
+	m.mu.Unlock()
+	if running {
+		// The worker records the cancellation when the handler returns.
+		cancel()
+		return m.store.GetJob(id)
+	}
+
+	if _, err := m.store.CancelQueuedJob(id); err != nil {
+		return nil, err
+	}
+	return m.publish(id)
+}
+
+// Retry queues a failed or canceled job again. Like Enqueue, it refuses to
+// queue a second job of an exclusive type.
+func (m *Manager) Retry(id int64) (*db.Job, error) {
+	job, err := m.reload(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	t := m.types[job.Type]
+	m.mu.Unlock()
+
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	if active, err := m.activeJob(job.Type, t); active != nil || err != nil {
+		return active, err
+	}
+	if err := m.store.RequeueJob(id); err != nil {
+		return nil, err
+	}
+	m.signal()
+	return m.publish(id)
+}
+
+// signal wakes up an idle worker.
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publish publishes the current state of a job and returns it.
+func (m *Manager) publish(id int64) (*db.Job, error) {
+	job, err := m.reload(id)
+	if err != nil {
+		return nil, err
+	}
+	m.publishJob(job)
+	return job, nil
+}
+
+// publishJob publishes job as updated or, once it is finished, as finished.
+func (m *Manager) publishJob(job *db.Job) {
+	eventType := events.JobUpdated
+	if job.Finished() {
+		eventType = events.JobFinished
+	}
+	m.events.Publish(eventType, job)
+}
+
+// reload returns the current state of a job, or an error wrapping
+// sql.ErrNoRows if it does not exist.
+func (m *Manager) reload(id int64) (*db.Job, error) {
+	job, err := m.store.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("job %d not found: %w", id, sql.ErrNoRows)
+	}
+	return job, nil
+}
+
+// work runs jobs until ctx is canceled.
+func (m *Manager) work(ctx context.Context) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		m.mu.Lock()
+		types := make([]string, 0, len(m.types))
+		for t := range m.types {
+			types = append(types, t)
+		}
+		m.mu.Unlock()
+
+		job, err := m.store.ClaimNextJob(types)
+		if err != nil {
+			fmt.Printf("[Error][Jobs] %v\n", err)
+		}
+		if job != nil {
+			m.run(ctx, job)
+			// Look for the next job right away.
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a claimed job and records its outcome.
+func (m *Manager) run(ctx context.Context, job *db.Job) {
+	m.mu.Lock()
+	t := m.types[job.Type]
+	jobCtx, cancel := context.WithCancel(ctx)
+	m.running[job.ID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, job.ID)
+		m.mu.Unlock()
+		cancel()
+	}()
+
+	fmt.Printf("[Job %d] Running %s (attempt %d/%d)...\n", job.ID, job.Type, job.Attempts, job.MaxAttempts)
+	m.publishJob(job)
+	report := func(progress, total int, message string) {
+		if err := m.store.UpdateJobProgress(job.ID, progress, total, message); err != nil {
+			fmt.Printf("[Error][Job %d] %v\n", job.ID, err)
+			return
+		}
+		// Handlers may report from several goroutines; publish a copy.
+		m.mu.Lock()
+		job.Progress, job.Total, job.Message = progress, total, message
+		snapshot := *job
+		m.mu.Unlock()
+		m.publishJob(&snapshot)
+	}
+
+	result, err := runHandler(jobCtx, t.Handler, job, report)
+	switch {
+	case err == nil:
+		err = m.store.FinishJob(job.ID, db.JobSucceeded, result, nil)
+		fmt.Printf("[Job %d] %s succeeded.\n", job.ID, job.Type)
+	case ctx.Err() != nil:
+		// The server is shutting down; queue the job again so it resumes
+		// after the restart.
+		fmt.Printf("[Job %d] %s interrupted by shutdown.\n", job.ID, job.Type)
+		err = m.store.InterruptJob(job.ID)
+	case jobCtx.Err() != nil:
+		err = m.store.FinishJob(job.ID, db.JobCanceled, result, nil)
+		fmt.Printf("[Job %d] %s canceled.\n", job.ID, job.Type)
+	case job.Attempts < job.MaxAttempts:
+		delay := retryBackoff << (job.Attempts - 1)
+		fmt.Printf("[Error][Job %d] %s failed, retrying in %s: %v\n", job.ID, job.Type, delay, err)
+		err = m.store.RetryJob(job.ID, time.Now().Add(delay), err)
+	default:
+		fmt.Printf("[Error][Job %d] %s failed: %v\n", job.ID, job.Type, err)
+		err = m.store.FinishJob(job.ID, db.JobFailed, result, err)
+	}
+	if err != nil {
+		fmt.Printf("[Error][Job %d] Could not record job status: %v\n", job.ID, err)
+		return
+	}
+	if _, err := m.publish(job.ID); err != nil {
+		fmt.Printf("[Error][Job %d] %v\n", job.ID, err)
+	}
+}
+
+// runHandler calls h, turning a panic into an error so one job cannot bring
+// down the server.
+func runHandler(ctx context.Context, h Handler, job *db.Job, report Reporter) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job, report)
+}
+
+// DecodeParams decodes the JSON params of job into v.
+func DecodeParams(job *db.Job, v interface{}) error {
+	if len(job.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(job.Params, v); err != nil {
+		return fmt.Errorf("invalid params for %s job: %w", job.Type, err)
+	}
+	return nil
+}
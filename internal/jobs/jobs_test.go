@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"star-sage/internal/db"
+	"testing"
+	"time"
+)
+
+// startManager starts a manager on a new MemoryStore with the given types.
+// Its workers stop when the test ends.
+func startManager(t *testing.T, types map[string]Type) (*Manager, *db.MemoryStore) {
+	t.Helper()
+	store := db.NewMemoryStore()
+	m := NewManager(store, 2, nil)
+	for name, typ := range types {
+		m.Register(name, typ)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		m.Wait()
+	})
+	return m, store
+}
+
+// waitForStatus waits until job id has the given status and returns it.
+func waitForStatus(t *testing.T, store db.JobStore, id int64, status string) *db.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.GetJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagerRunsJob(t *testing.T) {
+	m, store := startManager(t, map[string]Type{
+		"echo": {Handler: func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+			var params map[string]string
+			if err := DecodeParams(job, &params); err != nil {
+				return nil, err
+			}
+			report(1, 1, "echoed")
+			return params, nil
+		}},
+	})
+
+	if _, err := m.Enqueue("missing", nil); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("got %v, want ErrUnknownType", err)
+	}
+	job, err := m.Enqueue("echo", map[string]string{"say": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitForStatus(t, store, job.ID, db.JobSucceeded)
+	if string(job.Result) != `{"say":"hi"}` || job.Progress != 1 || job.Message != "echoed" || job.Attempts != 1 {
+		t.Errorf("got result %s, progress %d (%q) after %d attempts", job.Result, job.Progress, job.Message, job.Attempts)
+	}
+}
+
+func TestManagerFailedJob(t *testing.T) {
+	calls := make(chan int, 10)
+	fail := func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+		calls <- job.Attempts
+		if len(calls) == 1 {
+			panic("boom")
+		}
+		return nil, errors.New("boom")
+	}
+	m, store := startManager(t, map[string]Type{
+		"once":  {Handler: fail},
+		"retry": {Handler: fail, MaxAttempts: 3},
+	})
+
+	job, err := m.Enqueue("once", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A panic fails the job like an error.
+	failed := waitForStatus(t, store, job.ID, db.JobFailed)
+	if failed.Error != "job panicked: boom" {
+		t.Errorf("error = %q", failed.Error)
+	}
+
+	// A failed job queued again by hand starts over with fresh attempts.
+	if _, err := m.Retry(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if failed = waitForStatus(t, store, job.ID, db.JobFailed); failed.Attempts != 1 || failed.Error != "boom" {
+		t.Errorf("got %d attempts and error %q after retry", failed.Attempts, failed.Error)
+	}
+	if _, err := m.Retry(999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("retrying a missing job: got %v, want sql.ErrNoRows", err)
+	}
+
+	// A type with more attempts is queued again with a backoff.
+	job, err = m.Enqueue("retry", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		retrying, err := store.GetJob(job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retrying.Status == db.JobQueued && retrying.Attempts == 1 {
+			if retrying.Error != "boom" || retrying.RunAfter == "" {
+				t.Errorf("got error %q, run after %q", retrying.Error, retrying.RunAfter)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s after %d attempts, want queued for a retry", job.ID, retrying.Status, retrying.Attempts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Only failed or canceled jobs can be retried by hand.
+	if _, err := m.Retry(job.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("retrying a queued job: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	started := make(chan struct{})
+	m, store := startManager(t, map[string]Type{
+		"block": {Handler: func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, MaxAttempts: 3},
+	})
+
+	job, err := m.Enqueue("block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Canceling is not a failure, so the job is not retried.
+	canceled := waitForStatus(t, store, job.ID, db.JobCanceled)
+	if canceled.Attempts != 1 || canceled.FinishedAt == "" {
+		t.Errorf("got %d attempts, finished at %q", canceled.Attempts, canceled.FinishedAt)
+	}
+}
+
+func TestManagerCancelQueued(t *testing.T) {
+	store := db.NewMemoryStore()
+	m := NewManager(store, 1, nil)
+	m.Register("wait", Type{Handler: func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+		return nil, nil
+	}})
+
+	job, err := m.Enqueue("wait", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != db.JobCanceled {
+		t.Errorf("job is %s, want canceled", job.Status)
+	}
+	if _, err := m.Cancel(999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("canceling a missing job: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestManagerExclusive(t *testing.T) {
+	store := db.NewMemoryStore()
+	m := NewManager(store, 1, nil)
+	noop := func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) { return nil, nil }
+	m.Register("exclusive", Type{Handler: noop, Exclusive: true})
+	m.Register("shared", Type{Handler: noop})
+
+	first, err := m.Enqueue("exclusive", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := m.Enqueue("exclusive", nil)
+	if !errors.Is(err, ErrAlreadyActive) || active == nil || active.ID != first.ID {
+		t.Errorf("got %v and %+v, want ErrAlreadyActive with job %d", err, active, first.ID)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := m.Enqueue("shared", nil); err != nil {
+			t.Errorf("queueing a shared job: %v", err)
+		}
+	}
+
+	// A canceled exclusive job can only be retried once no other is active.
+	if _, err := m.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Enqueue("exclusive", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Retry(first.ID); !errors.Is(err, ErrAlreadyActive) {
+		t.Errorf("got %v, want ErrAlreadyActive", err)
+	}
+	if _, err := m.Cancel(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := m.Retry(first.ID); err != nil || job.Status != db.JobQueued {
+		t.Errorf("got %+v, %v, want the job queued again", job, err)
+	}
+}
+
+func TestManagerResumesInterruptedJob(t *testing.T) {
+	store := db.NewMemoryStore()
+	started := make(chan struct{}, 1)
+	block := Type{Handler: func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+		report(3, 10, "working")
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	m := NewManager(store, 1, nil)
+	m.Register("block", block)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	job, err := m.Enqueue("block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// Shutting down queues the job again with its progress.
+	cancel()
+	m.Wait()
+	interrupted := waitForStatus(t, store, job.ID, db.JobQueued)
+	if interrupted.Attempts != 0 || interrupted.Progress != 3 {
+		t.Errorf("got %d attempts and progress %d, want 0 and 3", interrupted.Attempts, interrupted.Progress)
+	}
+
+	// A new manager picks it up again.
+	m = NewManager(store, 1, nil)
+	m.Register("block", block)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		m.Wait()
+	}()
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if resumed := waitForStatus(t, store, job.ID, db.JobRunning); resumed.Attempts != 1 {
+		t.Errorf("resumed job has %d attempts, want 1", resumed.Attempts)
+	}
+}
+
+func TestManagerSchedule(t *testing.T) {
+	store := db.NewMemoryStore()
+	m := NewManager(store, 1, nil)
+	m.Register("tick", Type{Exclusive: true, Handler: func(ctx context.Context, job *db.Job, report Reporter) (interface{}, error) {
+		return nil, nil
+	}})
+	if wait := m.untilDue("tick", time.Hour); wait != 0 {
+		t.Errorf("without earlier jobs the first one is due in %s, want now", wait)
+	}
+
+	// The workers are not started, so the first scheduled job stays queued
+	// and later runs are skipped.
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Schedule(ctx, 20*time.Millisecond, "tick", nil)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	m.Wait()
+	queued, err := store.GetJobs("tick", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].Status != db.JobQueued {
+		t.Fatalf("got jobs %+v, want one queued job", queued)
+	}
+
+	// After a restart, the next job is due one interval after the last one.
+	if wait := m.untilDue("tick", time.Hour); wait <= 59*time.Minute || wait > time.Hour {
+		t.Errorf("next job is due in %s, want about an hour", wait)
+	}
+	if wait := m.untilDue("tick", time.Millisecond); wait != 0 {
+		t.Errorf("an overdue job is due in %s, want now", wait)
+	}
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Schedule queues a job of the given type every interval until ctx is
+// canceled. The first job is due interval after the latest job of the type
+// was created, so restarting the server neither skips nor repeats a run. A
+// run is skipped while a job of an exclusive type is still active.
+func (m *Manager) Schedule(ctx context.Context, interval time.Duration, jobType string, params interface{}) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		timer := time.NewTimer(m.untilDue(jobType, interval))
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			job, err := m.Enqueue(jobType, params)
+			switch {
+			case errors.Is(err, ErrAlreadyActive):
+				fmt.Printf("[Jobs] Skipping scheduled %s, job %d is still active.\n", jobType, job.ID)
+			case err != nil:
+				fmt.Printf("[Error][Jobs] Could not queue scheduled %s: %v\n", jobType, err)
+			default:
+				fmt.Printf("[Jobs] Queued scheduled %s as job %d.\n", jobType, job.ID)
+			}
+			timer.Reset(interval)
+		}
+	}()
+}
+
+// untilDue returns how long to wait before the next scheduled job of the
+// given type.
+func (m *Manager) untilDue(jobType string, interval time.Duration) time.Duration {
+	latest, err := m.store.GetJobs(jobType, "", 1)
+	if err != nil {
+		fmt.Printf("[Error][Jobs] %v\n", err)
+		return interval
+	}
+	if len(latest) == 0 {
+		return 0
+	}
+	created, err := time.Parse(time.RFC3339Nano, latest[0].CreatedAt)
+	if err != nil {
+		return interval
+	}
+	if wait := time.Until(created.Add(interval)); wait > 0 {
+		return wait
+	}
+	return 0
+}
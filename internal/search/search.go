@@ -0,0 +1,293 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"star-sage/internal/ai"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+)
+
+// Search modes.
+const (
+	// ModeHybrid merges keyword and semantic ranks. It falls back to keyword
+	// search when no embeddings exist or the query cannot be embedded.
+	ModeHybrid   = "hybrid"
+	ModeKeyword  = "keyword"
+	ModeSemantic = "semantic"
+)
+
+// Signals a result can be matched by.
+const (
+	SignalKeyword  = "keyword"
+	SignalSemantic = "semantic"
+)
+
+const (
+	// DefaultRRFK dampens the influence of the top ranks; 60 is the value from
+	// the original reciprocal rank fusion paper.
+	DefaultRRFK = 60
+
+	// DefaultLimit is used when no limit is given.
+	DefaultLimit = 20
+
+	// candidateFactor controls how many candidates each signal contributes to
+	// the fusion, relative to the requested limit.
+	candidateFactor = 5
+)
+
+// ErrNoEmbeddings is returned by semantic searches before 'starsage embed' has run.
+var ErrNoEmbeddings = errors.New("no embeddings found, run 'starsage embed' first")
+
+// ErrNoSignal is returned when the weights leave no signal to search with.
+var ErrNoSignal = errors.New("no search signal has a weight above zero")
+
+// Options configures a search.
+type Options struct {
+	Mode  string
+	Limit int
+	// KeywordWeight and SemanticWeight scale each signal's contribution to the
+	// fused score; nil means 1. A signal with a zero weight is not searched.
+	KeywordWeight  *float64
+	SemanticWeight *float64
+	// RRFK is the k constant of reciprocal rank fusion. Zero means DefaultRRFK.
+	RRFK float64
+	// MatchAny treats the query as natural language: the keyword search
+	// matches any of its words instead of interpreting FTS5 syntax. Without
+	// it, a query that is not valid FTS5 syntax matches all of its words.
+	MatchAny bool
+	// Embedding is the provider profile used to embed the query. Its model
+	// selects which stored embeddings are compared.
+	Embedding config.ProviderConfig
+}
+
+// Result is a repository found by a search.
+type Result struct {
+	db.Repository
+	// Snippet highlights the keyword match; it is empty for semantic-only matches.
+	Snippet string
+	// Score is the fused reciprocal rank score; higher is better.
+	Score float64
+	// MatchedBy lists the signals that found the repository.
+	MatchedBy []string
+	// KeywordRank and SemanticRank are 1-based positions in each signal's
+	// ranking, or 0 if the signal did not match.
+	KeywordRank  int
+	SemanticRank int
+	// Similarity is the cosine similarity to the query for semantic matches.
+	Similarity float64
+}
+
+// Response is the outcome of a search.
+type Response struct {
+	Query string
+	// Mode is the mode that was actually used, which can differ from the
+	// requested one when hybrid search falls back to keywords or a signal has
+	// a zero weight.
+	Mode    string
+	Results []Result
+	// Warning explains a fallback, if any.
+	Warning string `json:",omitempty"`
+}
+
+// Search runs query against store.
+func Search(ctx context.Context, store db.SearchStore, query string, opts Options) (*Response, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeHybrid
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit
+	}
+	if opts.RRFK <= 0 {
+		opts.RRFK = DefaultRRFK
+	}
+
+	resp := &Response{Query: query, Mode: opts.Mode}
+	switch opts.Mode {
+	case ModeKeyword, ModeSemantic, ModeHybrid:
+	default:
+		return nil, fmt.Errorf("unknown search mode: %s", opts.Mode)
+	}
+	if weight(opts.KeywordWeight) < 0 || weight(opts.SemanticWeight) < 0 {
+		return nil, fmt.Errorf("search weights must not be negative")
+	}
+
+	useKeyword := opts.Mode != ModeSemantic && weight(opts.KeywordWeight) > 0
+	useSemantic := opts.Mode != ModeKeyword && weight(opts.SemanticWeight) > 0
+	switch {
+	case !useKeyword && !useSemantic:
+		return nil, fmt.Errorf("%w for %s search", ErrNoSignal, opts.Mode)
+	case !useSemantic:
+		resp.Mode = ModeKeyword
+	case !useKeyword:
+		resp.Mode = ModeSemantic
+	}
+
+	candidates := opts.Limit
+	if resp.Mode == ModeHybrid {
+		candidates = opts.Limit * candidateFactor
+	}
+
+	var semantic []db.ScoredRepository
+	if useSemantic {
+		var err error
+		semantic, err = semanticSearch(ctx, store, query, opts.Embedding, candidates)
+		if err != nil {
+			if resp.Mode == ModeSemantic {
+				return nil, err
+			}
+			resp.Mode = ModeKeyword
+			if !errors.Is(err, ErrNoEmbeddings) {
+				resp.Warning = fmt.Sprintf("semantic search unavailable: %v", err)
+			}
+		}
+	}
+
+	var keyword []db.SearchResult
+	if useKeyword {
+		var err error
+		ftsQuery := query
+		if opts.MatchAny {
+			ftsQuery = anyTermsQuery(query)
+		}
+		if ftsQuery != "" {
+			keyword, err = store.SearchRepositories(ftsQuery, candidates)
+			if err != nil && !opts.MatchAny {
+				// Queries like "c++" are not valid FTS5 syntax; search for
+				// their words literally instead.
+				var literalErr error
+				if keyword, literalErr = store.SearchRepositories(allTermsQuery(query), candidates); literalErr == nil {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			// Natural language queries can be invalid FTS5 syntax; semantic
+			// results are still useful then.
+			if resp.Mode != ModeHybrid {
+				return nil, err
+			}
+			resp.Mode = ModeSemantic
+			resp.Warning = fmt.Sprintf("keyword search failed: %v", err)
+		}
+	}
+
+	resp.Results = fuse(keyword, semantic, opts)
+	return resp, nil
+}
+
+// semanticSearch embeds query with the profile and ranks the repositories
+// embedded with the same model.
+func semanticSearch(ctx context.Context, store db.SearchStore, query string, profile config.ProviderConfig, limit int) ([]db.ScoredRepository, error) {
+	n, err := store.CountEmbeddings(profile.Model)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w (model %s)", ErrNoEmbeddings, profile.Model)
+	}
+
+	embedder, err := ai.NewEmbedder(profile)
+	if err != nil {
+		return nil, err
+	}
+	vec, err := embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not embed query: %w", err)
+	}
+	return store.SemanticSearch(profile.Model, vec, limit)
+}
+
+// minTermLength skips words too short to be meaningful in MatchAny queries.
+const minTermLength = 2
+
+// anyTermsQuery turns free text into an FTS5 query matching any of its words.
+// Every word is quoted, so punctuation and FTS5 operators in the text are harmless.
+func anyTermsQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+	seen := make(map[string]bool)
+	var terms []string
+	for _, w := range words {
+		w = strings.ToLower(strings.Trim(w, "-_"))
+		if len([]rune(w)) < minTermLength || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, `"`+w+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// allTermsQuery turns free text into an FTS5 query matching all of its words.
+// Words are quoted, so FTS5 operators in the text are harmless.
+func allTermsQuery(text string) string {
+	var terms []string
+	for _, w := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// weight returns the weight w, or 1 if it is not set.
+func weight(w *float64) float64 {
+	if w == nil {
+		return 1
+	}
+	return *w
+}
+
+// fuse merges the keyword and semantic rankings with weighted reciprocal rank
+// fusion: score = sum over signals of weight / (k + rank). The matches of a
+// signal with a zero weight are left out.
+func fuse(keyword []db.SearchResult, semantic []db.ScoredRepository, opts Options) []Result {
+	byID := make(map[int64]*Result)
+	var order []int64
+	keywordWeight, semanticWeight := weight(opts.KeywordWeight), weight(opts.SemanticWeight)
+	if keywordWeight == 0 {
+		keyword = nil
+	}
+	if semanticWeight == 0 {
+		semantic = nil
+	}
+	get := func(repo db.Repository) *Result {
+		if r, ok := byID[repo.ID]; ok {
+			return r
+		}
+		r := &Result{Repository: repo}
+		byID[repo.ID] = r
+		order = append(order, repo.ID)
+		return r
+	}
+
+	for i, k := range keyword {
+		r := get(k.Repository)
+		r.Snippet = k.Snippet
+		r.KeywordRank = i + 1
+		r.MatchedBy = append(r.MatchedBy, SignalKeyword)
+		r.Score += keywordWeight / (opts.RRFK + float64(i+1))
+	}
+	for i, s := range semantic {
+		r := get(s.Repository)
+		r.SemanticRank = i + 1
+		r.Similarity = s.Score
+		r.MatchedBy = append(r.MatchedBy, SignalSemantic)
+		r.Score += semanticWeight / (opts.RRFK + float64(i+1))
+	}
+
+	results := make([]Result, 0, len(order))
+	for _, id := range order {
+		results = append(results, *byID[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results
+}
@@ -0,0 +1,275 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+func openTestStore(t *testing.T, repos ...db.Repository) *db.SQLiteStore {
+	t.Helper()
+	database := dbtest.OpenDB(t)
+	for i, r := range repos {
+		r.Source = db.DefaultSource
+		r.SourceID = int64(i + 1)
+		if _, err := db.UpsertRepository(database, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db.NewSQLiteStore(database)
+}
+
+func resultNames(results []Result) []string {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.FullName
+	}
+	return names
+}
+
+func TestSearchInvalidFTSQuery(t *testing.T) {
+	store := openTestStore(t,
+		db.Repository{FullName: "owner/cpp", Description: "A C++ library"},
+		db.Repository{FullName: "owner/go", Description: "A Go library"},
+	)
+
+	tests := []struct {
+		query string
+		mode  string
+		want  []string
+	}{
+		{"c++", ModeHybrid, []string{"owner/cpp"}},
+		{"c++", ModeKeyword, []string{"owner/cpp"}},
+		{`"library`, ModeKeyword, []string{"owner/cpp", "owner/go"}},
+		{"go (", ModeKeyword, []string{"owner/go"}},
+		{"description:go", ModeKeyword, []string{"owner/go"}},
+		{"go OR c", ModeKeyword, []string{"owner/cpp", "owner/go"}},
+	}
+	for _, tt := range tests {
+		resp, err := Search(context.Background(), store, tt.query, Options{Mode: tt.mode})
+		if err != nil {
+			t.Errorf("Search(%q, %s): %v", tt.query, tt.mode, err)
+			continue
+		}
+		if resp.Mode != ModeKeyword {
+			t.Errorf("Search(%q, %s) used mode %s, want keyword", tt.query, tt.mode, resp.Mode)
+		}
+		got := resultNames(resp.Results)
+		if !sameNames(got, tt.want) {
+			t.Errorf("Search(%q, %s) = %v, want %v", tt.query, tt.mode, got, tt.want)
+		}
+	}
+}
+
+// sameNames compares two result lists regardless of order.
+func sameNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, n := range got {
+		seen[n]++
+	}
+	for _, n := range want {
+		if seen[n] == 0 {
+			return false
+		}
+		seen[n]--
+	}
+	return true
+}
+
+func TestSearchStoreError(t *testing.T) {
+	store := openTestStore(t)
+	store.DB().Close()
+	if _, err := Search(context.Background(), store, "go", Options{Mode: ModeKeyword}); err == nil {
+		t.Error("Search on a closed database succeeded, want an error")
+	}
+}
+
+// fuseInputs returns keyword and semantic results for the named
+// repositories, in order. A repository's ID is the position of its name in the
+// alphabet, so the same name refers to the same repository in both lists.
+func fuseInputs(keyword, semantic string) ([]db.SearchResult, []db.ScoredRepository) {
+	repo := func(name rune) db.Repository {
+		return db.Repository{ID: int64(name - 'a' + 1), FullName: string(name)}
+	}
+	var k []db.SearchResult
+	for _, name := range keyword {
+		k = append(k, db.SearchResult{Repository: repo(name), Snippet: "<b>" + string(name) + "</b>"})
+	}
+	var s []db.ScoredRepository
+	for _, name := range semantic {
+		s = append(s, db.ScoredRepository{Repository: repo(name), Score: 0.5})
+	}
+	return k, s
+}
+
+func TestFuse(t *testing.T) {
+	w := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name     string
+		keyword  string
+		semantic string
+		opts     Options
+		want     string
+	}{
+		{name: "nothing", want: ""},
+		{name: "keyword only", keyword: "abc", want: "abc"},
+		{name: "semantic only", semantic: "ca", want: "ca"},
+		{name: "both signals rank higher", keyword: "ab", semantic: "bc", want: "bac"},
+		// Equal scores keep the keyword results first, then the order they
+		// were found in.
+		{name: "tie between signals", keyword: "a", semantic: "b", want: "ab"},
+		{name: "tie in both signals", keyword: "ab", semantic: "ba", want: "ab"},
+		{name: "tie on later ranks", keyword: "abc", semantic: "dbe", want: "badce"},
+		{name: "weighted semantic", keyword: "abc", semantic: "c", opts: Options{SemanticWeight: w(3)}, want: "cab"},
+		{name: "weighted keyword", keyword: "ab", semantic: "cd", opts: Options{KeywordWeight: w(0.5)}, want: "cdab"},
+		// A signal with a zero weight does not contribute matches.
+		{name: "zero keyword weight", keyword: "ab", semantic: "c", opts: Options{KeywordWeight: w(0)}, want: "c"},
+		{name: "zero semantic weight", keyword: "a", semantic: "ba", opts: Options{SemanticWeight: w(0)}, want: "a"},
+		{name: "limit", keyword: "abc", semantic: "d", opts: Options{Limit: 2}, want: "ad"},
+		// A small k favours the top ranks over matching both signals.
+		{name: "large k", keyword: "abc", semantic: "dec", want: "cadbe"},
+		{name: "small k", keyword: "abc", semantic: "dec", opts: Options{RRFK: 0.5}, want: "adcbe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts.Limit == 0 {
+				opts.Limit = DefaultLimit
+			}
+			if opts.RRFK == 0 {
+				opts.RRFK = DefaultRRFK
+			}
+			keyword, semantic := fuseInputs(tt.keyword, tt.semantic)
+			results := fuse(keyword, semantic, opts)
+			got := ""
+			for _, r := range results {
+				got += r.FullName
+			}
+			if got != tt.want {
+				t.Errorf("fused %q and %q into %q, want %q", tt.keyword, tt.semantic, got, tt.want)
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Score > results[i-1].Score {
+					t.Errorf("result %d scores %v, more than the one before it", i, results[i].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestFuseResult(t *testing.T) {
+	keyword, semantic := fuseInputs("ab", "cb")
+	results := fuse(keyword, semantic, Options{Limit: DefaultLimit, RRFK: DefaultRRFK})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	both, keywordOnly, semanticOnly := results[0], results[1], results[2]
+	if both.FullName != "b" || both.KeywordRank != 2 || both.SemanticRank != 2 ||
+		both.Snippet != "<b>b</b>" || both.Similarity != 0.5 ||
+		len(both.MatchedBy) != 2 || both.Score != 2.0/62 {
+		t.Errorf("result matched by both signals = %+v", both)
+	}
+	if keywordOnly.FullName != "a" || keywordOnly.SemanticRank != 0 ||
+		len(keywordOnly.MatchedBy) != 1 || keywordOnly.MatchedBy[0] != SignalKeyword || keywordOnly.Score != 1.0/61 {
+		t.Errorf("keyword result = %+v", keywordOnly)
+	}
+	if semanticOnly.FullName != "c" || semanticOnly.KeywordRank != 0 || semanticOnly.Snippet != "" ||
+		len(semanticOnly.MatchedBy) != 1 || semanticOnly.MatchedBy[0] != SignalSemantic || semanticOnly.Score != 1.0/61 {
+		t.Errorf("semantic result = %+v", semanticOnly)
+	}
+}
+
+// countingStore returns fixed search results and counts the searches.
+type countingStore struct {
+	keyword         []db.SearchResult
+	semantic        []db.ScoredRepository
+	keywordQueries  int
+	semanticQueries int
+}
+
+func (s *countingStore) SearchRepositories(query string, limit int) ([]db.SearchResult, error) {
+	s.keywordQueries++
+	return s.keyword, nil
+}
+
+func (s *countingStore) CountEmbeddings(model string) (int, error) {
+	return len(s.semantic), nil
+}
+
+func (s *countingStore) SemanticSearch(model string, query []float32, limit int) ([]db.ScoredRepository, error) {
+	s.semanticQueries++
+	return s.semantic, nil
+}
+
+func TestSearchWeights(t *testing.T) {
+	embeds := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		embeds++
+		w.Write([]byte(`{"data": [{"embedding": [1, 0]}]}`))
+	}))
+	defer srv.Close()
+	embedding := config.ProviderConfig{Name: "test", Type: "openai", BaseURL: srv.URL, Model: "embed"}
+
+	w := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name         string
+		opts         Options
+		wantMode     string
+		want         string
+		wantKeyword  bool
+		wantSemantic bool
+		wantErr      error
+		wantAnyErr   bool
+	}{
+		{name: "hybrid", opts: Options{Mode: ModeHybrid}, wantMode: ModeHybrid, want: "bac", wantKeyword: true, wantSemantic: true},
+		{name: "zero semantic weight", opts: Options{Mode: ModeHybrid, SemanticWeight: w(0)}, wantMode: ModeKeyword, want: "ab", wantKeyword: true},
+		{name: "zero keyword weight", opts: Options{Mode: ModeHybrid, KeywordWeight: w(0)}, wantMode: ModeSemantic, want: "bc", wantSemantic: true},
+		{name: "zero weights", opts: Options{Mode: ModeHybrid, KeywordWeight: w(0), SemanticWeight: w(0)}, wantErr: ErrNoSignal},
+		{name: "keyword with zero weight", opts: Options{Mode: ModeKeyword, KeywordWeight: w(0)}, wantErr: ErrNoSignal},
+		{name: "keyword ignores semantic weight", opts: Options{Mode: ModeKeyword, SemanticWeight: w(0)}, wantMode: ModeKeyword, want: "ab", wantKeyword: true},
+		{name: "negative weight", opts: Options{Mode: ModeHybrid, KeywordWeight: w(-1)}, wantAnyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyword, semantic := fuseInputs("ab", "bc")
+			store := &countingStore{keyword: keyword, semantic: semantic}
+			embeds = 0
+			opts := tt.opts
+			opts.Embedding = embedding
+			resp, err := Search(context.Background(), store, "query", opts)
+			if tt.wantErr != nil || tt.wantAnyErr {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+				if store.keywordQueries+store.semanticQueries+embeds > 0 {
+					t.Error("a rejected search ran queries")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			for _, r := range resp.Results {
+				got += r.FullName
+			}
+			if resp.Mode != tt.wantMode || got != tt.want {
+				t.Errorf("got %q in mode %s, want %q in mode %s", got, resp.Mode, tt.want, tt.wantMode)
+			}
+			if (store.keywordQueries > 0) != tt.wantKeyword {
+				t.Errorf("ran %d keyword queries, want keyword search: %v", store.keywordQueries, tt.wantKeyword)
+			}
+			if (store.semanticQueries > 0 || embeds > 0) != tt.wantSemantic {
+				t.Errorf("ran %d semantic queries and %d embeddings, want semantic search: %v", store.semanticQueries, embeds, tt.wantSemantic)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"star-sage/internal/db"
+	"strings"
+	"time"
+)
+
+const (
+	// tokenPrefix starts every API token, which makes them easy to recognize.
+	tokenPrefix = "ssk_"
+	// sessionCookie holds the session ID of a browser login.
+	sessionCookie = "starsage_session"
+	// csrfHeader must carry the session's CSRF token on every mutating request
+	// authenticated by the session cookie.
+	csrfHeader = "X-CSRF-Token"
+	// sessionLifetime is how long a browser login lasts.
+	sessionLifetime = 30 * 24 * time.Hour
+)
+
+// CreateToken generates an API token with the given scope and stores its
+// hash. The returned token is not stored and cannot be shown again.
+func CreateToken(store db.AuthStore, name, scope string) (string, error) {
+	secret, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	token := tokenPrefix + secret
+	if _, err := store.CreateAPIToken(name, scope, hashSecret(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// randomString returns 32 random bytes, encoded for use in headers and cookies.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret returns the hash under which a token or session ID is stored, so
+// that a copy of the database does not grant access.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// isPublicPath reports whether path is served without authentication: the
+// web UI, which shows a login form, and the login endpoints.
+func isPublicPath(path string) bool {
+	switch path {
+	case "/api/login", "/api/logout", "/api/session":
+		return true
+	}
+	return !strings.HasPrefix(path, "/api/")
+}
+
+// isSafeMethod reports whether method only reads.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// requireAuth wraps next so that API requests need a bearer token
+// ("Authorization: Bearer ssk_...") or a session cookie. Requests made with a
+// session cookie must send the session's CSRF token in the X-CSRF-Token
+// header unless they only read, and tokens with the read scope may only read.
+func (h *apiHandler) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var scope string
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			token, ok := strings.CutPrefix(auth, "Bearer ")
+			if !ok {
+				writeUnauthorized(w, "Unsupported authorization scheme")
+				return
+			}
+			t, err := h.store.UseAPIToken(hashSecret(strings.TrimSpace(token)))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Error checking token")
+				return
+			}
+			if t == nil {
+				writeUnauthorized(w, "Invalid token")
+				return
+			}
+			scope = t.Scope
+		} else {
+			session, err := h.session(r)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Error checking session")
+				return
+			}
+			if session == nil {
+				writeUnauthorized(w, "Authentication required")
+				return
+			}
+			if !isSafeMethod(r.Method) &&
+				subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(session.CSRFToken)) != 1 {
+				writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+				return
+			}
+			scope = session.Scope
+		}
+
+		if scope != db.ScopeWrite && !isSafeMethod(r.Method) {
+			writeError(w, http.StatusForbidden, "This token is read-only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeUnauthorized answers a request that lacks valid credentials.
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="starsage"`)
+	writeError(w, http.StatusUnauthorized, message)
+}
+
+// session returns the session of the request's cookie, or nil if there is
+// none or it is no longer valid.
+func (h *apiHandler) session(r *http.Request) (*db.Session, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	return h.store.GetSession(hashSecret(cookie.Value))
+}
+
+type loginRequest struct {
+	Token string `json:"token"`
+}
+
+// sessionResponse describes the authentication state to the web UI.
+type sessionResponse struct {
+	AuthEnabled   bool   `json:"auth_enabled"`
+	Authenticated bool   `json:"authenticated"`
+	Scope         string `json:"scope,omitempty"`
+	CSRFToken     string `json:"csrf_token,omitempty"`
+}
+
+// handleSession reports whether the request is logged in (GET). Without
+// authentication every request has the write scope.
+func (h *apiHandler) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+	if !h.auth {
+		writeJSON(w, http.StatusOK, sessionResponse{Authenticated: true, Scope: db.ScopeWrite})
+		return
+	}
+	session, err := h.session(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error checking session")
+		return
+	}
+	if session == nil {
+		writeJSON(w, http.StatusOK, sessionResponse{AuthEnabled: true})
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionResponse{AuthEnabled: true, Authenticated: true, Scope: session.Scope, CSRFToken: session.CSRFToken})
+}
+
+// handleLogin starts a browser session with an API token (POST {"token"}).
+// The session gets the token's scope and ends when the token is revoked.
+func (h *apiHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	if !h.auth {
+		writeError(w, http.StatusNotFound, "Authentication is not enabled")
+		return
+	}
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		writeError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+	token, err := h.store.UseAPIToken(hashSecret(strings.TrimSpace(req.Token)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error checking token")
+		return
+	}
+	if token == nil {
+		writeUnauthorized(w, "Invalid token")
+		return
+	}
+
+	sessionID, err := randomString()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	csrfToken, err := randomString()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	expires := time.Now().Add(sessionLifetime)
+	if err := h.store.CreateSession(hashSecret(sessionID), token.ID, csrfToken, expires); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeJSON(w, http.StatusOK, sessionResponse{AuthEnabled: true, Authenticated: true, Scope: token.Scope, CSRFToken: csrfToken})
+}
+
+// handleLogout ends the browser session (POST).
+func (h *apiHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := h.store.DeleteSession(hashSecret(cookie.Value)); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to end session")
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+// credentials authenticate a test request.
+type credentials struct {
+	// authorization is sent as the Authorization header.
+	authorization string
+	// session is the value of the session cookie.
+	session string
+	csrf    string
+}
+
+func (c credentials) header() http.Header {
+	h := make(http.Header)
+	if c.authorization != "" {
+		h.Set("Authorization", c.authorization)
+	}
+	if c.session != "" {
+		h.Set("Cookie", (&http.Cookie{Name: sessionCookie, Value: c.session}).String())
+	}
+	if c.csrf != "" {
+		h.Set(csrfHeader, c.csrf)
+	}
+	return h
+}
+
+// login starts a session with token and returns its cookie and CSRF token.
+func login(t *testing.T, h *apiHandler, token string) credentials {
+	t.Helper()
+	rec := serve(h, http.MethodPost, "/api/login", fmt.Sprintf(`{"token": %q}`, token), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: got status %d (%s)", rec.Code, rec.Body)
+	}
+	var resp sessionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookie {
+			return credentials{session: c.Value, csrf: resp.CSRFToken}
+		}
+	}
+	t.Fatal("login set no session cookie")
+	return credentials{}
+}
+
+func TestRequireAuth(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		h.auth = true
+		writeToken, err := CreateToken(store, "write", db.ScopeWrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readToken, err := CreateToken(store, "read", db.ScopeRead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeSession := login(t, h, writeToken)
+		readSession := login(t, h, readToken)
+		loggedOut := login(t, h, writeToken)
+		if rec := serve(h, http.MethodPost, "/api/logout", "", loggedOut.header()); rec.Code != http.StatusNoContent {
+			t.Fatalf("logout: got status %d", rec.Code)
+		}
+
+		bearer := func(token string) credentials { return credentials{authorization: "Bearer " + token} }
+		withCSRF := func(c credentials, csrf string) credentials {
+			c.csrf = csrf
+			return c
+		}
+		tests := []struct {
+			name       string
+			method     string
+			path       string
+			creds      credentials
+			wantStatus int
+		}{
+			{"no credentials", http.MethodGet, "/api/tags", credentials{}, http.StatusUnauthorized},
+			{"no credentials write", http.MethodPost, "/api/tags", credentials{}, http.StatusUnauthorized},
+			{"basic auth", http.MethodGet, "/api/tags", credentials{authorization: "Basic dXNlcjpwYXNz"}, http.StatusUnauthorized},
+			{"invalid token", http.MethodGet, "/api/tags", bearer("ssk_invalid"), http.StatusUnauthorized},
+			{"invalid session", http.MethodGet, "/api/tags", credentials{session: "invalid"}, http.StatusUnauthorized},
+			{"ended session", http.MethodGet, "/api/tags", loggedOut, http.StatusUnauthorized},
+			{"web UI", http.MethodGet, "/", credentials{}, http.StatusNotFound},
+			{"session state", http.MethodGet, "/api/session", credentials{}, http.StatusOK},
+
+			{"write token reads", http.MethodGet, "/api/tags", bearer(writeToken), http.StatusOK},
+			{"write token writes", http.MethodPost, "/api/tags", bearer(writeToken), http.StatusCreated},
+			{"read token reads", http.MethodGet, "/api/tags", bearer(readToken), http.StatusOK},
+			{"read token creates", http.MethodPost, "/api/tags", bearer(readToken), http.StatusForbidden},
+			{"read token deletes", http.MethodDelete, "/api/lists/1", bearer(readToken), http.StatusForbidden},
+			{"read token updates", http.MethodPut, "/api/lists/1", bearer(readToken), http.StatusForbidden},
+
+			{"session reads without CSRF", http.MethodGet, "/api/tags", withCSRF(writeSession, ""), http.StatusOK},
+			{"session writes without CSRF", http.MethodPost, "/api/tags", withCSRF(writeSession, ""), http.StatusForbidden},
+			{"session writes with wrong CSRF", http.MethodPost, "/api/tags", withCSRF(writeSession, readSession.csrf), http.StatusForbidden},
+			{"session writes with CSRF", http.MethodPost, "/api/tags", writeSession, http.StatusCreated},
+			{"read session writes with CSRF", http.MethodPost, "/api/tags", readSession, http.StatusForbidden},
+			{"read session reads", http.MethodGet, "/api/tags", withCSRF(readSession, ""), http.StatusOK},
+		}
+		for i, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				body := ""
+				if tt.method == http.MethodPost {
+					body = fmt.Sprintf(`{"name": "tag%d"}`, i)
+				}
+				rec := serve(h, tt.method, tt.path, body, tt.creds.header())
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("got status %d (%s), want %d", rec.Code, rec.Body, tt.wantStatus)
+				}
+				if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("401 response without WWW-Authenticate header")
+				}
+			})
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		token, err := CreateToken(store, "read", db.ScopeRead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Without authentication there is nothing to log in to.
+		if rec := serve(h, http.MethodPost, "/api/login", fmt.Sprintf(`{"token": %q}`, token), nil); rec.Code != http.StatusNotFound {
+			t.Errorf("login without auth: got status %d, want 404", rec.Code)
+		}
+
+		h.auth = true
+		for _, tt := range []struct {
+			body       string
+			wantStatus int
+		}{
+			{`{}`, http.StatusBadRequest},
+			{`{"token": "ssk_invalid"}`, http.StatusUnauthorized},
+		} {
+			if rec := serve(h, http.MethodPost, "/api/login", tt.body, nil); rec.Code != tt.wantStatus {
+				t.Errorf("login with %s: got status %d, want %d", tt.body, rec.Code, tt.wantStatus)
+			}
+		}
+
+		creds := login(t, h, token)
+		var state sessionResponse
+		rec := serve(h, http.MethodGet, "/api/session", "", creds.header())
+		if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+			t.Fatal(err)
+		}
+		want := sessionResponse{AuthEnabled: true, Authenticated: true, Scope: db.ScopeRead, CSRFToken: creds.csrf}
+		if state != want {
+			t.Errorf("session = %+v, want %+v", state, want)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventsHeartbeat is how often an idle event stream sends a comment, so
+// proxies and browsers do not consider the connection dead.
+const eventsHeartbeat = 25 * time.Second
+
+// handleEvents streams job progress and library changes as server-sent
+// events. Each event is named after its type, e.g. "job.updated", and carries
+// its data as JSON. The optional types parameter is a comma-separated list of
+// event types to receive. Events published while a client is disconnected are
+// not replayed; clients should reload what they show after reconnecting.
+func (h *apiHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	var types map[string]bool
+	if v := r.URL.Query().Get("types"); v != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(v, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+	disableWriteTimeout(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.shutdown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				// The client fell behind and was dropped; ending the response
+				// makes it reconnect and reload.
+				return
+			}
+			if types != nil && !types[e.Type] {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\n", e.ID)
+			writeSSE(w, e.Type, e.Data)
+			flusher.Flush()
+		}
+	}
+}
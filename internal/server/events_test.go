@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"star-sage/internal/db"
+	"star-sage/internal/events"
+	"strings"
+	"testing"
+)
+
+// readEvent reads the lines of the next event or comment of an event stream.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the event stream: %v (read %q)", err, lines)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestEventsAPI(t *testing.T) {
+	h := newTestHandler(t, db.NewMemoryStore())
+	srv := httptest.NewServer(h.routes(http.NotFoundHandler()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events?types=list.changed,%20job.deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("got status %d and content type %q", resp.StatusCode, ct)
+	}
+	body := bufio.NewReader(resp.Body)
+	// The stream is subscribed once its first line arrives.
+	if got := readEvent(t, body); got != "retry: 3000\n" {
+		t.Fatalf("got %q, want the retry interval", got)
+	}
+
+	h.events.Publish(events.RepoAdded, events.RepoRef{Source: "github.com", FullName: "owner/repo"})
+	h.events.Publish(events.ListChanged, events.ListRef{ID: 7})
+	h.events.Publish(events.JobDeleted, events.JobRef{ID: 3})
+	for _, want := range []string{
+		"id: 2\nevent: list.changed\ndata: {\"id\":7}\n",
+		"id: 3\nevent: job.deleted\ndata: {\"id\":3}\n",
+	} {
+		if got := readEvent(t, body); got != want {
+			t.Errorf("got event %q, want %q", got, want)
+		}
+	}
+
+	// Shutting down ends the stream.
+	close(h.shutdown)
+	if rest, err := io.ReadAll(body); err != nil || len(rest) != 0 {
+		t.Errorf("got %q, %v after shutdown, want the end of the stream", rest, err)
+	}
+}
+
+func TestEventsAPIMethod(t *testing.T) {
+	h := newTestHandler(t, db.NewMemoryStore())
+	request(t, h, http.MethodPost, "/api/events", "", http.StatusMethodNotAllowed, nil)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"star-sage/internal/export"
+	"strings"
+)
+
+// handleExport serves the starred repositories in one of the formats of
+// 'starsage export' as a download: format (json, csv, markdown,
+// html-bookmarks or opml; default json) and group_by (list or topic). The
+// repositories are filtered with the parameters of /api/repositories;
+// pagination and sorting do not apply.
+func (h *apiHandler) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	values := r.URL.Query()
+	opts := export.Options{Format: values.Get("format"), GroupBy: values.Get("group_by")}
+	if opts.Format == "" {
+		opts.Format = export.FormatJSON
+	}
+	contentType, ok := export.ContentType(opts.Format)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid format, must be one of "+strings.Join(export.Formats, ", "))
+		return
+	}
+	switch opts.GroupBy {
+	case "", export.GroupByList, export.GroupByTopic:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid group_by, must be list or topic")
+		return
+	}
+	values.Del("limit")
+	values.Del("offset")
+	query, err := h.parseRepoQuery(values)
+	if err != nil {
+		if reqErr, ok := err.(*requestError); ok {
+			writeError(w, reqErr.status, reqErr.message)
+		} else {
+			writeError(w, http.StatusInternalServerError, "Error exporting repositories")
+		}
+		return
+	}
+	opts.Filter = query.RepoFilter
+
+	// Render first, so a failure can still be reported with a status code.
+	var buf bytes.Buffer
+	if err := export.Export(&buf, h.store, opts); err != nil {
+		writeError(w, http.StatusInternalServerError, "Error exporting repositories")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(opts.Format)))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"star-sage/internal/db"
+	"star-sage/internal/events"
+	"star-sage/internal/jobs"
+	"star-sage/internal/source"
+	"star-sage/internal/syncer"
+	"strconv"
+	"strings"
+)
+
+// defaultJobsLimit is the number of jobs listed without a limit parameter.
+const defaultJobsLimit = 50
+
+type createJobRequest struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params"`
+}
+
+// handleJobs lists jobs (GET, filtered by the type and status query
+// parameters, newest first) or queues a new one (POST {"type", "params"}).
+func (h *apiHandler) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit, err := intParam(q, "limit", defaultJobsLimit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, err := h.store.GetJobs(q.Get("type"), q.Get("status"), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching jobs")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		var req createJobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Type == "" {
+			writeError(w, http.StatusBadRequest, "Job type is required")
+			return
+		}
+		var params interface{}
+		if len(req.Params) > 0 {
+			params = req.Params
+		}
+		job, err := h.jobs.Enqueue(req.Type, params)
+		writeQueuedJob(w, job, err)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleJobByID serves /api/jobs/{id}: GET returns the job, DELETE cancels a
+// queued or running job and deletes a finished one, and POST
+// /api/jobs/{id}/retry queues a failed or canceled job again.
+func (h *apiHandler) handleJobByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	if action == "retry" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+			return
+		}
+		job, err := h.jobs.Retry(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusConflict, "Only failed or canceled jobs can be retried")
+			return
+		}
+		writeQueuedJob(w, job, err)
+		return
+	}
+	if action != "" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	job, err := h.store.GetJob(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching job")
+		return
+	}
+	if job == nil {
+		writeError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodDelete:
+		if job.Finished() {
+			if err := h.store.DeleteJob(id); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to delete job")
+				return
+			}
+			h.events.Publish(events.JobDeleted, events.JobRef{ID: id})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		job, err := h.jobs.Cancel(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to cancel job")
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// writeQueuedJob answers a request that queued job with 202 Accepted, or maps
+// the error of Manager.Enqueue or Manager.Retry to a status code. A conflict
+// with an active job of an exclusive type reports that job's ID.
+func writeQueuedJob(w http.ResponseWriter, job *db.Job, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, job)
+	case errors.Is(err, jobs.ErrUnknownType):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, jobs.ErrAlreadyActive):
+		writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "job_id": job.ID})
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to queue job")
+	}
+}
+
+// decodeOptionalJSON decodes the request body into v, leaving v as it is
+// when the body is empty.
+func decodeOptionalJSON(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// handleSync queues a sync of a configured source with its stored token
+// (POST {"source"?, "limit"?, "prune"?, "resume"?}). Only one sync runs at a
+// time; while one is queued or running the request fails with 409 Conflict.
+func (h *apiHandler) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	var params jobs.SyncParams
+	if err := decodeOptionalJSON(r, &params); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// Check the source and its token now rather than in a job that would fail.
+	if _, err := syncer.OpenSource(params.Source, source.Options{}); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job, err := h.jobs.Enqueue(jobs.TypeSync, params)
+	writeQueuedJob(w, job, err)
+}
+
+// handleSummarize queues the summarization of repositories without a summary
+// (POST {"limit"?}).
+func (h *apiHandler) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	var params jobs.LimitParams
+	if err := decodeOptionalJSON(r, &params); err != nil || params.Limit < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	job, err := h.jobs.Enqueue(jobs.TypeSummarize, params)
+	writeQueuedJob(w, job, err)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"star-sage/internal/db"
+	"star-sage/internal/jobs"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// conflict is the body of a 409 response to a request for a job that is
+// already active.
+type conflict struct {
+	Error string `json:"error"`
+	JobID int64  `json:"job_id"`
+}
+
+func TestSyncAPI(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	h := newTestHandler(t, db.NewMemoryStore())
+
+	// Without a stored token the sync is refused before it is queued.
+	request(t, h, http.MethodPost, "/api/sync", "", http.StatusBadRequest, nil)
+	viper.Set("github_token", "secret")
+
+	var job db.Job
+	request(t, h, http.MethodPost, "/api/sync", `{"limit": 10, "prune": true}`, http.StatusAccepted, &job)
+	var params jobs.SyncParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != jobs.TypeSync || job.Status != db.JobQueued || params != (jobs.SyncParams{Limit: 10, Prune: true}) {
+		t.Errorf("got job %+v with params %+v", job, params)
+	}
+
+	// Only one sync is active at a time.
+	var c conflict
+	request(t, h, http.MethodPost, "/api/sync", "", http.StatusConflict, &c)
+	if c.JobID != job.ID || c.Error == "" {
+		t.Errorf("got conflict %+v, want one naming job %d", c, job.ID)
+	}
+
+	request(t, h, http.MethodGet, "/api/sync", "", http.StatusMethodNotAllowed, nil)
+	request(t, h, http.MethodPost, "/api/sync", "{", http.StatusBadRequest, nil)
+	request(t, h, http.MethodPost, "/api/sync", `{"source": "unknown"}`, http.StatusBadRequest, nil)
+}
+
+func TestSummarizeAPI(t *testing.T) {
+	h := newTestHandler(t, db.NewMemoryStore())
+
+	var job db.Job
+	request(t, h, http.MethodPost, "/api/summarize", "", http.StatusAccepted, &job)
+	if job.Type != jobs.TypeSummarize || string(job.Params) != `{"limit":0}` {
+		t.Errorf("got job %+v", job)
+	}
+	var c conflict
+	request(t, h, http.MethodPost, "/api/summarize", `{"limit": 5}`, http.StatusConflict, &c)
+	if c.JobID != job.ID {
+		t.Errorf("got conflict %+v, want one naming job %d", c, job.ID)
+	}
+
+	request(t, h, http.MethodGet, "/api/summarize", "", http.StatusMethodNotAllowed, nil)
+	for _, body := range []string{"{", `{"limit": -1}`, `{"limit": "all"}`} {
+		request(t, h, http.MethodPost, "/api/summarize", body, http.StatusBadRequest, nil)
+	}
+}
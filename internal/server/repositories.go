@@ -0,0 +1,211 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"star-sage/internal/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageSize is the number of repositories returned without a limit parameter.
+	defaultPageSize = 50
+	// maxPageSize bounds the limit parameter.
+	maxPageSize = 500
+)
+
+// requestError is an error caused by invalid request parameters.
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// handleGetRepositories lists the starred repositories one page at a time.
+//
+// Filters: q (free text), language, tag (name or ID), topic (name or ID) with
+// min_confidence, list (ID), min_stars, max_stars, archived, has_summary,
+// starred_after and starred_before (dates or RFC 3339 timestamps).
+// Sorting: sort (stars, starred_at, pushed_at, name or relevance) and order
+// (asc or desc). Pagination: limit (default 50, at most 500) and offset.
+func (h *apiHandler) handleGetRepositories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	query, err := h.parseRepoQuery(r.URL.Query())
+	if err != nil {
+		if reqErr, ok := err.(*requestError); ok {
+			writeError(w, reqErr.status, reqErr.message)
+		} else {
+			writeError(w, http.StatusInternalServerError, "Error fetching repositories")
+		}
+		return
+	}
+
+	page, err := h.store.QueryRepositories(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching repositories")
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseRepoQuery reads the query parameters of /api/repositories.
+func (h *apiHandler) parseRepoQuery(values url.Values) (db.RepoQuery, error) {
+	q := db.RepoQuery{
+		RepoFilter: db.RepoFilter{
+			Text:     strings.TrimSpace(values.Get("q")),
+			Language: values.Get("language"),
+		},
+		Sort:  values.Get("sort"),
+		Order: values.Get("order"),
+		Limit: defaultPageSize,
+	}
+	var err error
+
+	switch q.Sort {
+	case "", db.SortStars, db.SortStarredAt, db.SortPushedAt, db.SortName:
+	case db.SortRelevance:
+		if q.Text == "" {
+			return q, badRequest("Sorting by relevance requires q")
+		}
+	default:
+		return q, badRequest("Invalid sort: %s", q.Sort)
+	}
+	switch q.Order {
+	case "", db.OrderAsc, db.OrderDesc:
+	default:
+		return q, badRequest("Invalid order: %s", q.Order)
+	}
+
+	if q.Limit, err = intParam(values, "limit", defaultPageSize); err != nil {
+		return q, err
+	}
+	if q.Limit <= 0 || q.Limit > maxPageSize {
+		return q, badRequest("limit must be between 1 and %d", maxPageSize)
+	}
+	if q.Offset, err = intParam(values, "offset", 0); err != nil {
+		return q, err
+	}
+	if q.Offset < 0 {
+		return q, badRequest("offset must not be negative")
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **int
+	}{{"min_stars", &q.MinStars}, {"max_stars", &q.MaxStars}} {
+		if values.Get(p.name) == "" {
+			continue
+		}
+		n, err := intParam(values, p.name, 0)
+		if err != nil {
+			return q, err
+		}
+		*p.dest = &n
+	}
+	for _, p := range []struct {
+		name string
+		dest **bool
+	}{{"archived", &q.Archived}, {"has_summary", &q.HasSummary}} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, badRequest("Invalid %s", p.name)
+		}
+		*p.dest = &b
+	}
+	for _, p := range []struct {
+		name string
+		dest *string
+	}{{"starred_after", &q.StarredAfter}, {"starred_before", &q.StarredBefore}} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.dest, err = normalizeTimestamp(v); err != nil {
+			return q, badRequest("Invalid %s, use YYYY-MM-DD or RFC 3339", p.name)
+		}
+	}
+
+	if v := values.Get("tag"); v != "" {
+		tag, err := h.store.GetTagByName(v)
+		if err == nil && tag == nil {
+			if id, perr := strconv.ParseInt(v, 10, 64); perr == nil {
+				tag, err = h.store.GetTagByID(id)
+			}
+		}
+		if err != nil {
+			return q, err
+		}
+		if tag == nil {
+			return q, &requestError{status: http.StatusNotFound, message: "Tag not found"}
+		}
+		q.TagID = tag.ID
+	}
+	if v := values.Get("topic"); v != "" {
+		topic, err := h.store.GetTopicByName(v)
+		if err == nil && topic == nil {
+			if id, perr := strconv.ParseInt(v, 10, 64); perr == nil {
+				topic, err = h.store.GetTopicByID(id)
+			}
+		}
+		if err != nil {
+			return q, err
+		}
+		if topic == nil {
+			return q, &requestError{status: http.StatusNotFound, message: "Topic not found"}
+		}
+		q.TopicID = topic.ID
+		if v := values.Get("min_confidence"); v != "" {
+			if q.MinTopicConfidence, err = strconv.ParseFloat(v, 64); err != nil {
+				return q, badRequest("Invalid min_confidence")
+			}
+		}
+	}
+	if v := values.Get("list"); v != "" {
+		if q.ListID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return q, badRequest("Invalid list")
+		}
+	}
+	return q, nil
+}
+
+// intParam parses the integer query parameter name, or returns def if it is absent.
+func intParam(values url.Values, name string, def int) (int, error) {
+	v := values.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, badRequest("Invalid %s", name)
+	}
+	return n, nil
+}
+
+// normalizeTimestamp converts a date or RFC 3339 timestamp to the UTC form
+// GitHub uses, so it compares correctly with the stored starred_at values.
+func normalizeTimestamp(v string) (string, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t.Format("2006-01-02"), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z"), nil
+}
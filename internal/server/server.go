@@ -4,12 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"star-sage/frontend"
 	"star-sage/internal/ai"
+	"star-sage/internal/ask"
+	"star-sage/internal/config"
 	"star-sage/internal/db"
+	"star-sage/internal/events"
+	"star-sage/internal/jobs"
+	"star-sage/internal/search"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // writeJSON is a helper to write JSON responses.
@@ -30,46 +40,367 @@ func writeError(w http.ResponseWriter, status int, message string) {
 
 // apiHandler creates a http.HandlerFunc that shares a database connection.
 type apiHandler struct {
-	db *sql.DB
+	store  db.Store
+	ai     *ai.Registry
+	jobs   *jobs.Manager
+	events *events.Bus
+	// shutdown is closed when the server starts shutting down, ending
+	// long-lived responses such as event streams.
+	shutdown chan struct{}
+	// auth is set when API requests must be authenticated.
+	auth bool
 }
 
-// StartServer starts the web server on the given port.
-func StartServer(port int) error {
+// Defaults of the server options.
+const (
+	DefaultBind         = "127.0.0.1"
+	DefaultReadTimeout  = 30 * time.Second
+	DefaultWriteTimeout = 60 * time.Second
+	DefaultIdleTimeout  = 120 * time.Second
+
+	// shutdownTimeout bounds how long requests are drained and jobs are
+	// given to stop on shutdown.
+	shutdownTimeout = 15 * time.Second
+)
+
+// Options configures the web server.
+type Options struct {
+	// Bind is the address to listen on; it defaults to DefaultBind, i.e. only
+	// local connections.
+	Bind string
+	Port int
+	// ReadTimeout, WriteTimeout and IdleTimeout limit the time spent reading a
+	// request, writing its response and keeping an idle connection open. Zero
+	// selects the defaults. Streaming responses are exempt from WriteTimeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// AI resolves the providers for AI tasks.
+	AI *ai.Registry
+	// Workers is the number of background jobs run in parallel.
+	Workers int
+	// Proxy is used by sync jobs.
+	Proxy string
+	// SyncInterval, if positive, makes the server queue a sync of SyncSource
+	// this often.
+	SyncInterval time.Duration
+	SyncSource   string
+	// FrontendDir, if set, serves the web UI from this directory instead of
+	// the copy built into the binary, reloading the page when a file changes.
+	FrontendDir string
+	// Auth requires an API token or a browser login made with one for all API
+	// requests. At least one token must exist.
+	Auth bool
+}
+
+// StartServer starts the web server and the background job workers and runs
+// them until ctx is canceled. It then stops accepting connections, drains the
+// requests in flight, stops the running jobs so they resume on the next start
+// and closes the database.
+func StartServer(ctx context.Context, opts Options) error {
+	if opts.Bind == "" {
+		opts.Bind = DefaultBind
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = DefaultReadTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+
 	database, err := db.InitDB()
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
-	// The database connection is closed when the application exits.
-	// defer database.Close() is not used here as it would close immediately.
+	defer database.Close()
+	store := db.NewSQLiteStore(database)
+	if opts.Auth {
+		n, err := store.CountAPITokens()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("authentication is enabled but no tokens exist, create one with 'starsage serve token create'")
+		}
+	}
+
+	// Web UI
+	bus := events.NewBus()
+	var static *staticHandler
+	if opts.FrontendDir != "" {
+		static, err = newStaticHandler(os.DirFS(opts.FrontendDir), true)
+		if err != nil {
+			return fmt.Errorf("failed to load the web UI: %w", err)
+		}
+		fmt.Printf("Serving the web UI from %s with live reload.\n", opts.FrontendDir)
+		go watchFrontend(ctx, opts.FrontendDir, bus)
+	} else if static, err = newStaticHandler(frontend.Files, false); err != nil {
+		return fmt.Errorf("failed to load the web UI: %w", err)
+	}
 
-	h := &apiHandler{db: database}
+	// Jobs get their own context: they are stopped only after the requests
+	// in flight, which may still queue jobs, have been drained.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	manager := jobs.NewManager(store, opts.Workers, bus)
+	jobs.RegisterDefaults(manager, jobs.Deps{Store: store, AI: opts.AI, Proxy: opts.Proxy, Events: bus})
+	if err := manager.Start(jobsCtx); err != nil {
+		return fmt.Errorf("failed to start job workers: %w", err)
+	}
+	if opts.SyncInterval > 0 {
+		params := jobs.SyncParams{Source: opts.SyncSource}
+		if params.Source == "" {
+			params.Source = config.DefaultSourceName
+		}
+		fmt.Printf("Syncing %s every %s.\n", params.Source, opts.SyncInterval)
+		manager.Schedule(jobsCtx, opts.SyncInterval, jobs.TypeSync, params)
+	}
+
+	h := &apiHandler{store: store, ai: opts.AI, jobs: manager, events: bus, shutdown: make(chan struct{}), auth: opts.Auth}
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(opts.Bind, strconv.Itoa(opts.Port)),
+		Handler:           h.routes(static),
+		ReadHeaderTimeout: opts.ReadTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
+	srv.RegisterOnShutdown(func() { close(h.shutdown) })
+
+	if ip := net.ParseIP(opts.Bind); !opts.Auth && (ip == nil || !ip.IsLoopback()) {
+		fmt.Printf("Warning: listening on %s without --auth makes your library reachable and editable from other machines.\n", opts.Bind)
+	}
+	fmt.Printf("Server listening on http://%s\n", srv.Addr)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down, waiting for requests and jobs to finish...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Warning: not all requests finished: %v\n", err)
+	}
+
+	stopJobs()
+	stopped := make(chan struct{})
+	go func() {
+		manager.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		fmt.Println("Warning: some jobs did not stop in time; they will resume on the next start.")
+	}
+	fmt.Println("Server stopped.")
+	return nil
+}
+
+// routes returns the handler of all API endpoints, with static serving the
+// web UI, behind authentication if it is enabled.
+func (h *apiHandler) routes(static http.Handler) http.Handler {
 	mux := http.NewServeMux()
 
 	// API handlers
 	mux.HandleFunc("/api/repositories", h.handleGetRepositories)
-	mux.HandleFunc("/api/lists", h.handleLists) // Will handle GET (all) and POST
-	mux.HandleFunc("/api/lists/", h.handleListByID) // Will handle GET (by ID)
+	mux.HandleFunc("/api/repositories/", h.handleRepositoryByID) // /api/repositories/{id}/tags[/{tagId}]
+	mux.HandleFunc("/api/search", h.handleSearch)
+	mux.HandleFunc("/api/ask", h.handleAsk)
+	mux.HandleFunc("/api/export", h.handleExport)
+	mux.HandleFunc("/api/lists", h.handleLists)     // Will handle GET (all) and POST
+	mux.HandleFunc("/api/lists/", h.handleListByID) // GET, PUT, DELETE and /repositories/{repoId}
+	mux.HandleFunc("/api/topics", h.handleGetTopics)
+	mux.HandleFunc("/api/topics/", h.handleTopicRepositories) // GET /api/topics/{id}/repositories
+	mux.HandleFunc("/api/tags", h.handleTags)                 // GET (all) and POST
+	mux.HandleFunc("/api/tags/", h.handleTagByID)             // GET, PUT, DELETE, /merge and /repositories
+	mux.HandleFunc("/api/jobs", h.handleJobs)                 // GET (all) and POST
+	mux.HandleFunc("/api/jobs/", h.handleJobByID)             // GET, DELETE and POST /retry
+	mux.HandleFunc("/api/sync", h.handleSync)                 // POST
+	mux.HandleFunc("/api/summarize", h.handleSummarize)       // POST
+	mux.HandleFunc("/api/events", h.handleEvents)
+	mux.HandleFunc("/api/session", h.handleSession) // GET
+	mux.HandleFunc("/api/login", h.handleLogin)     // POST {"token"}
+	mux.HandleFunc("/api/logout", h.handleLogout)   // POST
+	mux.Handle("/", static)
 
-	// Static file server
-	mux.Handle("/", http.FileServer(http.Dir("./frontend")))
+	if h.auth {
+		return h.requireAuth(mux)
+	}
+	return mux
+}
 
-	addr := fmt.Sprintf(":%d", port)
-	fmt.Printf("Server listening on http://localhost%s\n", addr)
-	return http.ListenAndServe(addr, mux)
+// disableWriteTimeout lifts the server's write timeout for a streaming or
+// long-running response.
+func disableWriteTimeout(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		fmt.Printf("Warning: could not lift the write timeout: %v\n", err)
+	}
 }
 
-func (h *apiHandler) handleGetRepositories(w http.ResponseWriter, r *http.Request) {
+// handleSearch runs a hybrid keyword and semantic search.
+// Query parameters: q (required), limit, mode (hybrid, keyword or semantic),
+// keyword_weight and semantic_weight.
+func (h *apiHandler) handleSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
 		return
 	}
 
-	repos, err := db.GetAllRepositories(h.db)
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	opts := search.Options{Mode: q.Get("mode")}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+	if opts.KeywordWeight, err = parseWeight(q.Get("keyword_weight")); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid keyword_weight")
+		return
+	}
+	if opts.SemanticWeight, err = parseWeight(q.Get("semantic_weight")); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid semantic_weight")
+		return
+	}
+	switch opts.Mode {
+	case "", search.ModeHybrid, search.ModeKeyword, search.ModeSemantic:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid mode")
+		return
+	}
+	// A zero semantic weight leaves out the semantic search and its provider.
+	if opts.Mode != search.ModeKeyword && (opts.SemanticWeight == nil || *opts.SemanticWeight > 0) {
+		if opts.Embedding, err = h.ai.ProfileForTask(ai.TaskEmbed); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	resp, err := search.Search(r.Context(), h.store, query, opts)
+	if errors.Is(err, search.ErrNoSignal) {
+		writeError(w, http.StatusBadRequest, "At least one of keyword_weight and semantic_weight must be above zero")
+		return
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Error fetching repositories")
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Search failed: %v", err))
 		return
 	}
-	writeJSON(w, http.StatusOK, repos)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseWeight parses a non-negative search weight; it returns nil if v is empty.
+func parseWeight(v string) (*float64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, err
+	}
+	if f < 0 {
+		return nil, fmt.Errorf("weight must not be negative")
+	}
+	return &f, nil
+}
+
+type askRequest struct {
+	Question string `json:"question"`
+	Limit    int    `json:"limit"`
+	// Stream selects a text/event-stream response with the answer sent token by token.
+	Stream bool `json:"stream"`
+}
+
+// handleAsk answers a question about the starred repositories. Streaming
+// responses send a "sources" event, "token" events and a final "done" event
+// carrying the complete answer, or an "error" event.
+func (h *apiHandler) handleAsk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req askRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "Question is required")
+		return
+	}
+
+	provider, err := h.ai.ForTask(ai.TaskChat)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	embedding, err := h.ai.ProfileForTask(ai.TaskEmbed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	opts := ask.Options{Limit: req.Limit, Provider: provider, Embedding: embedding}
+	// Generating an answer can take longer than the write timeout.
+	disableWriteTimeout(w)
+
+	if !req.Stream {
+		answer, err := ask.Ask(r.Context(), h.store, req.Question, opts)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, answer)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(event string, data interface{}) {
+		writeSSE(w, event, data)
+		flusher.Flush()
+	}
+
+	opts.OnSources = func(sources []ask.Source) { send("sources", sources) }
+	opts.OnToken = func(token string) { send("token", token) }
+	answer, err := ask.Ask(r.Context(), h.store, req.Question, opts)
+	if err != nil {
+		send("error", map[string]string{"error": err.Error()})
+		return
+	}
+	send("done", answer)
+}
+
+// writeSSE writes one server-sent event with data encoded as JSON.
+func writeSSE(w http.ResponseWriter, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		fmt.Printf("Error encoding event %s: %v\n", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
 }
 
 func (h *apiHandler) handleLists(w http.ResponseWriter, r *http.Request) {
@@ -84,7 +415,7 @@ func (h *apiHandler) handleLists(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *apiHandler) handleGetLists(w http.ResponseWriter, r *http.Request) {
-	lists, err := db.GetLists(h.db)
+	lists, err := h.store.GetLists()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Error fetching lists")
 		return
@@ -97,90 +428,248 @@ type createListRequest struct {
 	Prompt string `json:"prompt"`
 }
 
+// handleCreateList creates a list and queues its classification. If the job
+// cannot be queued, the list is still created and the response (201 Created
+// instead of 202 Accepted) carries a warning.
 func (h *apiHandler) handleCreateList(w http.ResponseWriter, r *http.Request) {
 	var req createListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	if req.Name == "" || req.Prompt == "" {
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Prompt) == "" {
 		writeError(w, http.StatusBadRequest, "List name and prompt are required")
 		return
 	}
 
-	// For now, we'll do this synchronously. Asynchronous tasks can be added later.
 	fmt.Printf("Received request to create list '%s' with prompt: %s\n", req.Name, req.Prompt)
 
-	listID, err := db.CreateList(h.db, req.Name, req.Prompt)
+	listID, err := h.store.CreateList(req.Name, req.Prompt)
 	if err != nil {
-		// This could be a UNIQUE constraint violation, handle it gracefully.
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			writeError(w, http.StatusConflict, "A list with this name already exists")
-		} else {
-			writeError(w, http.StatusInternalServerError, "Failed to create list in database")
-		}
+		writeListError(w, err, "Failed to create list in database")
 		return
 	}
 
-	fmt.Printf("List '%s' created with ID: %d. Starting classification...\n", req.Name, listID)
-	// In a real app, this would be a background job.
-	// For simplicity, we run it in a goroutine and don't wait.
-	go h.runClassification(listID, req.Prompt)
+	h.events.Publish(events.ListChanged, events.ListRef{ID: listID})
+	job, err := h.jobs.Enqueue(jobs.TypeClassifyList, jobs.ClassifyListParams{ListID: listID})
+	if err != nil {
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "List created, but its classification could not be started.",
+			"list_id": listID,
+			"warning": fmt.Sprintf("could not start classification: %v", err),
+		})
+		return
+	}
+	fmt.Printf("List '%s' created with ID: %d. Classification queued as job %d.\n", req.Name, listID, job.ID)
 
 	writeJSON(w, http.StatusAccepted, map[string]interface{}{
 		"message": "List creation initiated. Classification is running in the background.",
 		"list_id": listID,
+		"job_id":  job.ID,
 	})
 }
 
-// runClassification is a helper to perform the AI classification in the background.
-func (h *apiHandler) runClassification(listID int64, prompt string) {
-	// This function runs in a goroutine, so it needs its own error handling.
-	fmt.Printf("Fetching all repositories for classification...\n")
-	repos, err := db.GetAllRepositories(h.db)
+type updateListRequest struct {
+	Name   *string `json:"name"`
+	Prompt *string `json:"prompt"`
+}
+
+// handleListByID serves /api/lists/{id}: GET lists its repositories, PUT
+// {"name"?, "prompt"?} updates the list and DELETE deletes it. A new prompt
+// starts a background re-classification; if it cannot be queued, the update
+// still stands and the response carries a warning. POST and DELETE on
+// /api/lists/{id}/repositories/{repoId} pin a repository to the list or
+// exclude it; classification respects both.
+func (h *apiHandler) handleListByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/lists/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		fmt.Printf("[Error][List %d] Failed to get repositories: %v\n", listID, err)
+		writeError(w, http.StatusBadRequest, "Invalid list ID")
 		return
 	}
 
-	// Note: In a real app, provider/model would come from config or the request.
-	// Here we hardcode it for simplicity.
-	provider := ai.NewOllamaProvider("llama3:8b", "", &http.Client{})
+	switch {
+	case len(parts) == 1:
+	case len(parts) == 3 && parts[1] == "repositories":
+		h.handleListRepository(w, r, id, parts[2])
+		return
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
 
-	fmt.Printf("[List %d] Classifying %d repositories...\n", listID, len(repos))
-	classifiedRepoIDs, err := ai.ClassifyRepositories(context.Background(), provider, prompt, repos)
+	switch r.Method {
+	case http.MethodGet:
+		repos, err := h.store.GetReposByListID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching repositories for the list")
+			return
+		}
+		writeJSON(w, http.StatusOK, repos)
+	case http.MethodPut:
+		h.handleUpdateList(w, r, id)
+	case http.MethodDelete:
+		if err := h.store.DeleteList(id); err != nil {
+			writeListError(w, err, "Failed to delete list")
+			return
+		}
+		h.events.Publish(events.ListChanged, events.ListRef{ID: id})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *apiHandler) handleUpdateList(w http.ResponseWriter, r *http.Request, id int64) {
+	var req updateListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	list, err := h.store.GetListByID(id)
 	if err != nil {
-		fmt.Printf("[Error][List %d] AI classification failed: %v\n", listID, err)
+		writeError(w, http.StatusInternalServerError, "Error fetching list")
+		return
+	}
+	if list == nil {
+		writeError(w, http.StatusNotFound, "List not found")
 		return
 	}
 
-	fmt.Printf("[List %d] Found %d matching repositories. Saving to database...\n", listID, len(classifiedRepoIDs))
-	if len(classifiedRepoIDs) > 0 {
-		if err := db.AddReposToList(h.db, listID, classifiedRepoIDs); err != nil {
-			fmt.Printf("[Error][List %d] Failed to add repos to list: %v\n", listID, err)
+	// Validate every field before changing anything, so that a bad request
+	// leaves the list as it was.
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "List name must not be empty")
+		return
+	}
+	if req.Prompt != nil && strings.TrimSpace(*req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "List prompt must not be empty")
+		return
+	}
+
+	promptChanged := req.Prompt != nil && *req.Prompt != list.Prompt
+	if req.Name != nil || promptChanged {
+		if err := h.store.UpdateList(id, req.Name, req.Prompt); err != nil {
+			writeListError(w, err, "Failed to update list")
+			return
+		}
+		h.events.Publish(events.ListChanged, events.ListRef{ID: id})
+	}
+	if promptChanged {
+		job, err := h.jobs.Enqueue(jobs.TypeClassifyList, jobs.ClassifyListParams{ListID: id})
+		if err != nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"message": "List updated, but its re-classification could not be started.",
+				"list_id": id,
+				"warning": fmt.Sprintf("could not start re-classification: %v", err),
+			})
 			return
 		}
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message": "List updated. Re-classification is running in the background.",
+			"list_id": id,
+			"job_id":  job.ID,
+		})
+		return
 	}
 
-	fmt.Printf("[List %d] Classification completed successfully.\n", listID)
+	if list, err = h.store.GetListByID(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching list")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
 }
 
-func (h *apiHandler) handleListByID(w http.ResponseWriter, r *http.Request) {
+// handleListRepository pins (POST) or excludes (DELETE) a repository of a list.
+func (h *apiHandler) handleListRepository(w http.ResponseWriter, r *http.Request, listID int64, repoIDStr string) {
+	repoID, err := strconv.ParseInt(repoIDStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid repository ID")
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		err = h.store.PinRepoToList(listID, repoID)
+	case http.MethodDelete:
+		err = h.store.ExcludeRepoFromList(listID, repoID)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if err != nil {
+		writeListError(w, err, "Failed to update list")
+		return
+	}
+	h.events.Publish(events.ListChanged, events.ListRef{ID: listID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeListError maps errors of the list functions to HTTP status codes.
+func writeListError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrListExists):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+func (h *apiHandler) handleGetTopics(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
 		return
 	}
 
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/lists/")
+	topics, err := h.store.GetTopics()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching topics")
+		return
+	}
+	writeJSON(w, http.StatusOK, topics)
+}
+
+// handleTopicRepositories lists the repositories of a topic, most confident
+// first. The optional min_confidence query parameter filters weak assignments.
+func (h *apiHandler) handleTopicRepositories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	idStr, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/topics/"), "/repositories")
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid list ID")
+		writeError(w, http.StatusBadRequest, "Invalid topic ID")
+		return
+	}
+
+	var minConfidence float64
+	if v := r.URL.Query().Get("min_confidence"); v != "" {
+		if minConfidence, err = strconv.ParseFloat(v, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid min_confidence")
+			return
+		}
+	}
+
+	topic, err := h.store.GetTopicByID(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching topic")
+		return
+	}
+	if topic == nil {
+		writeError(w, http.StatusNotFound, "Topic not found")
 		return
 	}
 
-	repos, err := db.GetReposByListID(h.db, id)
+	repos, err := h.store.GetReposByTopicID(id, minConfidence)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Error fetching repositories for the list")
+		writeError(w, http.StatusInternalServerError, "Error fetching repositories for the topic")
 		return
 	}
 	writeJSON(w, http.StatusOK, repos)
@@ -0,0 +1,415 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"star-sage/internal/events"
+	"star-sage/internal/jobs"
+	"star-sage/internal/search"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHandler returns an API handler on store. Jobs are queued in store
+// but not run.
+func newTestHandler(t *testing.T, store db.Store) *apiHandler {
+	t.Helper()
+	bus := events.NewBus()
+	manager := jobs.NewManager(store, 1, bus)
+	jobs.RegisterDefaults(manager, jobs.Deps{Store: store, Events: bus})
+	return &apiHandler{store: store, jobs: manager, events: bus, shutdown: make(chan struct{})}
+}
+
+// serve sends a request to the routes of h and returns the response.
+func serve(h *apiHandler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.routes(http.NotFoundHandler()).ServeHTTP(rec, req)
+	return rec
+}
+
+// request serves a request that must answer with status and decodes the
+// response into v, if it is not nil.
+func request(t *testing.T, h *apiHandler, method, path, body string, status int, v interface{}) {
+	t.Helper()
+	rec := serve(h, method, path, body, nil)
+	if rec.Code != status {
+		t.Fatalf("%s %s: got status %d (%s), want %d", method, path, rec.Code, strings.TrimSpace(rec.Body.String()), status)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: could not decode %s: %v", method, path, rec.Body, err)
+		}
+	}
+}
+
+func TestUpdateList(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantName   string
+		wantPrompt string
+	}{
+		{"rename", `{"name": "Renamed"}`, http.StatusOK, "Renamed", "developer tools"},
+		{"new prompt", `{"prompt": "command line tools"}`, http.StatusAccepted, "Tools", "command line tools"},
+		{"same prompt", `{"name": "Renamed", "prompt": "developer tools"}`, http.StatusOK, "Renamed", "developer tools"},
+		{"empty name", `{"name": " ", "prompt": "command line tools"}`, http.StatusBadRequest, "Tools", "developer tools"},
+		{"empty prompt", `{"name": "Renamed", "prompt": ""}`, http.StatusBadRequest, "Tools", "developer tools"},
+		{"duplicate name", `{"name": "Other", "prompt": "command line tools"}`, http.StatusConflict, "Tools", "developer tools"},
+		{"invalid body", `{"name": `, http.StatusBadRequest, "Tools", "developer tools"},
+	}
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				h := newTestHandler(t, store)
+				id, err := store.CreateList("Tools", "developer tools")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer store.DeleteList(id)
+				otherID, err := store.CreateList("Other", "anything else")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer store.DeleteList(otherID)
+				changes, unsubscribe := h.events.Subscribe()
+				defer unsubscribe()
+
+				rec := serve(h, http.MethodPut, fmt.Sprintf("/api/lists/%d", id), tt.body, nil)
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("got status %d (%s), want %d", rec.Code, rec.Body, tt.wantStatus)
+				}
+				list, err := store.GetListByID(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if list.Name != tt.wantName || list.Prompt != tt.wantPrompt {
+					t.Errorf("list is %q with prompt %q, want %q with prompt %q", list.Name, list.Prompt, tt.wantName, tt.wantPrompt)
+				}
+				if rec.Code >= 400 && len(changes) > 0 {
+					t.Errorf("a rejected update published %d events", len(changes))
+				}
+				if rec.Code == http.StatusAccepted {
+					var resp map[string]interface{}
+					if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp["job_id"] == nil {
+						t.Errorf("response %s has no job_id", rec.Body)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestListChangesWithoutClassification(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		// Without the classify_list type every job fails to queue.
+		h := &apiHandler{store: store, jobs: jobs.NewManager(store, 1, nil), events: events.NewBus(), shutdown: make(chan struct{})}
+
+		var resp struct {
+			ListID  int64  `json:"list_id"`
+			JobID   int64  `json:"job_id"`
+			Warning string `json:"warning"`
+		}
+		request(t, h, http.MethodPost, "/api/lists", `{"name": "Tools", "prompt": "developer tools"}`, http.StatusCreated, &resp)
+		if resp.ListID == 0 || resp.JobID != 0 || resp.Warning == "" {
+			t.Fatalf("list creation returned %+v, want a list and a warning", resp)
+		}
+		if list, err := store.GetListByID(resp.ListID); err != nil || list == nil {
+			t.Fatalf("created list: got %+v, %v", list, err)
+		}
+
+		resp.Warning = ""
+		path := fmt.Sprintf("/api/lists/%d", resp.ListID)
+		request(t, h, http.MethodPut, path, `{"name": "CLI", "prompt": "command line tools"}`, http.StatusOK, &resp)
+		if resp.Warning == "" {
+			t.Errorf("update returned %+v, want a warning", resp)
+		}
+		list, err := store.GetListByID(resp.ListID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list.Name != "CLI" || list.Prompt != "command line tools" {
+			t.Errorf("list is %q with prompt %q, want both fields updated", list.Name, list.Prompt)
+		}
+	})
+}
+
+func TestListsAPI(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		cli := store.AddRepository(db.Repository{FullName: "owner/cli", StargazersCount: 10})
+		web := store.AddRepository(db.Repository{FullName: "owner/web", StargazersCount: 20})
+
+		request(t, h, http.MethodPost, "/api/lists", `{"name": "Tools"}`, http.StatusBadRequest, nil)
+		request(t, h, http.MethodPost, "/api/lists", `{"name": " ", "prompt": "developer tools"}`, http.StatusBadRequest, nil)
+		var created struct {
+			ListID int64 `json:"list_id"`
+			JobID  int64 `json:"job_id"`
+		}
+		request(t, h, http.MethodPost, "/api/lists", `{"name": "Tools", "prompt": "developer tools"}`, http.StatusAccepted, &created)
+		if created.ListID == 0 || created.JobID == 0 {
+			t.Fatalf("list creation returned %+v, want a list and a job", created)
+		}
+		request(t, h, http.MethodPost, "/api/lists", `{"name": "Tools", "prompt": "again"}`, http.StatusConflict, nil)
+
+		path := fmt.Sprintf("/api/lists/%d", created.ListID)
+		request(t, h, http.MethodPost, fmt.Sprintf("%s/repositories/%d", path, cli), "", http.StatusNoContent, nil)
+		request(t, h, http.MethodDelete, fmt.Sprintf("%s/repositories/%d", path, web), "", http.StatusNoContent, nil)
+		request(t, h, http.MethodPost, path+"/repositories/9999", "", http.StatusNotFound, nil)
+		request(t, h, http.MethodPost, "/api/lists/9999/repositories/1", "", http.StatusNotFound, nil)
+
+		// Classification keeps the pinned repository and cannot add the excluded one.
+		if err := store.ReplaceListClassification(created.ListID, []int64{web}); err != nil {
+			t.Fatal(err)
+		}
+		var members []db.ListRepository
+		request(t, h, http.MethodGet, path, "", http.StatusOK, &members)
+		if len(members) != 1 || members[0].ID != cli || members[0].Membership != db.ListMembershipPinned {
+			t.Errorf("list members = %+v, want owner/cli pinned", members)
+		}
+
+		var lists []db.List
+		request(t, h, http.MethodGet, "/api/lists", "", http.StatusOK, &lists)
+		if len(lists) != 1 || lists[0].Name != "Tools" || lists[0].RepoCount != 1 {
+			t.Errorf("lists = %+v, want Tools with 1 repository", lists)
+		}
+
+		request(t, h, http.MethodDelete, path, "", http.StatusNoContent, nil)
+		request(t, h, http.MethodDelete, path, "", http.StatusNotFound, nil)
+	})
+}
+
+func TestRepositoriesAPI(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		store.AddRepository(db.Repository{FullName: "owner/small", Language: "Go", StargazersCount: 5, Description: "A small parser"})
+		store.AddRepository(db.Repository{FullName: "owner/big", Language: "Go", StargazersCount: 500, Description: "A big server"})
+		store.AddRepository(db.Repository{FullName: "owner/rusty", Language: "Rust", StargazersCount: 50, Description: "A parser in Rust"})
+
+		tests := []struct {
+			query string
+			want  []string
+			total int
+		}{
+			{"", []string{"owner/big", "owner/rusty", "owner/small"}, 3},
+			{"language=go", []string{"owner/big", "owner/small"}, 2},
+			{"sort=stars&order=asc&limit=2", []string{"owner/small", "owner/rusty"}, 3},
+			{"limit=1&offset=1", []string{"owner/rusty"}, 3},
+			{"min_stars=10&max_stars=100", []string{"owner/rusty"}, 1},
+			{"q=parser&sort=name", []string{"owner/rusty", "owner/small"}, 2},
+			{"sort=name", []string{"owner/big", "owner/rusty", "owner/small"}, 3},
+		}
+		for _, tt := range tests {
+			var page db.RepoPage
+			request(t, h, http.MethodGet, "/api/repositories?"+tt.query, "", http.StatusOK, &page)
+			var names []string
+			for _, r := range page.Repositories {
+				names = append(names, r.FullName)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) || page.Total != tt.total {
+				t.Errorf("%q: got %v of %d, want %v of %d", tt.query, names, page.Total, tt.want, tt.total)
+			}
+		}
+
+		for _, query := range []string{"sort=size", "limit=x", "min_stars=many", "sort=relevance", "tag=missing"} {
+			rec := serve(h, http.MethodGet, "/api/repositories?"+query, "", nil)
+			if rec.Code < 400 || rec.Code >= 500 {
+				t.Errorf("%q: got status %d, want a client error", query, rec.Code)
+			}
+		}
+	})
+}
+
+func TestRepositoryTagsAPI(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		id := store.AddRepository(db.Repository{FullName: "owner/cli"})
+		store.AddRepository(db.Repository{FullName: "owner/web"})
+		path := fmt.Sprintf("/api/repositories/%d/tags", id)
+
+		var tags []db.Tag
+		request(t, h, http.MethodPost, path, `{"name": "cli"}`, http.StatusOK, &tags)
+		if len(tags) != 1 || tags[0].Name != "cli" {
+			t.Fatalf("tags = %+v, want cli", tags)
+		}
+		tagID := tags[0].ID
+		request(t, h, http.MethodPost, path, `{"name": "a,b"}`, http.StatusBadRequest, nil)
+		request(t, h, http.MethodPost, "/api/repositories/9999/tags", `{"name": "cli"}`, http.StatusNotFound, nil)
+
+		var page db.RepoPage
+		request(t, h, http.MethodGet, "/api/repositories?tag=cli", "", http.StatusOK, &page)
+		if len(page.Repositories) != 1 || page.Repositories[0].ID != id {
+			t.Errorf("repositories tagged cli = %+v, want owner/cli", page.Repositories)
+		}
+
+		var all []db.Tag
+		request(t, h, http.MethodGet, "/api/tags", "", http.StatusOK, &all)
+		if len(all) != 1 || all[0].RepoCount != 1 {
+			t.Errorf("tags = %+v, want cli on 1 repository", all)
+		}
+
+		request(t, h, http.MethodDelete, fmt.Sprintf("%s/%d", path, tagID), "", http.StatusNoContent, nil)
+		request(t, h, http.MethodGet, path, "", http.StatusOK, &tags)
+		if len(tags) != 0 {
+			t.Errorf("tags after removal = %+v, want none", tags)
+		}
+	})
+}
+
+func TestSearchAPIWeights(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		store.AddRepository(db.Repository{FullName: "owner/parser", Description: "A fast parser"})
+
+		// Without semantic weight, no embedding provider is needed.
+		var resp search.Response
+		request(t, h, http.MethodGet, "/api/search?q=parser&semantic_weight=0", "", http.StatusOK, &resp)
+		if resp.Mode != search.ModeKeyword || len(resp.Results) != 1 {
+			t.Errorf("search = %+v, want one keyword result", resp)
+		}
+		for _, query := range []string{"keyword_weight=0&semantic_weight=0", "mode=keyword&keyword_weight=0", "keyword_weight=-1", "semantic_weight=x"} {
+			request(t, h, http.MethodGet, "/api/search?q=parser&"+query, "", http.StatusBadRequest, nil)
+		}
+	})
+}
+
+func TestAskAPIValidation(t *testing.T) {
+	h := newTestHandler(t, db.NewMemoryStore())
+	request(t, h, http.MethodGet, "/api/ask", "", http.StatusMethodNotAllowed, nil)
+	for _, body := range []string{"", "{", `{"question": "  "}`} {
+		request(t, h, http.MethodPost, "/api/ask", body, http.StatusBadRequest, nil)
+	}
+}
+
+func TestTopicsAPI(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		a := store.AddRepository(db.Repository{FullName: "owner/a"})
+		b := store.AddRepository(db.Repository{FullName: "owner/b"})
+		err := store.ReplaceTopics([]db.Topic{{Name: "cli"}}, []db.TopicAssignment{
+			{RepoID: a, Topic: "cli", Confidence: 1, Source: db.TopicSourceGitHub},
+			{RepoID: b, Topic: "cli", Confidence: 0.5, Source: db.TopicSourceAI},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var topics []db.Topic
+		request(t, h, http.MethodGet, "/api/topics", "", http.StatusOK, &topics)
+		if len(topics) != 1 || topics[0].Name != "cli" || topics[0].RepoCount != 2 {
+			t.Fatalf("got topics %+v, want cli with 2 repositories", topics)
+		}
+		path := fmt.Sprintf("/api/topics/%d/repositories", topics[0].ID)
+		var repos []db.TopicRepository
+		request(t, h, http.MethodGet, path, "", http.StatusOK, &repos)
+		if len(repos) != 2 || repos[0].FullName != "owner/a" || repos[0].AssignedBy != db.TopicSourceGitHub {
+			t.Errorf("got repositories %+v, want owner/a first", repos)
+		}
+		request(t, h, http.MethodGet, path+"?min_confidence=0.8", "", http.StatusOK, &repos)
+		if len(repos) != 1 {
+			t.Errorf("got %d repositories with min_confidence, want 1", len(repos))
+		}
+
+		request(t, h, http.MethodPost, "/api/topics", "", http.StatusMethodNotAllowed, nil)
+		request(t, h, http.MethodGet, path+"?min_confidence=high", "", http.StatusBadRequest, nil)
+		request(t, h, http.MethodGet, "/api/topics/x/repositories", "", http.StatusBadRequest, nil)
+		request(t, h, http.MethodGet, "/api/topics/9999/repositories", "", http.StatusNotFound, nil)
+		request(t, h, http.MethodGet, fmt.Sprintf("/api/topics/%d", topics[0].ID), "", http.StatusNotFound, nil)
+	})
+}
+
+// useTestDB makes the server open a new database in a temporary directory.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	config.SetDBPath(filepath.Join(t.TempDir(), "stars.db"))
+	t.Cleanup(func() { config.SetDBPath("") })
+}
+
+// freePort returns a local port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestStartServerShutdown(t *testing.T) {
+	useTestDB(t)
+	port := freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- StartServer(ctx, Options{Port: port, Workers: 1}) }()
+
+	// The server binds the loopback interface by default.
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(base + "/api/jobs")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /api/jobs: got status %d", resp.StatusCode)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// An open event stream does not hold up the shutdown.
+	stream, err := http.Get(base + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	body := bufio.NewReader(stream.Body)
+	if _, err := body.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("StartServer returned %v", err)
+		}
+	case <-time.After(shutdownTimeout / 2):
+		t.Fatal("server did not shut down")
+	}
+	if _, err := io.ReadAll(body); err != nil {
+		t.Errorf("event stream did not end cleanly: %v", err)
+	}
+	if resp, err := http.Get(base + "/api/jobs"); err == nil {
+		resp.Body.Close()
+		t.Error("server still accepts requests after the shutdown")
+	}
+}
+
+func TestStartServerAuthWithoutTokens(t *testing.T) {
+	useTestDB(t)
+	err := StartServer(context.Background(), Options{Port: freePort(t), Auth: true})
+	if err == nil || !strings.Contains(err.Error(), "no tokens exist") {
+		t.Errorf("got %v, want an error for authentication without tokens", err)
+	}
+}
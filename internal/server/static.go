@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"star-sage/internal/events"
+)
+
+const (
+	// indexFile is served for the root path.
+	indexFile = "index.html"
+	// frontendPollInterval is how often a development frontend directory is
+	// checked for changes.
+	frontendPollInterval = 500 * time.Millisecond
+)
+
+// liveReloadScript is added to index.html when the frontend is served from a
+// directory. It reloads the page whenever a file changes.
+const liveReloadScript = `<script>
+new EventSource('/api/events?types=frontend.changed').addEventListener('frontend.changed', () => location.reload());
+</script>
+`
+
+// asset is a file of the web UI.
+type asset struct {
+	content []byte
+	// hash identifies the content. It is the ETag and the version in asset
+	// URLs, which makes them safe to cache indefinitely.
+	hash string
+}
+
+// staticHandler serves the web UI. index.html refers to the other assets with
+// their content hash, e.g. script.js?v=1a2b3c, so browsers revalidate
+// index.html on every visit but never fetch an unchanged asset twice. In
+// development mode the files are read again on every request and nothing is
+// cached.
+type staticHandler struct {
+	fsys   fs.FS
+	dev    bool
+	assets map[string]asset
+}
+
+// newStaticHandler serves the files of fsys. With dev set they are reloaded
+// on every request and index.html reloads itself when they change.
+func newStaticHandler(fsys fs.FS, dev bool) (*staticHandler, error) {
+	h := &staticHandler{fsys: fsys, dev: dev}
+	assets, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	if !dev {
+		h.assets = assets
+	}
+	return h, nil
+}
+
+// load reads all assets and links index.html to their current versions.
+func (h *staticHandler) load() (map[string]asset, error) {
+	assets := make(map[string]asset)
+	err := fs.WalkDir(h.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(name, ".go") {
+			return err
+		}
+		content, err := fs.ReadFile(h.fsys, name)
+		if err != nil {
+			return err
+		}
+		assets[name] = newAsset(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read frontend files: %w", err)
+	}
+
+	index, ok := assets[indexFile]
+	if !ok {
+		return nil, fmt.Errorf("frontend has no %s", indexFile)
+	}
+	html := string(index.content)
+	for name, a := range assets {
+		if name != indexFile {
+			html = strings.ReplaceAll(html, `"`+name+`"`, `"`+name+`?v=`+a.hash+`"`)
+		}
+	}
+	if h.dev {
+		html = strings.Replace(html, "</body>", liveReloadScript+"</body>", 1)
+	}
+	assets[indexFile] = newAsset([]byte(html))
+	return assets, nil
+}
+
+// newAsset returns an asset with the hash of content.
+func newAsset(content []byte) asset {
+	sum := sha256.Sum256(content)
+	return asset{content: content, hash: hex.EncodeToString(sum[:])[:12]}
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	assets := h.assets
+	if h.dev {
+		var err error
+		if assets, err = h.load(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = indexFile
+	}
+	a, ok := assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case h.dev:
+		w.Header().Set("Cache-Control", "no-store")
+	case name != indexFile && r.URL.Query().Get("v") == a.hash:
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", `"`+a.hash+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(a.content))
+}
+
+// watchFrontend publishes events.FrontendChanged whenever a file below dir is
+// added, removed or modified, until ctx is canceled.
+func watchFrontend(ctx context.Context, dir string, bus *events.Bus) {
+	last := snapshotDir(dir)
+	ticker := time.NewTicker(frontendPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if current := snapshotDir(dir); current != last {
+			last = current
+			bus.Publish(events.FrontendChanged, nil)
+		}
+	}
+}
+
+// snapshotDir describes the names, sizes and modification times of the files
+// below dir, so that comparing two snapshots detects changes.
+func snapshotDir(dir string) string {
+	var entries []string
+	fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			entries = append(entries, fmt.Sprintf("%s %d %d", name, info.Size(), info.ModTime().UnixNano()))
+		}
+		return nil
+	})
+	return strings.Join(entries, "\n")
+}
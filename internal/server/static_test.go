@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFrontend returns a web UI whose index.html links to app.js and style.css.
+func testFrontend() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html": {Data: []byte(`<html><head><link href="style.css"><script src="app.js"></script></head>` +
+			`<body><a href="app.js.map">map</a> "img/app.js"</body></html>`)},
+		"app.js":       {Data: []byte("console.log('hi');")},
+		"style.css":    {Data: []byte("body {}")},
+		"embed.go":     {Data: []byte("package frontend")},
+		"app.js.map":   {Data: []byte("{}")},
+		"img/logo.svg": {Data: []byte("<svg/>")},
+	}
+}
+
+// get serves a GET request for target with h.
+func get(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticHandlerRewritesAssets(t *testing.T) {
+	fsys := testFrontend()
+	h, err := newStaticHandler(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appHash := newAsset(fsys["app.js"].Data).hash
+	cssHash := newAsset(fsys["style.css"].Data).hash
+
+	index := get(h, "/", nil).Body.String()
+	for _, want := range []string{`src="app.js?v=` + appHash + `"`, `href="style.css?v=` + cssHash + `"`} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html does not contain %s:\n%s", want, index)
+		}
+	}
+	// Only quoted names of whole assets are rewritten.
+	for _, want := range []string{`href="app.js.map?v=`, `"img/app.js"`} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html does not contain %s:\n%s", want, index)
+		}
+	}
+	if strings.Contains(index, "EventSource") {
+		t.Error("index.html has the live reload script outside of development mode")
+	}
+
+	if rec := get(h, "/embed.go", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("embed.go: got status %d, want 404", rec.Code)
+	}
+}
+
+func TestStaticHandlerCaching(t *testing.T) {
+	fsys := testFrontend()
+	h, err := newStaticHandler(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appHash := newAsset(fsys["app.js"].Data).hash
+
+	tests := []struct {
+		target    string
+		wantCache string
+	}{
+		{"/", "no-cache"},
+		{"/index.html", "no-cache"},
+		{"/app.js?v=" + appHash, "public, max-age=31536000, immutable"},
+		// An outdated or missing version must be revalidated.
+		{"/app.js?v=0123456789ab", "no-cache"},
+		{"/app.js", "no-cache"},
+	}
+	for _, tt := range tests {
+		rec := get(h, tt.target, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", tt.target, rec.Code)
+			continue
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.target, got, tt.wantCache)
+		}
+	}
+
+	rec := get(h, "/app.js", http.Header{"If-None-Match": {`"` + appHash + `"`}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("revalidating app.js: got status %d, want 304", rec.Code)
+	}
+	if rec := get(h, "/missing.js", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing.js: got status %d, want 404", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	post := httptest.NewRecorder()
+	h.ServeHTTP(post, req)
+	if post.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d, want 405", post.Code)
+	}
+}
+
+func TestStaticHandlerDev(t *testing.T) {
+	fsys := testFrontend()
+	h, err := newStaticHandler(fsys, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := get(h, "/", nil)
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if !strings.Contains(rec.Body.String(), liveReloadScript+"</body>") {
+		t.Errorf("index.html has no live reload script:\n%s", rec.Body)
+	}
+
+	// Changed files are served, and linked, without a restart.
+	fsys["app.js"] = &fstest.MapFile{Data: []byte("console.log('changed');")}
+	if body := get(h, "/app.js", nil).Body.String(); body != "console.log('changed');" {
+		t.Errorf("app.js = %q, want the changed file", body)
+	}
+	want := `src="app.js?v=` + newAsset(fsys["app.js"].Data).hash + `"`
+	if index := get(h, "/", nil).Body.String(); !strings.Contains(index, want) {
+		t.Errorf("index.html does not contain %s:\n%s", want, index)
+	}
+}
+
+func TestNewStaticHandlerWithoutIndex(t *testing.T) {
+	fsys := testFrontend()
+	delete(fsys, "index.html")
+	if _, err := newStaticHandler(fsys, false); err == nil {
+		t.Error("got no error for a frontend without index.html")
+	}
+}
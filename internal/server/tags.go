@@ -0,0 +1,241 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"star-sage/internal/db"
+	"strconv"
+	"strings"
+)
+
+type tagRequest struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+type mergeTagRequest struct {
+	Into int64 `json:"into"`
+}
+
+type repoTagRequest struct {
+	TagID int64  `json:"tag_id"`
+	Name  string `json:"name"`
+}
+
+// writeTagError maps errors of the tag functions to HTTP status codes.
+func writeTagError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrInvalidTagName):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, db.ErrTagExists):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// handleTags lists all tags (GET) or creates one (POST {"name", "color"}).
+func (h *apiHandler) handleTags(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := h.store.GetTags()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tags")
+			return
+		}
+		writeJSON(w, http.StatusOK, tags)
+	case http.MethodPost:
+		var req tagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil {
+			writeError(w, http.StatusBadRequest, "Tag name is required")
+			return
+		}
+		color := ""
+		if req.Color != nil {
+			color = *req.Color
+		}
+		id, err := h.store.CreateTag(*req.Name, color)
+		if err != nil {
+			writeTagError(w, err, "Failed to create tag")
+			return
+		}
+		tag, err := h.store.GetTagByID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tag")
+			return
+		}
+		writeJSON(w, http.StatusCreated, tag)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleTagByID serves /api/tags/{id} (GET, PUT {"name"?, "color"?}, DELETE),
+// POST /api/tags/{id}/merge {"into": id} and GET /api/tags/{id}/repositories.
+func (h *apiHandler) handleTagByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/tags/"), "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		tag, err := h.store.GetTagByID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tag")
+			return
+		}
+		if tag == nil {
+			writeError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, tag)
+	case action == "" && r.Method == http.MethodPut:
+		var req tagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := h.store.UpdateTag(id, req.Name, req.Color); err != nil {
+			writeTagError(w, err, "Failed to update tag")
+			return
+		}
+		tag, err := h.store.GetTagByID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tag")
+			return
+		}
+		if tag == nil {
+			writeError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, tag)
+	case action == "" && r.Method == http.MethodDelete:
+		if err := h.store.DeleteTag(id); err != nil {
+			writeTagError(w, err, "Failed to delete tag")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "merge" && r.Method == http.MethodPost:
+		var req mergeTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == 0 {
+			writeError(w, http.StatusBadRequest, "Target tag ID is required")
+			return
+		}
+		if req.Into == id {
+			writeError(w, http.StatusBadRequest, "Cannot merge a tag into itself")
+			return
+		}
+		if err := h.store.MergeTags(id, req.Into); err != nil {
+			writeTagError(w, err, "Failed to merge tags")
+			return
+		}
+		tag, err := h.store.GetTagByID(req.Into)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tag")
+			return
+		}
+		writeJSON(w, http.StatusOK, tag)
+	case action == "repositories" && r.Method == http.MethodGet:
+		tag, err := h.store.GetTagByID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching tag")
+			return
+		}
+		if tag == nil {
+			writeError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		repos, err := h.store.GetReposByTagID(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Error fetching repositories for the tag")
+			return
+		}
+		writeJSON(w, http.StatusOK, repos)
+	case action == "" || action == "merge" || action == "repositories":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// handleRepositoryByID serves the tags of a repository:
+// GET /api/repositories/{id}/tags, POST /api/repositories/{id}/tags
+// {"tag_id"} or {"name"} (creating the tag if needed) and
+// DELETE /api/repositories/{id}/tags/{tagId}.
+func (h *apiHandler) handleRepositoryByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/repositories/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "tags" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	repoID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid repository ID")
+		return
+	}
+	repo, err := h.store.GetRepositoryByID(repoID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching repository")
+		return
+	}
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Repository not found")
+		return
+	}
+
+	if len(parts) == 3 {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, "Only DELETE method is allowed")
+			return
+		}
+		tagID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid tag ID")
+			return
+		}
+		if err := h.store.UntagRepository(repoID, tagID); err != nil {
+			writeTagError(w, err, "Failed to remove tag")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req repoTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		tagID := req.TagID
+		if tagID == 0 {
+			if tagID, err = h.store.GetOrCreateTag(req.Name); err != nil {
+				writeTagError(w, err, "Failed to create tag")
+				return
+			}
+		}
+		if err := h.store.TagRepository(repoID, tagID); err != nil {
+			writeTagError(w, err, "Failed to add tag")
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tags, err := h.store.GetRepoTags(repoID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Error fetching tags")
+		return
+	}
+	writeJSON(w, http.StatusOK, tags)
+}
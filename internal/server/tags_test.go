@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"testing"
+)
+
+func TestTagsAPIConflicts(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		var cli, web db.Tag
+		request(t, h, http.MethodPost, "/api/tags", `{"name": "cli", "color": "#ff0000"}`, http.StatusCreated, &cli)
+		request(t, h, http.MethodPost, "/api/tags", `{"name": " web "}`, http.StatusCreated, &web)
+		if web.Name != "web" {
+			t.Errorf("created tag %q, want the trimmed name", web.Name)
+		}
+
+		tests := []struct {
+			name       string
+			method     string
+			path       string
+			body       string
+			wantStatus int
+		}{
+			{"create duplicate", http.MethodPost, "/api/tags", `{"name": "cli"}`, http.StatusConflict},
+			{"create duplicate ignoring case", http.MethodPost, "/api/tags", `{"name": " CLI "}`, http.StatusConflict},
+			{"create with comma", http.MethodPost, "/api/tags", `{"name": "a,b"}`, http.StatusBadRequest},
+			{"create without name", http.MethodPost, "/api/tags", `{"color": "#fff"}`, http.StatusBadRequest},
+			{"rename to another tag", http.MethodPut, fmt.Sprintf("/api/tags/%d", web.ID), `{"name": "CLI"}`, http.StatusConflict},
+			{"rename to another tag with a color", http.MethodPut, fmt.Sprintf("/api/tags/%d", web.ID), `{"name": "cli", "color": "#00ff00"}`, http.StatusConflict},
+			{"recolor with an empty name", http.MethodPut, fmt.Sprintf("/api/tags/%d", web.ID), `{"name": "", "color": "#00ff00"}`, http.StatusBadRequest},
+			{"rename to empty", http.MethodPut, fmt.Sprintf("/api/tags/%d", web.ID), `{"name": " "}`, http.StatusBadRequest},
+			{"rename missing", http.MethodPut, "/api/tags/9999", `{"name": "other"}`, http.StatusNotFound},
+			{"merge into itself", http.MethodPost, fmt.Sprintf("/api/tags/%d/merge", web.ID), fmt.Sprintf(`{"into": %d}`, web.ID), http.StatusBadRequest},
+			{"merge without target", http.MethodPost, fmt.Sprintf("/api/tags/%d/merge", web.ID), `{}`, http.StatusBadRequest},
+			{"merge into missing", http.MethodPost, fmt.Sprintf("/api/tags/%d/merge", web.ID), `{"into": 9999}`, http.StatusNotFound},
+			{"merge missing", http.MethodPost, "/api/tags/9999/merge", fmt.Sprintf(`{"into": %d}`, web.ID), http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				request(t, h, tt.method, tt.path, tt.body, tt.wantStatus, nil)
+			})
+		}
+
+		// Rejected changes leave both tags as they were.
+		var tags []db.Tag
+		request(t, h, http.MethodGet, "/api/tags", "", http.StatusOK, &tags)
+		if len(tags) != 2 || tags[0].Name != "cli" || tags[0].Color != "#ff0000" || tags[1].Name != "web" || tags[1].Color != "" {
+			t.Errorf("tags after rejected changes = %+v, want cli and web", tags)
+		}
+
+		// A tag can be renamed to a different case of its own name.
+		var renamed db.Tag
+		request(t, h, http.MethodPut, fmt.Sprintf("/api/tags/%d", cli.ID), `{"name": "CLI"}`, http.StatusOK, &renamed)
+		if renamed.ID != cli.ID || renamed.Name != "CLI" || renamed.Color != "#ff0000" {
+			t.Errorf("renamed tag = %+v, want CLI with its color", renamed)
+		}
+	})
+}
+
+func TestMergeTagsAPI(t *testing.T) {
+	dbtest.ForEach(t, func(t *testing.T, store dbtest.Store) {
+		h := newTestHandler(t, store)
+		both := store.AddRepository(db.Repository{FullName: "owner/both"})
+		webOnly := store.AddRepository(db.Repository{FullName: "owner/web"})
+		cliID, err := store.CreateTag("cli", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		webID, err := store.CreateTag("web", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rt := range [][2]int64{{both, cliID}, {both, webID}, {webOnly, webID}} {
+			if err := store.TagRepository(rt[0], rt[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var into db.Tag
+		request(t, h, http.MethodPost, fmt.Sprintf("/api/tags/%d/merge", webID), fmt.Sprintf(`{"into": %d}`, cliID), http.StatusOK, &into)
+		if into.ID != cliID {
+			t.Errorf("merge returned %+v, want the target tag", into)
+		}
+		request(t, h, http.MethodGet, fmt.Sprintf("/api/tags/%d", webID), "", http.StatusNotFound, nil)
+
+		var tags []db.Tag
+		request(t, h, http.MethodGet, "/api/tags", "", http.StatusOK, &tags)
+		if len(tags) != 1 || tags[0].ID != cliID || tags[0].RepoCount != 2 {
+			t.Errorf("tags after merge = %+v, want cli on 2 repositories", tags)
+		}
+		for _, id := range []int64{both, webOnly} {
+			var repoTags []db.Tag
+			request(t, h, http.MethodGet, fmt.Sprintf("/api/repositories/%d/tags", id), "", http.StatusOK, &repoTags)
+			if len(repoTags) != 1 || repoTags[0].ID != cliID {
+				t.Errorf("tags of repository %d = %+v, want only cli", id, repoTags)
+			}
+			repo, err := store.GetRepositoryByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(repo.Tags) != 1 || repo.Tags[0] != "cli" {
+				t.Errorf("repository %d lists tags %v, want [cli]", id, repo.Tags)
+			}
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"star-sage/internal/config"
+	"star-sage/internal/gh"
+)
+
+// giteaPageSize is the page size requested from /user/starred; Gitea caps it
+// at the instance's MAX_RESPONSE_ITEMS, 50 by default.
+const giteaPageSize = 50
+
+// readmeNames are tried in order when a source has no README endpoint.
+var readmeNames = []string{"README.md", "readme.md", "README", "README.rst", "README.txt"}
+
+// Gitea lists stars from a Gitea or Forgejo instance through its v1 API.
+type Gitea struct {
+	typ     string
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+func newGitea(cfg config.SourceConfig, opts Options) (*Gitea, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("source %s: base_url is required for %s", cfg.Name, cfg.Type)
+	}
+	client, err := gh.NewClient(opts.Proxy, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	return &Gitea{
+		typ:     cfg.Type,
+		baseURL: baseURL,
+		key:     hostKey(cfg.Type, baseURL, ""),
+		client:  client,
+	}, nil
+}
+
+// giteaRepo is a repository as returned by the Gitea API.
+type giteaRepo struct {
+	ID            int64    `json:"id"`
+	FullName      string   `json:"full_name"`
+	Description   string   `json:"description"`
+	HTMLURL       string   `json:"html_url"`
+	Language      string   `json:"language"`
+	StarsCount    int      `json:"stars_count"`
+	Archived      bool     `json:"archived"`
+	Fork          bool     `json:"fork"`
+	UpdatedAt     string   `json:"updated_at"`
+	Topics        []string `json:"topics"`
+	DefaultBranch string   `json:"default_branch"`
+}
+
+// Key implements Source.
+func (s *Gitea) Key() string { return s.key }
+
+// API implements Source.
+func (s *Gitea) API() string { return "rest" }
+
+// ListStarred implements Source. The cursor is the page number.
+func (s *Gitea) ListStarred(ctx context.Context, cursor string) (*Page, error) {
+	page := 1
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s cursor %q", s.typ, cursor)
+		}
+		page = n
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v1/user/starred?page=%d&limit=%d", s.baseURL, page, giteaPageSize)
+	resp, err := getJSON(ctx, s.client, reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var repos []giteaRepo
+	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+		return nil, fmt.Errorf("could not decode %s starred repositories: %w", s.typ, err)
+	}
+
+	out := &Page{}
+	out.TotalCount, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	for _, r := range repos {
+		out.Repos = append(out.Repos, Repo{
+			ID:            r.ID,
+			FullName:      r.FullName,
+			Description:   r.Description,
+			URL:           r.HTMLURL,
+			Language:      r.Language,
+			Stars:         r.StarsCount,
+			Topics:        r.Topics,
+			Archived:      r.Archived,
+			Fork:          r.Fork,
+			PushedAt:      r.UpdatedAt,
+			DefaultBranch: r.DefaultBranch,
+		})
+	}
+
+	seen := (page-1)*giteaPageSize + len(repos)
+	if len(repos) == giteaPageSize && (out.TotalCount == 0 || seen < out.TotalCount) {
+		out.NextCursor = strconv.Itoa(page + 1)
+	}
+	return out, nil
+}
+
+// FetchReadme implements Source by reading the raw README file from the default branch.
+func (s *Gitea) FetchReadme(ctx context.Context, repo Repo, etag string) (string, string, error) {
+	for _, name := range readmeNames {
+		rawURL := fmt.Sprintf("%s/api/v1/repos/%s/raw/%s", s.baseURL, repo.FullName, url.PathEscape(name))
+		if repo.DefaultBranch != "" {
+			rawURL += "?ref=" + url.QueryEscape(repo.DefaultBranch)
+		}
+		content, newEtag, found, err := getRaw(ctx, s.client, rawURL, etag)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get readme for %s: %w", repo.FullName, err)
+		}
+		if found {
+			return content, newEtag, nil
+		}
+	}
+	return "", "", nil
+}
+
+// Authenticate implements Source by asking for a personal access token.
+func (s *Gitea) Authenticate(ctx context.Context) (string, error) {
+	return promptToken(s.baseURL + "/user/settings/applications")
+}
+
+// getJSON performs a GET request and fails on any non-200 status.
+func getJSON(ctx context.Context, client *http.Client, reqURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned non-200 status: %s", reqURL, resp.Status)
+	}
+	return resp, nil
+}
+
+// getRaw fetches a raw file with an optional ETag. found is false on 404.
+// On 304 it returns an empty body and the original etag.
+func getRaw(ctx context.Context, client *http.Client, rawURL, etag string) (content, newEtag string, found bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return "", etag, true, nil
+	case http.StatusNotFound:
+		return "", "", false, nil
+	case http.StatusOK:
+	default:
+		return "", "", false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", false, err
+	}
+	return string(body), resp.Header.Get("ETag"), true, nil
+}
@@ -0,0 +1,206 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"star-sage/internal/config"
+)
+
+// giteaStars returns n starred repositories with IDs from first.
+func giteaStars(first, n int) []giteaRepo {
+	repos := make([]giteaRepo, n)
+	for i := range repos {
+		id := int64(first + i)
+		repos[i] = giteaRepo{ID: id, FullName: fmt.Sprintf("owner/repo%d", id), StarsCount: int(id), DefaultBranch: "main"}
+	}
+	return repos
+}
+
+func newTestGitea(t *testing.T, handler http.Handler) Source {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	src, err := New(config.SourceConfig{Type: TypeForgejo, BaseURL: srv.URL + "/", Token: "token"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGiteaListStarred(t *testing.T) {
+	tests := []struct {
+		name string
+		// total is sent as X-Total-Count unless it is empty.
+		total string
+		// pages are the sizes of the pages the server returns.
+		pages []int
+		want  []int
+	}{
+		{"one page", "3", []int{3}, []int{3}},
+		{"several pages", "103", []int{giteaPageSize, giteaPageSize, 3}, []int{giteaPageSize, giteaPageSize, 3}},
+		{"full last page", "100", []int{giteaPageSize, giteaPageSize}, []int{giteaPageSize, giteaPageSize}},
+		// Without a total, a full page may be followed by an empty one.
+		{"no total", "", []int{giteaPageSize, 0}, []int{giteaPageSize, 0}},
+		{"no stars", "0", []int{0}, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newTestGitea(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/user/starred" || r.Header.Get("Authorization") != "Bearer token" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				var page int
+				fmt.Sscan(r.URL.Query().Get("page"), &page)
+				if page < 1 || page > len(tt.pages) || r.URL.Query().Get("limit") != fmt.Sprint(giteaPageSize) {
+					http.Error(w, "unexpected page", http.StatusBadRequest)
+					return
+				}
+				if tt.total != "" {
+					w.Header().Set("X-Total-Count", tt.total)
+				}
+				json.NewEncoder(w).Encode(giteaStars((page-1)*giteaPageSize+1, tt.pages[page-1]))
+			}))
+
+			var got []int
+			cursor := ""
+			for {
+				page, err := src.ListStarred(context.Background(), cursor)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, len(page.Repos))
+				for i, r := range page.Repos {
+					if want := int64(len(got)-1)*giteaPageSize + int64(i) + 1; r.ID != want || r.DefaultBranch != "main" {
+						t.Fatalf("page %d has %+v at %d, want ID %d on main", len(got), r, i, want)
+					}
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got pages of %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGiteaListStarredErrors(t *testing.T) {
+	src := newTestGitea(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	if _, err := src.ListStarred(context.Background(), ""); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("got error %v, want the 401 status", err)
+	}
+	if _, err := src.ListStarred(context.Background(), "next"); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("got error %v for an invalid cursor", err)
+	}
+}
+
+// readmeServer serves files from files by path; a file with an ETag answers
+// matching If-None-Match requests with 304. It records the requested paths.
+type readmeServer struct {
+	files    map[string]string
+	etags    map[string]string
+	requests []string
+}
+
+func (s *readmeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.URL.RequestURI())
+	content, ok := s.files[r.URL.RequestURI()]
+	switch {
+	case content == "error":
+		http.Error(w, "boom", http.StatusInternalServerError)
+	case !ok:
+		http.NotFound(w, r)
+	case s.etags[r.URL.RequestURI()] != "" && r.Header.Get("If-None-Match") == s.etags[r.URL.RequestURI()]:
+		w.WriteHeader(http.StatusNotModified)
+	default:
+		w.Header().Set("ETag", s.etags[r.URL.RequestURI()])
+		w.Write([]byte(content))
+	}
+}
+
+func TestGiteaFetchReadme(t *testing.T) {
+	const base = "/api/v1/repos/owner/repo/raw/"
+	tests := []struct {
+		name       string
+		files      map[string]string
+		etags      map[string]string
+		branch     string
+		etag       string
+		wantReadme string
+		wantETag   string
+		wantErr    bool
+		// wantRequests is the number of file names tried.
+		wantRequests int
+	}{
+		{
+			name:         "README.md",
+			files:        map[string]string{base + "README.md?ref=main": "# repo"},
+			etags:        map[string]string{base + "README.md?ref=main": `"v1"`},
+			branch:       "main",
+			wantReadme:   "# repo",
+			wantETag:     `"v1"`,
+			wantRequests: 1,
+		},
+		{
+			name:         "later name",
+			files:        map[string]string{base + "README.rst?ref=dev%2Fnext": "repo\n===="},
+			branch:       "dev/next",
+			wantReadme:   "repo\n====",
+			wantRequests: 4,
+		},
+		{
+			name:         "no branch",
+			files:        map[string]string{base + "README.md": "# repo"},
+			wantReadme:   "# repo",
+			wantRequests: 1,
+		},
+		{
+			name:         "not modified",
+			files:        map[string]string{base + "README.md?ref=main": "# repo"},
+			etags:        map[string]string{base + "README.md?ref=main": `"v1"`},
+			branch:       "main",
+			etag:         `"v1"`,
+			wantETag:     `"v1"`,
+			wantRequests: 1,
+		},
+		{
+			name:         "no readme",
+			branch:       "main",
+			wantRequests: len(readmeNames),
+		},
+		{
+			name:         "server error",
+			files:        map[string]string{base + "README.md?ref=main": "error"},
+			branch:       "main",
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &readmeServer{files: tt.files, etags: tt.etags}
+			src := newTestGitea(t, srv)
+			readme, etag, err := src.FetchReadme(context.Background(), Repo{ID: 1, FullName: "owner/repo", DefaultBranch: tt.branch}, tt.etag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want one: %v", err, tt.wantErr)
+			}
+			if readme != tt.wantReadme || etag != tt.wantETag {
+				t.Errorf("got README %q with ETag %q, want %q with %q", readme, etag, tt.wantReadme, tt.wantETag)
+			}
+			if len(srv.requests) != tt.wantRequests {
+				t.Errorf("requested %v, want %d requests", srv.requests, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"star-sage/internal/config"
+	"star-sage/internal/gh"
+)
+
+// GitHub lists stars from github.com or a GitHub Enterprise Server instance.
+// It uses GraphQL, which returns READMEs inline, and falls back to REST.
+type GitHub struct {
+	host    gh.Host
+	key     string
+	proxy   string
+	client  *http.Client
+	useREST bool
+}
+
+func newGitHub(cfg config.SourceConfig, opts Options) (*GitHub, error) {
+	client, err := gh.NewClient(opts.Proxy, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	host := gh.NewHost(cfg.BaseURL, cfg.ClientID)
+	return &GitHub{
+		host:    host,
+		key:     hostKey(TypeGitHub, host.WebURL, "github.com"),
+		proxy:   opts.Proxy,
+		client:  client,
+		useREST: opts.UseREST,
+	}, nil
+}
+
+// Key implements Source.
+func (s *GitHub) Key() string { return s.key }
+
+// API implements Source.
+func (s *GitHub) API() string {
+	if s.useREST {
+		return "rest"
+	}
+	return "graphql"
+}
+
+// ListStarred implements Source. REST cursors are page URLs, so a cursor saved
+// by a REST run keeps using REST when resumed.
+func (s *GitHub) ListStarred(ctx context.Context, cursor string) (*Page, error) {
+	if strings.HasPrefix(cursor, "http") {
+		s.useREST = true
+	}
+
+	fetch := gh.FetchStarredPageGraphQL
+	if s.useREST {
+		fetch = gh.FetchStarredPageREST
+	}
+	page, err := fetch(ctx, s.client, s.host, cursor)
+	var fallback error
+	if err != nil && !s.useREST && cursor == "" && ctx.Err() == nil {
+		// GitHub Enterprise instances without GraphQL, or tokens lacking its
+		// scopes, can still be synced through REST.
+		fallback = fmt.Errorf("graphql sync failed: %w", err)
+		s.useREST = true
+		page, err = gh.FetchStarredPageREST(ctx, s.client, s.host, cursor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Page{NextCursor: page.NextCursor, TotalCount: page.TotalCount, Fallback: fallback}
+	for _, r := range page.Repos {
+		out.Repos = append(out.Repos, Repo{
+			ID:          r.ID,
+			FullName:    r.FullName,
+			Description: r.Description,
+			URL:         r.HTMLURL,
+			Language:    r.Language,
+			Stars:       r.StargazersCount,
+			Topics:      r.Topics,
+			License:     r.LicenseID(),
+			Archived:    r.Archived,
+			Fork:        r.Fork,
+			PushedAt:    r.PushedAt,
+			StarredAt:   r.StarredAt,
+			Readme:      r.Readme,
+			HasReadme:   r.HasReadme,
+		})
+	}
+	return out, nil
+}
+
+// FetchReadme implements Source.
+func (s *GitHub) FetchReadme(ctx context.Context, repo Repo, etag string) (string, string, error) {
+	return gh.GetReadme(ctx, s.client, s.host, repo.FullName, etag)
+}
+
+// Authenticate implements Source using the OAuth device flow.
+func (s *GitHub) Authenticate(ctx context.Context) (string, error) {
+	return gh.PerformDeviceFlow(ctx, s.host, s.proxy)
+}
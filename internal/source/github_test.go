@@ -0,0 +1,43 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"star-sage/internal/config"
+)
+
+func TestGitHubFallsBackToREST(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v3/user/starred", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"starred_at": "2024-01-01T00:00:00Z", "repo": {"id": 7, "full_name": "owner/repo"}}]`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	src, err := New(config.SourceConfig{Type: TypeGitHub, BaseURL: srv.URL, Token: "token"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.API() != "graphql" {
+		t.Fatalf("API() = %s before listing, want graphql", src.API())
+	}
+	page, err := src.ListStarred(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Fallback == nil {
+		t.Error("page does not report the fallback")
+	}
+	if src.API() != "rest" {
+		t.Errorf("API() = %s after the fallback, want rest", src.API())
+	}
+	if len(page.Repos) != 1 || page.Repos[0].FullName != "owner/repo" || page.Repos[0].StarredAt != "2024-01-01T00:00:00Z" {
+		t.Errorf("repos = %+v, want owner/repo", page.Repos)
+	}
+}
@@ -0,0 +1,128 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"star-sage/internal/config"
+	"star-sage/internal/gh"
+)
+
+const (
+	gitLabDotCom = "https://gitlab.com"
+
+	// gitLabPageSize is the maximum per_page accepted by the GitLab API.
+	gitLabPageSize = 100
+)
+
+// GitLab lists starred projects from gitlab.com or a self-managed instance.
+type GitLab struct {
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+func newGitLab(cfg config.SourceConfig, opts Options) (*GitLab, error) {
+	client, err := gh.NewClient(opts.Proxy, cfg.Token)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = gitLabDotCom
+	}
+	return &GitLab{
+		baseURL: baseURL,
+		key:     hostKey(TypeGitLab, baseURL, "gitlab.com"),
+		client:  client,
+	}, nil
+}
+
+// gitLabProject is a project as returned by the GitLab API.
+type gitLabProject struct {
+	ID                int64           `json:"id"`
+	PathWithNamespace string          `json:"path_with_namespace"`
+	Description       string          `json:"description"`
+	WebURL            string          `json:"web_url"`
+	StarCount         int             `json:"star_count"`
+	Archived          bool            `json:"archived"`
+	ForkedFromProject json.RawMessage `json:"forked_from_project"`
+	LastActivityAt    string          `json:"last_activity_at"`
+	Topics            []string        `json:"topics"`
+	DefaultBranch     string          `json:"default_branch"`
+}
+
+// Key implements Source.
+func (s *GitLab) Key() string { return s.key }
+
+// API implements Source.
+func (s *GitLab) API() string { return "rest" }
+
+// ListStarred implements Source. The cursor is the page number.
+func (s *GitLab) ListStarred(ctx context.Context, cursor string) (*Page, error) {
+	page := "1"
+	if cursor != "" {
+		page = cursor
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v4/projects?starred=true&per_page=%d&page=%s&order_by=id&sort=asc",
+		s.baseURL, gitLabPageSize, url.QueryEscape(page))
+	resp, err := getJSON(ctx, s.client, reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var projects []gitLabProject
+	if err := json.NewDecoder(resp.Body).Decode(&projects); err != nil {
+		return nil, fmt.Errorf("could not decode gitlab starred projects: %w", err)
+	}
+
+	out := &Page{NextCursor: resp.Header.Get("X-Next-Page")}
+	out.TotalCount, _ = strconv.Atoi(resp.Header.Get("X-Total"))
+	for _, p := range projects {
+		out.Repos = append(out.Repos, Repo{
+			ID:            p.ID,
+			FullName:      p.PathWithNamespace,
+			Description:   p.Description,
+			URL:           p.WebURL,
+			Stars:         p.StarCount,
+			Topics:        p.Topics,
+			Archived:      p.Archived,
+			Fork:          len(p.ForkedFromProject) > 0 && string(p.ForkedFromProject) != "null",
+			PushedAt:      p.LastActivityAt,
+			DefaultBranch: p.DefaultBranch,
+		})
+	}
+	return out, nil
+}
+
+// FetchReadme implements Source by reading the raw README file from the default branch.
+func (s *GitLab) FetchReadme(ctx context.Context, repo Repo, etag string) (string, string, error) {
+	if repo.DefaultBranch == "" {
+		// Empty projects have no default branch and therefore no README.
+		return "", "", nil
+	}
+	for _, name := range readmeNames {
+		rawURL := fmt.Sprintf("%s/api/v4/projects/%d/repository/files/%s/raw?ref=%s",
+			s.baseURL, repo.ID, url.PathEscape(name), url.QueryEscape(repo.DefaultBranch))
+		content, newEtag, found, err := getRaw(ctx, s.client, rawURL, etag)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get readme for %s: %w", repo.FullName, err)
+		}
+		if found {
+			return content, newEtag, nil
+		}
+	}
+	return "", "", nil
+}
+
+// Authenticate implements Source by asking for a personal access token.
+func (s *GitLab) Authenticate(ctx context.Context) (string, error) {
+	return promptToken(s.baseURL + "/-/user_settings/personal_access_tokens")
+}
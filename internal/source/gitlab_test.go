@@ -0,0 +1,98 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"star-sage/internal/config"
+)
+
+func newTestGitLab(t *testing.T, handler http.Handler) Source {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	src, err := New(config.SourceConfig{Type: TypeGitLab, BaseURL: srv.URL, Token: "token"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestGitLabListStarred(t *testing.T) {
+	pages := map[string]string{
+		"1": `[{"id": 1, "path_with_namespace": "group/app", "web_url": "https://gitlab.example.com/group/app", "star_count": 5,
+			"forked_from_project": null, "topics": ["cli"], "default_branch": "main", "last_activity_at": "2024-01-01T00:00:00Z"}]`,
+		"2": `[{"id": 2, "path_with_namespace": "group/sub/fork", "archived": true, "forked_from_project": {"id": 9}}]`,
+	}
+	src := newTestGitLab(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v4/projects" || q.Get("starred") != "true" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, ok := pages[q.Get("page")]
+		if !ok {
+			http.Error(w, "unexpected page", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Total", "2")
+		if q.Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		w.Write([]byte(body))
+	}))
+
+	first, err := src.ListStarred(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NextCursor != "2" || first.TotalCount != 2 || len(first.Repos) != 1 {
+		t.Fatalf("first page = %+v, want 1 of 2 projects and cursor 2", first)
+	}
+	app := first.Repos[0]
+	if app.FullName != "group/app" || app.Fork || app.Stars != 5 || app.DefaultBranch != "main" ||
+		len(app.Topics) != 1 || app.PushedAt != "2024-01-01T00:00:00Z" {
+		t.Errorf("first project = %+v", app)
+	}
+
+	second, err := src.ListStarred(context.Background(), first.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.NextCursor != "" || len(second.Repos) != 1 {
+		t.Fatalf("second page = %+v, want the last project", second)
+	}
+	if fork := second.Repos[0]; fork.FullName != "group/sub/fork" || !fork.Fork || !fork.Archived {
+		t.Errorf("second project = %+v, want an archived fork", fork)
+	}
+}
+
+func TestGitLabFetchReadme(t *testing.T) {
+	const base = "/api/v4/projects/7/repository/files/"
+	srv := &readmeServer{
+		files: map[string]string{base + "README/raw?ref=main": "plain readme"},
+		etags: map[string]string{base + "README/raw?ref=main": `"v1"`},
+	}
+	src := newTestGitLab(t, srv)
+	repo := Repo{ID: 7, FullName: "group/app", DefaultBranch: "main"}
+
+	readme, etag, err := src.FetchReadme(context.Background(), repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readme != "plain readme" || etag != `"v1"` {
+		t.Errorf("got README %q with ETag %q", readme, etag)
+	}
+	if len(srv.requests) != 3 {
+		t.Errorf("requested %v, want README.md, readme.md and README", srv.requests)
+	}
+
+	// Empty projects have no default branch and are not asked for files.
+	srv.requests = nil
+	readme, _, err = src.FetchReadme(context.Background(), Repo{ID: 8, FullName: "group/empty"}, "")
+	if err != nil || readme != "" || len(srv.requests) != 0 {
+		t.Errorf("empty project: got %q, %v after %d requests, want nothing", readme, err, len(srv.requests))
+	}
+}
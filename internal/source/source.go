@@ -0,0 +1,115 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"star-sage/internal/config"
+)
+
+// Supported source types.
+const (
+	TypeGitHub  = "github"
+	TypeGitea   = "gitea"
+	TypeForgejo = "forgejo"
+	TypeGitLab  = "gitlab"
+)
+
+// Repo is a starred repository as reported by a Source.
+type Repo struct {
+	// ID is the repository ID on the source.
+	ID          int64
+	FullName    string
+	Description string
+	URL         string
+	Language    string
+	Stars       int
+	Topics      []string
+	License     string
+	Archived    bool
+	Fork        bool
+	PushedAt    string
+	StarredAt   string
+	// DefaultBranch is used by sources that need a ref to read files.
+	DefaultBranch string
+	// Readme holds the README text when the listing already included it.
+	// HasReadme distinguishes "no README" from "not fetched".
+	Readme    string
+	HasReadme bool
+}
+
+// Page is one page of starred repositories. NextCursor is empty on the last page.
+type Page struct {
+	Repos      []Repo
+	NextCursor string
+	// TotalCount is the number of stars reported by the source, or 0 if unknown.
+	TotalCount int
+	// Fallback is set when the source switched to another API to list the
+	// page; it holds the error of the API it tried first.
+	Fallback error
+}
+
+// Source is a code hosting service the user stars repositories on.
+type Source interface {
+	// Key identifies the source in the database, e.g. "github" or "gitea:git.example.com".
+	Key() string
+	// API names the API used to list stars; it is recorded with each sync run.
+	API() string
+	// ListStarred returns the page of starred repositories at cursor ("" for the first page).
+	ListStarred(ctx context.Context, cursor string) (*Page, error)
+	// FetchReadme returns the README of repo and its new ETag. When etag still
+	// matches, it returns an empty README and the same etag. A missing README is not an error.
+	FetchReadme(ctx context.Context, repo Repo, etag string) (string, string, error)
+	// Authenticate interactively obtains an access token for the source.
+	Authenticate(ctx context.Context) (string, error)
+}
+
+// Options holds settings shared by all sources.
+type Options struct {
+	Proxy string
+	// UseREST makes GitHub sources list stars over REST instead of GraphQL.
+	UseREST bool
+}
+
+// New creates the Source described by cfg.
+func New(cfg config.SourceConfig, opts Options) (Source, error) {
+	switch cfg.Type {
+	case TypeGitHub, "":
+		return newGitHub(cfg, opts)
+	case TypeGitea, TypeForgejo:
+		return newGitea(cfg, opts)
+	case TypeGitLab:
+		return newGitLab(cfg, opts)
+	default:
+		return nil, fmt.Errorf("unsupported source type: %s", cfg.Type)
+	}
+}
+
+// hostKey builds a source key from its type and base URL. The public hosts of
+// GitHub and GitLab use the bare type so existing rows keep their key.
+func hostKey(typ, baseURL, publicHost string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || u.Host == publicHost {
+		return typ
+	}
+	return typ + ":" + u.Host
+}
+
+// promptToken asks the user to paste a personal access token created at tokenURL.
+func promptToken(tokenURL string) (string, error) {
+	fmt.Printf("\nCreate a personal access token with read access at:\n  %s\n", tokenURL)
+	fmt.Print("Paste the token here: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("could not read token: %w", err)
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no token entered")
+	}
+	return token, nil
+}
@@ -0,0 +1,22 @@
+package source
+
+import "testing"
+
+func TestHostKey(t *testing.T) {
+	tests := []struct {
+		typ, baseURL, publicHost string
+		want                     string
+	}{
+		{TypeGitHub, "https://github.com", "github.com", "github"},
+		{TypeGitHub, "https://ghe.example.com/", "github.com", "github:ghe.example.com"},
+		{TypeGitLab, "https://gitlab.com", "gitlab.com", "gitlab"},
+		{TypeGitea, "http://localhost:3000", "", "gitea:localhost:3000"},
+		{TypeForgejo, "https://codeberg.org", "", "forgejo:codeberg.org"},
+		{TypeGitea, "", "", "gitea"},
+	}
+	for _, tt := range tests {
+		if got := hostKey(tt.typ, tt.baseURL, tt.publicHost); got != tt.want {
+			t.Errorf("hostKey(%q, %q) = %q, want %q", tt.typ, tt.baseURL, got, tt.want)
+		}
+	}
+}
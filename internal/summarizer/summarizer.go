@@ -0,0 +1,119 @@
+// Package summarizer generates AI summaries for the READMEs of starred
+// repositories. It is shared by the summarize command and the server's
+// background jobs.
+package summarizer
+
+import (
+	"context"
+	"fmt"
+
+	"star-sage/internal/ai"
+	"star-sage/internal/db"
+)
+
+// DefaultLimit is the number of repositories summarized when no limit is given.
+const DefaultLimit = 5
+
+// Progress is reported through Options.OnProgress after each repository.
+type Progress struct {
+	// Done is the number of repositories handled so far, out of Total.
+	Done  int
+	Total int
+	Repo  db.Repository
+	// Summary is the summary saved for Repo. It is empty if Repo was skipped
+	// or Err is set.
+	Summary string
+	// Skipped is set when Repo has no README to summarize.
+	Skipped bool
+	Err     error
+}
+
+// Options configures a summarization run.
+type Options struct {
+	Provider ai.Provider
+	// Limit is the maximum number of repositories to summarize; 0 selects DefaultLimit.
+	Limit int
+	// OnStart, if set, is called before a repository is summarized.
+	OnStart func(repo db.Repository, done, total int)
+	// OnToken, if set and the provider supports streaming, receives the
+	// summary as it is generated.
+	OnToken func(token string)
+	// OnProgress, if set, is called after each repository.
+	OnProgress func(Progress)
+}
+
+// Result counts what a summarization run did.
+type Result struct {
+	Total      int
+	Summarized int
+	Skipped    int
+	Failed     int
+}
+
+// Run summarizes the repositories that have no summary yet. A failing
+// repository does not stop the run; it is reported through OnProgress and
+// counted in Result.Failed. Run returns an error if ctx is canceled, the
+// store fails or every summary failed.
+func Run(ctx context.Context, store db.SummaryStore, opts Options) (*Result, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	repos, err := store.GetReposForSummarization(limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get repositories to summarize: %w", err)
+	}
+
+	streamer, canStream := opts.Provider.(ai.StreamingProvider)
+	result := &Result{Total: len(repos)}
+	for i, repo := range repos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		p := Progress{Done: i + 1, Total: len(repos), Repo: repo}
+		if repo.ReadmeContent == "" {
+			result.Skipped++
+			p.Skipped = true
+			report(opts, p)
+			continue
+		}
+
+		if opts.OnStart != nil {
+			opts.OnStart(repo, i, len(repos))
+		}
+		prompt := ai.BuildSummaryPrompt(repo.ReadmeContent)
+		var summary string
+		if opts.OnToken != nil && canStream {
+			summary, err = streamer.GenerateStream(ctx, prompt, opts.OnToken)
+		} else {
+			summary, err = opts.Provider.Generate(ctx, prompt)
+		}
+		if err == nil {
+			err = store.UpdateRepoSummary(repo.ID, summary)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failed++
+			p.Err = err
+			report(opts, p)
+			continue
+		}
+		result.Summarized++
+		p.Summary = summary
+		report(opts, p)
+	}
+
+	if result.Failed > 0 && result.Summarized == 0 {
+		return result, fmt.Errorf("all %d summaries failed", result.Failed)
+	}
+	return result, nil
+}
+
+// report passes p to opts.OnProgress if set.
+func report(opts Options, p Progress) {
+	if opts.OnProgress != nil {
+		opts.OnProgress(p)
+	}
+}
@@ -0,0 +1,370 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"star-sage/internal/config"
+	"star-sage/internal/db"
+	"star-sage/internal/gh"
+	"star-sage/internal/source"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of README requests run in parallel when
+// Options.Concurrency is not set.
+const DefaultConcurrency = 8
+
+// Phase identifies what a running sync is currently doing.
+type Phase string
+
+const (
+	PhaseFetching    Phase = "fetching"
+	PhaseRepository  Phase = "repository"
+	PhaseWaiting     Phase = "rate_limited"
+	PhaseFallback    Phase = "api_fallback"
+	PhaseReconciling Phase = "reconciling"
+	PhaseDone        Phase = "done"
+)
+
+// Progress is reported through Options.OnProgress while a sync runs.
+type Progress struct {
+	Phase Phase
+	// Processed is the number of repositories handled so far.
+	Processed int
+	// Total is the number of starred repositories, or 0 while unknown.
+	Total int
+	// Repo is the repository that was just processed (PhaseRepository).
+	Repo string
+	// Err is set when processing Repo failed, or is the reason the source
+	// switched APIs (PhaseFallback).
+	Err error
+	// Outcome tells whether Repo was added, updated or left unchanged. It is
+	// only meaningful when Err is nil.
+	Outcome db.UpsertOutcome
+	// Wait is how long the sync is paused for the rate limit (PhaseWaiting).
+	Wait time.Duration
+	// API is the API the source switched to (PhaseFallback).
+	API string
+}
+
+// Options configures a sync run.
+type Options struct {
+	// Source is where the stars are listed from.
+	Source source.Source
+	// Limit stops the sync after this many repositories (0 for no limit).
+	Limit int
+	// Concurrency is the number of README requests run in parallel.
+	Concurrency int
+	// Prune deletes unstarred repositories instead of only marking them.
+	Prune bool
+	// Resume continues the last unfinished run from its checkpoint instead of
+	// starting again from the first page.
+	Resume bool
+	// OnProgress, if set, receives progress updates. Rate limit waits may be
+	// reported from README worker goroutines, so it must be safe for concurrent use.
+	OnProgress func(Progress)
+}
+
+// Result summarizes a finished sync run.
+type Result struct {
+	// Run is the persisted sync run, including its added/updated/unchanged counts.
+	Run       *db.SyncRun
+	Resumed   bool
+	Processed int
+	Failed    int
+	Unstarred int64
+	Pruned    int64
+	// Snapshot is the path of the snapshot taken before pruning, if any.
+	Snapshot string
+	// Reconciled is false when unstarred detection was skipped because of Limit.
+	Reconciled bool
+}
+
+// ErrNoToken is returned by OpenSource when the source has no stored token.
+var ErrNoToken = errors.New("authentication token not found")
+
+// OpenSource creates the configured source called name (the default source if
+// empty) with its stored token.
+func OpenSource(name string, opts source.Options) (source.Source, error) {
+	srcCfg, err := config.GetSource(name)
+	if err != nil {
+		return nil, err
+	}
+	if srcCfg.Token == "" {
+		return nil, fmt.Errorf("%w for source %s, please run 'starsage login --source %s' first", ErrNoToken, srcCfg.Name, srcCfg.Name)
+	}
+	src, err := source.New(srcCfg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not create source %s: %w", srcCfg.Name, err)
+	}
+	return src, nil
+}
+
+// repoResult is produced by a README worker for one repository.
+type repoResult struct {
+	repo db.Repository
+	err  error
+}
+
+// Run fetches the user's stars from opts.Source and saves them in store.
+// READMEs that are not part of the star listing are fetched by a bounded worker pool.
+//
+// Progress is checkpointed in the sync_runs table after every page. If ctx is
+// cancelled or a request fails, the run is left resumable with Options.Resume.
+func Run(ctx context.Context, store db.SyncStore, opts Options) (*Result, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	src := opts.Source
+	result := &Result{}
+	if opts.Resume {
+		run, err := store.GetResumableSyncRun(src.Key())
+		if err != nil {
+			return nil, err
+		}
+		if run != nil {
+			result.Run = run
+			result.Resumed = true
+		}
+	}
+	if result.Run == nil {
+		run, err := store.CreateSyncRun(src.Key(), src.API())
+		if err != nil {
+			return nil, err
+		}
+		result.Run = run
+	}
+
+	err := runSync(ctx, store, opts, result)
+	run := result.Run
+	switch {
+	case err == nil:
+		err = store.FinishSyncRun(run, db.SyncCompleted, nil)
+	case ctx.Err() != nil:
+		if ferr := store.FinishSyncRun(run, db.SyncInterrupted, err); ferr != nil {
+			err = fmt.Errorf("%w (could not record interrupted sync run: %v)", err, ferr)
+		}
+	default:
+		if ferr := store.FinishSyncRun(run, db.SyncFailed, err); ferr != nil {
+			err = fmt.Errorf("%w (could not record failed sync run: %v)", err, ferr)
+		}
+	}
+	return result, err
+}
+
+// runSync does the work of Run for the sync run in result.Run.
+func runSync(ctx context.Context, store db.SyncStore, opts Options, result *Result) error {
+	report := func(p Progress) {
+		if opts.OnProgress != nil {
+			opts.OnProgress(p)
+		}
+	}
+	src := opts.Source
+	syncRun := result.Run
+	ctx = gh.WithWaitHook(ctx, func(d time.Duration) {
+		report(Progress{Phase: PhaseWaiting, Wait: d})
+	})
+
+	// Pre-fetch existing etags to avoid querying the DB in a loop
+	existingRepos, err := store.GetAllReposWithETags(src.Key())
+	if err != nil {
+		return fmt.Errorf("could not pre-fetch existing repo data: %w", err)
+	}
+	existing := make(map[int64]db.Repository, len(existingRepos))
+	for _, r := range existingRepos {
+		existing[r.SourceID] = r
+	}
+
+	// A resumed run that has fetched every page only needs to be reconciled.
+	complete := result.Resumed && syncRun.Fetched
+	total := 0
+	for !complete {
+		report(Progress{Phase: PhaseFetching, Processed: syncRun.ReposDone, Total: total})
+		page, err := src.ListStarred(ctx, syncRun.Cursor)
+		if err != nil {
+			return fmt.Errorf("could not fetch starred repositories: %w", err)
+		}
+		// The source may have switched APIs, e.g. GitHub falling back to REST.
+		syncRun.API = src.API()
+		if page.Fallback != nil {
+			report(Progress{Phase: PhaseFallback, Processed: syncRun.ReposDone, Total: total, Err: page.Fallback, API: syncRun.API})
+		}
+		if page.TotalCount > 0 {
+			total = page.TotalCount
+		}
+
+		repos := page.Repos
+		trimmed := false
+		if opts.Limit > 0 && result.Processed+len(repos) > opts.Limit {
+			repos = repos[:opts.Limit-result.Processed]
+			trimmed = true
+		}
+
+		// The counts of a page are only added to the run when it is checkpointed,
+		// so that resuming an interrupted run does not count a partial page twice.
+		var counts db.SyncRun
+		seenIDs := make([]int64, 0, len(repos))
+		for res := range processPage(ctx, src, repos, existing, opts.Concurrency) {
+			result.Processed++
+			counts.ReposDone++
+			// Metadata is saved even when the README could not be fetched.
+			outcome, err := store.UpsertRepository(res.repo)
+			if err != nil {
+				res.err = fmt.Errorf("could not save repository: %w", err)
+			}
+			switch {
+			case res.err != nil:
+				result.Failed++
+				counts.Failed++
+			case outcome == db.RepoAdded:
+				counts.Added++
+			case outcome == db.RepoUpdated:
+				counts.Updated++
+			default:
+				counts.Unchanged++
+			}
+			seenIDs = append(seenIDs, res.repo.SourceID)
+			report(Progress{Phase: PhaseRepository, Processed: syncRun.ReposDone + counts.ReposDone, Total: total, Repo: res.repo.FullName,
+				Err: res.err, Outcome: outcome})
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if trimmed || (opts.Limit > 0 && result.Processed >= opts.Limit && page.NextCursor != "") {
+			// Stopped by --limit: the remote list was only partially seen.
+			addCounts(syncRun, counts)
+			return nil
+		}
+
+		next := *syncRun
+		addCounts(&next, counts)
+		next.Cursor = page.NextCursor
+		next.Fetched = page.NextCursor == ""
+		if err := store.CheckpointSyncRun(&next, seenIDs); err != nil {
+			return err
+		}
+		*syncRun = next
+		complete = syncRun.Fetched
+	}
+
+	report(Progress{Phase: PhaseReconciling, Processed: syncRun.ReposDone, Total: total})
+	starredIDs, err := store.GetSyncRunRepoIDs(syncRun.ID)
+	if err != nil {
+		return err
+	}
+	result.Reconciled = true
+	result.Unstarred, err = store.MarkUnstarred(src.Key(), starredIDs)
+	if err != nil {
+		return fmt.Errorf("could not reconcile unstarred repositories: %w", err)
+	}
+	syncRun.Removed = int(result.Unstarred)
+	if opts.Prune {
+		n, err := store.CountUnstarred(src.Key())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if result.Snapshot, err = store.TakeSnapshot(db.SnapshotPrune); err != nil {
+				return fmt.Errorf("could not snapshot database before pruning: %w", err)
+			}
+		}
+		result.Pruned, err = store.PruneUnstarred(src.Key())
+		if err != nil {
+			return fmt.Errorf("could not prune unstarred repositories: %w", err)
+		}
+		syncRun.Removed = int(result.Pruned)
+	}
+
+	report(Progress{Phase: PhaseDone, Processed: syncRun.ReposDone, Total: total})
+	return nil
+}
+
+// addCounts adds the repository counts of a page to run.
+func addCounts(run *db.SyncRun, page db.SyncRun) {
+	run.ReposDone += page.ReposDone
+	run.Added += page.Added
+	run.Updated += page.Updated
+	run.Unchanged += page.Unchanged
+	run.Failed += page.Failed
+}
+
+// processPage converts a page of stars into database rows, fetching missing
+// READMEs with up to concurrency parallel requests. The returned channel is
+// closed once every repository has been handled.
+func processPage(ctx context.Context, src source.Source, repos []source.Repo, existing map[int64]db.Repository, concurrency int) <-chan repoResult {
+	work := make(chan source.Repo)
+	results := make(chan repoResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range work {
+				results <- buildRepository(ctx, src, repo, existing[repo.ID])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(work)
+		for _, repo := range repos {
+			select {
+			case work <- repo:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// buildRepository maps a starred repository to its database row, fetching the
+// README separately when it was not included in the star listing.
+func buildRepository(ctx context.Context, src source.Source, repo source.Repo, old db.Repository) repoResult {
+	dbRepo := db.Repository{
+		Source:          src.Key(),
+		SourceID:        repo.ID,
+		FullName:        repo.FullName,
+		Description:     repo.Description,
+		URL:             repo.URL,
+		Language:        repo.Language,
+		StargazersCount: repo.Stars,
+		StarredAt:       repo.StarredAt,
+		PushedAt:        repo.PushedAt,
+		Topics:          repo.Topics,
+		License:         repo.License,
+		IsArchived:      repo.Archived,
+		IsFork:          repo.Fork,
+		ETag:            old.ETag,
+		ReadmeContent:   old.ReadmeContent,
+	}
+
+	if repo.HasReadme {
+		// The listing (e.g. GitHub GraphQL) already returned the README blob.
+		dbRepo.ReadmeContent = repo.Readme
+		return repoResult{repo: dbRepo}
+	}
+
+	readmeContent, newEtag, err := src.FetchReadme(ctx, repo, old.ETag)
+	if err != nil {
+		// Keep the previously stored README rather than wiping it.
+		return repoResult{repo: dbRepo, err: fmt.Errorf("could not get README: %w", err)}
+	}
+
+	// If README was not modified, keep the old content.
+	if !(newEtag == old.ETag && old.ETag != "") {
+		dbRepo.ReadmeContent = readmeContent
+	}
+	dbRepo.ETag = newEtag
+	return repoResult{repo: dbRepo}
+}
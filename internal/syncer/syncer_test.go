@@ -0,0 +1,397 @@
+package syncer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"star-sage/internal/db"
+	"star-sage/internal/db/dbtest"
+	"star-sage/internal/source"
+	"testing"
+)
+
+// fakeSource serves a fixed list of stars in pages.
+type fakeSource struct {
+	pages [][]source.Repo
+}
+
+func newFakeSource(pageSizes ...int) *fakeSource {
+	src := &fakeSource{}
+	id := int64(1)
+	for _, n := range pageSizes {
+		var page []source.Repo
+		for i := 0; i < n; i++ {
+			page = append(page, source.Repo{
+				ID:        id,
+				FullName:  fmt.Sprintf("owner/repo%d", id),
+				Readme:    "readme",
+				HasReadme: true,
+			})
+			id++
+		}
+		src.pages = append(src.pages, page)
+	}
+	return src
+}
+
+func (s *fakeSource) Key() string { return db.DefaultSource }
+func (s *fakeSource) API() string { return "fake" }
+
+func (s *fakeSource) ListStarred(ctx context.Context, cursor string) (*source.Page, error) {
+	i := 0
+	if cursor != "" {
+		fmt.Sscanf(cursor, "page%d", &i)
+	}
+	page := &source.Page{Repos: s.pages[i]}
+	if i+1 < len(s.pages) {
+		page.NextCursor = fmt.Sprintf("page%d", i+1)
+	}
+	return page, nil
+}
+
+func (s *fakeSource) FetchReadme(ctx context.Context, repo source.Repo, etag string) (string, string, error) {
+	return "", etag, nil
+}
+
+func (s *fakeSource) Authenticate(ctx context.Context) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func countUnstarred(t *testing.T, database *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM repositories WHERE unstarred_at IS NOT NULL;").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestResumeAfterInterruptedFirstPage is a regression test for a resumed run
+// that was interrupted on its first page being taken as fully fetched, which
+// marked (or pruned) the whole library as unstarred.
+func TestResumeAfterInterruptedFirstPage(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	src := newFakeSource(3, 2)
+
+	if _, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1}); err != nil {
+		t.Fatalf("initial sync: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := Run(ctx, db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, OnProgress: func(p Progress) {
+		if p.Phase == PhaseRepository {
+			cancel()
+		}
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted sync: got error %v, want context.Canceled", err)
+	}
+	run, err := db.GetResumableSyncRun(database, src.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run == nil || run.Status != db.SyncInterrupted {
+		t.Fatalf("interrupted sync left run %+v, want an interrupted run", run)
+	}
+	if run.ReposDone != 0 || run.HasCheckpoint() {
+		t.Errorf("interrupted run saved progress of an unfinished page: %+v", run)
+	}
+
+	result, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, Resume: true, Prune: true})
+	if err != nil {
+		t.Fatalf("resumed sync: %v", err)
+	}
+	if !result.Resumed {
+		t.Error("sync was not resumed")
+	}
+	if result.Unstarred != 0 || result.Pruned != 0 {
+		t.Errorf("resumed sync unstarred %d and pruned %d repositories, want none", result.Unstarred, result.Pruned)
+	}
+	if result.Run.ReposDone != 5 || result.Run.Unchanged != 5 {
+		t.Errorf("resumed run counted %d repositories (%d unchanged), want 5", result.Run.ReposDone, result.Run.Unchanged)
+	}
+	if n := countUnstarred(t, database); n != 0 {
+		t.Errorf("%d repositories marked unstarred, want 0", n)
+	}
+}
+
+func TestResumeAfterCheckpoint(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	src := newFakeSource(2, 2, 2)
+
+	// Interrupt the second page: the first one stays checkpointed.
+	ctx, cancel := context.WithCancel(context.Background())
+	seen := 0
+	_, err := Run(ctx, db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, OnProgress: func(p Progress) {
+		if p.Phase == PhaseRepository {
+			if seen++; seen == 3 {
+				cancel()
+			}
+		}
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted sync: got error %v, want context.Canceled", err)
+	}
+
+	run, err := db.GetResumableSyncRun(database, src.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run == nil || !run.HasCheckpoint() {
+		t.Fatalf("interrupted sync left run %+v, want a run with a checkpoint", run)
+	}
+
+	result, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, Resume: true})
+	if err != nil {
+		t.Fatalf("resumed sync: %v", err)
+	}
+	if result.Processed != 4 {
+		t.Errorf("resumed sync processed %d repositories, want the 4 after the checkpoint", result.Processed)
+	}
+	// Repositories stored before the interruption come back unchanged.
+	run = result.Run
+	if run.ReposDone != 6 || run.Added+run.Updated+run.Unchanged != 6 {
+		t.Errorf("resumed run counted %d repositories (%d added, %d updated, %d unchanged), want 6",
+			run.ReposDone, run.Added, run.Updated, run.Unchanged)
+	}
+	if !result.Reconciled || result.Unstarred != 0 {
+		t.Errorf("got reconciled %v with %d unstarred, want reconciled with none", result.Reconciled, result.Unstarred)
+	}
+}
+
+// TestRunRefusesEmptyReconcile checks that a sync seeing no stars at all fails
+// instead of marking the whole library as unstarred.
+func TestRunRefusesEmptyReconcile(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	if _, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: newFakeSource(2), Concurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: newFakeSource(0), Concurrency: 1, Prune: true})
+	if !errors.Is(err, db.ErrEmptyReconcile) {
+		t.Errorf("got error %v, want ErrEmptyReconcile", err)
+	}
+	if n := countUnstarred(t, database); n != 0 {
+		t.Errorf("%d repositories marked unstarred, want 0", n)
+	}
+	if id, _, _ := repoState(t, database, "owner/repo1"); id == 0 {
+		t.Error("repository was pruned")
+	}
+}
+
+// repoState returns the ID of a repository, whether it is marked unstarred
+// and its number of list memberships. The ID is 0 if it does not exist.
+func repoState(t *testing.T, database *sql.DB, fullName string) (id int64, unstarred bool, lists int) {
+	t.Helper()
+	var unstarredAt sql.NullString
+	err := database.QueryRow("SELECT id, unstarred_at FROM repositories WHERE full_name = ?;", fullName).Scan(&id, &unstarredAt)
+	if err == sql.ErrNoRows {
+		return 0, false, 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.QueryRow("SELECT COUNT(*) FROM list_repositories WHERE repository_id = ?;", id).Scan(&lists); err != nil {
+		t.Fatal(err)
+	}
+	return id, unstarredAt.Valid, lists
+}
+
+func TestRunReconcilesUnstarred(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	src := newFakeSource(3)
+	if _, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	id, _, _ := repoState(t, database, "owner/repo3")
+	listID, err := db.CreateList(database, "Tools", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PinRepoToList(database, listID, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateRepoSummary(database, id, "A summary"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unstarring keeps the repository with its summary and lists.
+	starred := src.pages[0]
+	src.pages[0] = starred[:2]
+	result, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Reconciled || result.Unstarred != 1 || result.Pruned != 0 {
+		t.Errorf("got reconciled %v, %d unstarred and %d pruned, want 1 unstarred", result.Reconciled, result.Unstarred, result.Pruned)
+	}
+	if _, unstarred, lists := repoState(t, database, "owner/repo3"); !unstarred || lists != 1 {
+		t.Errorf("got unstarred %v with %d lists, want unstarred in 1 list", unstarred, lists)
+	}
+	if repo, err := db.GetRepositoryByID(database, id); err != nil || repo.Summary != "A summary" {
+		t.Errorf("got %+v, %v, want the summary kept", repo, err)
+	}
+
+	// Starring it again clears the mark.
+	src.pages[0] = starred
+	if _, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, unstarred, _ := repoState(t, database, "owner/repo3"); unstarred {
+		t.Error("starred repository is still marked unstarred")
+	}
+
+	if matches, err := db.SearchRepositories(database, `"repo3"`, 10); err != nil || len(matches) != 1 {
+		t.Fatalf("search for the starred repository: got %+v, %v", matches, err)
+	}
+
+	// Pruning deletes it with its list memberships and search entry.
+	src.pages[0] = starred[:2]
+	result, err = Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unstarred != 1 || result.Pruned != 1 || result.Snapshot == "" {
+		t.Errorf("got %d unstarred and %d pruned with snapshot %q, want 1 each and a snapshot", result.Unstarred, result.Pruned, result.Snapshot)
+	}
+	if got, _, _ := repoState(t, database, "owner/repo3"); got != 0 {
+		t.Error("pruned repository still exists")
+	}
+	var memberships int
+	if err := database.QueryRow("SELECT COUNT(*) FROM list_repositories WHERE repository_id = ?;", id).Scan(&memberships); err != nil {
+		t.Fatal(err)
+	}
+	if memberships != 0 {
+		t.Errorf("pruned repository is still in %d lists", memberships)
+	}
+	matches, err := db.SearchRepositories(database, `"repo3"`, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("search still finds the pruned repository: %+v", matches)
+	}
+}
+
+// readmeSource serves READMEs over a separate request, like the REST API.
+type readmeSource struct {
+	fakeSource
+	readme  string
+	etag    string
+	err     error
+	fetches int
+}
+
+func (s *readmeSource) FetchReadme(ctx context.Context, repo source.Repo, etag string) (string, string, error) {
+	s.fetches++
+	if s.err != nil {
+		return "", "", s.err
+	}
+	if etag == s.etag {
+		return "", etag, nil
+	}
+	return s.readme, s.etag, nil
+}
+
+func TestBuildRepositoryReadme(t *testing.T) {
+	tests := []struct {
+		name string
+		repo source.Repo
+		old  db.Repository
+		src  readmeSource
+		// wantFetch is whether the README is requested separately.
+		wantFetch  bool
+		wantReadme string
+		wantETag   string
+		wantErr    bool
+	}{
+		{
+			name:       "listed",
+			repo:       source.Repo{Readme: "listed", HasReadme: true},
+			old:        db.Repository{ReadmeContent: "old", ETag: "v1"},
+			wantReadme: "listed",
+			wantETag:   "v1",
+		},
+		{
+			name:       "listed as empty",
+			repo:       source.Repo{HasReadme: true},
+			old:        db.Repository{ReadmeContent: "old"},
+			wantReadme: "",
+		},
+		{
+			// GraphQL found no blob under the names it tries, e.g. for docs/README.md.
+			name:       "not listed",
+			repo:       source.Repo{},
+			old:        db.Repository{ReadmeContent: "old", ETag: "v1"},
+			src:        readmeSource{readme: "fetched", etag: "v2"},
+			wantFetch:  true,
+			wantReadme: "fetched",
+			wantETag:   "v2",
+		},
+		{
+			name:       "not modified",
+			repo:       source.Repo{},
+			old:        db.Repository{ReadmeContent: "old", ETag: "v1"},
+			src:        readmeSource{readme: "fetched", etag: "v1"},
+			wantFetch:  true,
+			wantReadme: "old",
+			wantETag:   "v1",
+		},
+		{
+			name:       "fetch failed",
+			repo:       source.Repo{},
+			old:        db.Repository{ReadmeContent: "old", ETag: "v1"},
+			src:        readmeSource{err: errors.New("boom")},
+			wantFetch:  true,
+			wantReadme: "old",
+			wantETag:   "v1",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.src
+			res := buildRepository(context.Background(), &src, tt.repo, tt.old)
+			if (res.err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want one: %v", res.err, tt.wantErr)
+			}
+			if (src.fetches > 0) != tt.wantFetch {
+				t.Errorf("README fetched %d times, want a fetch: %v", src.fetches, tt.wantFetch)
+			}
+			if res.repo.ReadmeContent != tt.wantReadme || res.repo.ETag != tt.wantETag {
+				t.Errorf("got README %q with ETag %q, want %q with %q", res.repo.ReadmeContent, res.repo.ETag, tt.wantReadme, tt.wantETag)
+			}
+		})
+	}
+}
+
+// fallbackSource reports switching APIs on its first page.
+type fallbackSource struct {
+	fakeSource
+}
+
+func (s *fallbackSource) ListStarred(ctx context.Context, cursor string) (*source.Page, error) {
+	page, err := s.fakeSource.ListStarred(ctx, cursor)
+	if err == nil && cursor == "" {
+		page.Fallback = errors.New("graphql sync failed")
+	}
+	return page, err
+}
+
+func TestRunReportsFallback(t *testing.T) {
+	database := dbtest.OpenDB(t)
+	src := &fallbackSource{*newFakeSource(1, 1)}
+	var fallbacks []Progress
+	_, err := Run(context.Background(), db.NewSQLiteStore(database), Options{Source: src, Concurrency: 1, OnProgress: func(p Progress) {
+		if p.Phase == PhaseFallback {
+			fallbacks = append(fallbacks, p)
+		}
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fallbacks) != 1 || fallbacks[0].Err == nil || fallbacks[0].API != src.API() {
+		t.Errorf("fallback progress = %+v, want one report with the reason and API", fallbacks)
+	}
+}